@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/compat"
+	"github.com/spf13/cobra"
+)
+
+// ── compat ──────────────────────────────────────────────────────────────
+
+var compatCmd = &cobra.Command{
+	Use:   "compat",
+	Short: "Manage the Claude CLI versions tasks can run with",
+	Long: "Tasks run with the claude on PATH unless they set claude_version, which\n" +
+		"selects a binary pinned here: an exact version, or a prefix like 2.0 for\n" +
+		"the newest pinned 2.0.x. Pin an older CLI to keep critical tasks on known\n" +
+		"behavior when a new release changes its output. Install each version\n" +
+		"yourself (e.g. npm install --prefix ~/claude-2.0.14 @anthropic-ai/claude-code@2.0.14)\n" +
+		"and pin its binary; nothing is downloaded for you.",
+}
+
+var compatListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the claude binaries on PATH, the pinned ones and the agents",
+	Args:  cobra.NoArgs,
+	RunE:  runCompatList,
+}
+
+func runCompatList(cmd *cobra.Command, args []string) error {
+	found := compat.Discover()
+	fmt.Println("On PATH (the first is the default):")
+	if len(found) == 0 {
+		fmt.Println("  none")
+	}
+	for _, path := range found {
+		version, err := compat.ProbeVersion(path)
+		if err != nil {
+			version = "?"
+		}
+		fmt.Printf("  %-10s %s\n", version, path)
+	}
+
+	pins, err := compat.LoadPins(paths.ClaudeVersionsFile())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Pinned (select with claude_version):")
+	if len(pins) == 0 {
+		fmt.Println("  none")
+	}
+	for _, b := range pins {
+		fmt.Printf("  %-10s %s\n", b.Version, b.Path)
+	}
+
+	defined, err := compat.LoadAgents(paths.AgentsFile())
+	if err != nil {
+		return err
+	}
+	fmt.Println("Agents (select with agent):")
+	for _, name := range compat.AgentNames(defined) {
+		command := "claude"
+		if a, ok := compat.LookupAgent(defined, name); ok {
+			command = a.Command
+		}
+		fmt.Printf("  %-10s %s\n", name, command)
+	}
+	return nil
+}
+
+var compatPinCmd = &cobra.Command{
+	Use:   "pin <path>",
+	Short: "Pin a claude binary so tasks can select it by version",
+	Long: "pin runs the binary with --version and records it under that version,\n" +
+		"replacing an earlier pin of the same version. path may also be a command\n" +
+		"name found on PATH.",
+	Args: cobra.ExactArgs(1),
+	RunE: runCompatPin,
+}
+
+func runCompatPin(cmd *cobra.Command, args []string) error {
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return fmt.Errorf("find %s: %w", args[0], err)
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return err
+	}
+	version, err := compat.ProbeVersion(path)
+	if err != nil {
+		return err
+	}
+
+	file := paths.ClaudeVersionsFile()
+	pins, err := compat.LoadPins(file)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range pins {
+		if pins[i].Version == version {
+			pins[i].Path = path
+			replaced = true
+		}
+	}
+	if !replaced {
+		pins = append(pins, compat.Binary{Version: version, Path: path})
+	}
+	if err := compat.SavePins(file, pins); err != nil {
+		return fmt.Errorf("save pins: %w", err)
+	}
+
+	fmt.Printf("Pinned claude %s at %s\n", version, path)
+	if entry, _ := compat.LookupCompat(version); entry == nil {
+		fmt.Printf("WARNING: claude %s is not in the compatibility table; tasks pinned to it assume current CLI behavior\n", version)
+	}
+	return nil
+}
+
+var compatUnpinCmd = &cobra.Command{
+	Use:   "unpin <version>",
+	Short: "Remove a pinned claude version",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCompatUnpin,
+}
+
+func runCompatUnpin(cmd *cobra.Command, args []string) error {
+	file := paths.ClaudeVersionsFile()
+	pins, err := compat.LoadPins(file)
+	if err != nil {
+		return err
+	}
+	kept := pins[:0]
+	for _, b := range pins {
+		if b.Version != args[0] {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) == len(pins) {
+		return fmt.Errorf("claude %s is not pinned", args[0])
+	}
+	if err := compat.SavePins(file, kept); err != nil {
+		return fmt.Errorf("save pins: %w", err)
+	}
+	fmt.Printf("Unpinned claude %s\n", args[0])
+	return nil
+}
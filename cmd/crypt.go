@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/crypt"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+// loadEncryptionKey sets queue.EncryptionKey from the encryption_key_file
+// config key. keygen and the config commands run without it, so a missing
+// or unreadable key can still be created or unset.
+func loadEncryptionKey(cmd *cobra.Command) error {
+	if cmd == keygenCmd || cmd == decryptCmd || (cmd.Parent() != nil && cmd.Parent() == configCmd) {
+		return nil
+	}
+	path := paths.EncryptionKeyFile()
+	if path == "" {
+		return nil
+	}
+	key, err := crypt.LoadKey(path)
+	if err != nil {
+		return fmt.Errorf("encryption_key_file: %w", err)
+	}
+	queue.EncryptionKey = key
+	return nil
+}
+
+// ── keygen ──────────────────────────────────────────────────────────────
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen [path]",
+	Short: "Create a key for encrypting prompts, state and logs at rest",
+	Long: "keygen writes a new random key (mode 0600) to path, or to encryption.key\n" +
+		"in the autopilot home directory. It never overwrites an existing file.\n" +
+		"Point encryption_key_file at the key to turn encryption on; keep a copy\n" +
+		"somewhere safe, as encrypted prompts and logs cannot be read without it.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runKeygen,
+}
+
+func runKeygen(cmd *cobra.Command, args []string) error {
+	path := filepath.Join(paths.Home, "encryption.key")
+	if len(args) == 1 {
+		path = args[0]
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0755); err != nil {
+		return err
+	}
+	if err := crypt.GenerateKey(abs); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote key to %s\n", abs)
+	fmt.Printf("Enable it with: claude-autopilot config set encryption_key_file %s\n", abs)
+	return nil
+}
+
+// ── decrypt ─────────────────────────────────────────────────────────────
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <file>",
+	Short: "Print an encrypted task log or file with its lines decrypted",
+	Long: "decrypt prints file to stdout, decrypting every line sealed with the key\n" +
+		"named by encryption_key_file (or --key) and passing other lines through.",
+	Args: cobra.ExactArgs(1),
+	RunE: runDecrypt,
+}
+
+var decryptKey string
+
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	path := decryptKey
+	if path == "" {
+		path = paths.EncryptionKeyFile()
+	}
+	if path == "" {
+		return fmt.Errorf("no key: set encryption_key_file or pass --key")
+	}
+	key, err := crypt.LoadKey(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return key.OpenLines(os.Stdout, f)
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/compat"
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+// ── doctor ──────────────────────────────────────────────────────────────
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment and runner health, with recovery advice",
+	Args:  cobra.NoArgs,
+	RunE:  runDoctor,
+}
+
+// doctorReport collects check results and whether any check failed.
+type doctorReport struct {
+	failed bool
+}
+
+func (d *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Printf("[ok]   "+format+"\n", args...)
+}
+
+func (d *doctorReport) warn(format string, args ...interface{}) {
+	fmt.Printf("[warn] "+format+"\n", args...)
+}
+
+func (d *doctorReport) fail(format string, args ...interface{}) {
+	d.failed = true
+	fmt.Printf("[fail] "+format+"\n", args...)
+}
+
+// advise prints indented recovery steps under the preceding check.
+func (d *doctorReport) advise(lines ...string) {
+	for _, l := range lines {
+		fmt.Printf("       %s\n", l)
+	}
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	d := &doctorReport{}
+
+	// Claude CLI.
+	if version, err := compat.DetectVersion(); err != nil {
+		d.fail("claude CLI: %v", err)
+		d.advise("Install Claude Code and make sure 'claude' is on PATH.")
+	} else if entry, _ := compat.LookupCompat(version); entry != nil {
+		d.ok("claude CLI %s", version)
+	} else if adapter, err := compat.AdapterFor("claude", version); err != nil {
+		d.warn("claude CLI %s is not in the compat table: %v", version, err)
+	} else {
+		d.ok("claude CLI %s (not in the compat table; from --help: stream-json %v, resume %v)", version, adapter.SupportsStreamJSON(), adapter.SupportsResume())
+	}
+	pins, err := compat.LoadPins(paths.ClaudeVersionsFile())
+	if err != nil {
+		d.fail("pinned claude versions: %v", err)
+	}
+	for _, b := range pins {
+		if version, err := compat.ProbeVersion(b.Path); err != nil {
+			d.fail("pinned claude %s: %v", b.Version, err)
+			d.advise("Reinstall it, or run 'compat unpin " + b.Version + "'.")
+		} else if version != b.Version {
+			d.warn("pinned claude %s at %s now reports %s", b.Version, b.Path, version)
+			d.advise("Run 'compat pin " + b.Path + "' to pin it under its new version.")
+		} else {
+			d.ok("pinned claude %s", b.Version)
+		}
+	}
+
+	if _, err := compat.LoadAgents(paths.AgentsFile()); err != nil {
+		d.fail("agents: %v", err)
+		d.advise("Fix the agent definition in " + paths.AgentsFile() + ".")
+	}
+
+	// Directories and configuration.
+	if err := paths.EnsureDirs(); err != nil {
+		d.fail("directories under %s: %v", paths.Home, err)
+	} else {
+		d.ok("directories under %s", paths.Home)
+	}
+	if cfg, err := paths.Load(nil); err != nil {
+		d.fail("config: %v", err)
+		d.advise("Fix or remove the offending key in " + paths.ConfigFile() + ".")
+	} else {
+		d.ok("config")
+		if free, err := fileutil.FreeSpace(paths.StateDir()); err == nil && cfg.MinFreeDiskMB > 0 {
+			if free>>20 < uint64(cfg.MinFreeDiskMB) {
+				d.warn("disk space: %d MB free under %s, below min_free_disk_mb %d; the runner will pause", free>>20, paths.Home, cfg.MinFreeDiskMB)
+			} else {
+				d.ok("disk space: %d MB free", free>>20)
+			}
+		}
+	}
+
+	// Runner lock and heartbeat.
+	lockPath := paths.LockPath()
+	runnerActive := false
+	lk, acquired, err := lock.TryLock(lockPath)
+	switch {
+	case err != nil:
+		d.fail("runner lock: %v", err)
+	case acquired:
+		lk.Release()
+		d.ok("runner: idle")
+	default:
+		runnerActive = true
+		info, err := lock.ReadInfo(lockPath)
+		if err != nil {
+			d.warn("runner: active, but lockfile unreadable: %v", err)
+			break
+		}
+		if !info.Stale(time.Now()) {
+			d.ok("runner: active (PID %d, heartbeat ok)", info.PID)
+			break
+		}
+		d.fail("runner: PID %d holds the lock but its heartbeat is %s old",
+			info.PID, time.Since(info.HeartbeatAt).Truncate(time.Second))
+		d.advise(
+			fmt.Sprintf("The runner looks wedged. Check it with 'ps -p %d'.", info.PID),
+			fmt.Sprintf("Stop it with 'kill %d' so it can checkpoint its task; use 'kill -9 %d' if it does not exit.", info.PID, info.PID),
+			"The lock is released when the process exits, and its running task is reset to pending on the next 'run'.",
+		)
+	}
+
+	// Tasks stuck in running with no runner.
+	if !runnerActive {
+		stateDir := paths.StateDir()
+		tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
+		if err != nil {
+			d.fail("tasks: %v", err)
+		} else {
+			var stuck []string
+			for i := range tasks {
+				if st, _ := queue.LoadState(stateDir, tasks[i].ID); st != nil && st.Status == queue.StatusRunning {
+					stuck = append(stuck, tasks[i].ID)
+				}
+			}
+			if len(stuck) > 0 {
+				d.warn("%d task(s) marked running with no active runner: %v", len(stuck), stuck)
+				d.advise("They were interrupted by a crash and will be reset to pending on the next 'run'.")
+			} else {
+				d.ok("%d task(s) loaded", len(tasks))
+			}
+		}
+	}
+
+	if d.failed {
+		os.Exit(1)
+	}
+	return nil
+}
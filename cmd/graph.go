@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+// ── graph ───────────────────────────────────────────────────────────────
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Render the queue's dependency graph as DOT or Mermaid",
+	Long: "graph prints the queue as a graph: one node per task with its priority and\n" +
+		"status, an edge from each dependency (depends_on or a {{task:<id>.summary}}\n" +
+		"reference) to the task waiting on it, and tasks of one concurrency_group\n" +
+		"drawn together. --format dot output renders with Graphviz\n" +
+		"(graph | dot -Tsvg > queue.svg); --format mermaid can be pasted into\n" +
+		"Markdown docs.",
+	Args: cobra.NoArgs,
+	RunE: runGraph,
+}
+
+var graphFormat string
+
+func runGraph(cmd *cobra.Command, args []string) error {
+	if graphFormat != queue.GraphDOT && graphFormat != queue.GraphMermaid {
+		return fmt.Errorf("--format must be dot or mermaid (got %q)", graphFormat)
+	}
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), paths.StateDir())
+	if err != nil {
+		return fmt.Errorf("load tasks: %w", err)
+	}
+	states, err := queue.LoadAllStates(paths.StateDir())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: load states: %v\n", err)
+	}
+	statuses := make(map[string]string, len(states))
+	for id, st := range states {
+		statuses[id] = st.Status
+	}
+
+	out, err := queue.Graph(tasks, statuses, graphFormat)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
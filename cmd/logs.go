@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/tasklog"
+	"github.com/spf13/cobra"
+)
+
+// ── logs ────────────────────────────────────────────────────────────────
+
+var logsCmd = &cobra.Command{
+	Use:   "logs <task-id>",
+	Short: "Print a task's log, optionally only one attempt",
+	Long: "logs prints the per-task log of task-id, including its rotated backup,\n" +
+		"oldest first. --attempt N keeps only the lines of attempt N. NDJSON logs\n" +
+		"(log_format: ndjson) are printed as text, stderr lines prefixed with\n" +
+		"\"stderr: \"; read the file itself for the records. Encrypted lines are\n" +
+		"decrypted with encryption_key_file.",
+	Args: cobra.ExactArgs(1),
+	RunE: runLogs,
+}
+
+var logsAttempt int
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	id := args[0]
+	if logsAttempt < 0 {
+		return fmt.Errorf("--attempt must be positive (got %d)", logsAttempt)
+	}
+
+	var buf bytes.Buffer
+	found := false
+	for _, path := range []string{paths.TaskLog(id) + ".1", paths.TaskLog(id)} {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("read log: %w", err)
+		}
+		found = true
+		buf.Write(data)
+	}
+	if !found {
+		return fmt.Errorf("no log for task '%s'", id)
+	}
+
+	var r io.Reader = &buf
+	if queue.EncryptionKey != nil {
+		var plain bytes.Buffer
+		if err := queue.EncryptionKey.OpenLines(&plain, &buf); err != nil {
+			return fmt.Errorf("decrypt log: %w", err)
+		}
+		r = &plain
+	}
+	return tasklog.Copy(os.Stdout, r, logsAttempt)
+}
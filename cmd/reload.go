@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+// ── reload ──────────────────────────────────────────────────────────────
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Make the active runner re-read config and matchers",
+	Long: "reload asks the active runner to re-read config.yaml, the environment and\n" +
+		"matchers.yaml before it starts its next task, so notification, detection and\n" +
+		"limit settings change without a restart that drops the lock and any\n" +
+		"countdowns. Settings read only at startup (http_*, state_retention,\n" +
+		"encryption_key_file) still need a restart; the runner logs which changed.\n" +
+		"Task files need no reload: the runner reads them before every task.",
+	Args: cobra.NoArgs,
+	RunE: runReload,
+}
+
+func runReload(cmd *cobra.Command, args []string) error {
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+	// Report a broken file now; the runner would only log it and carry on
+	// with the old settings.
+	if _, err := paths.Load(nil); err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+	if _, err := paths.LoadMatchers(); err != nil {
+		return fmt.Errorf("load matchers: %w", err)
+	}
+
+	lk, acquired, err := lock.TryLock(paths.LockPath())
+	if err != nil {
+		return fmt.Errorf("probe lock: %w", err)
+	}
+	if acquired {
+		lk.Release()
+		fmt.Println("No runner is active; 'run' reads the current settings when it starts")
+		return nil
+	}
+	if err := queue.AppendCommand(paths.ControlDir(), queue.NewControlCommand("reload", "")); err != nil {
+		return fmt.Errorf("queue reload command: %w", err)
+	}
+	fmt.Println("Queued reload")
+	return nil
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/review"
+	"github.com/spf13/cobra"
+)
+
+// ── review ──────────────────────────────────────────────────────────────
+
+var reviewCmd = &cobra.Command{
+	Use:   "review [task-id]",
+	Short: "List tasks awaiting review, or show, approve or reject one",
+	Long: "Tasks with review: true run in a git worktree and stop in needs_review\n" +
+		"with their work committed to the branch autopilot/<task-id>. Without an\n" +
+		"argument, review lists those tasks. With a task ID it prints the diff;\n" +
+		"--approve (or the approve command) merges the branch into working_dir and\n" +
+		"marks the task done; --reject (or reject) discards the branch and marks the\n" +
+		"task failed. While a runner is active the decision is queued for it.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReview,
+}
+
+var (
+	reviewApprove bool
+	reviewReject  bool
+	reviewStat    bool
+)
+
+func runReview(cmd *cobra.Command, args []string) error {
+	if reviewApprove && reviewReject {
+		return fmt.Errorf("give --approve or --reject, not both")
+	}
+	if len(args) == 0 && (reviewApprove || reviewReject) {
+		return fmt.Errorf("task ID required with --approve or --reject")
+	}
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	stateDir := paths.StateDir()
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
+	if err != nil {
+		return fmt.Errorf("load tasks: %w", err)
+	}
+
+	if len(args) == 0 {
+		n := 0
+		for i := range tasks {
+			st, _ := queue.LoadState(stateDir, tasks[i].ID)
+			if st == nil || st.Status != queue.StatusNeedsReview {
+				continue
+			}
+			if n == 0 {
+				fmt.Printf("%-30s %-40s %s\n", "ID", "CHANGES", "TITLE")
+			}
+			n++
+			fmt.Printf("%-30s %-40s %s\n", tasks[i].ID, st.DiffSummary, tasks[i].Title)
+		}
+		if n == 0 {
+			fmt.Println("No tasks awaiting review")
+		}
+		return nil
+	}
+
+	taskID := args[0]
+	task := findTask(tasks, taskID)
+	if task == nil {
+		return fmt.Errorf("Task '%s' not found", taskID)
+	}
+	st, err := queue.LoadState(stateDir, taskID)
+	if err != nil {
+		return fmt.Errorf("load state for %s: %w", taskID, err)
+	}
+	if st == nil || st.Status != queue.StatusNeedsReview {
+		status := queue.StatusPending
+		if st != nil {
+			status = st.Status
+		}
+		return fmt.Errorf("Task '%s' is %s, not awaiting review", taskID, status)
+	}
+
+	if !reviewApprove && !reviewReject {
+		stat, err := review.Diff(task.WorkingDir, st.GitCommit, st.ReviewBranch, true)
+		if err != nil {
+			return fmt.Errorf("diff %s: %w", st.ReviewBranch, err)
+		}
+		fmt.Printf("Task %s: %s\n", task.ID, task.Title)
+		fmt.Printf("Branch %s (worktree %s)\n\n%s\n", st.ReviewBranch, st.Worktree, stat)
+		if reviewStat {
+			return nil
+		}
+		patch, err := review.Diff(task.WorkingDir, st.GitCommit, st.ReviewBranch, false)
+		if err != nil {
+			return fmt.Errorf("diff %s: %w", st.ReviewBranch, err)
+		}
+		fmt.Printf("\n%s\n", patch)
+		return nil
+	}
+
+	return decideReview([]string{taskID}, reviewApprove)
+}
+
+// ── approve / reject ────────────────────────────────────────────────────
+
+var approveCmd = &cobra.Command{
+	Use:   "approve <task-id>...",
+	Short: "Merge the work of tasks awaiting review and mark them done",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideReview(args, true)
+	},
+}
+
+var rejectCmd = &cobra.Command{
+	Use:   "reject <task-id>...",
+	Short: "Discard the work of tasks awaiting review and mark them failed",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return decideReview(args, false)
+	},
+}
+
+// decideReview approves or rejects tasks awaiting review. With no runner
+// active the decision is applied directly; otherwise it is queued for the
+// runner, which applies it between tasks.
+func decideReview(taskIDs []string, approve bool) error {
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+	op := "reject"
+	if approve {
+		op = "approve"
+	}
+
+	lk, acquired, err := lock.TryLock(paths.LockPath())
+	if err != nil {
+		return fmt.Errorf("probe lock: %w", err)
+	}
+	if !acquired {
+		for _, taskID := range taskIDs {
+			if err := queue.AppendCommand(paths.ControlDir(), queue.NewControlCommand(op, taskID)); err != nil {
+				return fmt.Errorf("queue %s command: %w", op, err)
+			}
+			fmt.Printf("Queued %s for %s\n", op, taskID)
+		}
+		return nil
+	}
+	defer lk.Release()
+
+	stateDir := paths.StateDir()
+	tasks, err := queue.LoadTasks(paths.TasksDir(), resolveProjectDir())
+	if err != nil {
+		return fmt.Errorf("load tasks: %w", err)
+	}
+	for _, taskID := range taskIDs {
+		task := findTask(tasks, taskID)
+		if task == nil {
+			return fmt.Errorf("Task '%s' not found", taskID)
+		}
+		st, err := queue.LoadState(stateDir, taskID)
+		if err != nil {
+			return fmt.Errorf("load state for %s: %w", taskID, err)
+		}
+		if st == nil {
+			st = &queue.TaskState{ID: taskID, Status: queue.StatusPending}
+		}
+		branch := st.ReviewBranch
+		if err := review.Decide(st, task.WorkingDir, approve); err != nil {
+			return fmt.Errorf("%s %s: %w", op, taskID, err)
+		}
+		if err := queue.SaveState(stateDir, st); err != nil {
+			return fmt.Errorf("save state for %s: %w", taskID, err)
+		}
+		if approve {
+			fmt.Printf("Approved task '%s': merged %s into %s\n", taskID, branch, task.WorkingDir)
+		} else {
+			fmt.Printf("Rejected task '%s': discarded %s\n", taskID, branch)
+		}
+	}
+	return nil
+}
+
+// findTask returns the task with the given ID, or nil.
+func findTask(tasks []queue.Task, id string) *queue.Task {
+	for i := range tasks {
+		if tasks[i].ID == id {
+			return &tasks[i]
+		}
+	}
+	return nil
+}
@@ -6,17 +6,23 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/hseinmoussa/claude-autopilot/internal/compat"
 	"github.com/hseinmoussa/claude-autopilot/internal/config"
 	"github.com/hseinmoussa/claude-autopilot/internal/detector"
+	"github.com/hseinmoussa/claude-autopilot/internal/estimate"
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
 	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
 	"github.com/hseinmoussa/claude-autopilot/internal/lock"
 	"github.com/hseinmoussa/claude-autopilot/internal/notifier"
 	"github.com/hseinmoussa/claude-autopilot/internal/queue"
 	"github.com/hseinmoussa/claude-autopilot/internal/runner"
+	"github.com/hseinmoussa/claude-autopilot/internal/service"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+	"github.com/hseinmoussa/claude-autopilot/internal/usage"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 )
@@ -24,11 +30,45 @@ import (
 // projectDir is the global --project-dir flag value.
 var projectDir string
 
+// stateDirFlag and queueName are the global --state-dir and --queue flag
+// values; paths is resolved from them (and CLAUDE_AUTOPILOT_HOME / XDG
+// variables) before any command runs.
+var (
+	stateDirFlag string
+	queueName    string
+	paths        config.Paths
+)
+
+// queueEnv selects a named queue when --queue is not given.
+const queueEnv = "CLAUDE_AUTOPILOT_QUEUE"
+
+// quiet, noColor and useUTC are the global --quiet, --no-color and --utc
+// flag values.
+var (
+	quiet   bool
+	noColor bool
+	useUTC  bool
+)
+
 // rootCmd is the top-level cobra command for claude-autopilot.
 var rootCmd = &cobra.Command{
 	Use:   "claude-autopilot",
 	Short: "Autonomous task runner for Claude Code",
 	Long:  "Autonomous task runner for Claude Code — auto-retries on rate limits, queues tasks, keeps working while you sleep.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		ui.Configure(quiet, noColor)
+		ui.SetUTC(useUTC)
+		if queueName == "" {
+			queueName = os.Getenv(queueEnv)
+		}
+		var err error
+		paths, err = config.Resolve(stateDirFlag).Queue(queueName)
+		if err != nil {
+			return err
+		}
+		queue.DefaultPriority, queue.DefaultMaxRetries = paths.TaskDefaults()
+		return loadEncryptionKey(cmd)
+	},
 }
 
 // SetVersion sets the CLI version string shown by --version.
@@ -50,8 +90,40 @@ var (
 	addTitle           string
 	addPriority        int
 	addModel           string
+	addAgent           string
+	addClaudeVersion   string
+	addContainer       string
+	addContainerNet    string
+	addContainerCPUs   float64
+	addContainerMem    string
+	addNice            int
+	addMaxCPUTime      string
+	addHangTimeout     string
+	addNoHang          bool
+	addMaxMemory       string
 	addSkipPermissions bool
 	addID              string
+	addTags            []string
+	addMaxRetries      int
+	addContext         []string
+	addContextCommands []string
+	addFlags           []string
+	addResumeStrategy  string
+	addDependsOn       []string
+	addGroup           string
+	addExclusive       bool
+	addRunDays         []string
+	addSkipDates       []string
+	addVerify          string
+	addRetryOn         []string
+	addFailFastOn      []string
+	addArtifacts       []string
+	addTeeOutput       string
+	addMaxCostUSD      float64
+	addMaxTokens       int
+	addExportSummary   bool
+	addReview          bool
+	addPlan            bool
 )
 
 func runAdd(cmd *cobra.Command, args []string) error {
@@ -60,6 +132,9 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	if addDir == "" {
 		return fmt.Errorf("--dir is required")
 	}
+	if addPriority < 0 || addMaxRetries < 0 {
+		return fmt.Errorf("--priority and --max-retries must not be negative")
+	}
 
 	// Validate and resolve --dir to absolute path.
 	absDir, err := filepath.Abs(addDir)
@@ -78,13 +153,15 @@ func runAdd(cmd *cobra.Command, args []string) error {
 	}
 
 	// Ensure base directories exist.
-	if err := config.EnsureDirs(); err != nil {
+	if err := paths.EnsureDirs(); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
 
-	// Generate title from prompt if not provided.
+	// Generate title from prompt if not provided. With encryption on the
+	// title is left to be derived from the decrypted prompt when loaded, so
+	// neither it nor the ID gives the prompt away.
 	title := addTitle
-	if title == "" {
+	if title == "" && queue.EncryptionKey == nil {
 		title = prompt
 		if len(title) > 60 {
 			title = title[:60]
@@ -104,10 +181,43 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		Title:           title,
 		Priority:        addPriority,
 		CreatedAt:       time.Now().UTC(),
+		Origin:          queue.NewOrigin(queue.OriginCLI, ""),
 		WorkingDir:      absDir,
 		SkipPermissions: addSkipPermissions,
-		Prompt:          prompt,
+		Prompt:          queue.SealPrompt(prompt),
 		Model:           addModel,
+		Agent:           addAgent,
+		ClaudeVersion:   addClaudeVersion,
+		Container:       addContainer,
+		ContainerNet:    addContainerNet,
+		ContainerCPUs:   addContainerCPUs,
+		ContainerMem:    addContainerMem,
+		Nice:            addNice,
+		MaxCPUTime:      addMaxCPUTime,
+		HangTimeout:     addHangTimeout,
+		NoHangDetection: addNoHang,
+		MaxMemory:       addMaxMemory,
+		MaxRetries:      addMaxRetries,
+		Tags:            addTags,
+		ContextFiles:    addContext,
+		ContextCommands: addContextCommands,
+		Flags:           addFlags,
+		ResumeStrategy:  addResumeStrategy,
+		DependsOn:       addDependsOn,
+		Group:           addGroup,
+		Exclusive:       addExclusive,
+		RunDays:         addRunDays,
+		SkipDates:       addSkipDates,
+		Verify:          addVerify,
+		RetryOn:         addRetryOn,
+		FailFastOn:      addFailFastOn,
+		Artifacts:       addArtifacts,
+		TeeOutput:       addTeeOutput,
+		MaxCostUSD:      addMaxCostUSD,
+		MaxTokens:       addMaxTokens,
+		ExportSummary:   addExportSummary,
+		Review:          addReview,
+		Plan:            addPlan,
 	}
 
 	data, err := yaml.Marshal(&task)
@@ -115,15 +225,56 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("marshal task: %w", err)
 	}
 
-	taskPath := filepath.Join(config.BaseDir(), "tasks", id+".yaml")
+	taskPath := filepath.Join(paths.TasksDir(), id+".yaml")
 	if _, err := os.Stat(taskPath); err == nil {
 		return fmt.Errorf("task with id %q already exists", id)
 	}
+	// Check the task the way the runner will load it, so a bad flag value
+	// is reported now rather than breaking the queue.
+	if _, err := queue.ParseMultiDocYAML(data, taskPath); err != nil {
+		return err
+	}
+	if addAgent != "" && addAgent != compat.DefaultAgent {
+		defined, err := compat.LoadAgents(paths.AgentsFile())
+		if err != nil {
+			return fmt.Errorf("load agents: %w", err)
+		}
+		if _, ok := compat.LookupAgent(defined, addAgent); !ok {
+			return fmt.Errorf("--agent: unknown agent '%s' (known: %s)", addAgent, strings.Join(compat.AgentNames(defined), ", "))
+		}
+	}
+	// Flags the runner sets itself are dropped at run time; say so now.
+	if addAgent == "" || addAgent == compat.DefaultAgent {
+		_, warnings := compat.CheckFlags(nil, addFlags)
+		for _, w := range warnings {
+			fmt.Printf("WARNING: %s\n", w)
+		}
+	}
+	if addClaudeVersion != "" {
+		if pins, err := compat.LoadPins(paths.ClaudeVersionsFile()); err == nil && compat.SelectBinary(pins, addClaudeVersion) == nil {
+			fmt.Printf("WARNING: no claude %s is pinned yet; the task fails until one is ('compat pin')\n", addClaudeVersion)
+		}
+	}
+	if len(addDependsOn) > 0 {
+		existing, err := queue.LoadTasks(paths.TasksDir(), resolveProjectDir())
+		if err != nil {
+			return fmt.Errorf("load tasks: %w", err)
+		}
+		for _, dep := range addDependsOn {
+			if findTask(existing, dep) == nil {
+				return fmt.Errorf("--depends-on: unknown task '%s'", dep)
+			}
+		}
+	}
 	if err := fileutil.AtomicWrite(taskPath, data, 0644); err != nil {
 		return fmt.Errorf("write task file: %w", err)
 	}
 
-	fmt.Printf("Added task '%s' (priority: %d)\n", id, addPriority)
+	priority := addPriority
+	if priority == 0 {
+		priority = queue.DefaultPriority
+	}
+	fmt.Printf("Added task '%s' (priority: %d)\n", id, priority)
 	return nil
 }
 
@@ -135,49 +286,166 @@ var runCmd = &cobra.Command{
 	RunE:  runRun,
 }
 
-var runYes bool
+var (
+	runYes     bool
+	runWatch   bool
+	runTags    []string
+	runOnly    []string
+	runExclude []string
+	runMaxWait time.Duration
+
+	runEvents     bool
+	runEventsFile string
+	runTeeDir     string
+	runIfNotRun   bool
+)
 
 func runRun(cmd *cobra.Command, args []string) error {
-	// Detect Claude Code version.
-	version, err := compat.DetectVersion()
+	r, err := newRunner()
 	if err != nil {
-		return fmt.Errorf("detect claude version: %w", err)
+		return err
+	}
+	r.YesFlag = runYes
+	r.Watch = runWatch
+	r.Tags = runTags
+	r.Only = runOnly
+	r.Exclude = runExclude
+	r.MaxWait = runMaxWait
+	r.IfNotRunning = runIfNotRun
+	if runTeeDir != "" {
+		abs, err := filepath.Abs(runTeeDir)
+		if err != nil {
+			return fmt.Errorf("resolve --tee-dir: %w", err)
+		}
+		r.TeeDir = abs
 	}
 
-	entry, err := compat.LookupCompat(version)
-	if err != nil {
-		return fmt.Errorf("lookup compat for version %s: %w", version, err)
+	switch {
+	case runEvents:
+		// stdout carries only events; everything human-facing moves to stderr.
+		ui.UseStderr()
+		r.Events = events.New(os.Stdout)
+	case runEventsFile != "":
+		w, err := events.Open(runEventsFile)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		r.Events = w
+	}
+
+	exitCode := r.Run()
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
+	return nil
+}
 
-	adapter := compat.NewAdapter(entry)
+// newRunner detects the Claude CLI version and builds a Runner with the
+// matching adapter, detector, configuration, and notifier.
+func newRunner() (*runner.Runner, error) {
+	// Load configuration first so config mistakes surface even when the
+	// Claude CLI is missing.
+	cfg, err := paths.Load(nil)
+	if err != nil {
+		return nil, &exitError{code: runner.ExitConfig, err: fmt.Errorf("load config: %w", err)}
+	}
 
 	// Load matchers for detection.
-	matchers, err := config.LoadMatchers()
+	matchers, err := paths.LoadMatchers()
 	if err != nil {
-		return fmt.Errorf("load matchers: %w", err)
+		return nil, &exitError{code: runner.ExitConfig, err: fmt.Errorf("load matchers: %w", err)}
 	}
 
-	det := detector.NewDetector(matchers.RateLimitPatterns, adapter.RateLimitExitCode())
-
-	// Load configuration.
-	cfg, err := config.Load(nil)
+	// Detect Claude Code version.
+	version, err := compat.DetectVersion()
 	if err != nil {
-		return fmt.Errorf("load config: %w", err)
+		return nil, &exitError{code: runner.ExitFatal, err: fmt.Errorf("detect claude version: %w", err)}
 	}
 
-	nfy := notifier.NewNotifier(&cfg)
+	// A version missing from the compat table is probed through its help.
+	adapter, err := compat.AdapterFor("claude", version)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	det := detector.NewDetector(matchers.RateLimitPatterns, adapter.RateLimitExitCode()).
+		WithFailurePatterns(matchers.FailurePatternsByCategory())
 
-	r := &runner.Runner{
+	return &runner.Runner{
 		Config:         &cfg,
 		Adapter:        adapter,
 		Detector:       det,
-		Notifier:       nfy,
+		Notifier:       notifier.NewNotifier(&cfg),
+		Paths:          paths,
 		ProjectDir:     resolveProjectDir(),
-		YesFlag:        runYes,
 		PromptPatterns: matchers.PromptPatterns,
+		PromptAnswers:  matchers.PromptAnswers,
+	}, nil
+}
+
+// ── exec ────────────────────────────────────────────────────────────────
+
+var execCmd = &cobra.Command{
+	Use:   "exec [prompt]",
+	Short: "Run one ad-hoc task now, without adding it to the queue",
+	Long: "Run one ad-hoc task in the foreground with the usual retry and rate-limit\n" +
+		"handling. The task is not written to the queue and its state is discarded, but\n" +
+		"its log, duration and rate-limit history and events.jsonl records are kept\n" +
+		"like any task's; 'clean --orphan-state' removes the log later.",
+	Args: cobra.ExactArgs(1),
+	RunE: runExec,
+}
+
+var (
+	execDir             string
+	execModel           string
+	execSkipPermissions bool
+	execMaxRetries      int
+	execYes             bool
+)
+
+func runExec(cmd *cobra.Command, args []string) error {
+	prompt := args[0]
+
+	absDir, err := filepath.Abs(execDir)
+	if err != nil {
+		return fmt.Errorf("resolve --dir: %w", err)
+	}
+	if info, err := os.Stat(absDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("Directory %s does not exist", absDir)
 	}
 
-	exitCode := r.Run()
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	title := prompt
+	if len(title) > 60 {
+		title = title[:60]
+	}
+
+	task := &queue.Task{
+		ID:              queue.GenerateID("exec " + title),
+		Title:           title,
+		Priority:        queue.DefaultPriority,
+		CreatedAt:       time.Now().UTC(),
+		WorkingDir:      absDir,
+		SkipPermissions: execSkipPermissions,
+		Prompt:          prompt,
+		Model:           execModel,
+		MaxRetries:      execMaxRetries,
+	}
+	if task.MaxRetries <= 0 {
+		task.MaxRetries = queue.DefaultMaxRetries
+	}
+
+	r, err := newRunner()
+	if err != nil {
+		return err
+	}
+	r.YesFlag = execYes
+
+	exitCode := r.RunOnce(task)
 	if exitCode != 0 {
 		os.Exit(exitCode)
 	}
@@ -189,50 +457,97 @@ func runRun(cmd *cobra.Command, args []string) error {
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all queued tasks",
-	RunE:  runList,
+	Long: "List queued tasks in scheduler order. Filters combine: --status, --dir and\n" +
+		"--tag narrow the list, --sort reorders it, and --limit caps the rows shown.",
+	RunE: runList,
 }
 
+var (
+	listTags     []string
+	listStatuses []string
+	listSort     string
+	listDir      string
+	listLimit    int
+	listOutput   string
+)
+
+// readOnly is --read-only on list, status and show: the command writes
+// nothing, not even the data directories or the lockfile, so it works for
+// observers with a read-only view of the data directory.
+var readOnly bool
+
 func runList(cmd *cobra.Command, args []string) error {
-	if err := config.EnsureDirs(); err != nil {
-		return fmt.Errorf("create directories: %w", err)
+	for _, st := range listStatuses {
+		if !queue.IsValidStatus(st) {
+			return fmt.Errorf("invalid --status '%s'", st)
+		}
+	}
+	switch listSort {
+	case listSortPriority, listSortCreated, listSortDuration:
+	default:
+		return fmt.Errorf("--sort must be priority, created or duration (got '%s')", listSort)
+	}
+	if listLimit < 0 {
+		return fmt.Errorf("--limit must not be negative")
+	}
+	if listOutput != "" && listOutput != "wide" {
+		return fmt.Errorf("--output must be wide (got '%s')", listOutput)
+	}
+	sel := taskSelector{statuses: listStatuses, tags: listTags}
+	if listDir != "" {
+		abs, err := filepath.Abs(listDir)
+		if err != nil {
+			return fmt.Errorf("resolve --dir: %w", err)
+		}
+		sel.dir = abs
 	}
 
-	base := config.BaseDir()
-	globalTaskDir := filepath.Join(base, "tasks")
-	stateDir := filepath.Join(base, "state")
+	if !readOnly {
+		if err := paths.EnsureDirs(); err != nil {
+			return fmt.Errorf("create directories: %w", err)
+		}
+	}
+
+	stateDir := paths.StateDir()
 
-	tasks, initCount, err := queue.LoadTasksAndInit(globalTaskDir, resolveProjectDir(), stateDir)
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
 	if err != nil {
 		return fmt.Errorf("load tasks: %w", err)
 	}
-	if initCount > 0 {
-		fmt.Printf("ℹ Initialized state for %d new tasks\n", initCount)
-	}
 
 	if len(tasks) == 0 {
 		fmt.Println("No tasks found.")
 		return nil
 	}
 
-	// Ensure init files and load states.
-	type taskRow struct {
-		Index    int
-		ID       string
-		Priority int
-		Status   string
-		Title    string
+	states, err := queue.LoadAllStates(stateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: load states: %v\n", err)
 	}
-
-	var rows []taskRow
+	var rows []listRow
 	for i := range tasks {
-		st, err := queue.LoadState(stateDir, tasks[i].ID)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: load state for %s: %v\n", tasks[i].ID, err)
-		}
+		st := states[tasks[i].ID]
 
 		status := queue.StatusPending
+		var duration time.Duration = -1
+		var attempts int
+		var resumeAt *time.Time
 		if st != nil {
 			status = st.Status
+			attempts = st.Attempt
+			if status == queue.StatusWaiting {
+				resumeAt = st.ResumeAt
+			}
+			if st.StartedAt != nil {
+				end := time.Now()
+				if st.EndedAt != nil {
+					end = *st.EndedAt
+				}
+				duration = end.Sub(*st.StartedAt)
+			}
+		}
+		if !sel.matches(&tasks[i], status) {
+			continue
 		}
 
 		title := tasks[i].Title
@@ -240,26 +555,76 @@ func runList(cmd *cobra.Command, args []string) error {
 			title = title[:50] + "..."
 		}
 
-		rows = append(rows, taskRow{
-			Index:    i + 1,
-			ID:       tasks[i].ID,
-			Priority: tasks[i].Priority,
-			Status:   status,
-			Title:    title,
+		rows = append(rows, listRow{
+			Index:     i + 1,
+			ID:        tasks[i].ID,
+			Priority:  tasks[i].Priority,
+			Status:    status,
+			Title:     title,
+			Tags:      strings.Join(tasks[i].Tags, ","),
+			CreatedAt: tasks[i].CreatedAt,
+			Duration:  duration,
+
+			Attempts:   attempts,
+			ResumeAt:   resumeAt,
+			WorkingDir: tasks[i].WorkingDir,
+			Model:      tasks[i].Model,
 		})
 	}
 
-	// Print table header.
-	fmt.Printf("%-4s %-30s %-8s %-12s %s\n", "#", "ID", "Priority", "Status", "Title")
-	fmt.Printf("%-4s %-30s %-8s %-12s %s\n", "---", "---", "---", "---", "---")
+	if len(rows) == 0 {
+		fmt.Println("No tasks match the given filters.")
+		return nil
+	}
+	sortListRows(rows, listSort)
+	total := len(rows)
+	if listLimit > 0 && total > listLimit {
+		rows = rows[:listLimit]
+	}
 
-	for _, r := range rows {
-		fmt.Printf("%-4d %-30s %-8d %-12s %s\n", r.Index, r.ID, r.Priority, r.Status, r.Title)
+	if listOutput == "wide" {
+		printWideList(rows)
+	} else {
+		// Print table header.
+		fmt.Printf("%-4s %-30s %-8s %-12s %-16s %s\n", "#", "ID", "Priority", "Status", "Tags", "Title")
+		fmt.Printf("%-4s %-30s %-8s %-12s %-16s %s\n", "---", "---", "---", "---", "---", "---")
+
+		for _, r := range rows {
+			fmt.Printf("%-4d %-30s %-8d %s %-16s %s\n", r.Index, r.ID, r.Priority, ui.Status(r.Status, 12), r.Tags, r.Title)
+		}
+	}
+	if len(rows) < total {
+		fmt.Printf("(showing %d of %d tasks)\n", len(rows), total)
 	}
 
 	return nil
 }
 
+// printWideList prints list -o wide: the compact columns plus attempts,
+// last run duration, next resume time, model and working directory.
+func printWideList(rows []listRow) {
+	const format = "%-4s %-30s %-8s %s %-8s %-10s %-12s %-20s %-16s %-30s %s\n"
+	fmt.Printf(format, "#", "ID", "Priority", fmt.Sprintf("%-12s", "Status"), "Attempts", "Last run", "Next resume", "Model", "Tags", "Dir", "Title")
+	fmt.Printf(format, "---", "---", "---", fmt.Sprintf("%-12s", "---"), "---", "---", "---", "---", "---", "---", "---")
+
+	for _, r := range rows {
+		lastRun := "-"
+		if r.Duration >= 0 {
+			lastRun = r.Duration.Truncate(time.Second).String()
+		}
+		resume := "-"
+		if r.ResumeAt != nil {
+			resume = ui.In(*r.ResumeAt).Format("Jan 02 15:04")
+		}
+		model := r.Model
+		if model == "" {
+			model = "-"
+		}
+		fmt.Printf(format, strconv.Itoa(r.Index), r.ID, strconv.Itoa(r.Priority), ui.Status(r.Status, 12),
+			strconv.Itoa(r.Attempts), lastRun, resume, model, r.Tags, r.WorkingDir, r.Title)
+	}
+}
+
 // ── status ──────────────────────────────────────────────────────────────
 
 var statusCmd = &cobra.Command{
@@ -269,59 +634,86 @@ var statusCmd = &cobra.Command{
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
-	if err := config.EnsureDirs(); err != nil {
-		return fmt.Errorf("create directories: %w", err)
+	if !readOnly {
+		if err := paths.EnsureDirs(); err != nil {
+			return fmt.Errorf("create directories: %w", err)
+		}
 	}
 
-	base := config.BaseDir()
-	lockPath := filepath.Join(base, "runner.lock")
-	globalTaskDir := filepath.Join(base, "tasks")
-	stateDir := filepath.Join(base, "state")
+	lockPath := paths.LockPath()
+	stateDir := paths.StateDir()
+
+	if queueName != "" {
+		fmt.Printf("Queue: %s\n", queueName)
+	}
 
 	// Probe runner lock (non-blocking).
-	lk, acquired, err := lock.TryLock(lockPath)
+	held, err := runnerActive(lockPath)
 	if err != nil {
 		return fmt.Errorf("probe lock: %w", err)
 	}
 
-	if acquired {
-		// We got the lock, so no runner is active. Release immediately.
-		lk.Release()
+	if !held {
 		fmt.Println("Runner: idle (no active instance)")
 	} else {
 		// Lock is held; read lock info.
-		pid, acquiredAt, err := lock.ReadLockInfo(lockPath)
+		info, err := lock.ReadInfo(lockPath)
 		if err != nil {
 			fmt.Println("Runner: active (PID unknown)")
 		} else {
-			fmt.Printf("Runner: active (PID %d, since %s)\n", pid, acquiredAt.Format(time.RFC3339))
+			fmt.Printf("Runner: active (PID %d, since %s)\n", info.PID, ui.At(info.AcquiredAt))
+			if h, err := runner.ReadHealth(paths.HealthFile()); err == nil && h.PID == info.PID {
+				switch {
+				case h.TaskID != "" && h.Progress != nil && h.Progress.String() != "":
+					fmt.Printf("Phase: %s (task %s, %s)\n", h.Phase, h.TaskID, h.Progress)
+				case h.TaskID != "":
+					fmt.Printf("Phase: %s (task %s)\n", h.Phase, h.TaskID)
+				case h.Reason != "":
+					fmt.Printf("Phase: %s (%s)\n", h.Phase, h.Reason)
+				default:
+					fmt.Printf("Phase: %s\n", h.Phase)
+				}
+			}
+			if info.Stale(time.Now()) {
+				fmt.Printf("WARNING: runner heartbeat is stale (last %s ago); it may be wedged. Run 'claude-autopilot doctor' for recovery steps.\n",
+					time.Since(info.HeartbeatAt).Truncate(time.Second))
+			}
+		}
+	}
+
+	if events, err := usage.Load(paths.RateLimitHistory()); err == nil {
+		window := usage.DefaultWindow
+		if cfg, err := paths.Load(nil); err == nil {
+			window = cfg.UsageWindow
+		}
+		if w, ok := usage.Predict(events, window, time.Now()); ok {
+			fmt.Printf("Usage window: predicted reset at %s (%d rate limit(s) recorded)\n", ui.Resume(w.End), len(events))
 		}
 	}
 
 	fmt.Println()
 
 	// Load tasks and compute summary.
-	tasks, initCount, err := queue.LoadTasksAndInit(globalTaskDir, resolveProjectDir(), stateDir)
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
 	if err != nil {
 		return fmt.Errorf("load tasks: %w", err)
 	}
-	if initCount > 0 {
-		fmt.Printf("ℹ Initialized state for %d new tasks\n", initCount)
-	}
 
 	counts := map[string]int{
-		queue.StatusPending:   0,
-		queue.StatusRunning:   0,
-		queue.StatusWaiting:   0,
-		queue.StatusDone:      0,
-		queue.StatusFailed:    0,
-		queue.StatusCancelled: 0,
+		queue.StatusPending:     0,
+		queue.StatusRunning:     0,
+		queue.StatusWaiting:     0,
+		queue.StatusDone:        0,
+		queue.StatusFailed:      0,
+		queue.StatusCancelled:   0,
+		queue.StatusNeedsReview: 0,
 	}
 	activeTask := ""
 	var nextResume *time.Time
 
+	states, _ := queue.LoadAllStates(stateDir)
 	for i := range tasks {
-		st, _ := queue.LoadState(stateDir, tasks[i].ID)
+		st := states[tasks[i].ID]
 		if st == nil {
 			counts[queue.StatusPending]++
 		} else {
@@ -344,37 +736,284 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Done:      %d\n", counts[queue.StatusDone])
 	fmt.Printf("  Failed:    %d\n", counts[queue.StatusFailed])
 	fmt.Printf("  Cancelled: %d\n", counts[queue.StatusCancelled])
+	if n := counts[queue.StatusNeedsReview]; n > 0 {
+		fmt.Printf("  Review:    %d\n", n)
+	}
 	fmt.Printf("  Total:     %d\n", len(tasks))
 	if activeTask != "" {
 		fmt.Printf("  Active:    %s\n", activeTask)
 	}
 	if nextResume != nil {
-		fmt.Printf("  Next resume at: %s\n", nextResume.Format(time.RFC3339))
+		fmt.Printf("  Next resume at: %s\n", ui.Resume(*nextResume))
+	}
+
+	// Predict when the queue drains from how long earlier tasks ran.
+	if runs, err := estimate.Load(paths.DurationHistory()); err == nil && len(runs) > 0 {
+		rem := estimate.New(runs).Queue(tasks, states, time.Now())
+		if rem.Tasks > 0 {
+			// With nothing running or pending, work picks up at the next
+			// resume. Rate limits still to come are not predicted.
+			start := time.Now()
+			if activeTask == "" && counts[queue.StatusPending] == 0 && nextResume != nil && nextResume.After(start) {
+				start = *nextResume
+			}
+			fmt.Printf("  ETA:       %s (%s)\n", ui.At(start.Add(rem.Duration)), rem)
+		}
+	}
+
+	return nil
+}
+
+// runnerActive reports whether a runner holds the lock at lockPath, by
+// taking and releasing it, or with --read-only by testing it without
+// touching the lockfile.
+func runnerActive(lockPath string) (bool, error) {
+	if readOnly {
+		return lock.Held(lockPath)
+	}
+	lk, acquired, err := lock.TryLock(lockPath)
+	if err != nil {
+		return false, err
+	}
+	if acquired {
+		lk.Release()
+	}
+	return !acquired, nil
+}
+
+// ── show ────────────────────────────────────────────────────────────────
+
+var showCmd = &cobra.Command{
+	Use:   "show [task-id]",
+	Short: "Show a task's details and attempt timeline",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runShow,
+}
+
+func runShow(cmd *cobra.Command, args []string) error {
+	taskID := args[0]
+
+	if !readOnly {
+		if err := paths.EnsureDirs(); err != nil {
+			return fmt.Errorf("create directories: %w", err)
+		}
+	}
+
+	stateDir := paths.StateDir()
+
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
+	if err != nil {
+		return fmt.Errorf("load tasks: %w", err)
+	}
+
+	var task *queue.Task
+	for i := range tasks {
+		if tasks[i].ID == taskID {
+			task = &tasks[i]
+			break
+		}
+	}
+	if task == nil {
+		return fmt.Errorf("Task '%s' not found", taskID)
+	}
+
+	st, err := queue.LoadState(stateDir, taskID)
+	if err != nil {
+		return fmt.Errorf("load state for %s: %w", taskID, err)
+	}
+	if st == nil {
+		st = &queue.TaskState{ID: taskID, Status: queue.StatusPending}
+	}
+
+	fmt.Printf("ID:          %s\n", task.ID)
+	fmt.Printf("Title:       %s\n", task.Title)
+	fmt.Printf("Status:      %s\n", ui.Status(st.Status, 0))
+	fmt.Printf("Priority:    %d\n", task.Priority)
+	fmt.Printf("Working dir: %s\n", task.WorkingDir)
+	fmt.Printf("Source:      %s\n", task.Source)
+	init, err := queue.LoadInit(stateDir, task.ID)
+	if err == nil && init == nil && task.Origin != nil {
+		// Not yet seen by the runner: the origin the task file declares.
+		init = &queue.TaskInit{ID: task.ID, CreatedAt: task.CreatedAt, Origin: task.Origin}
+	}
+	if err == nil && init != nil && init.Origin != nil {
+		fmt.Printf("Origin:      %s\n", describeOrigin(init))
+	}
+	fmt.Printf("Attempt:     %d/%d\n", st.Attempt, task.MaxRetries)
+	if st.TaskChecksum != "" && st.TaskChecksum != task.Checksum() && st.Status != queue.StatusDone {
+		fmt.Printf("Edited:      since attempt %d started (see task_change_action)\n", st.Attempt)
+	}
+	if st.ResumeAt != nil && st.Status == queue.StatusWaiting {
+		fmt.Printf("Resume at:   %s\n", ui.Resume(*st.ResumeAt))
+	}
+	if st.SessionID != "" {
+		fmt.Printf("Session:     %s\n", st.SessionID)
+	}
+	if deps := task.Dependencies(); len(deps) > 0 {
+		fmt.Printf("Depends on:  %s\n", strings.Join(deps, ", "))
+	}
+	if task.Verify != "" {
+		fmt.Printf("Verify:      %s\n", task.Verify)
+	}
+	if len(task.RetryOn) > 0 {
+		fmt.Printf("Retry on:    %s\n", strings.Join(task.RetryOn, "; "))
+	}
+	if len(task.FailFastOn) > 0 {
+		fmt.Printf("Fail fast:   %s\n", strings.Join(task.FailFastOn, "; "))
+	}
+	if task.NoHangDetection {
+		fmt.Printf("Hang check:  off\n")
+	} else if task.HangTimeout != "" {
+		fmt.Printf("Hang check:  after %s without output\n", task.HangTimeout)
+	}
+	if st.ReviewBranch != "" {
+		fmt.Printf("Review:      branch %s (worktree %s)\n", st.ReviewBranch, st.Worktree)
+	}
+	if task.MaxCostUSD > 0 || task.MaxTokens > 0 {
+		var limits []string
+		if task.MaxCostUSD > 0 {
+			limits = append(limits, fmt.Sprintf("$%.2f", task.MaxCostUSD))
+		}
+		if task.MaxTokens > 0 {
+			limits = append(limits, fmt.Sprintf("%d tokens", task.MaxTokens))
+		}
+		fmt.Printf("Budget:      %s\n", strings.Join(limits, ", "))
+	}
+	if st.Summary != "" {
+		fmt.Printf("\nSummary:\n%s\n", st.Summary)
+	}
+
+	fmt.Println()
+	if len(st.Attempts) == 0 {
+		fmt.Println("No attempts recorded.")
+		return nil
+	}
+
+	fmt.Printf("%-4s %-20s %-9s %-5s %-15s %-8s %-9s %s\n", "#", "Started", "Duration", "Exit", "Result", "Cost", "Tokens", "Reason")
+	fmt.Printf("%-4s %-20s %-9s %-5s %-15s %-8s %-9s %s\n", "---", "---", "---", "---", "---", "---", "---", "---")
+
+	var totalCost float64
+	var totalTokens int
+	for _, a := range st.Attempts {
+		cost := "-"
+		if a.CostUSD > 0 {
+			cost = fmt.Sprintf("$%.2f", a.CostUSD)
+			totalCost += a.CostUSD
+		}
+		tokens := "-"
+		if a.Tokens > 0 {
+			tokens = strconv.Itoa(a.Tokens)
+			totalTokens += a.Tokens
+		}
+		number := strconv.Itoa(a.Number)
+		if a.Superseded {
+			number = "(" + number + ")" // re-run as attempt Number
+		}
+		fmt.Printf("%-4s %-20s %-9s %-5d %-15s %-8s %-9s %s\n",
+			number,
+			ui.In(a.StartedAt).Format("2006-01-02 15:04:05"),
+			a.EndedAt.Sub(a.StartedAt).Round(time.Second),
+			a.ExitCode,
+			a.Result,
+			cost,
+			tokens,
+			a.Reason,
+		)
+	}
+	if totalCost > 0 {
+		fmt.Printf("\nTotal cost: $%.2f\n", totalCost)
+	}
+	if totalTokens > 0 {
+		fmt.Printf("Total tokens: %d\n", totalTokens)
 	}
 
 	return nil
 }
 
+// describeOrigin formats a task's origin for show, e.g. "cli by alice@laptop
+// on 2026-01-15 22:04:00 CET".
+func describeOrigin(init *queue.TaskInit) string {
+	o := init.Origin
+	s := o.Kind
+	if o.Ref != "" {
+		s += " " + o.Ref
+	}
+	if who := strings.Trim(o.User+"@"+o.Host, "@"); who != "" {
+		if o.Kind == queue.OriginFile {
+			s += ", first seen by " + who
+		} else {
+			s += " by " + who
+		}
+	}
+	return s + " on " + ui.At(init.CreatedAt)
+}
+
 // ── retry ───────────────────────────────────────────────────────────────
 
 var retryCmd = &cobra.Command{
 	Use:   "retry [task-id]",
 	Short: "Retry a failed or cancelled task",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runRetry,
+	Long: "Retry a failed or cancelled task. With --all-failed or --status, every task\n" +
+		"in that status is reset to pending in one command; --tag limits this to tagged\n" +
+		"tasks (alone, it retries every failed or cancelled task with the tag).",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRetry,
 }
 
+var (
+	retryAllFailed bool
+	retryStatus    string
+	retryTags      []string
+)
+
 func runRetry(cmd *cobra.Command, args []string) error {
-	taskID := args[0]
+	if retryAllFailed {
+		if retryStatus != "" && retryStatus != queue.StatusFailed {
+			return fmt.Errorf("--all-failed cannot be combined with --status %s", retryStatus)
+		}
+		retryStatus = queue.StatusFailed
+	}
+	bulk := retryStatus != "" || len(retryTags) > 0
+	if bulk && len(args) > 0 {
+		return fmt.Errorf("give either a task ID or --all-failed/--status/--tag, not both")
+	}
+	if !bulk && len(args) == 0 {
+		return fmt.Errorf("task ID required (or use --all-failed / --status / --tag)")
+	}
+	if retryStatus != "" && retryStatus != queue.StatusFailed && retryStatus != queue.StatusCancelled {
+		return fmt.Errorf("--status must be failed or cancelled (got '%s')", retryStatus)
+	}
 
-	if err := config.EnsureDirs(); err != nil {
+	if err := paths.EnsureDirs(); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
 
-	base := config.BaseDir()
-	lockPath := filepath.Join(base, "runner.lock")
-	stateDir := filepath.Join(base, "state")
-	controlDir := filepath.Join(base, "control")
+	lockPath := paths.LockPath()
+	stateDir := paths.StateDir()
+	controlDir := paths.ControlDir()
+
+	// Resolve the target task IDs.
+	var taskIDs []string
+	if bulk {
+		var err error
+		sel := taskSelector{
+			statuses: []string{queue.StatusFailed, queue.StatusCancelled},
+			tags:     retryTags,
+		}
+		if retryStatus != "" {
+			sel.statuses = []string{retryStatus}
+		}
+		taskIDs, err = selectTaskIDs(sel)
+		if err != nil {
+			return err
+		}
+		if len(taskIDs) == 0 {
+			fmt.Println("No matching tasks to retry")
+			return nil
+		}
+	} else {
+		taskIDs = []string{args[0]}
+	}
 
 	// Try non-blocking lock acquire.
 	lk, acquired, err := lock.TryLock(lockPath)
@@ -386,41 +1025,40 @@ func runRetry(cmd *cobra.Command, args []string) error {
 		// No runner is active; apply directly.
 		defer lk.Release()
 
-		st, err := queue.LoadState(stateDir, taskID)
-		if err != nil {
-			return fmt.Errorf("load state for %s: %w", taskID, err)
-		}
-		if st == nil {
-			return fmt.Errorf("no state found for task %s", taskID)
-		}
+		for _, taskID := range taskIDs {
+			st, err := queue.LoadState(stateDir, taskID)
+			if err != nil {
+				return fmt.Errorf("load state for %s: %w", taskID, err)
+			}
+			if st == nil {
+				return fmt.Errorf("no state found for task %s", taskID)
+			}
 
-		if st.Status != queue.StatusFailed && st.Status != queue.StatusCancelled {
-			return fmt.Errorf("Task '%s' is %s, only failed/cancelled tasks can be retried", taskID, st.Status)
-		}
+			if st.Status != queue.StatusFailed && st.Status != queue.StatusCancelled {
+				return fmt.Errorf("Task '%s' is %s, only failed/cancelled tasks can be retried", taskID, st.Status)
+			}
 
-		st.Status = queue.StatusPending
-		st.Attempt = 0
-		st.ResumeAt = nil
+			st.Status = queue.StatusPending
+			st.Attempt = 0
+			st.ResumeAt = nil
 
-		if err := queue.SaveState(stateDir, st); err != nil {
-			return fmt.Errorf("save state for %s: %w", taskID, err)
-		}
+			if err := queue.SaveState(stateDir, st); err != nil {
+				return fmt.Errorf("save state for %s: %w", taskID, err)
+			}
 
-		fmt.Printf("Reset task '%s' to pending (attempt 0)\n", taskID)
+			fmt.Printf("Reset task '%s' to pending (attempt 0)\n", taskID)
+		}
 		return nil
 	}
 
-	// Runner is active; queue the retry command.
-	cc := queue.ControlCommand{
-		Op:          "retry",
-		TaskID:      taskID,
-		RequestedAt: time.Now().UTC(),
-	}
-	if err := queue.AppendCommand(controlDir, cc); err != nil {
-		return fmt.Errorf("queue retry command: %w", err)
-	}
+	// Runner is active; queue a retry command per task.
+	for _, taskID := range taskIDs {
+		if err := queue.AppendCommand(controlDir, queue.NewControlCommand("retry", taskID)); err != nil {
+			return fmt.Errorf("queue retry command: %w", err)
+		}
 
-	fmt.Printf("Queued retry for %s\n", taskID)
+		fmt.Printf("Queued retry for %s\n", taskID)
+	}
 	return nil
 }
 
@@ -429,21 +1067,68 @@ func runRetry(cmd *cobra.Command, args []string) error {
 var cancelCmd = &cobra.Command{
 	Use:   "cancel [task-id]",
 	Short: "Cancel a pending, waiting, or failed task",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runCancel,
+	Long: "Cancel a pending, waiting, or failed task. With --all-pending,\n" +
+		"--priority-below, --dir, or --tag, every matching task is cancelled; filters\n" +
+		"combine, so --all-pending --dir ./api cancels only that project's pending tasks.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCancel,
 }
 
+var (
+	cancelAllPending    bool
+	cancelPriorityBelow int
+	cancelDir           string
+	cancelTags          []string
+)
+
 func runCancel(cmd *cobra.Command, args []string) error {
-	taskID := args[0]
+	sel := taskSelector{
+		statuses:      []string{queue.StatusPending, queue.StatusWaiting, queue.StatusFailed},
+		priorityBelow: cancelPriorityBelow,
+		tags:          cancelTags,
+	}
+	if cancelAllPending {
+		sel.statuses = []string{queue.StatusPending}
+	}
+	if cancelDir != "" {
+		abs, err := filepath.Abs(cancelDir)
+		if err != nil {
+			return fmt.Errorf("resolve --dir: %w", err)
+		}
+		sel.dir = abs
+	}
 
-	if err := config.EnsureDirs(); err != nil {
+	bulk := cancelAllPending || cancelPriorityBelow > 0 || cancelDir != "" || len(cancelTags) > 0
+	if bulk && len(args) > 0 {
+		return fmt.Errorf("give either a task ID or filter flags, not both")
+	}
+	if !bulk && len(args) == 0 {
+		return fmt.Errorf("task ID required (or use --all-pending / --priority-below / --dir / --tag)")
+	}
+
+	if err := paths.EnsureDirs(); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
 
-	base := config.BaseDir()
-	lockPath := filepath.Join(base, "runner.lock")
-	stateDir := filepath.Join(base, "state")
-	controlDir := filepath.Join(base, "control")
+	lockPath := paths.LockPath()
+	stateDir := paths.StateDir()
+	controlDir := paths.ControlDir()
+
+	// Resolve the target task IDs.
+	var taskIDs []string
+	if bulk {
+		var err error
+		taskIDs, err = selectTaskIDs(sel)
+		if err != nil {
+			return err
+		}
+		if len(taskIDs) == 0 {
+			fmt.Println("No matching tasks to cancel")
+			return nil
+		}
+	} else {
+		taskIDs = []string{args[0]}
+	}
 
 	// Try non-blocking lock acquire.
 	lk, acquired, err := lock.TryLock(lockPath)
@@ -455,55 +1140,71 @@ func runCancel(cmd *cobra.Command, args []string) error {
 		// No runner is active; apply directly.
 		defer lk.Release()
 
-		st, err := queue.LoadState(stateDir, taskID)
-		if err != nil {
-			return fmt.Errorf("load state for %s: %w", taskID, err)
-		}
-		if st == nil {
-			// No state means pending; create and set to cancelled.
-			st = &queue.TaskState{
-				ID:     taskID,
-				Status: queue.StatusPending,
+		snap := queue.NewSnapshot(paths.TrashDir(), "cancel", time.Now())
+		for _, taskID := range taskIDs {
+			if err := cancelTaskState(stateDir, taskID, snap); err != nil {
+				return err
 			}
 		}
+		return nil
+	}
 
-		switch st.Status {
-		case queue.StatusDone:
-			fmt.Printf("Task '%s' already completed\n", taskID)
-			return nil
-		case queue.StatusCancelled:
-			// idempotent no-op
-			return nil
-		case queue.StatusRunning:
-			fmt.Printf("Task '%s' is currently running. It will be marked cancelled after it completes or on next queue reload.\n", taskID)
-			return nil
-		case queue.StatusPending, queue.StatusWaiting, queue.StatusFailed:
-			if !queue.ValidTransition(st.Status, queue.StatusCancelled) {
-				return fmt.Errorf("cannot transition task %s from %s to cancelled", taskID, st.Status)
-			}
-			st.Status = queue.StatusCancelled
-			if err := queue.SaveState(stateDir, st); err != nil {
-				return fmt.Errorf("save state for %s: %w", taskID, err)
-			}
-			fmt.Printf("Cancelled task '%s'\n", taskID)
-			return nil
-		default:
-			return fmt.Errorf("task %s has unexpected status %q", taskID, st.Status)
+	// Runner is active; queue a cancel command per task.
+	for _, taskID := range taskIDs {
+		if err := queue.AppendCommand(controlDir, queue.NewControlCommand("cancel", taskID)); err != nil {
+			return fmt.Errorf("queue cancel command: %w", err)
 		}
+
+		fmt.Printf("Queued cancel for %s\n", taskID)
 	}
+	return nil
+}
 
-	// Runner is active; queue the cancel command.
-	cc := queue.ControlCommand{
-		Op:          "cancel",
-		TaskID:      taskID,
-		RequestedAt: time.Now().UTC(),
+// cancelTaskState cancels a single task directly in the state directory,
+// recording its prior state in snap for 'undo'. The caller must hold the
+// runner lock.
+func cancelTaskState(stateDir, taskID string, snap *queue.Snapshot) error {
+	st, err := queue.LoadState(stateDir, taskID)
+	if err != nil {
+		return fmt.Errorf("load state for %s: %w", taskID, err)
 	}
-	if err := queue.AppendCommand(controlDir, cc); err != nil {
-		return fmt.Errorf("queue cancel command: %w", err)
+	if st == nil {
+		// No state means pending; create and set to cancelled.
+		st = &queue.TaskState{
+			ID:     taskID,
+			Status: queue.StatusPending,
+		}
 	}
 
-	fmt.Printf("Queued cancel for %s\n", taskID)
-	return nil
+	switch st.Status {
+	case queue.StatusDone:
+		fmt.Printf("Task '%s' already completed\n", taskID)
+		return nil
+	case queue.StatusCancelled:
+		// idempotent no-op
+		return nil
+	case queue.StatusRunning:
+		fmt.Printf("Task '%s' is currently running. It will be marked cancelled after it completes or on next queue reload.\n", taskID)
+		return nil
+	case queue.StatusNeedsReview:
+		fmt.Printf("Task '%s' is awaiting review; use 'reject %s' to discard it\n", taskID, taskID)
+		return nil
+	case queue.StatusPending, queue.StatusWaiting, queue.StatusFailed:
+		if !queue.ValidTransition(st.Status, queue.StatusCancelled) {
+			return fmt.Errorf("cannot transition task %s from %s to cancelled", taskID, st.Status)
+		}
+		if err := snap.Copy(stateDir, taskID, st.Status); err != nil {
+			return fmt.Errorf("snapshot %s: %w", taskID, err)
+		}
+		st.Status = queue.StatusCancelled
+		if err := queue.SaveState(stateDir, st); err != nil {
+			return fmt.Errorf("save state for %s: %w", taskID, err)
+		}
+		fmt.Printf("Cancelled task '%s'\n", taskID)
+		return nil
+	default:
+		return fmt.Errorf("task %s has unexpected status %q", taskID, st.Status)
+	}
 }
 
 // ── clean ───────────────────────────────────────────────────────────────
@@ -511,32 +1212,53 @@ func runCancel(cmd *cobra.Command, args []string) error {
 var cleanCmd = &cobra.Command{
 	Use:   "clean",
 	Short: "Clean orphan temp files and rotated log backups",
-	RunE:  runClean,
+	Long: "Clean orphan temp files and rotated log backups. With --orphan-state, also\n" +
+		"remove the state, init record and logs of tasks that no longer exist in any\n" +
+		"task file, once they are older than --older-than (default: state_retention).",
+	RunE: runClean,
 }
 
+var (
+	cleanOrphanState bool
+	cleanOlderThan   time.Duration
+	cleanDryRun      bool
+)
+
 func runClean(cmd *cobra.Command, args []string) error {
-	if err := config.EnsureDirs(); err != nil {
+	if err := paths.EnsureDirs(); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
+	if cleanOrphanState {
+		retention := cleanOlderThan
+		if !cmd.Flags().Changed("older-than") {
+			cfg, err := paths.Load(nil)
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+			retention = cfg.StateRetention
+		}
+		if err := cleanOrphans(retention); err != nil {
+			return err
+		}
+	}
 
-	base := config.BaseDir()
 	cleanDirs := []string{
-		base,
-		filepath.Join(base, "state"),
-		filepath.Join(base, "tasks"),
-		filepath.Join(base, "control"),
+		paths.Home,
+		paths.StateDir(),
+		paths.TasksDir(),
+		paths.ControlDir(),
 	}
 	if projectTasksDir := resolveProjectDir(); projectTasksDir != "" {
 		cleanDirs = append(cleanDirs, projectTasksDir)
 	}
 
-	n, err := fileutil.CleanOrphanTemps(cleanDirs)
+	n, skipped, err := fileutil.CleanOrphanTemps(cleanDirs)
 	if err != nil {
 		return fmt.Errorf("clean orphan temps: %w", err)
 	}
 
 	// Clean rotated log backups (*.log.N).
-	logDir := filepath.Join(base, "logs")
+	logDir := paths.LogsDir()
 	rotated := 0
 	entries, readErr := os.ReadDir(logDir)
 	if readErr == nil {
@@ -555,6 +1277,65 @@ func runClean(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Cleaned artifacts: %d temp files, %d log files\n", n, rotated)
+	if skipped > 0 {
+		fmt.Printf("Kept %d temp files whose writer still looks alive (removed once older than 24h)\n", skipped)
+	}
+	return nil
+}
+
+// cleanOrphans removes the records of tasks deleted from their sources
+// that have not changed for retention. Unlike the runner's automatic
+// cleanup it includes tasks initialized before sources were recorded, which
+// it can only judge against the global and current project task dirs.
+func cleanOrphans(retention time.Duration) error {
+	lk, acquired, err := lock.TryLock(paths.LockPath())
+	if err != nil {
+		return fmt.Errorf("probe lock: %w", err)
+	}
+	if !acquired {
+		return fmt.Errorf("a runner is active; --orphan-state needs it stopped")
+	}
+	defer lk.Release()
+
+	tasks, err := queue.LoadTasks(paths.TasksDir(), resolveProjectDir())
+	if err != nil {
+		return fmt.Errorf("load tasks: %w", err)
+	}
+	orphans, err := queue.FindOrphans(paths.StateDir(), tasks)
+	if err != nil {
+		return err
+	}
+	kept, removed := 0, 0
+	snap := queue.NewSnapshot(paths.TrashDir(), "remove", time.Now())
+	for _, o := range orphans {
+		if time.Since(o.ModTime) < retention {
+			kept++
+			continue
+		}
+		from := o.Source
+		switch {
+		case o.Unverified:
+			from = "unknown source"
+		case from == "":
+			from = "exec"
+		}
+		if cleanDryRun {
+			fmt.Printf("Would remove %s (%s, last changed %s)\n", o.ID, from, ui.At(o.ModTime))
+			continue
+		}
+		if _, err := snap.Move(paths.StateDir(), paths.LogsDir(), o.ID); err != nil {
+			return fmt.Errorf("remove state of %s: %w", o.ID, err)
+		}
+		removed++
+		fmt.Printf("Removed %s (%s)\n", o.ID, from)
+	}
+	if !cleanDryRun {
+		fmt.Printf("Removed the state of %d deleted task(s)", removed)
+		if kept > 0 {
+			fmt.Printf("; kept %d changed within %s", kept, retention)
+		}
+		fmt.Println()
+	}
 	return nil
 }
 
@@ -579,11 +1360,11 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if err := config.EnsureDirs(); err != nil {
+	if err := paths.EnsureDirs(); err != nil {
 		return fmt.Errorf("create directories: %w", err)
 	}
 
-	if err := config.SetConfigValue(key, value); err != nil {
+	if err := paths.SetConfigValue(key, value); err != nil {
 		return fmt.Errorf("set config: %w", err)
 	}
 
@@ -601,7 +1382,7 @@ var configGetCmd = &cobra.Command{
 func runConfigGet(cmd *cobra.Command, args []string) error {
 	key := args[0]
 
-	val, err := config.GetConfigValue(key)
+	val, err := paths.GetConfigValue(key)
 	if err != nil {
 		return err
 	}
@@ -619,7 +1400,7 @@ var configListCmd = &cobra.Command{
 }
 
 func runConfigList(cmd *cobra.Command, args []string) error {
-	values, err := config.ListConfig()
+	values, err := paths.ListConfig()
 	if err != nil {
 		return fmt.Errorf("list config: %w", err)
 	}
@@ -643,10 +1424,44 @@ var configPathCmd = &cobra.Command{
 	Use:   "path",
 	Short: "Print the config file path",
 	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(filepath.Join(config.BaseDir(), "config.yaml"))
+		fmt.Println(paths.ConfigFile())
 	},
 }
 
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check config file and environment values, showing each effective value and its source",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigValidate,
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	settings, unknown, err := paths.Check()
+	if err != nil {
+		return fmt.Errorf("validate config: %w", err)
+	}
+
+	fmt.Printf("Config file: %s\n\n", paths.ConfigFile())
+	invalid := 0
+	for _, s := range settings {
+		fmt.Printf("%-25s = %-20s (source: %s)\n", s.Key, s.Value, s.Source)
+		if s.Err != nil {
+			fmt.Printf("  error: %v\n", s.Err)
+			invalid++
+		}
+	}
+	for _, k := range unknown {
+		fmt.Printf("warning: unknown key %q in config file\n", k)
+	}
+
+	if invalid > 0 {
+		fmt.Printf("\n%d invalid value(s).\n", invalid)
+		os.Exit(runner.ExitConfig)
+	}
+	fmt.Println("\nConfig is valid.")
+	return nil
+}
+
 // resolveSource determines which configuration layer provided the effective
 // value for a key: env, file, or default.
 func resolveSource(key string) string {
@@ -656,7 +1471,7 @@ func resolveSource(key string) string {
 	}
 
 	// Check if the key is set in the config file by attempting to read raw.
-	data, err := os.ReadFile(filepath.Join(config.BaseDir(), "config.yaml"))
+	data, err := os.ReadFile(paths.ConfigFile())
 	if err == nil && len(data) > 0 {
 		var raw map[string]interface{}
 		if yaml.Unmarshal(data, &raw) == nil {
@@ -669,6 +1484,122 @@ func resolveSource(key string) string {
 	return "default"
 }
 
+// ── service ─────────────────────────────────────────────────────────────
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage the background service (systemd on Linux, launchd on macOS)",
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and start a user service running 'run --watch'",
+	Long: "Install and start a per-user service that runs 'claude-autopilot run --watch --yes'\n" +
+		"and restarts it after abnormal exits. PATH, HOME, and CLAUDE_AUTOPILOT_* variables\n" +
+		"from the current shell are copied into the service environment. Pass --project-dir\n" +
+		"to have the service also run that project's local tasks. With --queue the service\n" +
+		"runs that queue and is named after it, so each queue can have its own; pass the\n" +
+		"same --queue to 'service uninstall' and 'service status'.",
+	Args: cobra.NoArgs,
+	RunE: runServiceInstall,
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the user service",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := service.Uninstall(queueName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed service %s\n", path)
+		return nil
+	},
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the user service status",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := service.Status(queueName)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+		return nil
+	},
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate claude-autopilot binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	runArgs := []string{"run", "--watch", "--yes"}
+	if projectDir != "" {
+		abs, err := filepath.Abs(projectDir)
+		if err != nil {
+			return fmt.Errorf("resolve --project-dir: %w", err)
+		}
+		runArgs = append(runArgs, "--project-dir", abs)
+	}
+	if stateDirFlag != "" {
+		abs, err := filepath.Abs(stateDirFlag)
+		if err != nil {
+			return fmt.Errorf("resolve --state-dir: %w", err)
+		}
+		runArgs = append(runArgs, "--state-dir", abs)
+	}
+	if queueName != "" {
+		runArgs = append(runArgs, "--queue", queueName)
+	}
+
+	env := map[string]string{
+		"PATH": os.Getenv("PATH"),
+		"HOME": os.Getenv("HOME"),
+	}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(k, "CLAUDE_AUTOPILOT_") {
+			env[k] = v
+		}
+	}
+	for _, k := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME"} {
+		if v := os.Getenv(k); v != "" {
+			env[k] = v
+		}
+	}
+
+	home, _ := os.UserHomeDir()
+	path, err := service.Install(service.Spec{
+		Executable: exe,
+		Args:       runArgs,
+		Env:        env,
+		LogPath:    filepath.Join(paths.LogsDir(), "service.log"),
+		WorkingDir: home,
+		Queue:      queueName,
+	})
+	if err != nil {
+		if path != "" {
+			return fmt.Errorf("wrote %s but could not start the service: %w", path, err)
+		}
+		return err
+	}
+
+	fmt.Printf("Installed and started service %s\n", path)
+	fmt.Printf("Logs: %s\n", filepath.Join(paths.LogsDir(), "service.log"))
+	return nil
+}
+
 // ── helpers ─────────────────────────────────────────────────────────────
 
 // resolveProjectDir returns the effective project-local task directory.
@@ -695,34 +1626,169 @@ func resolveProjectDir() string {
 func init() {
 	// Global flags.
 	rootCmd.PersistentFlags().StringVar(&projectDir, "project-dir", "", "project-local task directory (default: cwd)")
+	rootCmd.PersistentFlags().StringVar(&stateDirFlag, "state-dir", "", "directory for tasks, state, logs and config (default: $CLAUDE_AUTOPILOT_HOME or ~/.claude-autopilot)")
+	rootCmd.PersistentFlags().StringVar(&queueName, "queue", "", "use a named queue with its own tasks, state, logs and lock (default: $CLAUDE_AUTOPILOT_QUEUE)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress informational output")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colored output (also honors NO_COLOR)")
+	rootCmd.PersistentFlags().BoolVar(&useUTC, "utc", false, "show times in UTC instead of local time")
 
 	// add command flags.
 	addCmd.Flags().StringVar(&addDir, "dir", "", "working directory for the task (required)")
 	addCmd.Flags().StringVar(&addTitle, "title", "", "task title (default: first 60 chars of prompt)")
-	addCmd.Flags().IntVar(&addPriority, "priority", 10, "task priority (lower = higher priority)")
+	addCmd.Flags().IntVar(&addPriority, "priority", 0, "task priority, lower runs first (default: default_priority, 10)")
+	addCmd.Flags().IntVar(&addMaxRetries, "max-retries", 0, "attempts before the task is marked failed (default: default_max_retries, 5)")
 	addCmd.Flags().StringVar(&addModel, "model", "", "Claude model to use")
+	addCmd.Flags().StringVar(&addAgent, "agent", "", "coding agent CLI to run the task with: claude (default), gemini, aider or one from agents.yaml")
+	addCmd.Flags().StringVar(&addContainer, "container", "", "run the agent in this docker/podman image with --dir mounted")
+	addCmd.Flags().StringVar(&addContainerNet, "container-network", "", "network for the container, e.g. none")
+	addCmd.Flags().Float64Var(&addContainerCPUs, "container-cpus", 0, "CPU limit for the container")
+	addCmd.Flags().StringVar(&addContainerMem, "container-memory", "", "memory limit for the container, e.g. 4g")
+	addCmd.Flags().IntVar(&addNice, "nice", 0, "run the agent at this niceness, 0-19")
+	addCmd.Flags().StringVar(&addHangTimeout, "hang-timeout", "", "kill the agent after this long without output, e.g. 45m (default: hang_timeout)")
+	addCmd.Flags().BoolVar(&addNoHang, "no-hang-detection", false, "never kill the agent for producing no output")
+	addCmd.Flags().StringVar(&addMaxCPUTime, "max-cpu-time", "", "kill the agent after this much CPU time, e.g. 30m")
+	addCmd.Flags().StringVar(&addMaxMemory, "max-memory", "", "memory limit for the agent process, e.g. 8g")
+	addCmd.Flags().StringVar(&addClaudeVersion, "claude-version", "", "run with the pinned claude CLI of this version (see 'compat list')")
 	addCmd.Flags().BoolVar(&addSkipPermissions, "skip-permissions", false, "skip permission prompts")
 	addCmd.Flags().StringVar(&addID, "id", "", "task ID (default: auto-generated)")
+	addCmd.Flags().StringSliceVar(&addTags, "tag", nil, "tag the task (repeatable or comma-separated)")
+	addCmd.Flags().StringArrayVar(&addContext, "context", nil, "context file, directory or glob, relative to --dir (repeatable)")
+	addCmd.Flags().StringArrayVar(&addContextCommands, "context-command", nil, "command whose output is prepended to the prompt (repeatable)")
+	addCmd.Flags().StringArrayVar(&addFlags, "flag", nil, "extra Claude CLI flag, e.g. --flag=--verbose (repeatable)")
+	addCmd.Flags().StringVar(&addResumeStrategy, "resume-strategy", "", "native, reprompt or fresh")
+	addCmd.Flags().StringSliceVar(&addDependsOn, "depends-on", nil, "task that must be done first (repeatable or comma-separated)")
+	addCmd.Flags().StringVar(&addGroup, "concurrency-group", "", "never run at the same time as other tasks of this group")
+	addCmd.Flags().BoolVar(&addExclusive, "exclusive", false, "run alone, after other instances finish their running tasks")
+	addCmd.Flags().StringSliceVar(&addRunDays, "run-days", nil, "weekdays the task may start on, e.g. sat,sun")
+	addCmd.Flags().StringSliceVar(&addSkipDates, "skip-date", nil, "date (YYYY-MM-DD) the task does not start on (repeatable or comma-separated)")
+	addCmd.Flags().StringVar(&addVerify, "verify", "", "shell command that must succeed for a completion to count")
+	addCmd.Flags().StringArrayVar(&addRetryOn, "retry-on", nil, "output pattern that makes a failure retried (repeatable)")
+	addCmd.Flags().StringArrayVar(&addFailFastOn, "fail-fast-on", nil, "output pattern that fails the task without retrying (repeatable)")
+	addCmd.Flags().StringVar(&addTeeOutput, "tee-output", "", "also copy the raw output to this file, relative to --dir")
+	addCmd.Flags().StringArrayVar(&addArtifacts, "artifact", nil, "output file or glob to keep on completion (repeatable)")
+	addCmd.Flags().Float64Var(&addMaxCostUSD, "max-cost-usd", 0, "fail the task once its attempts cost this much")
+	addCmd.Flags().IntVar(&addMaxTokens, "max-tokens", 0, "fail the task once its attempts use this many tokens")
+	addCmd.Flags().BoolVar(&addExportSummary, "export-summary", false, "keep the final message for {{task:<id>.summary}}")
+	addCmd.Flags().BoolVar(&addReview, "review", false, "work in a worktree and wait for approval")
+	addCmd.Flags().BoolVar(&addPlan, "plan", false, "plan the work and queue it as subtasks")
 	_ = addCmd.MarkFlagRequired("dir")
 
 	// run command flags.
 	runCmd.Flags().BoolVarP(&runYes, "yes", "y", false, "skip first-run safety prompt")
+	runCmd.Flags().BoolVar(&runWatch, "watch", false, "keep running when the queue is empty and pick up new tasks as they are added")
+	runCmd.Flags().StringSliceVar(&runTags, "tag", nil, "only run tasks with this tag (repeatable)")
+	runCmd.Flags().StringSliceVar(&runOnly, "only", nil, "only run these task IDs or glob patterns (repeatable)")
+	runCmd.Flags().DurationVar(&runMaxWait, "max-wait", 0, "exit with code 5 instead of waiting longer than this for a rate-limit reset (e.g. 3h)")
+	runCmd.Flags().StringSliceVar(&runExclude, "exclude", nil, "skip these task IDs or glob patterns for this run (repeatable)")
+	runCmd.Flags().BoolVar(&runEvents, "events", false, "write NDJSON lifecycle events to stdout (human output goes to stderr)")
+	runCmd.Flags().StringVar(&runEventsFile, "events-file", "", "append NDJSON lifecycle events to this file")
+	runCmd.Flags().BoolVar(&runIfNotRun, "if-not-running", false, "exit 0 at once if another instance is already running (for cron)")
+	runCmd.Flags().StringVar(&runTeeDir, "tee-dir", "", "also copy each task's raw output to <dir>/<task-id>.log (a task's tee_output wins)")
+	runCmd.MarkFlagsMutuallyExclusive("events", "events-file")
+
+	// exec command flags.
+	execCmd.Flags().StringVar(&execDir, "dir", ".", "working directory for the task")
+	execCmd.Flags().StringVar(&execModel, "model", "", "Claude model to use")
+	execCmd.Flags().BoolVar(&execSkipPermissions, "skip-permissions", false, "skip permission prompts")
+	execCmd.Flags().IntVar(&execMaxRetries, "max-retries", 0, "attempts before giving up (default: default_max_retries, 5)")
+	execCmd.Flags().BoolVarP(&execYes, "yes", "y", false, "skip first-run safety prompt")
+
+	// list command flags.
+	listCmd.Flags().StringSliceVar(&listTags, "tag", nil, "only list tasks with this tag (repeatable)")
+	listCmd.Flags().StringSliceVar(&listStatuses, "status", nil, "only list tasks in this status (repeatable or comma-separated)")
+	listCmd.Flags().StringVar(&listSort, "sort", listSortPriority, "order rows by priority (scheduler order), created, or duration (longest first)")
+	listCmd.Flags().StringVar(&listDir, "dir", "", "only list tasks whose working_dir is this directory or inside it")
+	listCmd.Flags().IntVar(&listLimit, "limit", 0, "show at most N tasks (0 = all)")
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "", "output format: wide adds attempts, last run, next resume, model and working dir")
+	for _, c := range []*cobra.Command{listCmd, statusCmd, showCmd} {
+		c.Flags().BoolVar(&readOnly, "read-only", false, "write nothing (no data directories or lockfile), for read-only mounts")
+	}
+
+	// retry command flags.
+	retryCmd.Flags().BoolVar(&retryAllFailed, "all-failed", false, "retry every failed task")
+	retryCmd.Flags().StringVar(&retryStatus, "status", "", "retry every task in this status (failed or cancelled)")
+	retryCmd.Flags().StringSliceVar(&retryTags, "tag", nil, "retry failed/cancelled tasks with this tag (repeatable)")
+
+	// cancel command flags.
+	cancelCmd.Flags().BoolVar(&cancelAllPending, "all-pending", false, "cancel every pending task")
+	cancelCmd.Flags().IntVar(&cancelPriorityBelow, "priority-below", 0, "cancel tasks with lower priority than N (priority number greater than N)")
+	cancelCmd.Flags().StringVar(&cancelDir, "dir", "", "cancel tasks whose working_dir is this directory or inside it")
+	cancelCmd.Flags().StringSliceVar(&cancelTags, "tag", nil, "cancel tasks with this tag (repeatable)")
+
+	// review command flags.
+	reviewCmd.Flags().BoolVar(&reviewApprove, "approve", false, "merge the task's branch into its working_dir and mark it done")
+	reviewCmd.Flags().BoolVar(&reviewReject, "reject", false, "discard the task's branch and mark it failed")
+	reviewCmd.Flags().BoolVar(&reviewStat, "stat", false, "show only the diff summary")
+
+	// undo command flags.
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "list the operations that can be undone, newest first")
+
+	// logs command flags.
+	logsCmd.Flags().IntVar(&logsAttempt, "attempt", 0, "only print this attempt (default: all)")
+
+	// summary command flags.
+	summaryCmd.Flags().StringVar(&summaryRun, "run", "last", "run to summarize: last or a run ID")
+	summaryCmd.Flags().StringVar(&summaryFormat, "format", "md", "output format: md or json")
+	summaryCmd.Flags().BoolVar(&summaryList, "list", false, "list the recorded runs, newest first")
+
+	// graph command flags.
+	graphCmd.Flags().StringVar(&graphFormat, "format", queue.GraphDOT, "output format: dot or mermaid")
+
+	// decrypt command flags.
+	decryptCmd.Flags().StringVar(&decryptKey, "key", "", "key file to use instead of encryption_key_file")
+
+	// clean command flags.
+	cleanCmd.Flags().BoolVar(&cleanOrphanState, "orphan-state", false, "also remove state and logs of tasks deleted from their task files")
+	cleanCmd.Flags().DurationVar(&cleanOlderThan, "older-than", 0, "with --orphan-state, only remove tasks unchanged for this long (default: state_retention)")
+	cleanCmd.Flags().BoolVar(&cleanDryRun, "dry-run", false, "with --orphan-state, list what would be removed")
 
 	// config subcommands.
 	configCmd.AddCommand(configSetCmd)
 	configCmd.AddCommand(configGetCmd)
 	configCmd.AddCommand(configListCmd)
 	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	// service subcommands.
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStatusCmd)
+
+	// compat subcommands.
+	compatCmd.AddCommand(compatListCmd)
+	compatCmd.AddCommand(compatPinCmd)
+	compatCmd.AddCommand(compatUnpinCmd)
 
 	// Register all commands on root.
 	rootCmd.AddCommand(addCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(execCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(showCmd)
 	rootCmd.AddCommand(retryCmd)
 	rootCmd.AddCommand(cancelCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(summaryCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(graphCmd)
+
+	// task namespace: aliases of the task commands, sharing their flags,
+	// plus edit and remove.
+	taskCmd.AddCommand(taskAlias(addCmd), taskAlias(listCmd), taskAlias(showCmd), taskEditCmd,
+		taskRemoveCmd, taskAlias(retryCmd), taskAlias(cancelCmd))
+	rootCmd.AddCommand(taskCmd)
+	rootCmd.AddCommand(reloadCmd)
+	rootCmd.AddCommand(reviewCmd)
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(rejectCmd)
 	rootCmd.AddCommand(cleanCmd)
 	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(serviceCmd)
+	rootCmd.AddCommand(compatCmd)
+	rootCmd.AddCommand(keygenCmd)
+	rootCmd.AddCommand(decryptCmd)
 }
 
 // Execute runs the root command and returns any error. The caller (main.go)
@@ -739,11 +1805,18 @@ func Execute() error {
 	return nil
 }
 
-// exitError wraps an exit code for signaling from RunE handlers.
+// exitError wraps an exit code for signaling from RunE handlers. When err
+// is set, cobra prints it before Execute exits with code.
 type exitError struct {
 	code int
+	err  error
 }
 
 func (e *exitError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
 	return fmt.Sprintf("exit code %d", e.code)
 }
+
+func (e *exitError) Unwrap() error { return e.err }
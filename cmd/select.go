@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// taskSelector picks tasks for bulk retry/cancel. Every criterion that is
+// set must match; an empty selector matches every task.
+type taskSelector struct {
+	statuses      []string // task status is one of these
+	priorityBelow int      // priority number greater than this (lower priority); 0 = unset
+	dir           string   // absolute working_dir, or a parent of it
+	tags          []string // task carries any of these tags
+}
+
+// matches reports whether a task with the given status satisfies s.
+func (s taskSelector) matches(t *queue.Task, status string) bool {
+	if len(s.statuses) > 0 {
+		found := false
+		for _, st := range s.statuses {
+			if st == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if s.priorityBelow > 0 && t.Priority <= s.priorityBelow {
+		return false
+	}
+	if !t.HasAnyTag(s.tags) {
+		return false
+	}
+	if s.dir != "" {
+		rel, err := filepath.Rel(s.dir, t.WorkingDir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectTaskIDs returns the IDs of all queued tasks matching sel, in queue
+// order. Tasks without a state file count as pending.
+func selectTaskIDs(sel taskSelector) ([]string, error) {
+	stateDir := paths.StateDir()
+
+	tasks, err := queue.LoadTasksReadOnly(paths.TasksDir(), resolveProjectDir(), stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("load tasks: %w", err)
+	}
+
+	states, err := queue.LoadAllStates(stateDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: load states: %v\n", err)
+	}
+	var ids []string
+	for i := range tasks {
+		st := states[tasks[i].ID]
+		status := queue.StatusPending
+		if st != nil {
+			status = st.Status
+		}
+		if sel.matches(&tasks[i], status) {
+			ids = append(ids, tasks[i].ID)
+		}
+	}
+	return ids, nil
+}
+
+// list --sort orders.
+const (
+	listSortPriority = "priority"
+	listSortCreated  = "created"
+	listSortDuration = "duration"
+)
+
+// listRow is one task as shown by list.
+type listRow struct {
+	Index     int // position in scheduler order, 1-based
+	ID        string
+	Priority  int
+	Status    string
+	Title     string
+	Tags      string
+	CreatedAt time.Time
+	Duration  time.Duration // current or last attempt; -1 if never started
+
+	// Shown only by list -o wide.
+	Attempts   int
+	ResumeAt   *time.Time
+	WorkingDir string
+	Model      string
+}
+
+// sortListRows orders rows, which arrive in scheduler order, by the given
+// --sort key. Ties keep scheduler order.
+func sortListRows(rows []listRow, by string) {
+	switch by {
+	case listSortCreated:
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].CreatedAt.Before(rows[j].CreatedAt)
+		})
+	case listSortDuration:
+		sort.SliceStable(rows, func(i, j int) bool {
+			return rows[i].Duration > rows[j].Duration
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+func TestTaskSelector_Matches(t *testing.T) {
+	task := &queue.Task{ID: "a", Priority: 7, WorkingDir: "/work/api/service", Tags: []string{"backend", "auth"}}
+
+	tests := []struct {
+		name   string
+		sel    taskSelector
+		status string
+		want   bool
+	}{
+		{"empty selector", taskSelector{}, queue.StatusDone, true},
+		{"status match", taskSelector{statuses: []string{queue.StatusPending}}, queue.StatusPending, true},
+		{"status mismatch", taskSelector{statuses: []string{queue.StatusPending}}, queue.StatusFailed, false},
+		{"lower priority", taskSelector{priorityBelow: 5}, queue.StatusPending, true},
+		{"not lower priority", taskSelector{priorityBelow: 7}, queue.StatusPending, false},
+		{"dir parent", taskSelector{dir: "/work/api"}, queue.StatusPending, true},
+		{"dir sibling prefix", taskSelector{dir: "/work/ap"}, queue.StatusPending, false},
+		{"dir elsewhere", taskSelector{dir: "/work/web"}, queue.StatusPending, false},
+		{"any tag", taskSelector{tags: []string{"frontend", "auth"}}, queue.StatusPending, true},
+		{"no tag", taskSelector{tags: []string{"frontend"}}, queue.StatusPending, false},
+	}
+	for _, tt := range tests {
+		if got := tt.sel.matches(task, tt.status); got != tt.want {
+			t.Errorf("%s: matches = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortListRows(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := func() []listRow {
+		return []listRow{
+			{ID: "a", CreatedAt: base.Add(2 * time.Hour), Duration: -1},
+			{ID: "b", CreatedAt: base, Duration: time.Minute},
+			{ID: "c", CreatedAt: base.Add(time.Hour), Duration: time.Hour},
+		}
+	}
+	ids := func(rs []listRow) string {
+		var out []string
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	for by, want := range map[string]string{
+		listSortPriority: "a,b,c",
+		listSortCreated:  "b,c,a",
+		listSortDuration: "c,b,a",
+	} {
+		rs := rows()
+		sortListRows(rs, by)
+		if got := ids(rs); got != want {
+			t.Errorf("sort by %s = %s; want %s", by, got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/runs"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// ── summary ─────────────────────────────────────────────────────────────
+
+var summaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Show the end-of-run summary of a past run",
+	Long: "summary shows again the summary a run printed when it exited: each task's\n" +
+		"status, duration, retries and changes, failures, artifacts and the totals.\n" +
+		"--run picks the run by ID (its start time in UTC, e.g. 20260115-220400);\n" +
+		"the default is the last one. --list shows the recorded runs. The last 100\n" +
+		"runs are kept.",
+	Args: cobra.NoArgs,
+	RunE: runSummary,
+}
+
+var (
+	summaryRun    string
+	summaryFormat string
+	summaryList   bool
+)
+
+func runSummary(cmd *cobra.Command, args []string) error {
+	if summaryFormat != "md" && summaryFormat != "json" {
+		return fmt.Errorf("--format must be md or json (got %q)", summaryFormat)
+	}
+	recs, err := runs.Load(paths.RunHistory())
+	if err != nil {
+		return err
+	}
+
+	if summaryList {
+		if len(recs) == 0 {
+			fmt.Println("No runs recorded yet")
+			return nil
+		}
+		fmt.Printf("%-16s %-20s %-10s %s\n", "RUN", "STARTED", "ELAPSED", "DONE/FAILED/TOTAL")
+		for i := len(recs) - 1; i >= 0; i-- {
+			r := recs[i]
+			fmt.Printf("%-16s %-20s %-10s %d/%d/%d\n", r.ID, ui.In(r.StartedAt).Format("2006-01-02 15:04:05"),
+				r.EndedAt.Sub(r.StartedAt).Truncate(time.Second), r.Totals.Done, r.Totals.Failed, r.Totals.Total)
+		}
+		return nil
+	}
+
+	rec, err := runs.Find(recs, summaryRun)
+	if err != nil {
+		return err
+	}
+	if summaryFormat == "json" {
+		data, err := json.MarshalIndent(rec, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal summary: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	fmt.Print(rec.Markdown())
+	return nil
+}
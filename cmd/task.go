@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/spf13/cobra"
+)
+
+// ── task ────────────────────────────────────────────────────────────────
+
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Manage tasks: add, list, show, edit, remove, retry, cancel",
+	Long: "task groups the commands that work on individual tasks. add, list, show,\n" +
+		"retry and cancel are the same as the top-level commands of those names,\n" +
+		"flags included; edit and remove exist only here.",
+}
+
+// taskAlias returns a copy of the top-level command c for the task
+// namespace. It shares c's flags, so they must be defined first.
+func taskAlias(c *cobra.Command) *cobra.Command {
+	alias := &cobra.Command{
+		Use:   c.Use,
+		Short: c.Short,
+		Long:  c.Long,
+		Args:  c.Args,
+		RunE:  c.RunE,
+	}
+	alias.Flags().AddFlagSet(c.Flags())
+	return alias
+}
+
+var taskEditCmd = &cobra.Command{
+	Use:   "edit <task-id>",
+	Short: "Open a task's file in $VISUAL or $EDITOR",
+	Long: "edit opens the file that defines task-id in $VISUAL, $EDITOR or vi\n" +
+		"(notepad on Windows), then loads the queue and reports any validation\n" +
+		"error the edit introduced. For a multi-document or pipeline file the\n" +
+		"whole file is opened.",
+	Args: cobra.ExactArgs(1),
+	RunE: runTaskEdit,
+}
+
+func runTaskEdit(cmd *cobra.Command, args []string) error {
+	task, err := loadTask(args[0])
+	if err != nil {
+		return err
+	}
+	if task.Source == "" {
+		return fmt.Errorf("task '%s' has no task file", task.ID)
+	}
+	file := queue.SourceFile(task.Source)
+
+	editor := strings.Fields(firstNonEmpty(os.Getenv("VISUAL"), os.Getenv("EDITOR")))
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+		if runtime.GOOS == "windows" {
+			editor = []string{"notepad"}
+		}
+	}
+	ed := exec.Command(editor[0], append(editor[1:], file)...)
+	ed.Stdin, ed.Stdout, ed.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := ed.Run(); err != nil {
+		return fmt.Errorf("run editor: %w", err)
+	}
+
+	if _, err := queue.LoadTasks(paths.TasksDir(), resolveProjectDir()); err != nil {
+		return fmt.Errorf("%s no longer loads; fix it with 'task edit %s': %w", file, task.ID, err)
+	}
+	fmt.Printf("Saved %s\n", file)
+	return nil
+}
+
+var taskRemoveCmd = &cobra.Command{
+	Use:   "remove <task-id>",
+	Short: "Delete a task's file, state and logs (undo restores them)",
+	Long: "remove takes task-id out of the queue: its task file, state and logs move\n" +
+		"to the trash, so 'undo' brings them back. A task defined in a file with\n" +
+		"other tasks (multi-document or pipeline) must be removed with 'task edit'.\n" +
+		"While a runner is active the removal is queued for it, and a running task\n" +
+		"is removed once its attempt ends.",
+	Args: cobra.ExactArgs(1),
+	RunE: runTaskRemove,
+}
+
+func runTaskRemove(cmd *cobra.Command, args []string) error {
+	task, err := loadTask(args[0])
+	if err != nil {
+		return err
+	}
+	if task.Source == "" {
+		return fmt.Errorf("task '%s' has no task file", task.ID)
+	}
+	if file := queue.SourceFile(task.Source); file != task.Source {
+		return fmt.Errorf("task '%s' is defined in %s together with other tasks; remove it with 'task edit %s'", task.ID, file, task.ID)
+	}
+
+	lk, acquired, err := lock.TryLock(paths.LockPath())
+	if err != nil {
+		return fmt.Errorf("probe lock: %w", err)
+	}
+	if !acquired {
+		if err := queue.AppendCommand(paths.ControlDir(), queue.NewControlCommand("remove", task.ID)); err != nil {
+			return fmt.Errorf("queue remove command: %w", err)
+		}
+		fmt.Printf("Queued removal of '%s'\n", task.ID)
+		return nil
+	}
+	defer lk.Release()
+
+	if err := queue.RemoveTask(paths.TrashDir(), paths.StateDir(), paths.LogsDir(), task, time.Now()); err != nil {
+		return err
+	}
+	fmt.Printf("Removed '%s' ('undo' restores it)\n", task.ID)
+	return nil
+}
+
+// loadTask loads the queue and returns task id from it.
+func loadTask(id string) (*queue.Task, error) {
+	if err := paths.EnsureDirs(); err != nil {
+		return nil, fmt.Errorf("create directories: %w", err)
+	}
+	tasks, err := queue.LoadTasks(paths.TasksDir(), resolveProjectDir())
+	if err != nil {
+		return nil, fmt.Errorf("load tasks: %w", err)
+	}
+	task := findTask(tasks, id)
+	if task == nil {
+		return nil, fmt.Errorf("Task '%s' not found", id)
+	}
+	return task, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// ── undo ────────────────────────────────────────────────────────────────
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [n]",
+	Short: "Undo the last n cancels or removals (default 1)",
+	Long: "cancel, task remove and clean --orphan-state keep a snapshot of what they\n" +
+		"change. undo restores the last n of those operations, newest first: a\n" +
+		"cancelled task gets its previous state back (status, attempts, session,\n" +
+		"resume time) if it is still cancelled, and removed state and logs (and the\n" +
+		"task file, after task remove) come back if the task has no state again\n" +
+		"since. --list shows what can be undone. The last 50\n" +
+		"operations are kept. While a runner is active the undo is queued for it.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUndo,
+}
+
+var undoList bool
+
+func runUndo(cmd *cobra.Command, args []string) error {
+	n := 1
+	if len(args) == 1 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return fmt.Errorf("n must be a positive number (got %q)", args[0])
+		}
+	}
+	if err := paths.EnsureDirs(); err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	entries, err := queue.ListTrash(paths.TrashDir())
+	if err != nil {
+		return fmt.Errorf("read trash: %w", err)
+	}
+	if undoList {
+		if len(entries) == 0 {
+			fmt.Println("Nothing to undo")
+			return nil
+		}
+		fmt.Printf("%-4s %-20s %-8s %s\n", "#", "WHEN", "OP", "TASKS")
+		for i, e := range entries {
+			fmt.Printf("%-4d %-20s %-8s %s\n", i+1, ui.In(e.At).Format("2006-01-02 15:04:05"), e.Op, trashTaskIDs(e))
+		}
+		return nil
+	}
+	if len(entries) == 0 {
+		fmt.Println("Nothing to undo")
+		return nil
+	}
+	if n > len(entries) {
+		n = len(entries)
+	}
+
+	lk, acquired, err := lock.TryLock(paths.LockPath())
+	if err != nil {
+		return fmt.Errorf("probe lock: %w", err)
+	}
+	if !acquired {
+		for _, e := range entries[:n] {
+			if err := queue.AppendCommand(paths.ControlDir(), queue.NewControlCommand("undo", e.Name)); err != nil {
+				return fmt.Errorf("queue undo command: %w", err)
+			}
+			fmt.Printf("Queued undo of %s %s (%s)\n", e.Op, trashTaskIDs(e), ui.In(e.At).Format("2006-01-02 15:04:05"))
+		}
+		return nil
+	}
+	defer lk.Release()
+
+	for _, e := range entries[:n] {
+		restored, skipped, err := queue.Undo(paths.TrashDir(), paths.StateDir(), paths.LogsDir(), e.Name)
+		for _, id := range restored {
+			fmt.Printf("Restored '%s' (undo %s)\n", id, e.Op)
+		}
+		ids := make([]string, 0, len(skipped))
+		for id := range skipped {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Printf("Skipped '%s': %s\n", id, skipped[id])
+		}
+		if err != nil {
+			return fmt.Errorf("undo %s: %w", e.Op, err)
+		}
+	}
+	return nil
+}
+
+// trashTaskIDs lists the tasks of a trash entry, abbreviated past five.
+func trashTaskIDs(e queue.TrashEntry) string {
+	ids := make([]string, 0, len(e.Tasks))
+	for _, t := range e.Tasks {
+		ids = append(ids, t.ID)
+	}
+	if len(ids) > 5 {
+		return fmt.Sprintf("%s and %d more", strings.Join(ids[:5], ", "), len(ids)-5)
+	}
+	return strings.Join(ids, ", ")
+}
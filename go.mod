@@ -3,6 +3,8 @@ module github.com/hseinmoussa/claude-autopilot
 go 1.24.2
 
 require (
+	github.com/creack/pty v1.1.24
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/sys v0.40.0
 	gopkg.in/yaml.v3 v3.0.1
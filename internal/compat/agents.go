@@ -0,0 +1,107 @@
+package compat
+
+import "sort"
+
+// DefaultAgent is the agent tasks run with when they do not set one.
+const DefaultAgent = "claude"
+
+// Agent is a coding agent CLI the runner can drive in place of Claude Code,
+// with the same queue, retry and rate-limit handling. Claude Code itself is
+// not an Agent value: its version is detected at startup and may be pinned
+// per task (see Binary).
+type Agent struct {
+	Name    string
+	Command string // executable, looked up on PATH
+	Adapter CLIAdapter
+	// RateLimitPatterns are added to the configured rate_limit_patterns for
+	// this agent's tasks, for the errors it prints on a rate limit.
+	RateLimitPatterns []string
+}
+
+// builtinAgents are the agents known without any configuration.
+var builtinAgents = map[string]Agent{
+	"gemini": {
+		Name:              "gemini",
+		Command:           "gemini",
+		Adapter:           geminiAdapter{},
+		RateLimitPatterns: []string{"RESOURCE_EXHAUSTED", "Quota exceeded"},
+	},
+	"aider": {
+		Name:              "aider",
+		Command:           "aider",
+		Adapter:           aiderAdapter{},
+		RateLimitPatterns: []string{"RateLimitError"},
+	},
+}
+
+// LookupAgent returns the agent called name: one defined in agents.yaml
+// (see LoadAgents), which may replace a built-in one, or a built-in one.
+func LookupAgent(defined map[string]Agent, name string) (Agent, bool) {
+	if a, ok := defined[name]; ok {
+		return a, true
+	}
+	a, ok := builtinAgents[name]
+	return a, ok
+}
+
+// AgentNames lists the agents a task may name, the default first.
+func AgentNames(defined map[string]Agent) []string {
+	var names []string
+	for name := range builtinAgents {
+		names = append(names, name)
+	}
+	for name := range defined {
+		if _, ok := builtinAgents[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultAgent}, names...)
+}
+
+// geminiAdapter drives Gemini CLI in non-interactive mode. It prints plain
+// text and has no session the runner can resume.
+type geminiAdapter struct{}
+
+func (geminiAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool, extraFlags []string) []string {
+	var args []string
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	if skipPerms {
+		args = append(args, "--yolo")
+	}
+	args = append(args, extraFlags...)
+	// Attach the prompt to its flag so one starting with "-" stays a value.
+	return append(args, "--prompt="+prompt)
+}
+
+func (geminiAdapter) SupportsStreamJSON() bool { return false }
+func (geminiAdapter) SupportsResume() bool     { return false }
+func (geminiAdapter) RateLimitExitCode() int   { return -1 }
+func (geminiAdapter) CheckFlags(flags []string) ([]string, []string) {
+	return flags, nil
+}
+
+// aiderAdapter drives aider with a single --message, after which it exits.
+// Output is plain text without colors, and there is no session to resume.
+type aiderAdapter struct{}
+
+func (aiderAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool, extraFlags []string) []string {
+	args := []string{"--no-pretty", "--no-stream"}
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	if skipPerms {
+		args = append(args, "--yes-always")
+	}
+	args = append(args, extraFlags...)
+	return append(args, "--message="+prompt)
+}
+
+func (aiderAdapter) SupportsStreamJSON() bool { return false }
+func (aiderAdapter) SupportsResume() bool     { return false }
+func (aiderAdapter) RateLimitExitCode() int   { return -1 }
+func (aiderAdapter) CheckFlags(flags []string) ([]string, []string) {
+	return flags, nil
+}
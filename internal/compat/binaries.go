@@ -0,0 +1,104 @@
+package compat
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Binary is a Claude CLI install at a known path, pinned so tasks can
+// select it by version through claude_version.
+type Binary struct {
+	Version string `yaml:"version"`
+	Path    string `yaml:"path"`
+}
+
+// ProbeVersion runs `<path> --version` and returns the parsed version.
+func ProbeVersion(path string) (string, error) {
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("run %s --version: %w", path, err)
+	}
+	return parseVersionOutput(string(out))
+}
+
+// Discover returns every claude executable on PATH, in PATH order and
+// without duplicates. The first one is what an unpinned task runs.
+func Discover() []string {
+	var found []string
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		path, err := exec.LookPath(filepath.Join(dir, "claude"))
+		if err != nil {
+			continue
+		}
+		key := path
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			key = real
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		found = append(found, path)
+	}
+	return found
+}
+
+// LoadPins reads the pinned binaries from path. A missing file means none.
+func LoadPins(path string) ([]Binary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var pins []Binary
+	if err := yaml.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return pins, nil
+}
+
+// SavePins writes pins to path, sorted newest version first.
+func SavePins(path string, pins []Binary) error {
+	sort.SliceStable(pins, func(i, j int) bool {
+		return CompareSemver(pins[i].Version, pins[j].Version) > 0
+	})
+	data, err := yaml.Marshal(pins)
+	if err != nil {
+		return err
+	}
+	return fileutil.AtomicWrite(path, data, 0644)
+}
+
+// SelectBinary returns the pin for version want: an exact match, or else the
+// newest pin want is a prefix of on a dot or pre-release boundary, so "2.0"
+// selects the newest pinned 2.0.x. It returns nil if no pin matches.
+func SelectBinary(pins []Binary, want string) *Binary {
+	want = strings.TrimPrefix(want, "v")
+	var best *Binary
+	for i := range pins {
+		b := &pins[i]
+		if b.Version == want {
+			return b
+		}
+		if !strings.HasPrefix(b.Version, want+".") && !strings.HasPrefix(b.Version, want+"-") {
+			continue
+		}
+		if best == nil || CompareSemver(b.Version, best.Version) > 0 {
+			best = b
+		}
+	}
+	return best
+}
@@ -2,7 +2,6 @@ package compat
 
 import (
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 )
@@ -14,6 +13,10 @@ type CompatEntry struct {
 	StreamJSON        bool   // supports --output-format stream-json
 	ResumeFlag        bool   // supports --resume / session continuation
 	ExitCodeRateLimit int    // exit code emitted on rate limit (-1 = not supported)
+	SkipPermsFlag     bool   // supports --dangerously-skip-permissions
+	// Flags lists the CLI flags tasks may pass through their flags field
+	// (see CheckFlags).
+	Flags []string
 }
 
 // defaultCompat is the built-in compatibility table, ordered newest first.
@@ -24,6 +27,16 @@ var defaultCompat = []CompatEntry{
 		StreamJSON:        true,
 		ResumeFlag:        true,
 		ExitCodeRateLimit: 75,
+		SkipPermsFlag:     true,
+		Flags: []string{
+			"--add-dir", "--agents", "--allowedTools", "--allowed-tools",
+			"--append-system-prompt", "--betas", "--debug", "--disallowedTools",
+			"--disallowed-tools", "--fallback-model", "--fork-session", "--ide",
+			"--include-partial-messages", "--max-turns", "--mcp-config",
+			"--mcp-debug", "--permission-mode", "--permission-prompt-tool",
+			"--plugin-dir", "--replay-user-messages", "--setting-sources",
+			"--settings", "--strict-mcp-config", "--system-prompt",
+		},
 	},
 	{
 		MinVersion:        "1.0.0",
@@ -31,17 +44,19 @@ var defaultCompat = []CompatEntry{
 		StreamJSON:        false,
 		ResumeFlag:        false,
 		ExitCodeRateLimit: -1,
+		SkipPermsFlag:     true,
+		Flags: []string{
+			"--add-dir", "--allowedTools", "--append-system-prompt", "--debug",
+			"--disallowedTools", "--max-turns", "--mcp-config", "--mcp-debug",
+			"--permission-mode", "--permission-prompt-tool", "--system-prompt",
+		},
 	},
 }
 
 // DetectVersion runs `claude --version` and returns the parsed version string.
 // The output is expected to contain a semver-like version (e.g. "claude 2.1.3").
 func DetectVersion() (string, error) {
-	out, err := exec.Command("claude", "--version").Output()
-	if err != nil {
-		return "", fmt.Errorf("run claude --version: %w", err)
-	}
-	return parseVersionOutput(string(out))
+	return ProbeVersion("claude")
 }
 
 // parseVersionOutput extracts a semver version from command output.
@@ -83,6 +98,8 @@ type CLIAdapter interface {
 	SupportsResume() bool
 	// RateLimitExitCode returns the exit code used for rate limits, or -1.
 	RateLimitExitCode() int
+	// CheckFlags vets a task's extra flags (see the CheckFlags function).
+	CheckFlags(flags []string) (keep []string, warnings []string)
 }
 
 // NewAdapter creates a CLIAdapter from a CompatEntry. If entry is nil (unknown
@@ -116,7 +133,7 @@ func (a *knownAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool
 		args = append(args, "--model", model)
 	}
 
-	if skipPerms {
+	if skipPerms && a.entry.SkipPermsFlag {
 		args = append(args, "--dangerously-skip-permissions")
 	}
 
@@ -130,6 +147,9 @@ func (a *knownAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool
 func (a *knownAdapter) SupportsStreamJSON() bool { return a.entry.StreamJSON }
 func (a *knownAdapter) SupportsResume() bool     { return a.entry.ResumeFlag }
 func (a *knownAdapter) RateLimitExitCode() int   { return a.entry.ExitCodeRateLimit }
+func (a *knownAdapter) CheckFlags(flags []string) ([]string, []string) {
+	return CheckFlags(a.entry, flags)
+}
 
 // safeAdapter is used when the CLI version is unknown. It optimistically tries
 // modern features (stream-json, resume) since they degrade gracefully.
@@ -162,6 +182,9 @@ func (a *safeAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool,
 func (a *safeAdapter) SupportsStreamJSON() bool { return true }
 func (a *safeAdapter) SupportsResume() bool     { return true }
 func (a *safeAdapter) RateLimitExitCode() int   { return 75 }
+func (a *safeAdapter) CheckFlags(flags []string) ([]string, []string) {
+	return CheckFlags(nil, flags)
+}
 
 // CompareSemver compares two semver strings (MAJOR.MINOR.PATCH).
 // Returns -1 if a < b, 0 if a == b, 1 if a > b.
@@ -1,6 +1,12 @@
 package compat
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
 
 // ---------------------------------------------------------------------------
 // LookupCompat
@@ -159,8 +165,9 @@ func TestNewAdapter_KnownEntry(t *testing.T) {
 
 func TestNewAdapter_KnownEntry_BuildArgs(t *testing.T) {
 	entry := &CompatEntry{
-		StreamJSON: true,
-		ResumeFlag: true,
+		StreamJSON:    true,
+		ResumeFlag:    true,
+		SkipPermsFlag: true,
 	}
 	adapter := NewAdapter(entry)
 
@@ -302,3 +309,319 @@ func assertNotContains(t *testing.T, args []string, unwanted string) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// CheckFlags
+// ---------------------------------------------------------------------------
+
+func TestCheckFlags_DropsRunnerFlags(t *testing.T) {
+	entry, _ := LookupCompat("2.1.0")
+	keep, warnings := CheckFlags(entry, []string{
+		"--output-format", "json", "--max-turns", "5", "--model=opus", "--verbose", "--allowedTools", "Bash,Edit",
+	})
+	want := []string{"--max-turns", "5", "--allowedTools", "Bash,Edit"}
+	if strings.Join(keep, " ") != strings.Join(want, " ") {
+		t.Errorf("keep = %v; want %v", keep, want)
+	}
+	if len(warnings) != 3 || !strings.Contains(warnings[0], "--output-format") || !strings.Contains(warnings[1], "set model instead") {
+		t.Errorf("warnings = %q; want one per runner flag", warnings)
+	}
+}
+
+func TestCheckFlags_UnknownFlagPassedThrough(t *testing.T) {
+	entry, _ := LookupCompat("2.1.0")
+	keep, warnings := CheckFlags(entry, []string{"--max-turn", "5"})
+	if len(keep) != 2 {
+		t.Errorf("keep = %v; want the unknown flag passed through", keep)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "--max-turn") {
+		t.Errorf("warnings = %q; want the typo reported", warnings)
+	}
+
+	// Without a compat entry only runner flags are checked.
+	if _, warnings := CheckFlags(nil, []string{"--max-turn", "5"}); len(warnings) != 0 {
+		t.Errorf("warnings = %q; want none without an entry", warnings)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pinned binaries
+// ---------------------------------------------------------------------------
+
+func TestSelectBinary(t *testing.T) {
+	pins := []Binary{
+		{Version: "2.0.9", Path: "/a"},
+		{Version: "2.0.14", Path: "/b"},
+		{Version: "2.1.0", Path: "/c"},
+		{Version: "1.0.3", Path: "/d"},
+		{Version: "3.0.0-dev.1", Path: "/e"},
+	}
+	tests := []struct {
+		want string
+		path string // "" = no match
+	}{
+		{"2.0.9", "/a"},
+		{"v2.1.0", "/c"},
+		{"2.0", "/b"}, // newest 2.0.x, compared as semver
+		{"2", "/c"},
+		{"1", "/d"},
+		{"2.0.1", ""}, // not a dot-boundary prefix of 2.0.14
+		{"3.0.0", "/e"},
+		{"4", ""},
+	}
+	for _, tt := range tests {
+		b := SelectBinary(pins, tt.want)
+		got := ""
+		if b != nil {
+			got = b.Path
+		}
+		if got != tt.path {
+			t.Errorf("SelectBinary(%q) = %q; want %q", tt.want, got, tt.path)
+		}
+	}
+}
+
+func TestPins_SaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "claude-versions.yaml")
+
+	pins, err := LoadPins(path)
+	if err != nil || pins != nil {
+		t.Fatalf("LoadPins(missing) = %v, %v; want nil, nil", pins, err)
+	}
+
+	if err := SavePins(path, []Binary{{"1.0.3", "/old"}, {"2.0.14", "/new"}}); err != nil {
+		t.Fatalf("SavePins: %v", err)
+	}
+	pins, err = LoadPins(path)
+	if err != nil {
+		t.Fatalf("LoadPins: %v", err)
+	}
+	if len(pins) != 2 || pins[0].Version != "2.0.14" || pins[1].Path != "/old" {
+		t.Errorf("pins = %+v; want newest first", pins)
+	}
+}
+
+func TestProbeVersion(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	path := filepath.Join(t.TempDir(), "claude")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\necho '2.0.14 (Claude Code)'\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	version, err := ProbeVersion(path)
+	if err != nil {
+		t.Fatalf("ProbeVersion: %v", err)
+	}
+	if version != "2.0.14" {
+		t.Errorf("version = %q; want 2.0.14", version)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Capability probing
+// ---------------------------------------------------------------------------
+
+const sampleHelp = `Usage: claude [options] [command] [prompt]
+
+Options:
+  -p, --print                       Print response and exit
+  --output-format <format>          Output format: "text", "json", or "stream-json"
+  -r, --resume [sessionId]          Resume a conversation
+  --max-turns <n>                   Maximum agentic turns
+  --add-dir <directories...>        Additional directories to allow tool access to
+  -h, --help                        Display help for command
+`
+
+func TestParseHelp(t *testing.T) {
+	entry, err := parseHelp("3.0.0", sampleHelp)
+	if err != nil {
+		t.Fatalf("parseHelp: %v", err)
+	}
+	if !entry.StreamJSON || !entry.ResumeFlag {
+		t.Errorf("StreamJSON=%v ResumeFlag=%v; want both", entry.StreamJSON, entry.ResumeFlag)
+	}
+	if entry.SkipPermsFlag {
+		t.Error("SkipPermsFlag set though help does not list --dangerously-skip-permissions")
+	}
+	if !containsFlag(entry.Flags, "--max-turns") || !containsFlag(entry.Flags, "--add-dir") {
+		t.Errorf("Flags = %v; want --max-turns and --add-dir", entry.Flags)
+	}
+	if containsFlag(entry.Flags, "--print") || containsFlag(entry.Flags, "--resume") {
+		t.Errorf("Flags = %v; runner flags should not be allowlisted", entry.Flags)
+	}
+
+	// Skip permissions is left out of the args when the CLI lacks it.
+	args := NewAdapter(entry).BuildArgs("p", "", "", true, nil)
+	assertNotContains(t, args, "--dangerously-skip-permissions")
+}
+
+func TestParseHelp_TextOnly(t *testing.T) {
+	entry, err := parseHelp("3.0.0", "Options:\n  --print  print\n  --output-format <format>  text or json\n")
+	if err != nil {
+		t.Fatalf("parseHelp: %v", err)
+	}
+	if entry.StreamJSON || entry.ResumeFlag {
+		t.Errorf("StreamJSON=%v ResumeFlag=%v; want neither", entry.StreamJSON, entry.ResumeFlag)
+	}
+}
+
+func TestParseHelp_NotClaude(t *testing.T) {
+	if _, err := parseHelp("3.0.0", "usage: something-else [-v]\n"); err == nil {
+		t.Error("expected an error for help without --print")
+	}
+}
+
+func TestAdapterFor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "claude")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + sampleHelp + "EOF\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	adapter, err := AdapterFor(path, "9.1.0")
+	if err != nil {
+		t.Fatalf("AdapterFor: %v", err)
+	}
+	if _, warnings := adapter.CheckFlags([]string{"--max-turns", "5"}); len(warnings) != 0 {
+		t.Errorf("probed adapter warned about a listed flag: %v", warnings)
+	}
+
+	// A known version never runs the binary.
+	if _, err := AdapterFor(filepath.Join(dir, "missing"), "2.1.0"); err != nil {
+		t.Errorf("AdapterFor(known version): %v", err)
+	}
+
+	// A failed probe falls back to safe mode.
+	adapter, err = AdapterFor(filepath.Join(dir, "missing"), "9.2.0")
+	if err == nil || adapter == nil || !adapter.SupportsStreamJSON() {
+		t.Errorf("AdapterFor(unprobeable) = %v, %v; want safe mode and an error", adapter, err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Agents
+// ---------------------------------------------------------------------------
+
+func TestLookupAgent(t *testing.T) {
+	if _, ok := LookupAgent(nil, "claude"); ok {
+		t.Error("claude should not be a built-in agent; it is the default CLI")
+	}
+	if _, ok := LookupAgent(nil, "cursor"); ok {
+		t.Error("LookupAgent(cursor) found an agent")
+	}
+	names := AgentNames(nil)
+	if len(names) < 3 || names[0] != DefaultAgent {
+		t.Errorf("AgentNames = %v; want the default first", names)
+	}
+}
+
+func TestAgentAdapters_BuildArgs(t *testing.T) {
+	gemini, _ := LookupAgent(nil, "gemini")
+	args := gemini.Adapter.BuildArgs("-fix it", "gemini-2.5-pro", "sess", true, []string{"--sandbox"})
+	assertContains(t, args, "--model")
+	assertContains(t, args, "gemini-2.5-pro")
+	assertContains(t, args, "--yolo")
+	assertContains(t, args, "--sandbox")
+	assertContains(t, args, "--prompt=-fix it")
+	assertNotContains(t, args, "sess")
+
+	aider, _ := LookupAgent(nil, "aider")
+	args = aider.Adapter.BuildArgs("fix it", "", "", false, nil)
+	assertContains(t, args, "--message=fix it")
+	assertNotContains(t, args, "--yes-always")
+	assertNotContains(t, args, "--model")
+
+	// Other agents' flags are not vetted against Claude's.
+	if keep, warnings := aider.Adapter.CheckFlags([]string{"--model", "x"}); len(keep) != 2 || len(warnings) != 0 {
+		t.Errorf("CheckFlags = %v, %v; want flags passed through", keep, warnings)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Command template agents
+// ---------------------------------------------------------------------------
+
+func writeAgents(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agents.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAgents(t *testing.T) {
+	agents, err := LoadAgents(filepath.Join(t.TempDir(), "missing.yaml"))
+	if err != nil || agents != nil {
+		t.Fatalf("LoadAgents(missing) = %v, %v; want nil, nil", agents, err)
+	}
+
+	path := writeAgents(t, `
+wrapper:
+  command: [acme, run, "{{flags}}", "--model={{model}}", "--resume={{session}}", "--", "{{prompt}}"]
+  output: ndjson
+  skip_permissions_args: [--yes]
+  rate_limit_patterns: ["quota gone"]
+gemini:
+  command: [gemini-wrapper, "{{prompt}}"]
+`)
+	agents, err = LoadAgents(path)
+	if err != nil {
+		t.Fatalf("LoadAgents: %v", err)
+	}
+	w, ok := LookupAgent(agents, "wrapper")
+	if !ok || w.Command != "acme" || len(w.RateLimitPatterns) != 1 {
+		t.Fatalf("wrapper = %+v, %v", w, ok)
+	}
+	if !w.Adapter.SupportsStreamJSON() || !w.Adapter.SupportsResume() || w.Adapter.RateLimitExitCode() != -1 {
+		t.Error("wrapper should use ndjson, resume via {{session}}, and no rate-limit exit code")
+	}
+	if g, _ := LookupAgent(agents, "gemini"); g.Command != "gemini-wrapper" {
+		t.Errorf("gemini command = %q; agents.yaml should replace the built-in", g.Command)
+	}
+	if names := AgentNames(agents); len(names) != 4 {
+		t.Errorf("AgentNames = %v; want claude, aider, gemini, wrapper", names)
+	}
+}
+
+func TestLoadAgents_Invalid(t *testing.T) {
+	for name, yaml := range map[string]string{
+		"no prompt":    "x:\n  command: [x, run]\n",
+		"no command":   "x:\n  output: text\n",
+		"placeholder":  "x:\n  command: [x, \"{{prompt}}\", \"{{cwd}}\"]\n",
+		"flags inline": "x:\n  command: [x, \"--f={{flags}}\", \"{{prompt}}\"]\n",
+		"output":       "x:\n  command: [x, \"{{prompt}}\"]\n  output: json\n",
+		"claude":       "claude:\n  command: [x, \"{{prompt}}\"]\n",
+	} {
+		if _, err := LoadAgents(writeAgents(t, yaml)); err == nil {
+			t.Errorf("%s: LoadAgents succeeded; want an error", name)
+		}
+	}
+}
+
+func TestTemplateAdapter_BuildArgs(t *testing.T) {
+	a := &templateAdapter{t: AgentTemplate{
+		Command:             []string{"acme", "run", "{{flags}}", "--model={{model}}", "--resume={{ session }}", "--", "{{prompt}}"},
+		SkipPermissionsArgs: []string{"--yes"},
+	}}
+	got := strings.Join(a.BuildArgs("do it", "", "", true, []string{"--fast"}), " ")
+	if want := "run --yes --fast -- do it"; got != want {
+		t.Errorf("args = %q; want %q", got, want)
+	}
+	got = strings.Join(a.BuildArgs("do it", "big", "s-1", false, nil), " ")
+	if want := "run --model=big --resume=s-1 -- do it"; got != want {
+		t.Errorf("args = %q; want %q", got, want)
+	}
+
+	// Without {{flags}}, flags follow the executable.
+	a = &templateAdapter{t: AgentTemplate{Command: []string{"acme", "{{prompt}}"}}}
+	got = strings.Join(a.BuildArgs("do it", "", "", false, []string{"-v"}), " ")
+	if want := "-v do it"; got != want {
+		t.Errorf("args = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package compat
+
+import (
+	"fmt"
+	"strings"
+)
+
+// runnerFlags are the flags BuildArgs sets itself, with whether each takes a
+// value and what to use instead. Passing one in a task's flags would change
+// the output format the runner parses or fight its session handling.
+var runnerFlags = map[string]struct {
+	takesValue bool
+	hint       string
+}{
+	"--print":                        {false, "the runner always runs in print mode"},
+	"-p":                             {false, "the runner always runs in print mode"},
+	"--verbose":                      {false, "the runner sets it with stream-json"},
+	"--output-format":                {true, "the runner parses stream-json output"},
+	"--input-format":                 {true, "the runner passes the prompt as an argument"},
+	"--resume":                       {true, "resume is managed by the runner (resume_strategy)"},
+	"-r":                             {true, "resume is managed by the runner (resume_strategy)"},
+	"--continue":                     {false, "resume is managed by the runner (resume_strategy)"},
+	"-c":                             {false, "resume is managed by the runner (resume_strategy)"},
+	"--session-id":                   {true, "sessions are managed by the runner"},
+	"--model":                        {true, "set model instead"},
+	"--dangerously-skip-permissions": {false, "set skip_permissions instead"},
+}
+
+// CheckFlags vets a task's extra CLI flags. Flags the runner sets itself are
+// dropped (with their value) and reported, so a stray --output-format cannot
+// break stream parsing for the whole attempt. With a known entry, flags
+// outside its allowlist are reported too but passed through, since the CLI
+// may have gained them since this table was written. Tokens that do not
+// start with "-" are values and pass through as they are.
+func CheckFlags(entry *CompatEntry, flags []string) (keep []string, warnings []string) {
+	for i := 0; i < len(flags); i++ {
+		f := flags[i]
+		if !strings.HasPrefix(f, "-") {
+			keep = append(keep, f)
+			continue
+		}
+		name, _, hasValue := strings.Cut(f, "=")
+		if rf, ok := runnerFlags[name]; ok {
+			warnings = append(warnings, fmt.Sprintf("flag %s ignored: %s", name, rf.hint))
+			if rf.takesValue && !hasValue && i+1 < len(flags) && !strings.HasPrefix(flags[i+1], "-") {
+				i++
+			}
+			continue
+		}
+		if entry != nil && !containsFlag(entry.Flags, name) {
+			warnings = append(warnings, fmt.Sprintf("flag %s is not known for claude %s-%s; passing it through (check for a typo)", name, entry.MinVersion, entry.MaxVersion))
+		}
+		keep = append(keep, f)
+	}
+	return keep, warnings
+}
+
+func containsFlag(list []string, name string) bool {
+	for _, f := range list {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package compat
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// helpFlagRe finds the long flags listed in `claude --help` output.
+var helpFlagRe = regexp.MustCompile(`(?:^|[\s,\[])(--[a-zA-Z][a-zA-Z0-9-]*)`)
+
+// probed caches ProbeEntry results, failures included, by binary path and
+// version, so each binary's help is read once per process.
+var (
+	probedMu sync.Mutex
+	probed   = make(map[string]probeResult)
+)
+
+type probeResult struct {
+	entry *CompatEntry
+	err   error
+}
+
+// ProbeEntry builds a CompatEntry for a version missing from the table by
+// running `<path> --help` once and reading which flags it lists, so an
+// unknown CLI gets the arguments it supports instead of safe mode's
+// guesses. The rate-limit exit code cannot be seen in help output and is
+// assumed to be the one safe mode uses.
+func ProbeEntry(path, version string) (*CompatEntry, error) {
+	key := path + "@" + version
+	probedMu.Lock()
+	defer probedMu.Unlock()
+	if res, ok := probed[key]; ok {
+		return res.entry, res.err
+	}
+	var res probeResult
+	// Some CLI versions print help on stderr or exit non-zero after it.
+	out, err := exec.Command(path, "--help").CombinedOutput()
+	if len(out) == 0 && err != nil {
+		res.err = fmt.Errorf("run %s --help: %w", path, err)
+	} else {
+		res.entry, res.err = parseHelp(version, string(out))
+	}
+	probed[key] = res
+	return res.entry, res.err
+}
+
+// parseHelp builds a CompatEntry for version from `claude --help` output.
+// Help that does not list --print is not the CLI the runner expects.
+func parseHelp(version, help string) (*CompatEntry, error) {
+	entry := &CompatEntry{
+		MinVersion:        version,
+		MaxVersion:        version,
+		ExitCodeRateLimit: 75, // as safe mode assumes
+	}
+	seen := make(map[string]bool)
+	for _, m := range helpFlagRe.FindAllStringSubmatch(help, -1) {
+		name := m[1]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "--output-format":
+			entry.StreamJSON = strings.Contains(help, "stream-json")
+		case "--resume":
+			entry.ResumeFlag = true
+		case "--dangerously-skip-permissions":
+			entry.SkipPermsFlag = true
+		}
+		if _, ok := runnerFlags[name]; !ok {
+			entry.Flags = append(entry.Flags, name)
+		}
+	}
+	if !seen["--print"] {
+		return nil, fmt.Errorf("claude %s --help does not list --print", version)
+	}
+	return entry, nil
+}
+
+// AdapterFor returns the adapter for the claude binary at path, which
+// reported version: from the table when the version is known, otherwise
+// from probing its help. If the probe fails, the safe-mode adapter is
+// returned along with the error, which callers log and carry on.
+func AdapterFor(path, version string) (CLIAdapter, error) {
+	if entry, _ := LookupCompat(version); entry != nil {
+		return NewAdapter(entry), nil
+	}
+	entry, err := ProbeEntry(path, version)
+	if err != nil {
+		return NewAdapter(nil), fmt.Errorf("probe claude %s capabilities: %w; using safe mode", version, err)
+	}
+	return NewAdapter(entry), nil
+}
@@ -0,0 +1,172 @@
+package compat
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// placeholderRe matches the placeholders of a command template.
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z]+)\s*\}\}`)
+
+// AgentTemplate is an agent defined in agents.yaml by a command template,
+// for CLIs the runner has no built-in adapter for.
+type AgentTemplate struct {
+	// Command is the argv to run. {{prompt}}, {{model}} and {{session}} are
+	// replaced in each argument, and an argument is dropped when one of them
+	// is empty, so "--model={{model}}" disappears for tasks without a model.
+	// An argument that is exactly {{flags}} becomes the task's flags (and
+	// SkipPermissionsArgs); without one, they follow the executable.
+	Command []string `yaml:"command"`
+	// Output is "text" (default) or "ndjson" for Claude-compatible
+	// stream-json, which gives cost, usage and session IDs for {{session}}.
+	Output string `yaml:"output,omitempty"`
+	// SkipPermissionsArgs are added with the flags for tasks with
+	// skip_permissions.
+	SkipPermissionsArgs []string `yaml:"skip_permissions_args,omitempty"`
+	// RateLimitPatterns are matched like rate_limit_patterns, for this
+	// agent's tasks only.
+	RateLimitPatterns []string `yaml:"rate_limit_patterns,omitempty"`
+	// RateLimitExitCode is the exit code meaning rate limited (0 = none).
+	RateLimitExitCode int `yaml:"rate_limit_exit_code,omitempty"`
+}
+
+// LoadAgents reads the agents defined in path, keyed by name. A missing
+// file defines none.
+func LoadAgents(path string) (map[string]Agent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var templates map[string]AgentTemplate
+	if err := yaml.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	agents := make(map[string]Agent, len(templates))
+	for name, t := range templates {
+		if name == DefaultAgent {
+			return nil, fmt.Errorf("%s: agent %q is built in and cannot be redefined", path, name)
+		}
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("%s: agent %q: %w", path, name, err)
+		}
+		agents[name] = Agent{
+			Name:              name,
+			Command:           t.Command[0],
+			Adapter:           &templateAdapter{t: t},
+			RateLimitPatterns: t.RateLimitPatterns,
+		}
+	}
+	return agents, nil
+}
+
+func (t AgentTemplate) validate() error {
+	if len(t.Command) == 0 || strings.TrimSpace(t.Command[0]) == "" {
+		return fmt.Errorf("command is required")
+	}
+	switch t.Output {
+	case "", "text", "ndjson":
+	default:
+		return fmt.Errorf("output must be text or ndjson (got %q)", t.Output)
+	}
+	hasPrompt := false
+	for _, arg := range t.Command {
+		for _, m := range placeholderRe.FindAllStringSubmatch(arg, -1) {
+			switch m[1] {
+			case "prompt":
+				hasPrompt = true
+			case "model", "session":
+			case "flags":
+				if !isFlagsArg(arg) {
+					return fmt.Errorf("{{flags}} must be an argument of its own")
+				}
+			default:
+				return fmt.Errorf("unknown placeholder %s (use {{prompt}}, {{model}}, {{session}} or {{flags}})", m[0])
+			}
+		}
+	}
+	if !hasPrompt {
+		return fmt.Errorf("command must pass {{prompt}}")
+	}
+	return nil
+}
+
+// templateAdapter builds arguments from an AgentTemplate.
+type templateAdapter struct {
+	t AgentTemplate
+}
+
+func (a *templateAdapter) BuildArgs(prompt, model, sessionID string, skipPerms bool, extraFlags []string) []string {
+	values := map[string]string{"prompt": prompt, "model": model, "session": sessionID}
+	var flags []string
+	if skipPerms {
+		flags = append(flags, a.t.SkipPermissionsArgs...)
+	}
+	flags = append(flags, extraFlags...)
+
+	var args []string
+	placedFlags := false
+	for _, arg := range a.t.Command[1:] {
+		if isFlagsArg(arg) {
+			args = append(args, flags...)
+			placedFlags = true
+			continue
+		}
+		empty := false
+		arg = placeholderRe.ReplaceAllStringFunc(arg, func(p string) string {
+			v := values[placeholderRe.FindStringSubmatch(p)[1]]
+			if v == "" {
+				empty = true
+			}
+			return v
+		})
+		if !empty {
+			args = append(args, arg)
+		}
+	}
+	if !placedFlags {
+		args = append(flags, args...)
+	}
+	return args
+}
+
+// isFlagsArg reports whether arg is the {{flags}} placeholder on its own.
+func isFlagsArg(arg string) bool {
+	m := placeholderRe.FindStringSubmatch(arg)
+	return m != nil && m[1] == "flags" && m[0] == strings.TrimSpace(arg)
+}
+
+func (a *templateAdapter) SupportsStreamJSON() bool { return a.t.Output == "ndjson" }
+
+// SupportsResume reports whether the template passes a session, which
+// needs ndjson output to learn the session ID from.
+func (a *templateAdapter) SupportsResume() bool {
+	if a.t.Output != "ndjson" {
+		return false
+	}
+	for _, arg := range a.t.Command {
+		for _, m := range placeholderRe.FindAllStringSubmatch(arg, -1) {
+			if m[1] == "session" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *templateAdapter) RateLimitExitCode() int {
+	if a.t.RateLimitExitCode == 0 {
+		return -1
+	}
+	return a.t.RateLimitExitCode
+}
+
+func (a *templateAdapter) CheckFlags(flags []string) ([]string, []string) {
+	return flags, nil
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"fmt"
+	"log"
+	"net"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,6 +20,139 @@ type Config struct {
 	WebhookURL          string        `yaml:"webhook_url"`
 	NotificationDesktop bool          `yaml:"notification_desktop"`
 	NotificationBell    bool          `yaml:"notification_bell"`
+
+	// NotificationBellRepeat is how many times the bell rings when a run
+	// finishes with failed tasks. A single bell is used for clean runs.
+	NotificationBellRepeat   int    `yaml:"notification_bell_repeat"`
+	NotificationSoundCommand string `yaml:"notification_sound_command"`
+
+	// ntfy.sh push channel. Disabled while NtfyTopic is empty.
+	NtfyTopic  string `yaml:"ntfy_topic"`
+	NtfyServer string `yaml:"ntfy_server"`
+	NtfyToken  string `yaml:"ntfy_token"`
+
+	// Pushover channel. Enabled when both the user key and app token are set.
+	// Failed runs are sent at emergency priority, re-alerting every
+	// PushoverRetry until acknowledged or PushoverExpire elapses.
+	PushoverUserKey  string        `yaml:"pushover_user_key"`
+	PushoverAppToken string        `yaml:"pushover_app_token"`
+	PushoverRetry    time.Duration `yaml:"pushover_retry"`
+	PushoverExpire   time.Duration `yaml:"pushover_expire"`
+
+	// NotificationEvents filters which events reach which channels; see
+	// notifier.ParseEventFilters for the syntax.
+	NotificationEvents string `yaml:"notification_events"`
+
+	// OnEventCommand is a user script run for every notifier event, with
+	// event details in AUTOPILOT_* env vars and as JSON on stdin.
+	OnEventCommand string `yaml:"on_event_command"`
+
+	// Context file limits. Zero disables a byte limit. ContextBinary is
+	// "skip" (warn and omit binary files) or "error" (fail the attempt).
+	ContextMaxFileBytes  int    `yaml:"context_max_file_bytes"`
+	ContextMaxTotalBytes int    `yaml:"context_max_total_bytes"`
+	ContextBinary        string `yaml:"context_binary"`
+
+	// PromptTokenLimit is the estimated prompt size (in tokens) above which
+	// PromptLimitAction applies: "warn" logs and continues, "fail" fails the
+	// task without spawning Claude. Zero disables the guard.
+	PromptTokenLimit  int    `yaml:"prompt_token_limit"`
+	PromptLimitAction string `yaml:"prompt_limit_action"`
+
+	// DirLockPolicy controls what happens when another autopilot instance
+	// holds the working directory lock: "wait" defers the task, "skip"
+	// leaves it pending for this run, "off" disables the lock.
+	DirLockPolicy string `yaml:"dir_lock_policy"`
+
+	// Runner timings. HangCheckInterval is how often output silence is
+	// checked; PromptSilenceGate is how long output must stall at a
+	// permission prompt before the task is killed; ShutdownPollInterval is
+	// how often a running task checks for a shutdown request;
+	// WaitPollInterval re-evaluates the queue when file watching is
+	// unavailable; KillGracePeriod separates SIGTERM from SIGKILL.
+	HangCheckInterval    time.Duration `yaml:"hang_check_interval"`
+	PromptSilenceGate    time.Duration `yaml:"prompt_silence_gate"`
+	ShutdownPollInterval time.Duration `yaml:"shutdown_poll_interval"`
+	WaitPollInterval     time.Duration `yaml:"wait_poll_interval"`
+	KillGracePeriod      time.Duration `yaml:"kill_grace_period"`
+
+	// UsageWindow is the length of a Claude usage window, used to predict
+	// the next reset from observed rate limits. When PaceBeforeReset is
+	// non-zero, no new task starts within that long of the predicted reset.
+	UsageWindow     time.Duration `yaml:"usage_window"`
+	PaceBeforeReset time.Duration `yaml:"pace_before_reset"`
+
+	// PromptAction is what happens to a task stuck at a permission prompt:
+	// "kill" stops it after PromptSilenceGate; "answer" runs tasks with an
+	// auto_approve list under a PTY and replies to matching prompt_answers.
+	PromptAction string `yaml:"prompt_action"`
+
+	// UsePTY runs every task under a pseudo-terminal, for CLI versions that
+	// behave differently without a TTY.
+	UsePTY bool `yaml:"use_pty"`
+
+	// FailOnNoChanges treats a task that completes without changing any
+	// files in its git working directory as failed, so it is retried up to
+	// max_retries. Tasks can override it with fail_on_no_changes.
+	FailOnNoChanges bool `yaml:"fail_on_no_changes"`
+
+	// HTTPListen is the host:port on which `run` serves its HTTP endpoints
+	// (dashboard, live output websocket). Empty disables the listener.
+	HTTPListen string `yaml:"http_listen"`
+	// HTTPToken is the bearer token HTTP clients must present. Empty means
+	// a token is generated once and kept in <home>/http.token.
+	HTTPToken string `yaml:"http_token"`
+	// HTTPTLSCert and HTTPTLSKey switch the listener to HTTPS.
+	HTTPTLSCert string `yaml:"http_tls_cert"`
+	HTTPTLSKey  string `yaml:"http_tls_key"`
+	// HTTPClientCA requires client certificates signed by this CA (mTLS);
+	// such clients need no token.
+	HTTPClientCA string `yaml:"http_client_ca"`
+	// DefaultPriority and DefaultMaxRetries apply to tasks that leave
+	// priority or max_retries unset.
+	DefaultPriority   int `yaml:"default_priority"`
+	DefaultMaxRetries int `yaml:"default_max_retries"`
+	// PromptChangeAction applies when a task that has already run is found
+	// with a different prompt: "warn" logs and carries on, "reset" clears
+	// its attempt count and session so the new prompt starts fresh.
+	PromptChangeAction string `yaml:"prompt_change_action"`
+	// TaskChangeAction is the same for edits to any other field of the
+	// task's definition.
+	TaskChangeAction string `yaml:"task_change_action"`
+	// StateRetention is how long the state and logs of a task deleted from
+	// its source are kept before the runner removes them. Zero disables
+	// the cleanup.
+	StateRetention time.Duration `yaml:"state_retention"`
+	// ArchiveDone moves finished tasks (task file, state and logs) into the
+	// archive directory at the end of a run. Tasks can override it.
+	ArchiveDone bool `yaml:"archive_done"`
+	// EncryptionKeyFile, when set, names the key (see 'keygen') used to
+	// encrypt prompts written by the CLI, transcript excerpts in task state,
+	// and task logs.
+	EncryptionKeyFile string `yaml:"encryption_key_file"`
+	// ContainerRuntime runs tasks with a container image: "docker",
+	// "podman", or empty for whichever of them is on PATH (docker first).
+	ContainerRuntime string `yaml:"container_runtime"`
+	// MinFreeDiskMB pauses the queue while the state directory or the next
+	// task's working directory has less free space than this. Zero
+	// disables the check.
+	MinFreeDiskMB int `yaml:"min_free_disk_mb"`
+	// NoRetryFailures lists the failure categories (see matchers.yaml
+	// failure_patterns) that fail a task at once instead of retrying it.
+	NoRetryFailures string `yaml:"no_retry_failures"`
+	// NetworkProbe is the host:port dialed to tell a network outage from a
+	// task failure. While it is unreachable the queue pauses. Empty
+	// disables the probe.
+	NetworkProbe string `yaml:"network_probe"`
+	// Scheduling is the order actionable tasks start in: "priority"
+	// (default), "round_robin_by_dir", which takes turns between working
+	// directories and keeps priority order within each, or "weighted",
+	// which draws tasks at random weighted by priority.
+	Scheduling string `yaml:"scheduling"`
+	// LogFormat is how per-task logs are written: "text" (default), the
+	// raw output lines, or "ndjson", one JSON record per line with its
+	// time, stream and attempt.
+	LogFormat string `yaml:"log_format"`
 }
 
 // knownKeys lists every valid configuration key.
@@ -27,13 +162,87 @@ var knownKeys = map[string]bool{
 	"webhook_url":          true,
 	"notification_desktop": true,
 	"notification_bell":    true,
+
+	"notification_bell_repeat":   true,
+	"notification_sound_command": true,
+	"ntfy_topic":                 true,
+	"ntfy_server":                true,
+	"ntfy_token":                 true,
+	"pushover_user_key":          true,
+	"pushover_app_token":         true,
+	"pushover_retry":             true,
+	"pushover_expire":            true,
+	"notification_events":        true,
+	"on_event_command":           true,
+	"context_max_file_bytes":     true,
+	"context_max_total_bytes":    true,
+	"context_binary":             true,
+	"prompt_token_limit":         true,
+	"prompt_limit_action":        true,
+	"dir_lock_policy":            true,
+	"hang_check_interval":        true,
+	"prompt_silence_gate":        true,
+	"shutdown_poll_interval":     true,
+	"wait_poll_interval":         true,
+	"kill_grace_period":          true,
+	"usage_window":               true,
+	"pace_before_reset":          true,
+	"prompt_action":              true,
+	"use_pty":                    true,
+	"fail_on_no_changes":         true,
+	"http_listen":                true,
+	"http_token":                 true,
+	"http_tls_cert":              true,
+	"http_tls_key":               true,
+	"http_client_ca":             true,
+	"default_priority":           true,
+	"default_max_retries":        true,
+	"prompt_change_action":       true,
+	"task_change_action":         true,
+	"state_retention":            true,
+	"archive_done":               true,
+	"encryption_key_file":        true,
+	"container_runtime":          true,
+	"min_free_disk_mb":           true,
+	"no_retry_failures":          true,
+	"network_probe":              true,
+	"scheduling":                 true,
+	"log_format":                 true,
 }
 
 // defaults returns a Config with all default values applied.
 func defaults() Config {
 	return Config{
-		HangTimeout:      10 * time.Minute,
-		NotificationBell: true,
+		HangTimeout:            10 * time.Minute,
+		NotificationBell:       true,
+		NotificationBellRepeat: 3,
+		NtfyServer:             "https://ntfy.sh",
+		PushoverRetry:          60 * time.Second,
+		PushoverExpire:         time.Hour,
+		NotificationEvents:     "run_complete,run_failed,disk_low",
+		ContextMaxFileBytes:    256 * 1024,
+		ContextMaxTotalBytes:   1024 * 1024,
+		ContextBinary:          "skip",
+		PromptTokenLimit:       150000,
+		PromptLimitAction:      "warn",
+		DirLockPolicy:          "wait",
+		HangCheckInterval:      5 * time.Second,
+		PromptSilenceGate:      30 * time.Second,
+		ShutdownPollInterval:   500 * time.Millisecond,
+		WaitPollInterval:       30 * time.Second,
+		KillGracePeriod:        10 * time.Second,
+		UsageWindow:            5 * time.Hour,
+		PromptAction:           "kill",
+		DefaultPriority:        10,
+		DefaultMaxRetries:      5,
+		PromptChangeAction:     "warn",
+		TaskChangeAction:       "warn",
+		StateRetention:         7 * 24 * time.Hour,
+		MinFreeDiskMB:          1024,
+		NoRetryFailures:        "auth,context_too_long,permission_denied",
+		NetworkProbe:           "api.anthropic.com:443",
+		Scheduling:             "priority",
+		LogFormat:              "text",
 	}
 }
 
@@ -45,40 +254,52 @@ type configFileRaw struct {
 	WebhookURL          *string `yaml:"webhook_url,omitempty"`
 	NotificationDesktop *bool   `yaml:"notification_desktop,omitempty"`
 	NotificationBell    *bool   `yaml:"notification_bell,omitempty"`
-}
 
-// BaseDir returns the root configuration directory: ~/.claude-autopilot/
-func BaseDir() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		// Fall back to HOME env var.
-		home = os.Getenv("HOME")
-	}
-	return filepath.Join(home, ".claude-autopilot")
-}
-
-// EnsureDirs creates the full directory tree required by claude-autopilot:
-// base, state, tasks, logs, control.
-func EnsureDirs() error {
-	base := BaseDir()
-	dirs := []string{
-		base,
-		filepath.Join(base, "state"),
-		filepath.Join(base, "tasks"),
-		filepath.Join(base, "logs"),
-		filepath.Join(base, "control"),
-	}
-	for _, d := range dirs {
-		if err := os.MkdirAll(d, 0755); err != nil {
-			return fmt.Errorf("create dir %s: %w", d, err)
-		}
-	}
-	return nil
-}
-
-// configFilePath returns the path to the main config file.
-func configFilePath() string {
-	return filepath.Join(BaseDir(), "config.yaml")
+	NotificationBellRepeat   *int    `yaml:"notification_bell_repeat,omitempty"`
+	NotificationSoundCommand *string `yaml:"notification_sound_command,omitempty"`
+	NtfyTopic                *string `yaml:"ntfy_topic,omitempty"`
+	NtfyServer               *string `yaml:"ntfy_server,omitempty"`
+	NtfyToken                *string `yaml:"ntfy_token,omitempty"`
+	PushoverUserKey          *string `yaml:"pushover_user_key,omitempty"`
+	PushoverAppToken         *string `yaml:"pushover_app_token,omitempty"`
+	PushoverRetry            *string `yaml:"pushover_retry,omitempty"`
+	PushoverExpire           *string `yaml:"pushover_expire,omitempty"`
+	NotificationEvents       *string `yaml:"notification_events,omitempty"`
+	OnEventCommand           *string `yaml:"on_event_command,omitempty"`
+	ContextMaxFileBytes      *int    `yaml:"context_max_file_bytes,omitempty"`
+	ContextMaxTotalBytes     *int    `yaml:"context_max_total_bytes,omitempty"`
+	ContextBinary            *string `yaml:"context_binary,omitempty"`
+	PromptTokenLimit         *int    `yaml:"prompt_token_limit,omitempty"`
+	PromptLimitAction        *string `yaml:"prompt_limit_action,omitempty"`
+	DirLockPolicy            *string `yaml:"dir_lock_policy,omitempty"`
+	HangCheckInterval        *string `yaml:"hang_check_interval,omitempty"`
+	PromptSilenceGate        *string `yaml:"prompt_silence_gate,omitempty"`
+	ShutdownPollInterval     *string `yaml:"shutdown_poll_interval,omitempty"`
+	WaitPollInterval         *string `yaml:"wait_poll_interval,omitempty"`
+	KillGracePeriod          *string `yaml:"kill_grace_period,omitempty"`
+	UsageWindow              *string `yaml:"usage_window,omitempty"`
+	PaceBeforeReset          *string `yaml:"pace_before_reset,omitempty"`
+	PromptAction             *string `yaml:"prompt_action,omitempty"`
+	UsePTY                   *bool   `yaml:"use_pty,omitempty"`
+	FailOnNoChanges          *bool   `yaml:"fail_on_no_changes,omitempty"`
+	HTTPListen               *string `yaml:"http_listen,omitempty"`
+	HTTPToken                *string `yaml:"http_token,omitempty"`
+	HTTPTLSCert              *string `yaml:"http_tls_cert,omitempty"`
+	HTTPTLSKey               *string `yaml:"http_tls_key,omitempty"`
+	HTTPClientCA             *string `yaml:"http_client_ca,omitempty"`
+	DefaultPriority          *int    `yaml:"default_priority,omitempty"`
+	DefaultMaxRetries        *int    `yaml:"default_max_retries,omitempty"`
+	PromptChangeAction       *string `yaml:"prompt_change_action,omitempty"`
+	TaskChangeAction         *string `yaml:"task_change_action,omitempty"`
+	StateRetention           *string `yaml:"state_retention,omitempty"`
+	ArchiveDone              *bool   `yaml:"archive_done,omitempty"`
+	EncryptionKeyFile        *string `yaml:"encryption_key_file,omitempty"`
+	ContainerRuntime         *string `yaml:"container_runtime,omitempty"`
+	MinFreeDiskMB            *int    `yaml:"min_free_disk_mb,omitempty"`
+	NoRetryFailures          *string `yaml:"no_retry_failures,omitempty"`
+	NetworkProbe             *string `yaml:"network_probe,omitempty"`
+	Scheduling               *string `yaml:"scheduling,omitempty"`
+	LogFormat                *string `yaml:"log_format,omitempty"`
 }
 
 // Load reads configuration from disk and applies the resolution order:
@@ -86,18 +307,23 @@ func configFilePath() string {
 //	CLI flag > env var > config file > default
 //
 // CLI flag overrides are passed via the overrides map (key -> string value).
-func Load(overrides map[string]string) (Config, error) {
-	cfg := defaults()
-
-	// --- Layer 1: config file ---
-	raw, err := loadRawFile()
+//
+// Invalid values in the config file or environment are rejected rather than
+// ignored; unknown keys in the config file are logged as warnings.
+func (p Paths) Load(overrides map[string]string) (Config, error) {
+	// --- Layers 1 and 2: config file, environment variables ---
+	cfg, settings, unknown, err := p.resolve()
 	if err != nil {
 		return cfg, err
 	}
-	applyFileToConfig(raw, &cfg)
-
-	// --- Layer 2: environment variables ---
-	applyEnvToConfig(&cfg)
+	for _, k := range unknown {
+		log.Printf("WARN: %s: unknown config key %q", p.ConfigFile(), k)
+	}
+	for _, s := range settings {
+		if s.Err != nil {
+			return cfg, s.Err
+		}
+	}
 
 	// --- Layer 3: CLI flag overrides ---
 	if err := applyOverrides(overrides, &cfg); err != nil {
@@ -107,11 +333,35 @@ func Load(overrides map[string]string) (Config, error) {
 	return cfg, nil
 }
 
+// EncryptionKeyFile returns the effective encryption_key_file, resolved
+// quietly like TaskDefaults.
+func (p Paths) EncryptionKeyFile() string {
+	cfg, _, _, _ := p.resolve()
+	return cfg.EncryptionKeyFile
+}
+
+// TaskDefaults returns the effective default_priority and
+// default_max_retries. Unlike Load it logs nothing and never fails: an
+// unreadable config or invalid value yields the built-in default, and Load
+// reports the problem where config is required.
+func (p Paths) TaskDefaults() (priority, maxRetries int) {
+	cfg, _, _, _ := p.resolve()
+	builtin := defaults()
+	priority, maxRetries = cfg.DefaultPriority, cfg.DefaultMaxRetries
+	if priority < 1 {
+		priority = builtin.DefaultPriority
+	}
+	if maxRetries < 1 {
+		maxRetries = builtin.DefaultMaxRetries
+	}
+	return priority, maxRetries
+}
+
 // loadRawFile reads and parses the YAML config file. If the file does not
 // exist the returned struct is zero-valued (all pointers nil).
-func loadRawFile() (configFileRaw, error) {
+func (p Paths) loadRawFile() (configFileRaw, error) {
 	var raw configFileRaw
-	data, err := os.ReadFile(configFilePath())
+	data, err := os.ReadFile(p.ConfigFile())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return raw, nil
@@ -142,6 +392,161 @@ func applyFileToConfig(raw configFileRaw, cfg *Config) {
 	if raw.NotificationBell != nil {
 		cfg.NotificationBell = *raw.NotificationBell
 	}
+	if raw.NotificationBellRepeat != nil {
+		cfg.NotificationBellRepeat = *raw.NotificationBellRepeat
+	}
+	if raw.NotificationSoundCommand != nil {
+		cfg.NotificationSoundCommand = *raw.NotificationSoundCommand
+	}
+	if raw.NtfyTopic != nil {
+		cfg.NtfyTopic = *raw.NtfyTopic
+	}
+	if raw.NtfyServer != nil {
+		cfg.NtfyServer = *raw.NtfyServer
+	}
+	if raw.NtfyToken != nil {
+		cfg.NtfyToken = *raw.NtfyToken
+	}
+	if raw.PushoverUserKey != nil {
+		cfg.PushoverUserKey = *raw.PushoverUserKey
+	}
+	if raw.PushoverAppToken != nil {
+		cfg.PushoverAppToken = *raw.PushoverAppToken
+	}
+	if raw.PushoverRetry != nil {
+		if d, err := time.ParseDuration(*raw.PushoverRetry); err == nil {
+			cfg.PushoverRetry = d
+		}
+	}
+	if raw.PushoverExpire != nil {
+		if d, err := time.ParseDuration(*raw.PushoverExpire); err == nil {
+			cfg.PushoverExpire = d
+		}
+	}
+	if raw.NotificationEvents != nil {
+		cfg.NotificationEvents = *raw.NotificationEvents
+	}
+	if raw.OnEventCommand != nil {
+		cfg.OnEventCommand = *raw.OnEventCommand
+	}
+	if raw.ContextMaxFileBytes != nil {
+		cfg.ContextMaxFileBytes = *raw.ContextMaxFileBytes
+	}
+	if raw.ContextMaxTotalBytes != nil {
+		cfg.ContextMaxTotalBytes = *raw.ContextMaxTotalBytes
+	}
+	if raw.ContextBinary != nil {
+		cfg.ContextBinary = *raw.ContextBinary
+	}
+	if raw.PromptTokenLimit != nil {
+		cfg.PromptTokenLimit = *raw.PromptTokenLimit
+	}
+	if raw.PromptLimitAction != nil {
+		cfg.PromptLimitAction = *raw.PromptLimitAction
+	}
+	if raw.DirLockPolicy != nil {
+		cfg.DirLockPolicy = *raw.DirLockPolicy
+	}
+	if raw.HangCheckInterval != nil {
+		if d, err := time.ParseDuration(*raw.HangCheckInterval); err == nil {
+			cfg.HangCheckInterval = d
+		}
+	}
+	if raw.PromptSilenceGate != nil {
+		if d, err := time.ParseDuration(*raw.PromptSilenceGate); err == nil {
+			cfg.PromptSilenceGate = d
+		}
+	}
+	if raw.ShutdownPollInterval != nil {
+		if d, err := time.ParseDuration(*raw.ShutdownPollInterval); err == nil {
+			cfg.ShutdownPollInterval = d
+		}
+	}
+	if raw.WaitPollInterval != nil {
+		if d, err := time.ParseDuration(*raw.WaitPollInterval); err == nil {
+			cfg.WaitPollInterval = d
+		}
+	}
+	if raw.KillGracePeriod != nil {
+		if d, err := time.ParseDuration(*raw.KillGracePeriod); err == nil {
+			cfg.KillGracePeriod = d
+		}
+	}
+	if raw.UsageWindow != nil {
+		if d, err := time.ParseDuration(*raw.UsageWindow); err == nil {
+			cfg.UsageWindow = d
+		}
+	}
+	if raw.PaceBeforeReset != nil {
+		if d, err := time.ParseDuration(*raw.PaceBeforeReset); err == nil {
+			cfg.PaceBeforeReset = d
+		}
+	}
+	if raw.PromptAction != nil {
+		cfg.PromptAction = *raw.PromptAction
+	}
+	if raw.UsePTY != nil {
+		cfg.UsePTY = *raw.UsePTY
+	}
+	if raw.FailOnNoChanges != nil {
+		cfg.FailOnNoChanges = *raw.FailOnNoChanges
+	}
+	if raw.HTTPListen != nil {
+		cfg.HTTPListen = *raw.HTTPListen
+	}
+	if raw.HTTPToken != nil {
+		cfg.HTTPToken = *raw.HTTPToken
+	}
+	if raw.HTTPTLSCert != nil {
+		cfg.HTTPTLSCert = *raw.HTTPTLSCert
+	}
+	if raw.HTTPTLSKey != nil {
+		cfg.HTTPTLSKey = *raw.HTTPTLSKey
+	}
+	if raw.HTTPClientCA != nil {
+		cfg.HTTPClientCA = *raw.HTTPClientCA
+	}
+	if raw.DefaultPriority != nil {
+		cfg.DefaultPriority = *raw.DefaultPriority
+	}
+	if raw.DefaultMaxRetries != nil {
+		cfg.DefaultMaxRetries = *raw.DefaultMaxRetries
+	}
+	if raw.PromptChangeAction != nil {
+		cfg.PromptChangeAction = *raw.PromptChangeAction
+	}
+	if raw.TaskChangeAction != nil {
+		cfg.TaskChangeAction = *raw.TaskChangeAction
+	}
+	if raw.StateRetention != nil {
+		if d, err := time.ParseDuration(*raw.StateRetention); err == nil {
+			cfg.StateRetention = d
+		}
+	}
+	if raw.ArchiveDone != nil {
+		cfg.ArchiveDone = *raw.ArchiveDone
+	}
+	if raw.EncryptionKeyFile != nil {
+		cfg.EncryptionKeyFile = *raw.EncryptionKeyFile
+	}
+	if raw.ContainerRuntime != nil {
+		cfg.ContainerRuntime = *raw.ContainerRuntime
+	}
+	if raw.MinFreeDiskMB != nil {
+		cfg.MinFreeDiskMB = *raw.MinFreeDiskMB
+	}
+	if raw.NoRetryFailures != nil {
+		cfg.NoRetryFailures = *raw.NoRetryFailures
+	}
+	if raw.NetworkProbe != nil {
+		cfg.NetworkProbe = *raw.NetworkProbe
+	}
+	if raw.Scheduling != nil {
+		cfg.Scheduling = *raw.Scheduling
+	}
+	if raw.LogFormat != nil {
+		cfg.LogFormat = *raw.LogFormat
+	}
 }
 
 // applyEnvToConfig reads CLAUDE_AUTOPILOT_<UPPER_SNAKE_KEY> env vars.
@@ -163,12 +568,180 @@ func applyEnvToConfig(cfg *Config) {
 	if v, ok := lookupEnv("notification_bell"); ok {
 		cfg.NotificationBell = parseBool(v)
 	}
+	if v, ok := lookupEnv("notification_bell_repeat"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.NotificationBellRepeat = n
+		}
+	}
+	if v, ok := lookupEnv("notification_sound_command"); ok {
+		cfg.NotificationSoundCommand = v
+	}
+	if v, ok := lookupEnv("ntfy_topic"); ok {
+		cfg.NtfyTopic = v
+	}
+	if v, ok := lookupEnv("ntfy_server"); ok {
+		cfg.NtfyServer = v
+	}
+	if v, ok := lookupEnv("ntfy_token"); ok {
+		cfg.NtfyToken = v
+	}
+	if v, ok := lookupEnv("pushover_user_key"); ok {
+		cfg.PushoverUserKey = v
+	}
+	if v, ok := lookupEnv("pushover_app_token"); ok {
+		cfg.PushoverAppToken = v
+	}
+	if v, ok := lookupEnv("pushover_retry"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PushoverRetry = d
+		}
+	}
+	if v, ok := lookupEnv("pushover_expire"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PushoverExpire = d
+		}
+	}
+	if v, ok := lookupEnv("notification_events"); ok {
+		cfg.NotificationEvents = v
+	}
+	if v, ok := lookupEnv("on_event_command"); ok {
+		cfg.OnEventCommand = v
+	}
+	if v, ok := lookupEnv("context_max_file_bytes"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ContextMaxFileBytes = n
+		}
+	}
+	if v, ok := lookupEnv("context_max_total_bytes"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.ContextMaxTotalBytes = n
+		}
+	}
+	if v, ok := lookupEnv("context_binary"); ok {
+		cfg.ContextBinary = v
+	}
+	if v, ok := lookupEnv("prompt_token_limit"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.PromptTokenLimit = n
+		}
+	}
+	if v, ok := lookupEnv("prompt_limit_action"); ok {
+		cfg.PromptLimitAction = v
+	}
+	if v, ok := lookupEnv("dir_lock_policy"); ok {
+		cfg.DirLockPolicy = v
+	}
+	if v, ok := lookupEnv("hang_check_interval"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.HangCheckInterval = d
+		}
+	}
+	if v, ok := lookupEnv("prompt_silence_gate"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PromptSilenceGate = d
+		}
+	}
+	if v, ok := lookupEnv("shutdown_poll_interval"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.ShutdownPollInterval = d
+		}
+	}
+	if v, ok := lookupEnv("wait_poll_interval"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.WaitPollInterval = d
+		}
+	}
+	if v, ok := lookupEnv("kill_grace_period"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.KillGracePeriod = d
+		}
+	}
+	if v, ok := lookupEnv("usage_window"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.UsageWindow = d
+		}
+	}
+	if v, ok := lookupEnv("pace_before_reset"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PaceBeforeReset = d
+		}
+	}
+	if v, ok := lookupEnv("prompt_action"); ok {
+		cfg.PromptAction = v
+	}
+	if v, ok := lookupEnv("use_pty"); ok {
+		cfg.UsePTY = parseBool(v)
+	}
+	if v, ok := lookupEnv("fail_on_no_changes"); ok {
+		cfg.FailOnNoChanges = parseBool(v)
+	}
+	if v, ok := lookupEnv("http_listen"); ok {
+		cfg.HTTPListen = v
+	}
+	if v, ok := lookupEnv("http_token"); ok {
+		cfg.HTTPToken = v
+	}
+	if v, ok := lookupEnv("http_tls_cert"); ok {
+		cfg.HTTPTLSCert = v
+	}
+	if v, ok := lookupEnv("http_tls_key"); ok {
+		cfg.HTTPTLSKey = v
+	}
+	if v, ok := lookupEnv("http_client_ca"); ok {
+		cfg.HTTPClientCA = v
+	}
+	if v, ok := lookupEnv("default_priority"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.DefaultPriority = n
+		}
+	}
+	if v, ok := lookupEnv("default_max_retries"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.DefaultMaxRetries = n
+		}
+	}
+	if v, ok := lookupEnv("prompt_change_action"); ok {
+		cfg.PromptChangeAction = v
+	}
+	if v, ok := lookupEnv("task_change_action"); ok {
+		cfg.TaskChangeAction = v
+	}
+	if v, ok := lookupEnv("state_retention"); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.StateRetention = d
+		}
+	}
+	if v, ok := lookupEnv("archive_done"); ok {
+		cfg.ArchiveDone = parseBool(v)
+	}
+	if v, ok := lookupEnv("encryption_key_file"); ok {
+		cfg.EncryptionKeyFile = v
+	}
+	if v, ok := lookupEnv("container_runtime"); ok {
+		cfg.ContainerRuntime = v
+	}
+	if v, ok := lookupEnv("min_free_disk_mb"); ok {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MinFreeDiskMB = n
+		}
+	}
+	if v, ok := lookupEnv("no_retry_failures"); ok {
+		cfg.NoRetryFailures = v
+	}
+	if v, ok := lookupEnv("network_probe"); ok {
+		cfg.NetworkProbe = v
+	}
+	if v, ok := lookupEnv("scheduling"); ok {
+		cfg.Scheduling = v
+	}
+	if v, ok := lookupEnv("log_format"); ok {
+		cfg.LogFormat = v
+	}
 }
 
 // lookupEnv checks for CLAUDE_AUTOPILOT_<UPPER_SNAKE_KEY>.
 func lookupEnv(key string) (string, bool) {
-	envKey := "CLAUDE_AUTOPILOT_" + strings.ToUpper(key)
-	return os.LookupEnv(envKey)
+	return os.LookupEnv(envName(key))
 }
 
 func parseBool(s string) bool {
@@ -196,11 +769,172 @@ func applyOverrides(overrides map[string]string, cfg *Config) error {
 			}
 			cfg.HangTimeout = d
 		case "webhook_url":
+			if err := validateWebhookURL(v); err != nil {
+				return err
+			}
 			cfg.WebhookURL = v
 		case "notification_desktop":
 			cfg.NotificationDesktop = parseBool(v)
 		case "notification_bell":
 			cfg.NotificationBell = parseBool(v)
+		case "notification_bell_repeat":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid notification_bell_repeat %q: %w", v, err)
+			}
+			cfg.NotificationBellRepeat = n
+		case "notification_sound_command":
+			cfg.NotificationSoundCommand = v
+		case "ntfy_topic":
+			cfg.NtfyTopic = v
+		case "ntfy_server":
+			cfg.NtfyServer = v
+		case "ntfy_token":
+			cfg.NtfyToken = v
+		case "pushover_user_key":
+			cfg.PushoverUserKey = v
+		case "pushover_app_token":
+			cfg.PushoverAppToken = v
+		case "pushover_retry":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid pushover_retry %q: %w", v, err)
+			}
+			cfg.PushoverRetry = d
+		case "pushover_expire":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid pushover_expire %q: %w", v, err)
+			}
+			cfg.PushoverExpire = d
+		case "notification_events":
+			cfg.NotificationEvents = v
+		case "on_event_command":
+			cfg.OnEventCommand = v
+		case "context_max_file_bytes":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid context_max_file_bytes %q: %w", v, err)
+			}
+			cfg.ContextMaxFileBytes = n
+		case "context_max_total_bytes":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid context_max_total_bytes %q: %w", v, err)
+			}
+			cfg.ContextMaxTotalBytes = n
+		case "context_binary":
+			cfg.ContextBinary = v
+		case "prompt_token_limit":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid prompt_token_limit %q: %w", v, err)
+			}
+			cfg.PromptTokenLimit = n
+		case "prompt_limit_action":
+			cfg.PromptLimitAction = v
+		case "dir_lock_policy":
+			cfg.DirLockPolicy = v
+		case "hang_check_interval":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid hang_check_interval %q: %w", v, err)
+			}
+			cfg.HangCheckInterval = d
+		case "prompt_silence_gate":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid prompt_silence_gate %q: %w", v, err)
+			}
+			cfg.PromptSilenceGate = d
+		case "shutdown_poll_interval":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid shutdown_poll_interval %q: %w", v, err)
+			}
+			cfg.ShutdownPollInterval = d
+		case "wait_poll_interval":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid wait_poll_interval %q: %w", v, err)
+			}
+			cfg.WaitPollInterval = d
+		case "kill_grace_period":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid kill_grace_period %q: %w", v, err)
+			}
+			cfg.KillGracePeriod = d
+		case "usage_window":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid usage_window %q: %w", v, err)
+			}
+			cfg.UsageWindow = d
+		case "pace_before_reset":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid pace_before_reset %q: %w", v, err)
+			}
+			cfg.PaceBeforeReset = d
+		case "prompt_action":
+			cfg.PromptAction = v
+		case "use_pty":
+			cfg.UsePTY = parseBool(v)
+		case "fail_on_no_changes":
+			cfg.FailOnNoChanges = parseBool(v)
+		case "http_listen":
+			cfg.HTTPListen = v
+		case "http_token":
+			cfg.HTTPToken = v
+		case "http_tls_cert":
+			cfg.HTTPTLSCert = v
+		case "http_tls_key":
+			cfg.HTTPTLSKey = v
+		case "http_client_ca":
+			cfg.HTTPClientCA = v
+		case "default_priority":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid default_priority %q: %w", v, err)
+			}
+			cfg.DefaultPriority = n
+		case "default_max_retries":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid default_max_retries %q: %w", v, err)
+			}
+			cfg.DefaultMaxRetries = n
+		case "prompt_change_action":
+			cfg.PromptChangeAction = v
+		case "task_change_action":
+			cfg.TaskChangeAction = v
+		case "state_retention":
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid state_retention %q: %w", v, err)
+			}
+			cfg.StateRetention = d
+		case "archive_done":
+			cfg.ArchiveDone = parseBool(v)
+		case "encryption_key_file":
+			cfg.EncryptionKeyFile = v
+		case "container_runtime":
+			cfg.ContainerRuntime = v
+		case "min_free_disk_mb":
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid min_free_disk_mb %q: %w", v, err)
+			}
+			cfg.MinFreeDiskMB = n
+		case "no_retry_failures":
+			cfg.NoRetryFailures = v
+		case "network_probe":
+			cfg.NetworkProbe = v
+		case "scheduling":
+			cfg.Scheduling = v
+		case "log_format":
+			cfg.LogFormat = v
 		}
 	}
 	return nil
@@ -224,33 +958,41 @@ func knownKeysList() string {
 
 // SetConfigValue writes a key-value pair to the config file. The file is
 // created if it does not exist. Uses atomic write for crash safety.
-func SetConfigValue(key, value string) error {
+func (p Paths) SetConfigValue(key, value string) error {
 	if err := ValidateKey(key); err != nil {
 		return err
 	}
 
-	raw, err := loadRawFile()
+	raw, err := p.loadRawFile()
 	if err != nil {
 		return err
 	}
 
-	setRawValue(&raw, key, value)
+	if err := setRawValue(&raw, key, value); err != nil {
+		return err
+	}
 
 	data, err := yaml.Marshal(&raw)
 	if err != nil {
 		return fmt.Errorf("marshal config: %w", err)
 	}
-	return fileutil.AtomicWrite(configFilePath(), data, 0644)
+	return fileutil.AtomicWrite(p.ConfigFile(), data, 0644)
 }
 
-func setRawValue(raw *configFileRaw, key, value string) {
+func setRawValue(raw *configFileRaw, key, value string) error {
 	switch key {
 	case "skip_permissions":
 		b := parseBool(value)
 		raw.SkipPermissions = &b
 	case "hang_timeout":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid hang_timeout %q: %w", value, err)
+		}
 		raw.HangTimeout = &value
 	case "webhook_url":
+		if err := validateWebhookURL(value); err != nil {
+			return err
+		}
 		raw.WebhookURL = &value
 	case "notification_desktop":
 		b := parseBool(value)
@@ -258,17 +1000,217 @@ func setRawValue(raw *configFileRaw, key, value string) {
 	case "notification_bell":
 		b := parseBool(value)
 		raw.NotificationBell = &b
+	case "notification_bell_repeat":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid notification_bell_repeat %q: must be an integer", value)
+		}
+		raw.NotificationBellRepeat = &n
+	case "notification_sound_command":
+		raw.NotificationSoundCommand = &value
+	case "ntfy_topic":
+		raw.NtfyTopic = &value
+	case "ntfy_server":
+		raw.NtfyServer = &value
+	case "ntfy_token":
+		raw.NtfyToken = &value
+	case "pushover_user_key":
+		raw.PushoverUserKey = &value
+	case "pushover_app_token":
+		raw.PushoverAppToken = &value
+	case "pushover_retry":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid pushover_retry %q: %w", value, err)
+		}
+		raw.PushoverRetry = &value
+	case "pushover_expire":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid pushover_expire %q: %w", value, err)
+		}
+		raw.PushoverExpire = &value
+	case "notification_events":
+		raw.NotificationEvents = &value
+	case "on_event_command":
+		raw.OnEventCommand = &value
+	case "context_max_file_bytes":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid context_max_file_bytes %q: must be an integer", value)
+		}
+		raw.ContextMaxFileBytes = &n
+	case "context_max_total_bytes":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid context_max_total_bytes %q: must be an integer", value)
+		}
+		raw.ContextMaxTotalBytes = &n
+	case "context_binary":
+		if value != "skip" && value != "error" {
+			return fmt.Errorf("invalid context_binary %q: must be skip or error", value)
+		}
+		raw.ContextBinary = &value
+	case "prompt_token_limit":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid prompt_token_limit %q: must be an integer", value)
+		}
+		raw.PromptTokenLimit = &n
+	case "prompt_limit_action":
+		if value != "warn" && value != "fail" {
+			return fmt.Errorf("invalid prompt_limit_action %q: must be warn or fail", value)
+		}
+		raw.PromptLimitAction = &value
+	case "dir_lock_policy":
+		if value != "wait" && value != "skip" && value != "off" {
+			return fmt.Errorf("invalid dir_lock_policy %q: must be wait, skip or off", value)
+		}
+		raw.DirLockPolicy = &value
+	case "hang_check_interval":
+		if err := validateInterval("hang_check_interval", value); err != nil {
+			return err
+		}
+		raw.HangCheckInterval = &value
+	case "prompt_silence_gate":
+		if err := validateInterval("prompt_silence_gate", value); err != nil {
+			return err
+		}
+		raw.PromptSilenceGate = &value
+	case "shutdown_poll_interval":
+		if err := validateInterval("shutdown_poll_interval", value); err != nil {
+			return err
+		}
+		raw.ShutdownPollInterval = &value
+	case "wait_poll_interval":
+		if err := validateInterval("wait_poll_interval", value); err != nil {
+			return err
+		}
+		raw.WaitPollInterval = &value
+	case "kill_grace_period":
+		if err := validateInterval("kill_grace_period", value); err != nil {
+			return err
+		}
+		raw.KillGracePeriod = &value
+	case "usage_window":
+		if err := validateInterval("usage_window", value); err != nil {
+			return err
+		}
+		raw.UsageWindow = &value
+	case "pace_before_reset":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid pace_before_reset %q: %w", value, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid pace_before_reset %q: must not be negative", value)
+		}
+		raw.PaceBeforeReset = &value
+	case "prompt_action":
+		if value != "kill" && value != "answer" {
+			return fmt.Errorf("invalid prompt_action %q: must be kill or answer", value)
+		}
+		raw.PromptAction = &value
+	case "use_pty":
+		b := parseBool(value)
+		raw.UsePTY = &b
+	case "fail_on_no_changes":
+		b := parseBool(value)
+		raw.FailOnNoChanges = &b
+	case "http_listen":
+		if value != "" {
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				return fmt.Errorf("invalid http_listen %q: %w", value, err)
+			}
+		}
+		raw.HTTPListen = &value
+	case "http_token":
+		raw.HTTPToken = &value
+	case "http_tls_cert":
+		raw.HTTPTLSCert = &value
+	case "http_tls_key":
+		raw.HTTPTLSKey = &value
+	case "http_client_ca":
+		raw.HTTPClientCA = &value
+	case "default_priority":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid default_priority %q: must be a positive integer", value)
+		}
+		raw.DefaultPriority = &n
+	case "default_max_retries":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid default_max_retries %q: must be a positive integer", value)
+		}
+		raw.DefaultMaxRetries = &n
+	case "prompt_change_action":
+		if value != "warn" && value != "reset" {
+			return fmt.Errorf("invalid prompt_change_action %q: must be warn or reset", value)
+		}
+		raw.PromptChangeAction = &value
+	case "task_change_action":
+		if value != "warn" && value != "reset" {
+			return fmt.Errorf("invalid task_change_action %q: must be warn or reset", value)
+		}
+		raw.TaskChangeAction = &value
+	case "state_retention":
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid state_retention %q: %w", value, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid state_retention %q: must not be negative", value)
+		}
+		raw.StateRetention = &value
+	case "archive_done":
+		b := parseBool(value)
+		raw.ArchiveDone = &b
+	case "encryption_key_file":
+		raw.EncryptionKeyFile = &value
+	case "container_runtime":
+		if value != "" && value != "docker" && value != "podman" {
+			return fmt.Errorf("invalid container_runtime %q: must be docker or podman", value)
+		}
+		raw.ContainerRuntime = &value
+	case "min_free_disk_mb":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid min_free_disk_mb %q: must be a non-negative integer", value)
+		}
+		raw.MinFreeDiskMB = &n
+	case "no_retry_failures":
+		if _, err := ParseFailureList(value); err != nil {
+			return fmt.Errorf("invalid no_retry_failures %q: %w", value, err)
+		}
+		raw.NoRetryFailures = &value
+	case "network_probe":
+		if value != "" {
+			if _, _, err := net.SplitHostPort(value); err != nil {
+				return fmt.Errorf("invalid network_probe %q: must be host:port", value)
+			}
+		}
+		raw.NetworkProbe = &value
+	case "scheduling":
+		if value != "priority" && value != "round_robin_by_dir" && value != "weighted" {
+			return fmt.Errorf("invalid scheduling %q: must be priority, round_robin_by_dir or weighted", value)
+		}
+		raw.Scheduling = &value
+	case "log_format":
+		if value != "text" && value != "ndjson" {
+			return fmt.Errorf("invalid log_format %q: must be text or ndjson", value)
+		}
+		raw.LogFormat = &value
 	}
+	return nil
 }
 
 // GetConfigValue returns the current effective value of a config key as a
 // string, after applying the full resolution order (file + env; no CLI flags).
-func GetConfigValue(key string) (string, error) {
+func (p Paths) GetConfigValue(key string) (string, error) {
 	if err := ValidateKey(key); err != nil {
 		return "", err
 	}
 
-	cfg, err := Load(nil)
+	cfg, err := p.Load(nil)
 	if err != nil {
 		return "", err
 	}
@@ -284,23 +1226,163 @@ func GetConfigValue(key string) (string, error) {
 		return fmt.Sprintf("%t", cfg.NotificationDesktop), nil
 	case "notification_bell":
 		return fmt.Sprintf("%t", cfg.NotificationBell), nil
+	case "notification_bell_repeat":
+		return strconv.Itoa(cfg.NotificationBellRepeat), nil
+	case "notification_sound_command":
+		return cfg.NotificationSoundCommand, nil
+	case "ntfy_topic":
+		return cfg.NtfyTopic, nil
+	case "ntfy_server":
+		return cfg.NtfyServer, nil
+	case "ntfy_token":
+		return cfg.NtfyToken, nil
+	case "pushover_user_key":
+		return cfg.PushoverUserKey, nil
+	case "pushover_app_token":
+		return cfg.PushoverAppToken, nil
+	case "pushover_retry":
+		return cfg.PushoverRetry.String(), nil
+	case "pushover_expire":
+		return cfg.PushoverExpire.String(), nil
+	case "notification_events":
+		return cfg.NotificationEvents, nil
+	case "on_event_command":
+		return cfg.OnEventCommand, nil
+	case "context_max_file_bytes":
+		return strconv.Itoa(cfg.ContextMaxFileBytes), nil
+	case "context_max_total_bytes":
+		return strconv.Itoa(cfg.ContextMaxTotalBytes), nil
+	case "context_binary":
+		return cfg.ContextBinary, nil
+	case "prompt_token_limit":
+		return strconv.Itoa(cfg.PromptTokenLimit), nil
+	case "prompt_limit_action":
+		return cfg.PromptLimitAction, nil
+	case "dir_lock_policy":
+		return cfg.DirLockPolicy, nil
+	case "hang_check_interval":
+		return cfg.HangCheckInterval.String(), nil
+	case "prompt_silence_gate":
+		return cfg.PromptSilenceGate.String(), nil
+	case "shutdown_poll_interval":
+		return cfg.ShutdownPollInterval.String(), nil
+	case "wait_poll_interval":
+		return cfg.WaitPollInterval.String(), nil
+	case "kill_grace_period":
+		return cfg.KillGracePeriod.String(), nil
+	case "usage_window":
+		return cfg.UsageWindow.String(), nil
+	case "pace_before_reset":
+		return cfg.PaceBeforeReset.String(), nil
+	case "prompt_action":
+		return cfg.PromptAction, nil
+	case "use_pty":
+		return fmt.Sprintf("%t", cfg.UsePTY), nil
+	case "fail_on_no_changes":
+		return fmt.Sprintf("%t", cfg.FailOnNoChanges), nil
+	case "http_listen":
+		return cfg.HTTPListen, nil
+	case "http_token":
+		return cfg.HTTPToken, nil
+	case "http_tls_cert":
+		return cfg.HTTPTLSCert, nil
+	case "http_tls_key":
+		return cfg.HTTPTLSKey, nil
+	case "http_client_ca":
+		return cfg.HTTPClientCA, nil
+	case "default_priority":
+		return strconv.Itoa(cfg.DefaultPriority), nil
+	case "default_max_retries":
+		return strconv.Itoa(cfg.DefaultMaxRetries), nil
+	case "prompt_change_action":
+		return cfg.PromptChangeAction, nil
+	case "task_change_action":
+		return cfg.TaskChangeAction, nil
+	case "state_retention":
+		return cfg.StateRetention.String(), nil
+	case "archive_done":
+		return fmt.Sprintf("%t", cfg.ArchiveDone), nil
+	case "encryption_key_file":
+		return cfg.EncryptionKeyFile, nil
+	case "container_runtime":
+		return cfg.ContainerRuntime, nil
+	case "min_free_disk_mb":
+		return strconv.Itoa(cfg.MinFreeDiskMB), nil
+	case "no_retry_failures":
+		return cfg.NoRetryFailures, nil
+	case "network_probe":
+		return cfg.NetworkProbe, nil
+	case "scheduling":
+		return cfg.Scheduling, nil
+	case "log_format":
+		return cfg.LogFormat, nil
 	default:
 		return "", fmt.Errorf("unknown key: %s", key)
 	}
 }
 
 // ListConfig returns all config keys and their current effective values.
-func ListConfig() (map[string]string, error) {
-	cfg, err := Load(nil)
+func (p Paths) ListConfig() (map[string]string, error) {
+	cfg, err := p.Load(nil)
 	if err != nil {
 		return nil, err
 	}
+	return configValues(cfg), nil
+}
 
+// configValues renders every key of cfg as a string.
+func configValues(cfg Config) map[string]string {
 	return map[string]string{
 		"skip_permissions":     fmt.Sprintf("%t", cfg.SkipPermissions),
 		"hang_timeout":         cfg.HangTimeout.String(),
 		"webhook_url":          cfg.WebhookURL,
 		"notification_desktop": fmt.Sprintf("%t", cfg.NotificationDesktop),
 		"notification_bell":    fmt.Sprintf("%t", cfg.NotificationBell),
-	}, nil
+
+		"notification_bell_repeat":   strconv.Itoa(cfg.NotificationBellRepeat),
+		"notification_sound_command": cfg.NotificationSoundCommand,
+		"ntfy_topic":                 cfg.NtfyTopic,
+		"ntfy_server":                cfg.NtfyServer,
+		"ntfy_token":                 cfg.NtfyToken,
+		"pushover_user_key":          cfg.PushoverUserKey,
+		"pushover_app_token":         cfg.PushoverAppToken,
+		"pushover_retry":             cfg.PushoverRetry.String(),
+		"pushover_expire":            cfg.PushoverExpire.String(),
+		"notification_events":        cfg.NotificationEvents,
+		"on_event_command":           cfg.OnEventCommand,
+		"context_max_file_bytes":     strconv.Itoa(cfg.ContextMaxFileBytes),
+		"context_max_total_bytes":    strconv.Itoa(cfg.ContextMaxTotalBytes),
+		"context_binary":             cfg.ContextBinary,
+		"prompt_token_limit":         strconv.Itoa(cfg.PromptTokenLimit),
+		"prompt_limit_action":        cfg.PromptLimitAction,
+		"dir_lock_policy":            cfg.DirLockPolicy,
+		"hang_check_interval":        cfg.HangCheckInterval.String(),
+		"prompt_silence_gate":        cfg.PromptSilenceGate.String(),
+		"shutdown_poll_interval":     cfg.ShutdownPollInterval.String(),
+		"wait_poll_interval":         cfg.WaitPollInterval.String(),
+		"kill_grace_period":          cfg.KillGracePeriod.String(),
+		"usage_window":               cfg.UsageWindow.String(),
+		"pace_before_reset":          cfg.PaceBeforeReset.String(),
+		"prompt_action":              cfg.PromptAction,
+		"use_pty":                    fmt.Sprintf("%t", cfg.UsePTY),
+		"fail_on_no_changes":         fmt.Sprintf("%t", cfg.FailOnNoChanges),
+		"http_listen":                cfg.HTTPListen,
+		"http_token":                 cfg.HTTPToken,
+		"http_tls_cert":              cfg.HTTPTLSCert,
+		"http_tls_key":               cfg.HTTPTLSKey,
+		"http_client_ca":             cfg.HTTPClientCA,
+		"default_priority":           strconv.Itoa(cfg.DefaultPriority),
+		"default_max_retries":        strconv.Itoa(cfg.DefaultMaxRetries),
+		"prompt_change_action":       cfg.PromptChangeAction,
+		"task_change_action":         cfg.TaskChangeAction,
+		"state_retention":            cfg.StateRetention.String(),
+		"archive_done":               fmt.Sprintf("%t", cfg.ArchiveDone),
+		"encryption_key_file":        cfg.EncryptionKeyFile,
+		"container_runtime":          cfg.ContainerRuntime,
+		"min_free_disk_mb":           strconv.Itoa(cfg.MinFreeDiskMB),
+		"no_retry_failures":          cfg.NoRetryFailures,
+		"network_probe":              cfg.NetworkProbe,
+		"scheduling":                 cfg.Scheduling,
+		"log_format":                 cfg.LogFormat,
+	}
 }
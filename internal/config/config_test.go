@@ -12,7 +12,7 @@ import (
 // ---------------------------------------------------------------------------
 
 func TestLoad_Defaults(t *testing.T) {
-	// Point BaseDir to a temp directory with no config file.
+	// Point the home directory at a temp directory with no config file.
 	dir := t.TempDir()
 	origHome := os.Getenv("HOME")
 	os.Setenv("HOME", dir)
@@ -29,7 +29,7 @@ func TestLoad_Defaults(t *testing.T) {
 		os.Unsetenv(key)
 	}
 
-	cfg, err := Load(nil)
+	cfg, err := Resolve("").Load(nil)
 	if err != nil {
 		t.Fatalf("Load: %v", err)
 	}
@@ -79,7 +79,7 @@ notification_bell: false
 `
 	os.WriteFile(confFile, []byte(content), 0644)
 
-	cfg, err := Load(nil)
+	cfg, err := Resolve("").Load(nil)
 	if err != nil {
 		t.Fatalf("Load: %v", err)
 	}
@@ -123,7 +123,7 @@ func TestLoad_OverridesApplied(t *testing.T) {
 		"hang_timeout":     "30s",
 	}
 
-	cfg, err := Load(overrides)
+	cfg, err := Resolve("").Load(overrides)
 	if err != nil {
 		t.Fatalf("Load: %v", err)
 	}
@@ -146,7 +146,7 @@ func TestLoad_UnknownOverrideKey(t *testing.T) {
 		"nonexistent_key": "value",
 	}
 
-	_, err := Load(overrides)
+	_, err := Resolve("").Load(overrides)
 	if err == nil {
 		t.Fatal("expected error for unknown override key")
 	}
@@ -204,11 +204,11 @@ func TestSetGetConfigValue_Roundtrip(t *testing.T) {
 	// Create the config directory.
 	os.MkdirAll(filepath.Join(dir, ".claude-autopilot"), 0755)
 
-	if err := SetConfigValue("webhook_url", "https://hooks.example.com"); err != nil {
+	if err := Resolve("").SetConfigValue("webhook_url", "https://hooks.example.com"); err != nil {
 		t.Fatalf("SetConfigValue: %v", err)
 	}
 
-	val, err := GetConfigValue("webhook_url")
+	val, err := Resolve("").GetConfigValue("webhook_url")
 	if err != nil {
 		t.Fatalf("GetConfigValue: %v", err)
 	}
@@ -217,15 +217,61 @@ func TestSetGetConfigValue_Roundtrip(t *testing.T) {
 	}
 }
 
+func TestSetConfigValue_BellRepeatMustBeInteger(t *testing.T) {
+	dir := t.TempDir()
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", dir)
+	defer os.Setenv("HOME", origHome)
+	os.Unsetenv("CLAUDE_AUTOPILOT_NOTIFICATION_BELL_REPEAT")
+
+	if err := Resolve("").SetConfigValue("notification_bell_repeat", "lots"); err == nil {
+		t.Fatal("expected error for non-integer notification_bell_repeat")
+	}
+
+	if err := Resolve("").SetConfigValue("notification_bell_repeat", "5"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+	cfg, err := Resolve("").Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.NotificationBellRepeat != 5 {
+		t.Errorf("NotificationBellRepeat = %d; want 5", cfg.NotificationBellRepeat)
+	}
+}
+
+func TestTaskDefaults(t *testing.T) {
+	p := At(t.TempDir())
+	t.Setenv("CLAUDE_AUTOPILOT_DEFAULT_PRIORITY", "")
+	os.Unsetenv("CLAUDE_AUTOPILOT_DEFAULT_PRIORITY")
+	t.Setenv("CLAUDE_AUTOPILOT_DEFAULT_MAX_RETRIES", "0")
+
+	if pri, retries := p.TaskDefaults(); pri != 10 || retries != 5 {
+		t.Errorf("TaskDefaults = %d, %d; want built-in 10, 5", pri, retries)
+	}
+	if err := p.SetConfigValue("default_priority", "0"); err == nil {
+		t.Error("expected error for default_priority 0")
+	}
+	if err := p.SetConfigValue("default_priority", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Load(nil); err == nil {
+		t.Error("Load should reject CLAUDE_AUTOPILOT_DEFAULT_MAX_RETRIES=0")
+	}
+	if pri, retries := p.TaskDefaults(); pri != 3 || retries != 5 {
+		t.Errorf("TaskDefaults = %d, %d; want 3, 5", pri, retries)
+	}
+}
+
 func TestSetConfigValue_InvalidKey(t *testing.T) {
-	err := SetConfigValue("not_a_key", "value")
+	err := Resolve("").SetConfigValue("not_a_key", "value")
 	if err == nil {
 		t.Fatal("expected error for invalid key")
 	}
 }
 
 func TestGetConfigValue_InvalidKey(t *testing.T) {
-	_, err := GetConfigValue("not_a_key")
+	_, err := Resolve("").GetConfigValue("not_a_key")
 	if err == nil {
 		t.Fatal("expected error for invalid key")
 	}
@@ -252,7 +298,7 @@ func TestListConfig_ReturnsAllKeys(t *testing.T) {
 		os.Unsetenv(key)
 	}
 
-	result, err := ListConfig()
+	result, err := Resolve("").ListConfig()
 	if err != nil {
 		t.Fatalf("ListConfig: %v", err)
 	}
@@ -263,6 +309,45 @@ func TestListConfig_ReturnsAllKeys(t *testing.T) {
 		"webhook_url",
 		"notification_desktop",
 		"notification_bell",
+		"notification_bell_repeat",
+		"notification_sound_command",
+		"ntfy_topic",
+		"ntfy_server",
+		"ntfy_token",
+		"pushover_user_key",
+		"pushover_app_token",
+		"pushover_retry",
+		"pushover_expire",
+		"notification_events",
+		"on_event_command",
+		"context_max_file_bytes",
+		"context_max_total_bytes",
+		"context_binary",
+		"prompt_token_limit",
+		"prompt_limit_action",
+		"dir_lock_policy",
+		"hang_check_interval",
+		"prompt_silence_gate",
+		"shutdown_poll_interval",
+		"wait_poll_interval",
+		"kill_grace_period",
+		"usage_window",
+		"pace_before_reset",
+		"prompt_action",
+		"use_pty",
+		"fail_on_no_changes",
+		"http_listen",
+		"http_token",
+		"http_tls_cert",
+		"http_tls_key",
+		"http_client_ca",
+		"default_priority",
+		"default_max_retries",
+		"prompt_change_action",
+		"task_change_action",
+		"state_retention", "archive_done", "encryption_key_file",
+		"container_runtime", "min_free_disk_mb", "no_retry_failures",
+		"network_probe", "scheduling", "log_format",
 	}
 
 	for _, k := range expectedKeys {
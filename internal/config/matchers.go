@@ -4,8 +4,9 @@ import (
 	_ "embed"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strings"
 
+	"github.com/hseinmoussa/claude-autopilot/internal/detector"
 	"gopkg.in/yaml.v3"
 )
 
@@ -18,16 +19,34 @@ type MatchersConfig struct {
 	RateLimitPatterns []string `yaml:"rate_limit_patterns"`
 	PromptPatterns    []string `yaml:"prompt_patterns"`
 
+	// FailurePatterns classify failed invocations, keyed by failure
+	// category (auth, network, context_too_long, permission_denied,
+	// cli_crash).
+	FailurePatterns map[string][]string `yaml:"failure_patterns,omitempty"`
+
 	// Exclude lists let user overrides selectively remove default patterns.
-	ExcludeRateLimitPatterns []string `yaml:"exclude_rate_limit_patterns,omitempty"`
-	ExcludePromptPatterns    []string `yaml:"exclude_prompt_patterns,omitempty"`
+	ExcludeRateLimitPatterns []string            `yaml:"exclude_rate_limit_patterns,omitempty"`
+	ExcludePromptPatterns    []string            `yaml:"exclude_prompt_patterns,omitempty"`
+	ExcludeFailurePatterns   map[string][]string `yaml:"exclude_failure_patterns,omitempty"`
+
+	// PromptAnswers are the replies available when prompt_action is
+	// "answer". There are no defaults; tasks opt in by name via auto_approve.
+	PromptAnswers []PromptAnswer `yaml:"prompt_answers,omitempty"`
+}
+
+// PromptAnswer replies to an interactive prompt whose output contains
+// Pattern (case-insensitive) by writing Response to the CLI's terminal.
+type PromptAnswer struct {
+	Name     string `yaml:"name"`
+	Pattern  string `yaml:"pattern"`
+	Response string `yaml:"response"`
 }
 
 // LoadMatchers loads the merged matcher configuration. Defaults are read from
 // the embedded matchers.default.yaml. User overrides from
-// ~/.claude-autopilot/matchers.yaml extend the default lists; exclude lists
+// matchers.yaml in the config directory extend the default lists; exclude lists
 // remove entries from the defaults.
-func LoadMatchers() (MatchersConfig, error) {
+func (p Paths) LoadMatchers() (MatchersConfig, error) {
 	// Parse embedded defaults.
 	var base MatchersConfig
 	if err := yaml.Unmarshal(defaultMatchersYAML, &base); err != nil {
@@ -35,7 +54,7 @@ func LoadMatchers() (MatchersConfig, error) {
 	}
 
 	// Parse user overrides (optional).
-	userPath := filepath.Join(BaseDir(), "matchers.yaml")
+	userPath := p.MatchersFile()
 	data, err := os.ReadFile(userPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -49,6 +68,19 @@ func LoadMatchers() (MatchersConfig, error) {
 		return base, fmt.Errorf("parse user matchers: %w", err)
 	}
 
+	for _, a := range user.PromptAnswers {
+		if a.Name == "" || a.Pattern == "" {
+			return base, fmt.Errorf("%s: prompt_answers entries need a name and a pattern", userPath)
+		}
+	}
+	for _, m := range []map[string][]string{user.FailurePatterns, user.ExcludeFailurePatterns} {
+		for name := range m {
+			if _, err := detector.ParseFailureCategory(name); err != nil {
+				return base, fmt.Errorf("%s: %w", userPath, err)
+			}
+		}
+	}
+
 	return merge(base, user), nil
 }
 
@@ -68,6 +100,15 @@ func merge(base, user MatchersConfig) MatchersConfig {
 	// Append user additions (deduplicated against existing entries).
 	result.RateLimitPatterns = appendUnique(result.RateLimitPatterns, user.RateLimitPatterns)
 	result.PromptPatterns = appendUnique(result.PromptPatterns, user.PromptPatterns)
+	result.PromptAnswers = user.PromptAnswers
+
+	result.FailurePatterns = make(map[string][]string, len(base.FailurePatterns))
+	for category, patterns := range base.FailurePatterns {
+		result.FailurePatterns[category] = filterExcluded(patterns, toSet(user.ExcludeFailurePatterns[category]))
+	}
+	for category, patterns := range user.FailurePatterns {
+		result.FailurePatterns[category] = appendUnique(result.FailurePatterns[category], patterns)
+	}
 
 	return result
 }
@@ -100,3 +141,31 @@ func appendUnique(base, additions []string) []string {
 	}
 	return base
 }
+
+// FailurePatternsByCategory returns the failure patterns keyed by their
+// detector category. Categories were validated when the matchers loaded.
+func (m MatchersConfig) FailurePatternsByCategory() map[detector.FailureCategory][]string {
+	out := make(map[detector.FailureCategory][]string, len(m.FailurePatterns))
+	for name, patterns := range m.FailurePatterns {
+		out[detector.FailureCategory(name)] = patterns
+	}
+	return out
+}
+
+// ParseFailureList parses a comma-separated list of failure categories,
+// like no_retry_failures.
+func ParseFailureList(s string) ([]detector.FailureCategory, error) {
+	var out []detector.FailureCategory
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		c, err := detector.ParseFailureCategory(name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
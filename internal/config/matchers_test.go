@@ -17,7 +17,7 @@ func TestLoadMatchers_DefaultPatterns(t *testing.T) {
 	os.Setenv("HOME", dir)
 	defer os.Setenv("HOME", origHome)
 
-	mc, err := LoadMatchers()
+	mc, err := Resolve("").LoadMatchers()
 	if err != nil {
 		t.Fatalf("LoadMatchers: %v", err)
 	}
@@ -63,7 +63,7 @@ prompt_patterns:
 `
 	os.WriteFile(filepath.Join(confDir, "matchers.yaml"), []byte(userYAML), 0644)
 
-	mc, err := LoadMatchers()
+	mc, err := Resolve("").LoadMatchers()
 	if err != nil {
 		t.Fatalf("LoadMatchers: %v", err)
 	}
@@ -125,7 +125,7 @@ exclude_prompt_patterns:
 `
 	os.WriteFile(filepath.Join(confDir, "matchers.yaml"), []byte(userYAML), 0644)
 
-	mc, err := LoadMatchers()
+	mc, err := Resolve("").LoadMatchers()
 	if err != nil {
 		t.Fatalf("LoadMatchers: %v", err)
 	}
@@ -202,3 +202,93 @@ func TestMerge_DeduplicatesUserAdditions(t *testing.T) {
 		t.Error("\"y\" should be added to PromptPatterns")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Prompt answers
+// ---------------------------------------------------------------------------
+
+func TestLoadMatchers_PromptAnswers(t *testing.T) {
+	dir := t.TempDir()
+	p := At(dir)
+
+	userYAML := `
+prompt_answers:
+  - name: proceed
+    pattern: "Do you want to proceed?"
+    response: "y\n"
+`
+	os.WriteFile(p.MatchersFile(), []byte(userYAML), 0644)
+
+	mc, err := p.LoadMatchers()
+	if err != nil {
+		t.Fatalf("LoadMatchers: %v", err)
+	}
+	if len(mc.PromptAnswers) != 1 || mc.PromptAnswers[0].Name != "proceed" || mc.PromptAnswers[0].Response != "y\n" {
+		t.Errorf("PromptAnswers = %+v", mc.PromptAnswers)
+	}
+
+	os.WriteFile(p.MatchersFile(), []byte("prompt_answers:\n  - response: \"y\\n\"\n"), 0644)
+	if _, err := p.LoadMatchers(); err == nil {
+		t.Error("expected an error for an answer without name and pattern")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Failure patterns
+// ---------------------------------------------------------------------------
+
+func TestLoadMatchers_FailurePatterns(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	confDir := filepath.Join(dir, ".claude-autopilot")
+	os.MkdirAll(confDir, 0755)
+
+	userYAML := `
+failure_patterns:
+  network:
+    - "proxy refused"
+exclude_failure_patterns:
+  cli_crash:
+    - "panic:"
+`
+	os.WriteFile(filepath.Join(confDir, "matchers.yaml"), []byte(userYAML), 0644)
+
+	mc, err := Resolve("").LoadMatchers()
+	if err != nil {
+		t.Fatalf("LoadMatchers: %v", err)
+	}
+	byCategory := mc.FailurePatternsByCategory()
+	if !contains(byCategory["network"], "proxy refused") || !contains(byCategory["network"], "ECONNREFUSED") {
+		t.Errorf("network patterns = %v; want defaults plus the user's", byCategory["network"])
+	}
+	if contains(byCategory["cli_crash"], "panic:") || len(byCategory["cli_crash"]) == 0 {
+		t.Errorf("cli_crash patterns = %v; want defaults without panic:", byCategory["cli_crash"])
+	}
+	if len(byCategory["auth"]) == 0 {
+		t.Error("expected default auth patterns")
+	}
+
+	os.WriteFile(filepath.Join(confDir, "matchers.yaml"), []byte("failure_patterns:\n  gremlins: [\"boo\"]\n"), 0644)
+	if _, err := Resolve("").LoadMatchers(); err == nil {
+		t.Error("LoadMatchers with an unknown failure category succeeded; want an error")
+	}
+}
+
+func TestParseFailureList(t *testing.T) {
+	got, err := ParseFailureList(" auth, network ,")
+	if err != nil || len(got) != 2 || got[0] != "auth" || got[1] != "network" {
+		t.Errorf("ParseFailureList = %v, %v; want [auth network]", got, err)
+	}
+	if _, err := ParseFailureList("auth,nope"); err == nil {
+		t.Error("ParseFailureList(nope) succeeded; want an error")
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
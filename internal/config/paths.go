@@ -0,0 +1,197 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HomeEnv relocates the whole claude-autopilot directory, like --state-dir.
+const HomeEnv = "CLAUDE_AUTOPILOT_HOME"
+
+// appName is the directory name used under XDG base directories.
+const appName = "claude-autopilot"
+
+// Paths locates claude-autopilot's on-disk files. Home holds everything the
+// runner mutates (tasks/, state/, logs/, control/, runner.lock); ConfigDir
+// holds config.yaml and matchers.yaml. Both are the same directory unless
+// XDG base directories are in use.
+type Paths struct {
+	Home      string
+	ConfigDir string
+}
+
+// At returns Paths rooted entirely at dir.
+func At(dir string) Paths {
+	return Paths{Home: dir, ConfigDir: dir}
+}
+
+// Resolve determines where claude-autopilot keeps its files, in order:
+//
+//	stateDir (--state-dir) > $CLAUDE_AUTOPILOT_HOME > existing ~/.claude-autopilot >
+//	$XDG_STATE_HOME / $XDG_CONFIG_HOME > ~/.claude-autopilot
+//
+// An existing ~/.claude-autopilot wins over XDG so that setting the XDG
+// variables later does not strand an established queue.
+func Resolve(stateDir string) Paths {
+	if stateDir != "" {
+		return At(absPath(stateDir))
+	}
+	if v := os.Getenv(HomeEnv); v != "" {
+		return At(absPath(v))
+	}
+
+	legacy := filepath.Join(userHome(), ".claude-autopilot")
+	if _, err := os.Stat(legacy); err == nil {
+		return At(legacy)
+	}
+
+	p := At(legacy)
+	if v := os.Getenv("XDG_STATE_HOME"); v != "" {
+		p.Home = filepath.Join(v, appName)
+		p.ConfigDir = p.Home
+	}
+	if v := os.Getenv("XDG_CONFIG_HOME"); v != "" {
+		p.ConfigDir = filepath.Join(v, appName)
+	}
+	return p
+}
+
+// Queue returns the Paths for a named queue: tasks, state, logs and the
+// runner lock move to <home>/queues/<name>/, while config is shared. An
+// empty name returns p unchanged.
+func (p Paths) Queue(name string) (Paths, error) {
+	if name == "" {
+		return p, nil
+	}
+	if !validQueueName(name) {
+		return p, fmt.Errorf("invalid queue name %q: use letters, digits, '-' and '_'", name)
+	}
+	p.Home = filepath.Join(p.Home, "queues", name)
+	return p, nil
+}
+
+func validQueueName(name string) bool {
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+func userHome() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		// Fall back to HOME env var.
+		home = os.Getenv("HOME")
+	}
+	return home
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
+
+// TasksDir is the global task directory.
+func (p Paths) TasksDir() string { return filepath.Join(p.Home, "tasks") }
+
+// StateDir holds per-task .state.json and .init.json files.
+func (p Paths) StateDir() string { return filepath.Join(p.Home, "state") }
+
+// LogsDir holds per-task logs and summary.log.
+func (p Paths) LogsDir() string { return filepath.Join(p.Home, "logs") }
+
+// TaskLog is the per-task log of id, holding every attempt; rotation moves
+// it to TaskLog(id)+".1".
+func (p Paths) TaskLog(id string) string { return filepath.Join(p.LogsDir(), id+".log") }
+
+// EventLog is the runner's append-only record of lifecycle events, state
+// changes, detections and control commands.
+func (p Paths) EventLog() string { return filepath.Join(p.LogsDir(), "events.jsonl") }
+
+// ControlDir holds the queued control command file.
+func (p Paths) ControlDir() string { return filepath.Join(p.Home, "control") }
+
+// LockPath is the runner lockfile.
+func (p Paths) LockPath() string { return filepath.Join(p.Home, "runner.lock") }
+
+// RateLimitHistory records observed rate limits for usage-window prediction.
+func (p Paths) RateLimitHistory() string { return filepath.Join(p.Home, "ratelimits.json") }
+
+// DurationHistory records how long completed tasks ran, for queue ETAs.
+func (p Paths) DurationHistory() string { return filepath.Join(p.Home, "durations.json") }
+
+// RunHistory holds the summaries of recent runs for the summary command.
+func (p Paths) RunHistory() string { return filepath.Join(p.Home, "runs.json") }
+
+// ArtifactsDir holds copies of task output files, per task and attempt.
+func (p Paths) ArtifactsDir() string { return filepath.Join(p.Home, "artifacts") }
+
+// ArchiveDir holds archived tasks, in one directory per day.
+func (p Paths) ArchiveDir() string { return filepath.Join(p.Home, "archive") }
+
+// TrashDir holds snapshots of cancelled and removed tasks for 'undo'.
+func (p Paths) TrashDir() string { return filepath.Join(p.Home, "trash") }
+
+// WorktreesDir holds the git worktrees of review-mode tasks.
+func (p Paths) WorktreesDir() string { return filepath.Join(p.Home, "worktrees") }
+
+// HTTPTokenFile holds the generated HTTP listener token when http_token is
+// not set.
+func (p Paths) HTTPTokenFile() string { return filepath.Join(p.Home, "http.token") }
+
+// HealthFile returns the runner snapshot for external monitors.
+func (p Paths) HealthFile() string { return filepath.Join(p.Home, "health.json") }
+
+// GroupLockPath is the lock held while a task of concurrency group name
+// runs. It sits beside the config so that every queue shares it.
+func (p Paths) GroupLockPath(name string) string {
+	return filepath.Join(p.ConfigDir, "locks", name+".lock")
+}
+
+// RunLockPath is shared by every running task and held exclusively while an
+// exclusive task runs. Group names cannot contain '_', so it never clashes
+// with a group lock.
+func (p Paths) RunLockPath() string { return filepath.Join(p.ConfigDir, "locks", "_running.lock") }
+
+// DrainLockPath is held by an instance waiting to start an exclusive task;
+// while it is held no other task starts.
+func (p Paths) DrainLockPath() string { return filepath.Join(p.ConfigDir, "locks", "_drain.lock") }
+
+// ConfigFile is the main config file.
+func (p Paths) ConfigFile() string { return filepath.Join(p.ConfigDir, "config.yaml") }
+
+// MatchersFile holds user matcher overrides.
+func (p Paths) MatchersFile() string { return filepath.Join(p.ConfigDir, "matchers.yaml") }
+
+// ClaudeVersionsFile lists the Claude CLI binaries pinned with 'compat pin'.
+func (p Paths) ClaudeVersionsFile() string { return filepath.Join(p.ConfigDir, "claude-versions.yaml") }
+
+// AgentsFile defines agents by command template, for CLIs without a
+// built-in adapter.
+func (p Paths) AgentsFile() string { return filepath.Join(p.ConfigDir, "agents.yaml") }
+
+// EnsureDirs creates the full directory tree required by claude-autopilot:
+// home, state, tasks, logs, control, and the config directory.
+func (p Paths) EnsureDirs() error {
+	dirs := []string{
+		p.Home,
+		p.StateDir(),
+		p.TasksDir(),
+		p.LogsDir(),
+		p.ControlDir(),
+		p.ConfigDir,
+	}
+	for _, d := range dirs {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("create dir %s: %w", d, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolve_Precedence(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv(HomeEnv, "")
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "config"))
+
+	p := Resolve("")
+	if p.Home != filepath.Join(home, "state", "claude-autopilot") || p.ConfigDir != filepath.Join(home, "config", "claude-autopilot") {
+		t.Errorf("XDG paths = %+v", p)
+	}
+
+	legacy := filepath.Join(home, ".claude-autopilot")
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if p := Resolve(""); p != At(legacy) {
+		t.Errorf("existing ~/.claude-autopilot should win over XDG: %+v", p)
+	}
+
+	t.Setenv(HomeEnv, filepath.Join(home, "env"))
+	if p := Resolve(""); p != At(filepath.Join(home, "env")) {
+		t.Errorf("%s paths = %+v", HomeEnv, p)
+	}
+
+	if p := Resolve(filepath.Join(home, "flag")); p != At(filepath.Join(home, "flag")) {
+		t.Errorf("--state-dir paths = %+v", p)
+	}
+}
+
+func TestPaths_EnsureDirs(t *testing.T) {
+	dir := t.TempDir()
+	p := Paths{Home: filepath.Join(dir, "home"), ConfigDir: filepath.Join(dir, "cfg")}
+	if err := p.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{p.TasksDir(), p.StateDir(), p.LogsDir(), p.ControlDir(), p.ConfigDir} {
+		if _, err := os.Stat(d); err != nil {
+			t.Errorf("missing %s: %v", d, err)
+		}
+	}
+}
+
+func TestPaths_Queue(t *testing.T) {
+	p := At("/data")
+	q, err := p.Queue("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Home != filepath.Join("/data", "queues", "work") || q.ConfigDir != "/data" {
+		t.Errorf("queue paths = %+v", q)
+	}
+	if q, _ := p.Queue(""); q != p {
+		t.Errorf("empty queue should be the default: %+v", q)
+	}
+	for _, bad := range []string{"../x", "a/b", "with space"} {
+		if _, err := p.Queue(bad); err == nil {
+			t.Errorf("Queue(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of an effective config value, lowest precedence first.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// Setting is one key of the effective configuration (file + env; no CLI
+// flags) together with where its value came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Err    error // invalid value in the config file or environment
+}
+
+// Check reports every known key's effective value and source, sorted by key,
+// along with unknown keys found in the config file. Invalid values are
+// reported per key in Setting.Err; the returned error is reserved for a
+// config file that cannot be read or parsed.
+func (p Paths) Check() ([]Setting, []string, error) {
+	_, settings, unknown, err := p.resolve()
+	return settings, unknown, err
+}
+
+// resolve merges defaults, the config file and the environment. Invalid
+// values are skipped when building the Config and recorded on the matching
+// Setting instead.
+func (p Paths) resolve() (Config, []Setting, []string, error) {
+	cfg := defaults()
+
+	raw, err := p.loadRawFile()
+	if err != nil {
+		return cfg, nil, nil, err
+	}
+	fileVals, err := p.fileValues()
+	if err != nil {
+		return cfg, nil, nil, err
+	}
+	applyFileToConfig(raw, &cfg)
+	applyEnvToConfig(&cfg)
+	effective := configValues(cfg)
+
+	var unknown []string
+	for k := range fileVals {
+		if !knownKeys[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+
+	keys := make([]string, 0, len(knownKeys))
+	for k := range knownKeys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, k := range keys {
+		s := Setting{Key: k, Value: effective[k], Source: SourceDefault}
+		if v, ok := fileVals[k]; ok {
+			s.Source = SourceFile
+			if err := validateValue(k, v); err != nil {
+				s.Err = fmt.Errorf("%s: %w", p.ConfigFile(), err)
+			}
+		}
+		if v, ok := lookupEnv(k); ok {
+			s.Source = SourceEnv
+			if err := validateValue(k, v); err != nil && s.Err == nil {
+				s.Err = fmt.Errorf("%s: %w", envName(k), err)
+			}
+		}
+		settings = append(settings, s)
+	}
+	return cfg, settings, unknown, nil
+}
+
+// fileValues returns the config file's top-level entries rendered as
+// strings. Keys with null values are omitted.
+func (p Paths) fileValues() (map[string]string, error) {
+	data, err := os.ReadFile(p.ConfigFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var m map[string]interface{}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse config file: %w", err)
+	}
+	vals := make(map[string]string, len(m))
+	for k, v := range m {
+		if v != nil {
+			vals[k] = fmt.Sprint(v)
+		}
+	}
+	return vals, nil
+}
+
+// validateValue applies the same checks as `config set` to a single value.
+func validateValue(key, value string) error {
+	var scratch configFileRaw
+	return setRawValue(&scratch, key, value)
+}
+
+// validateWebhookURL accepts an empty value (webhooks disabled) or an
+// absolute http(s) URL.
+func validateWebhookURL(v string) error {
+	if v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook_url %q: must be an http:// or https:// URL", v)
+	}
+	return nil
+}
+
+// validateInterval accepts a strictly positive duration.
+func validateInterval(key, v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, v, err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("invalid %s %q: must be positive", key, v)
+	}
+	return nil
+}
+
+// envName returns the environment variable that sets key.
+func envName(key string) string {
+	return "CLAUDE_AUTOPILOT_" + strings.ToUpper(key)
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) Paths {
+	t.Helper()
+	p := At(t.TempDir())
+	if err := os.WriteFile(filepath.Join(p.ConfigDir, "config.yaml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad_RejectsMalformedDuration(t *testing.T) {
+	p := writeConfigFile(t, "hang_timeout: 5x\n")
+	_, err := p.Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid hang_timeout") {
+		t.Fatalf("err = %v; want invalid hang_timeout", err)
+	}
+}
+
+func TestLoad_RejectsMalformedEnvValue(t *testing.T) {
+	t.Setenv("CLAUDE_AUTOPILOT_PUSHOVER_RETRY", "soon")
+	_, err := At(t.TempDir()).Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "CLAUDE_AUTOPILOT_PUSHOVER_RETRY") {
+		t.Fatalf("err = %v; want env var named in error", err)
+	}
+}
+
+func TestSetConfigValue_ValidatesWebhookURL(t *testing.T) {
+	p := At(t.TempDir())
+	for _, bad := range []string{"hooks.example.com", "ftp://example.com/x", "https://"} {
+		if err := p.SetConfigValue("webhook_url", bad); err == nil {
+			t.Errorf("webhook_url %q should be rejected", bad)
+		}
+	}
+	if err := p.SetConfigValue("webhook_url", "https://hooks.example.com/x"); err != nil {
+		t.Errorf("valid webhook rejected: %v", err)
+	}
+	if err := p.SetConfigValue("webhook_url", ""); err != nil {
+		t.Errorf("clearing webhook rejected: %v", err)
+	}
+}
+
+func TestCheck_SourcesErrorsAndUnknownKeys(t *testing.T) {
+	p := writeConfigFile(t, "webhook_url: not-a-url\nnotification_bell: false\nhang_timout: 5m\n")
+	t.Setenv("CLAUDE_AUTOPILOT_NOTIFICATION_BELL", "true")
+
+	settings, unknown, err := p.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byKey := make(map[string]Setting, len(settings))
+	for _, s := range settings {
+		byKey[s.Key] = s
+	}
+
+	if s := byKey["webhook_url"]; s.Source != SourceFile || s.Err == nil {
+		t.Errorf("webhook_url = %+v; want file source with error", s)
+	}
+	if s := byKey["notification_bell"]; s.Source != SourceEnv || s.Value != "true" || s.Err != nil {
+		t.Errorf("notification_bell = %+v; want env source", s)
+	}
+	if s := byKey["hang_timeout"]; s.Source != SourceDefault {
+		t.Errorf("hang_timeout = %+v; want default", s)
+	}
+	if len(unknown) != 1 || unknown[0] != "hang_timout" {
+		t.Errorf("unknown = %v; want [hang_timout]", unknown)
+	}
+}
+
+func TestSetConfigValue_IntervalsMustBePositive(t *testing.T) {
+	p := At(t.TempDir())
+	for _, bad := range []string{"0s", "-5s", "fast"} {
+		if err := p.SetConfigValue("hang_check_interval", bad); err == nil {
+			t.Errorf("hang_check_interval %q should be rejected", bad)
+		}
+	}
+	if err := p.SetConfigValue("kill_grace_period", "3s"); err != nil {
+		t.Fatalf("SetConfigValue: %v", err)
+	}
+	cfg, err := p.Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.KillGracePeriod.String() != "3s" || cfg.ShutdownPollInterval.String() != "500ms" {
+		t.Errorf("kill_grace_period = %v, shutdown_poll_interval = %v", cfg.KillGracePeriod, cfg.ShutdownPollInterval)
+	}
+}
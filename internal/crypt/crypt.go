@@ -0,0 +1,173 @@
+// Package crypt encrypts sensitive task data at rest (prompts, transcript
+// excerpts in task state, and task logs) with AES-256-GCM under a key file.
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Prefix marks an encrypted value: Prefix + base64(nonce || ciphertext).
+const Prefix = "enc:v1:"
+
+// Key seals and opens values with AES-256-GCM.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// GenerateKey writes a new random key to path, readable only by its owner.
+// It refuses to overwrite an existing file, since that would make
+// everything sealed under the old key unreadable.
+func GenerateKey(path string) error {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("generate key: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists; remove it first only if nothing is encrypted with it", path)
+		}
+		return fmt.Errorf("create key file: %w", err)
+	}
+	if _, err := fmt.Fprintln(f, hex.EncodeToString(raw)); err != nil {
+		f.Close()
+		return fmt.Errorf("write key file: %w", err)
+	}
+	return f.Close()
+}
+
+// LoadKey reads a key written by GenerateKey: 64 hex characters. On Unix
+// the file must not be readable by group or others.
+func LoadKey(path string) (*Key, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("encryption key %s is accessible by other users; run chmod 600 on it", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key: %w", err)
+	}
+	raw, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(raw) != 32 {
+		return nil, fmt.Errorf("encryption key %s: want 64 hex characters", path)
+	}
+	return newKey(raw)
+}
+
+func newKey(raw []byte) (*Key, error) {
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{aead: aead}, nil
+}
+
+// IsSealed reports whether s is an encrypted value.
+func IsSealed(s string) bool {
+	return strings.HasPrefix(s, Prefix)
+}
+
+// Seal encrypts plain and returns it as a single-line string.
+func (k *Key) Seal(plain []byte) string {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		panic("crypt: read random nonce: " + err.Error())
+	}
+	sealed := k.aead.Seal(nonce, nonce, plain, nil)
+	return Prefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+// Open decrypts a value produced by Seal.
+func (k *Key) Open(s string) ([]byte, error) {
+	if !IsSealed(s) {
+		return nil, errors.New("value is not encrypted")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, Prefix))
+	if err != nil || len(data) < k.aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	n := k.aead.NonceSize()
+	plain, err := k.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, errors.New("cannot decrypt value: wrong encryption key or corrupted data")
+	}
+	return plain, nil
+}
+
+// LineWriter returns a writer that seals each complete line written to it
+// and writes it to w on a line of its own. Empty lines stay empty. Callers
+// must end their output with a newline; a trailing partial line is dropped.
+// The writer is safe for concurrent use.
+func (k *Key) LineWriter(w io.Writer) io.Writer {
+	return &lineWriter{key: k, w: w}
+}
+
+type lineWriter struct {
+	mu  sync.Mutex
+	key *Key
+	w   io.Writer
+	buf []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := lw.buf[:i]
+		out := "\n"
+		if len(line) > 0 {
+			out = lw.key.Seal(line) + "\n"
+		}
+		lw.buf = lw.buf[i+1:]
+		if _, err := io.WriteString(lw.w, out); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// OpenLines copies r to w, decrypting sealed lines and passing others
+// through unchanged, so logs written partly before encryption was enabled
+// read back whole.
+func (k *Key) OpenLines(w io.Writer, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if IsSealed(line) {
+			plain, err := k.Open(line)
+			if err != nil {
+				return err
+			}
+			line = string(plain)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,80 @@
+package crypt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) (*Key, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := GenerateKey(path); err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	k, err := LoadKey(path)
+	if err != nil {
+		t.Fatalf("LoadKey: %v", err)
+	}
+	return k, path
+}
+
+func TestSealOpen(t *testing.T) {
+	k, path := testKey(t)
+	sealed := k.Seal([]byte("secret prompt"))
+	if !IsSealed(sealed) || strings.Contains(sealed, "secret") || strings.Contains(sealed, "\n") {
+		t.Fatalf("Seal = %q; want a single opaque line", sealed)
+	}
+	if again := k.Seal([]byte("secret prompt")); again == sealed {
+		t.Error("sealing twice gave the same ciphertext")
+	}
+	plain, err := k.Open(sealed)
+	if err != nil || string(plain) != "secret prompt" {
+		t.Errorf("Open = %q, %v", plain, err)
+	}
+
+	other, _ := testKey(t)
+	if _, err := other.Open(sealed); err == nil {
+		t.Error("Open with another key should fail")
+	}
+	if err := GenerateKey(path); err == nil {
+		t.Error("GenerateKey overwrote an existing key")
+	}
+}
+
+func TestLoadKey_RejectsSharedFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+	_, path := testKey(t)
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKey(path); err == nil {
+		t.Error("LoadKey accepted a world-readable key file")
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	k, _ := testKey(t)
+	var buf bytes.Buffer
+	w := k.LineWriter(&buf)
+	fmt.Fprintf(w, "\n[header] attempt=1\n")
+	fmt.Fprint(w, "partial ")
+	fmt.Fprintln(w, "line")
+	if strings.Contains(buf.String(), "attempt") || strings.Count(buf.String(), "\n") != 3 {
+		t.Fatalf("written = %q; want three lines, two sealed", buf.String())
+	}
+
+	var out bytes.Buffer
+	if err := k.OpenLines(&out, strings.NewReader(buf.String()+"plain line\n")); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n[header] attempt=1\npartial line\nplain line\n"; out.String() != want {
+		t.Errorf("OpenLines = %q; want %q", out.String(), want)
+	}
+}
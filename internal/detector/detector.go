@@ -39,27 +39,50 @@ func (d DetectionResult) String() string {
 // RateLimitResult contains the full detection outcome.
 type RateLimitResult struct {
 	Result    DetectionResult
-	ResetTime *time.Time // non-nil if a reset time could be extracted
-	Reason    string     // human-readable explanation of detection
+	ResetTime *time.Time      // non-nil if a reset time could be extracted
+	Reason    string          // human-readable explanation of detection
+	Category  FailureCategory // for Failed: why, when the output shows it
 }
 
 // Detector inspects CLI exit codes and output to detect rate limits.
 type Detector struct {
-	patterns           []string
-	rateLimitExitCode  int
-	resetTimeRegex     *regexp.Regexp
+	patterns          []string
+	rateLimitExitCode int
+	resetTimeRegex    *regexp.Regexp
+	failurePatterns   map[FailureCategory][]string
 }
 
+// resetTimeRegex captures the reset time after "reset at", "resets in",
+// or a "reset_at"/"resets_at"/"reset:" key in a JSON body or header. A "."
+// ends the capture only before a space or the end, so fractional seconds in
+// ISO timestamps survive.
+var resetTimeRegex = regexp.MustCompile(`(?i)(?:will\s+)?\bresets?(?:_at|_time)?(?:"?\s*[:=]\s*"?|\s+(?:at\s+)?)(.+?)(?:\.\s|\.$|"|\}|$)`)
+
 // NewDetector creates a Detector with the given stderr/stdout patterns and
 // the expected rate-limit exit code (-1 if exit code detection is disabled).
 func NewDetector(patterns []string, rateLimitExitCode int) *Detector {
 	return &Detector{
 		patterns:          patterns,
 		rateLimitExitCode: rateLimitExitCode,
-		resetTimeRegex:    regexp.MustCompile(`(?i)(?:will\s+)?reset\s+(?:at\s+)?(.+?)(?:\.|$)`),
+		resetTimeRegex:    resetTimeRegex,
 	}
 }
 
+// WithExitCode returns a copy of d that expects rateLimitExitCode instead,
+// for a CLI version other than the one d was built for.
+func (d *Detector) WithExitCode(rateLimitExitCode int) *Detector {
+	c := *d
+	c.rateLimitExitCode = rateLimitExitCode
+	return &c
+}
+
+// WithPatterns returns a copy of d that also matches patterns.
+func (d *Detector) WithPatterns(patterns []string) *Detector {
+	c := *d
+	c.patterns = append(append([]string(nil), d.patterns...), patterns...)
+	return &c
+}
+
 // Detect analyzes the exit code, stdout, and stderr of a completed CLI
 // invocation and returns a layered detection result.
 //
@@ -67,7 +90,7 @@ func NewDetector(patterns []string, rateLimitExitCode int) *Detector {
 //  1. Exit code: 0 = success, rateLimitExitCode = rate limited
 //  2. Stderr pattern matching (high confidence)
 //  3. Stdout pattern matching (lower confidence)
-//  4. Unknown error classification
+//  4. Failure classification (see FailureCategory)
 func (d *Detector) Detect(exitCode int, stdout, stderr string) RateLimitResult {
 	// Layer 1: Exit code.
 	if exitCode == 0 {
@@ -106,13 +129,35 @@ func (d *Detector) Detect(exitCode int, stdout, stderr string) RateLimitResult {
 		}
 	}
 
-	// Layer 4: Unknown error classification.
+	// Layer 4: Failure classification.
+	if category, pattern := d.classify(stdout, stderr); category != "" {
+		return RateLimitResult{
+			Result:   Failed,
+			Category: category,
+			Reason:   string(category) + ": matched pattern: " + pattern,
+		}
+	}
 	return RateLimitResult{
 		Result: Failed,
 		Reason: "non-zero exit code with no rate limit indicators",
 	}
 }
 
+// DetectLine checks a single line of output while the CLI is still running.
+// Only the configured patterns are consulted, since there is no exit code
+// yet; a match is reported as RateLimited with any reset time on the line.
+func (d *Detector) DetectLine(line string) (RateLimitResult, bool) {
+	pattern, matched := d.matchPatterns(line)
+	if !matched {
+		return RateLimitResult{}, false
+	}
+	return RateLimitResult{
+		Result:    RateLimited,
+		ResetTime: d.extractResetTime(line),
+		Reason:    "matched pattern: " + pattern,
+	}, true
+}
+
 // matchPatterns performs case-insensitive substring matching against the
 // configured patterns. Returns the first matching pattern and true, or
 // empty string and false.
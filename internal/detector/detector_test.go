@@ -1,7 +1,10 @@
 package detector
 
 import (
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestDetector() *Detector {
@@ -182,6 +185,49 @@ func TestDetect_ResetTimeInStdout(t *testing.T) {
 	}
 }
 
+func TestDetect_ResetTimeFormats(t *testing.T) {
+	d := newTestDetector()
+	at := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	for _, output := range []string{
+		"Rate limit hit. Your limit resets in 2 hours.",
+		`{"type":"error","error":{"type":"rate_limit_error","reset_at":"` + at.UTC().Format("2006-01-02T15:04:05.000Z") + `"}}`,
+		`{"error":"rate limit exceeded","resets_at":` + strconv.FormatInt(at.Unix(), 10) + `}`,
+		"x-ratelimit-reset: " + strconv.FormatInt(at.Unix(), 10),
+	} {
+		result := d.Detect(75, "", output)
+		if result.ResetTime == nil {
+			t.Errorf("no reset time extracted from %q", output)
+			continue
+		}
+		if diff := result.ResetTime.Sub(at); diff < -time.Second || diff > time.Second {
+			t.Errorf("ResetTime from %q = %s; want %s", output, result.ResetTime, at)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Streaming detection
+// ---------------------------------------------------------------------------
+
+func TestDetectLine(t *testing.T) {
+	d := newTestDetector()
+
+	result, ok := d.DetectLine("Claude usage limit reached. Will reset at 6:30 PM.")
+	if !ok || result.Result != RateLimited {
+		t.Fatalf("DetectLine = %+v, %v; want RateLimited", result, ok)
+	}
+	if result.ResetTime == nil || result.ResetTime.Hour() != 18 {
+		t.Errorf("ResetTime = %v; want 18:30", result.ResetTime)
+	}
+	if result.Reason != "matched pattern: Claude usage limit reached" {
+		t.Errorf("Reason = %q", result.Reason)
+	}
+
+	if _, ok := d.DetectLine("compiling 12 packages"); ok {
+		t.Error("ordinary output should not match")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // DetectionResult.String()
 // ---------------------------------------------------------------------------
@@ -222,3 +268,42 @@ func TestDetect_StderrHasPriorityOverStdout(t *testing.T) {
 		t.Error("Reason should not be empty")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Failure classification
+// ---------------------------------------------------------------------------
+
+func TestDetect_FailureCategory(t *testing.T) {
+	d := newTestDetector().WithFailurePatterns(map[FailureCategory][]string{
+		FailureAuth:    {"Invalid API key"},
+		FailureNetwork: {"ECONNREFUSED"},
+	})
+
+	result := d.Detect(1, "", "Error: invalid api key · Please run /login")
+	if result.Result != Failed || result.Category != FailureAuth {
+		t.Errorf("Detect(auth error) = %+v; want Failed with category auth", result)
+	}
+
+	// Only the tail of stdout counts: earlier lines are the transcript.
+	transcript := "connect ECONNREFUSED 127.0.0.1:5432\n" + strings.Repeat("tool output\n", classifyTailLines)
+	if result := d.Detect(1, transcript, ""); result.Category != "" {
+		t.Errorf("Detect(error quoted in transcript) = %+v; want no category", result)
+	}
+	if result := d.Detect(1, "working\nconnect ECONNREFUSED 10.0.0.1:443\n", ""); result.Category != FailureNetwork {
+		t.Errorf("Detect(network error at the end) = %+v; want category network", result)
+	}
+
+	// Rate limits are detected before failures are classified.
+	if result := d.Detect(1, "", "rate limit hit; Invalid API key"); result.Result != RateLimited || result.Category != "" {
+		t.Errorf("Detect(rate limit) = %+v; want RateLimited without category", result)
+	}
+}
+
+func TestParseFailureCategory(t *testing.T) {
+	if c, err := ParseFailureCategory("context_too_long"); err != nil || c != FailureContextLength {
+		t.Errorf("ParseFailureCategory(context_too_long) = %q, %v", c, err)
+	}
+	if _, err := ParseFailureCategory("bad_luck"); err == nil {
+		t.Error("ParseFailureCategory(bad_luck) succeeded; want an error")
+	}
+}
@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FailureCategory says why a CLI invocation failed, when its output shows
+// it. Each category has its own retry policy (see Retryable).
+type FailureCategory string
+
+// Failure categories.
+const (
+	FailureAuth          FailureCategory = "auth"              // missing, invalid or expired credentials
+	FailureNetwork       FailureCategory = "network"           // the API could not be reached
+	FailureContextLength FailureCategory = "context_too_long"  // the prompt or session exceeds the model's context
+	FailurePermission    FailureCategory = "permission_denied" // a tool call was refused
+	FailureCrash         FailureCategory = "cli_crash"         // the CLI itself crashed
+)
+
+// FailureCategories lists the categories in the order their patterns are
+// tried, most specific first.
+var FailureCategories = []FailureCategory{
+	FailureAuth,
+	FailureContextLength,
+	FailurePermission,
+	FailureNetwork,
+	FailureCrash,
+}
+
+// ParseFailureCategory returns the category called name.
+func ParseFailureCategory(name string) (FailureCategory, error) {
+	for _, c := range FailureCategories {
+		if string(c) == name {
+			return c, nil
+		}
+	}
+	names := make([]string, len(FailureCategories))
+	for i, c := range FailureCategories {
+		names[i] = string(c)
+	}
+	return "", fmt.Errorf("unknown failure category %q (known: %s)", name, strings.Join(names, ", "))
+}
+
+// classifyTailLines is how many trailing stdout lines are classified. The
+// rest of stdout is the transcript, where tool output quoting an error
+// (a test hitting ECONNREFUSED, say) says nothing about the CLI.
+const classifyTailLines = 5
+
+// WithFailurePatterns returns a copy of d that classifies failures by
+// patterns, keyed by category.
+func (d *Detector) WithFailurePatterns(patterns map[FailureCategory][]string) *Detector {
+	c := *d
+	c.failurePatterns = patterns
+	return &c
+}
+
+// classify returns the category of a failure whose stderr, or last lines
+// of stdout, contain one of its patterns (case-insensitive), and the
+// pattern. The category is empty when nothing matches.
+func (d *Detector) classify(stdout, stderr string) (FailureCategory, string) {
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) > classifyTailLines {
+		lines = lines[len(lines)-classifyTailLines:]
+	}
+	text := strings.ToLower(stderr + "\n" + strings.Join(lines, "\n"))
+	for _, c := range FailureCategories {
+		for _, p := range d.failurePatterns[c] {
+			if strings.Contains(text, strings.ToLower(p)) {
+				return c, p
+			}
+		}
+	}
+	return "", ""
+}
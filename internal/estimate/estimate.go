@@ -0,0 +1,176 @@
+// Package estimate records how long finished tasks ran and predicts how
+// long queued ones will take, so status can tell when the queue will drain.
+package estimate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// maxRuns bounds the history kept on disk.
+const maxRuns = 500
+
+// Run is the running time of one completed task.
+type Run struct {
+	At         time.Time     `json:"at"` // completion time
+	TaskID     string        `json:"task_id"`
+	Tags       []string      `json:"tags,omitempty"`
+	WorkingDir string        `json:"working_dir,omitempty"`
+	Duration   time.Duration `json:"duration"` // all attempts together, waits excluded
+}
+
+// Load reads the run history at path, oldest first. A missing file yields
+// no runs.
+func Load(path string) ([]Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read duration history: %w", err)
+	}
+	var runs []Run
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("parse duration history: %w", err)
+	}
+	return runs, nil
+}
+
+// Record appends run to the history at path, keeping the most recent
+// maxRuns entries.
+func Record(path string, run Run) error {
+	runs, err := Load(path)
+	if err != nil {
+		// A corrupt history only costs estimates; start afresh.
+		runs = nil
+	}
+	runs = append(runs, run)
+	if len(runs) > maxRuns {
+		runs = runs[len(runs)-maxRuns:]
+	}
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal duration history: %w", err)
+	}
+	return fileutil.AtomicWrite(path, data, 0644)
+}
+
+// RunTime is the time a task spent running over all its attempts.
+func RunTime(st *queue.TaskState) time.Duration {
+	var total time.Duration
+	for _, a := range st.Attempts {
+		if a.EndedAt.After(a.StartedAt) {
+			total += a.EndedAt.Sub(a.StartedAt)
+		}
+	}
+	return total
+}
+
+// Estimator predicts task durations from a run history.
+type Estimator struct {
+	byTask map[string][]time.Duration
+	byTag  map[string][]time.Duration
+	byDir  map[string][]time.Duration
+	all    []time.Duration
+}
+
+// New builds an Estimator from runs.
+func New(runs []Run) *Estimator {
+	e := &Estimator{
+		byTask: make(map[string][]time.Duration),
+		byTag:  make(map[string][]time.Duration),
+		byDir:  make(map[string][]time.Duration),
+	}
+	for _, r := range runs {
+		e.byTask[r.TaskID] = append(e.byTask[r.TaskID], r.Duration)
+		for _, tag := range r.Tags {
+			e.byTag[tag] = append(e.byTag[tag], r.Duration)
+		}
+		if r.WorkingDir != "" {
+			e.byDir[r.WorkingDir] = append(e.byDir[r.WorkingDir], r.Duration)
+		}
+		e.all = append(e.all, r.Duration)
+	}
+	return e
+}
+
+// Estimate predicts how long task will run: the median of its own earlier
+// runs, or else of runs sharing one of its tags, of runs in its working
+// directory, or of every run, in that order. It returns false when there
+// is no history at all.
+func (e *Estimator) Estimate(task *queue.Task) (time.Duration, bool) {
+	if d := e.byTask[task.ID]; len(d) > 0 {
+		return median(d), true
+	}
+	var tagged []time.Duration
+	for _, tag := range task.Tags {
+		tagged = append(tagged, e.byTag[tag]...)
+	}
+	if len(tagged) > 0 {
+		return median(tagged), true
+	}
+	if d := e.byDir[task.WorkingDir]; len(d) > 0 {
+		return median(d), true
+	}
+	if len(e.all) > 0 {
+		return median(e.all), true
+	}
+	return 0, false
+}
+
+// Remaining is the predicted work left in a queue.
+type Remaining struct {
+	Tasks    int           // tasks still to run or finish
+	Duration time.Duration // their predicted running time
+}
+
+// Queue predicts the work left in tasks: pending and waiting tasks take
+// their whole estimate, a running task what is left of it at now. With no
+// history the duration is zero.
+func (e *Estimator) Queue(tasks []queue.Task, states map[string]*queue.TaskState, now time.Time) Remaining {
+	var rem Remaining
+	for i := range tasks {
+		status := queue.StatusPending
+		st := states[tasks[i].ID]
+		if st != nil {
+			status = st.Status
+		}
+		switch status {
+		case queue.StatusPending, queue.StatusWaiting, queue.StatusRunning:
+		default:
+			continue
+		}
+		rem.Tasks++
+		d, _ := e.Estimate(&tasks[i])
+		if status == queue.StatusRunning && st.StartedAt != nil {
+			d -= now.Sub(*st.StartedAt)
+			if d < 0 {
+				d = 0
+			}
+		}
+		rem.Duration += d
+	}
+	return rem
+}
+
+// String describes the remaining work for status lines, e.g.
+// "~2h15m0s for 6 task(s)".
+func (r Remaining) String() string {
+	return fmt.Sprintf("~%s for %d task(s)", r.Duration.Round(time.Minute), r.Tasks)
+}
+
+func median(d []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), d...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,76 @@
+package estimate
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+func TestRecordAndLoad_KeepsMostRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "durations.json")
+	for i := 0; i < maxRuns+3; i++ {
+		if err := Record(path, Run{TaskID: "t", Duration: time.Duration(i) * time.Second}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runs, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != maxRuns {
+		t.Fatalf("got %d runs; want %d", len(runs), maxRuns)
+	}
+	if runs[0].Duration != 3*time.Second {
+		t.Errorf("oldest kept run took %v; want the first 3 dropped", runs[0].Duration)
+	}
+}
+
+func TestEstimate_Fallbacks(t *testing.T) {
+	e := New([]Run{
+		{TaskID: "own", Duration: 10 * time.Minute, WorkingDir: "/a"},
+		{TaskID: "own", Duration: 20 * time.Minute, WorkingDir: "/a"},
+		{TaskID: "x", Tags: []string{"docs"}, Duration: 5 * time.Minute, WorkingDir: "/b"},
+		{TaskID: "y", Duration: 60 * time.Minute, WorkingDir: "/c"},
+	})
+
+	cases := []struct {
+		name string
+		task queue.Task
+		want time.Duration
+	}{
+		{"own history", queue.Task{ID: "own", Tags: []string{"docs"}}, 15 * time.Minute},
+		{"by tag", queue.Task{ID: "new", Tags: []string{"docs"}, WorkingDir: "/c"}, 5 * time.Minute},
+		{"by dir", queue.Task{ID: "new", WorkingDir: "/c"}, 60 * time.Minute},
+		{"overall", queue.Task{ID: "new", WorkingDir: "/z"}, 15 * time.Minute},
+	}
+	for _, tc := range cases {
+		if got, ok := e.Estimate(&tc.task); !ok || got != tc.want {
+			t.Errorf("%s: Estimate = %v, %v; want %v", tc.name, got, ok, tc.want)
+		}
+	}
+
+	if _, ok := New(nil).Estimate(&queue.Task{ID: "new"}); ok {
+		t.Error("an empty history should not estimate")
+	}
+}
+
+func TestQueue(t *testing.T) {
+	now := time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)
+	started := now.Add(-4 * time.Minute)
+	e := New([]Run{{TaskID: "a", Duration: 10 * time.Minute}})
+	tasks := []queue.Task{{ID: "a"}, {ID: "b"}, {ID: "c"}, {ID: "d"}}
+	states := map[string]*queue.TaskState{
+		"a": {ID: "a", Status: queue.StatusRunning, StartedAt: &started},
+		"b": {ID: "b", Status: queue.StatusWaiting},
+		"c": {ID: "c", Status: queue.StatusDone},
+		// d has no state yet: pending.
+	}
+
+	rem := e.Queue(tasks, states, now)
+	if rem.Tasks != 3 || rem.Duration != 26*time.Minute {
+		t.Errorf("Queue = %+v; want 3 tasks, 6m left of a plus 10m each for b and d", rem)
+	}
+}
@@ -0,0 +1,192 @@
+// Package events writes the runner's machine-readable lifecycle stream: one
+// JSON object per line (NDJSON), so orchestrators and UIs can follow a run
+// without parsing human-oriented logs.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Type identifies a lifecycle event.
+type Type string
+
+// Event types, in roughly the order a task produces them.
+const (
+	TaskStarted  Type = "task_started"
+	OutputChunk  Type = "output_chunk"
+	RateLimited  Type = "rate_limited"
+	TaskRetrying Type = "task_retrying"
+	TaskDone     Type = "task_done"
+	NeedsReview  Type = "task_needs_review"
+	TaskFailed   Type = "task_failed"
+	RunSummary   Type = "run_summary"
+)
+
+// Event types written only to the runner's event log (events.jsonl), not
+// to the --events stream.
+const (
+	StateChanged Type = "state_changed" // a task's status changed
+	Detection    Type = "detection"     // the detector classified an attempt
+	ControlOp    Type = "control"       // a queued control command was applied
+)
+
+// Event is one line of the stream. Fields that do not apply to a type are
+// omitted.
+type Event struct {
+	Type       Type       `json:"type"`
+	Time       time.Time  `json:"time"`
+	TaskID     string     `json:"task_id,omitempty"`
+	Attempt    int        `json:"attempt,omitempty"`
+	Title      string     `json:"title,omitempty"`       // task_started
+	WorkingDir string     `json:"working_dir,omitempty"` // task_started
+	Stream     string     `json:"stream,omitempty"`      // output_chunk: stdout or stderr
+	Output     string     `json:"output,omitempty"`      // output_chunk: one line of CLI output
+	Reason     string     `json:"reason,omitempty"`      // why a task was rate limited, retried or failed
+	ResumeAt   *time.Time `json:"resume_at,omitempty"`   // rate_limited, task_retrying
+	Changes    string     `json:"changes,omitempty"`     // task_done, task_needs_review: git diff summary
+	Branch     string     `json:"branch,omitempty"`      // task_needs_review: branch holding the work
+	Summary    *Summary   `json:"summary,omitempty"`     // run_summary
+	From       string     `json:"from,omitempty"`        // state_changed: previous status
+	Status     string     `json:"status,omitempty"`      // state_changed: new status
+	Result     string     `json:"result,omitempty"`      // detection: completed, rate_limited, ...
+	Op         string     `json:"op,omitempty"`          // control: retry, cancel, ...
+}
+
+// Summary holds the end-of-run task counts.
+type Summary struct {
+	Done           int     `json:"done"`
+	Failed         int     `json:"failed"`
+	Cancelled      int     `json:"cancelled"`
+	Pending        int     `json:"pending"`
+	Waiting        int     `json:"waiting"`
+	NeedsReview    int     `json:"needs_review,omitempty"`
+	Total          int     `json:"total"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// Writer serializes events to an underlying stream. A nil *Writer discards
+// events, so callers need not check whether a stream was requested.
+type Writer struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	failed bool
+}
+
+// New returns a Writer emitting to w.
+func New(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Open returns a Writer appending to the file at path, creating it if needed.
+func Open(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open events file: %w", err)
+	}
+	return &Writer{w: f, closer: f}, nil
+}
+
+// OpenLog returns a Writer appending to the file at path that keeps the
+// file under maxBytes: before a write would grow it past that, the file is
+// moved to path.1, older backups shift up to path.<keep>, and the oldest is
+// dropped.
+func OpenLog(path string, maxBytes int64, keep int) (*Writer, error) {
+	rf := &rotatingFile{path: path, maxBytes: maxBytes, keep: keep}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return &Writer{w: rf, closer: rf}, nil
+}
+
+// rotatingFile is the append-only file behind OpenLog. Writes are whole
+// events, so rotation never splits a line.
+type rotatingFile struct {
+	path     string
+	maxBytes int64
+	keep     int
+	f        *os.File
+	size     int64
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat event log: %w", err)
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	if rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return fmt.Errorf("close event log: %w", err)
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.keep))
+	for i := rf.keep - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+	}
+	if err := os.Rename(rf.path, rf.path+".1"); err != nil {
+		return fmt.Errorf("rotate event log: %w", err)
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error { return rf.f.Close() }
+
+// Emit writes ev as a single line, stamping the current time if ev.Time is
+// zero. A write failure is logged once and later events are dropped; the
+// event stream never stops a run.
+func (w *Writer) Emit(ev Event) {
+	if w == nil {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed {
+		return
+	}
+	if _, err := w.w.Write(data); err != nil {
+		w.failed = true
+		log.Printf("WARN: event stream: %v; further events dropped", err)
+	}
+}
+
+// Close closes the underlying file, if Open created one.
+func (w *Writer) Close() error {
+	if w == nil || w.closer == nil {
+		return nil
+	}
+	return w.closer.Close()
+}
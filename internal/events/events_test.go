@@ -0,0 +1,132 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenAndEmit_WritesOneObjectPerLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	w, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Emit(Event{Type: TaskStarted, TaskID: "a", Attempt: 1, Title: "Task A"})
+	w.Emit(Event{Type: OutputChunk, TaskID: "a", Stream: "stdout", Output: `{"type":"result"}`})
+	w.Emit(Event{Type: RunSummary, Summary: &Summary{Done: 1, Total: 1}})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		got = append(got, ev)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d events; want 3", len(got))
+	}
+	if got[0].Type != TaskStarted || got[0].Time.IsZero() || got[0].Title != "Task A" {
+		t.Errorf("event 0 = %+v", got[0])
+	}
+	if got[1].Output != `{"type":"result"}` {
+		t.Errorf("output chunk = %q", got[1].Output)
+	}
+	if got[2].Summary == nil || got[2].Summary.Done != 1 {
+		t.Errorf("summary = %+v", got[2].Summary)
+	}
+}
+
+func TestOpenLog_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	ev := Event{Type: StateChanged, Time: time.Now().UTC(), TaskID: "a", From: "pending", Status: "running"}
+	line, _ := json.Marshal(ev)
+	// Three lines fit in a file before it rotates.
+	w, err := OpenLog(path, int64(3*(len(line)+1)), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 12; i++ {
+		w.Emit(ev)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"events.jsonl", "events.jsonl.1", "events.jsonl.2"} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != 3 {
+			t.Errorf("%s has %d lines; want 3", name, len(lines))
+		}
+		for _, l := range lines {
+			if !json.Valid([]byte(l)) {
+				t.Errorf("%s: rotation split a line: %q", name, l)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("only 2 backups should be kept")
+	}
+}
+
+func TestEmit_NilWriterAndOmittedFields(t *testing.T) {
+	var nilWriter *Writer
+	nilWriter.Emit(Event{Type: TaskDone}) // must not panic
+
+	var b strings.Builder
+	New(&b).Emit(Event{Type: TaskDone, TaskID: "a"})
+	line := b.String()
+	for _, absent := range []string{"output", "resume_at", "summary"} {
+		if strings.Contains(line, `"`+absent+`"`) {
+			t.Errorf("task_done line has %q: %s", absent, line)
+		}
+	}
+}
+
+func TestHub_FanOutAndUnsubscribe(t *testing.T) {
+	h := NewHub()
+	a, unsubA := h.Subscribe()
+	b, unsubB := h.Subscribe()
+	defer unsubB()
+
+	h.Publish(Event{Type: TaskStarted, TaskID: "x"})
+	if ev := <-a; ev.TaskID != "x" {
+		t.Errorf("subscriber a got %+v", ev)
+	}
+	if ev := <-b; ev.TaskID != "x" {
+		t.Errorf("subscriber b got %+v", ev)
+	}
+
+	unsubA()
+	unsubA() // idempotent
+	if _, ok := <-a; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+
+	// A subscriber that stops reading must not block publishers.
+	for i := 0; i < subscriberBuffer+10; i++ {
+		h.Publish(Event{Type: OutputChunk})
+	}
+	if len(b) != subscriberBuffer {
+		t.Errorf("buffered %d events; want %d", len(b), subscriberBuffer)
+	}
+}
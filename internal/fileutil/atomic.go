@@ -136,8 +136,10 @@ func isLinkUnsupported(err error) bool {
 
 // CleanOrphanTemps sweeps temp files in the given directories.
 // Pass 1: delete if owner PID is dead. Pass 2: delete if mtime > 24h.
-func CleanOrphanTemps(dirs []string) (int, error) {
-	cleaned := 0
+// It returns the number of files deleted and the number kept because
+// their owner still looks alive (possibly a reused PID, or one from
+// another PID namespace, until the age fallback removes them).
+func CleanOrphanTemps(dirs []string) (cleaned, skipped int, err error) {
 	now := time.Now()
 
 	for _, dir := range dirs {
@@ -146,7 +148,7 @@ func CleanOrphanTemps(dirs []string) (int, error) {
 			if os.IsNotExist(err) {
 				continue
 			}
-			return cleaned, err
+			return cleaned, skipped, err
 		}
 
 		for _, entry := range entries {
@@ -164,7 +166,7 @@ func CleanOrphanTemps(dirs []string) (int, error) {
 			pid := extractPID(name)
 
 			// Pass 1: dead-owner cleanup
-			if pid > 0 && !isProcessAlive(pid) {
+			if pid > 0 && !Processes.Alive(pid) {
 				os.Remove(fullPath)
 				cleaned++
 				continue
@@ -178,11 +180,13 @@ func CleanOrphanTemps(dirs []string) (int, error) {
 			if now.Sub(info.ModTime()) > 24*time.Hour {
 				os.Remove(fullPath)
 				cleaned++
+			} else if pid > 0 {
+				skipped++
 			}
 		}
 	}
 
-	return cleaned, nil
+	return cleaned, skipped, nil
 }
 
 func extractPID(name string) int {
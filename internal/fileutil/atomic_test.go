@@ -191,7 +191,7 @@ func TestCleanOrphanTemps_DeletesDeadPIDTemps(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cleaned, err := CleanOrphanTemps([]string{dir})
+	cleaned, _, err := CleanOrphanTemps([]string{dir})
 	if err != nil {
 		t.Fatalf("CleanOrphanTemps: %v", err)
 	}
@@ -212,12 +212,12 @@ func TestCleanOrphanTemps_SkipsAlivePIDTemps(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cleaned, err := CleanOrphanTemps([]string{dir})
+	cleaned, skipped, err := CleanOrphanTemps([]string{dir})
 	if err != nil {
 		t.Fatalf("CleanOrphanTemps: %v", err)
 	}
-	if cleaned != 0 {
-		t.Errorf("cleaned = %d; want 0 (alive PID should be skipped)", cleaned)
+	if cleaned != 0 || skipped != 1 {
+		t.Errorf("cleaned, skipped = %d, %d; want 0, 1 (alive PID should be skipped)", cleaned, skipped)
 	}
 	if _, err := os.Stat(aliveFile); err != nil {
 		t.Error("alive-PID temp file should NOT have been deleted")
@@ -237,7 +237,7 @@ func TestCleanOrphanTemps_DeletesOldTemps(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cleaned, err := CleanOrphanTemps([]string{dir})
+	cleaned, _, err := CleanOrphanTemps([]string{dir})
 	if err != nil {
 		t.Fatalf("CleanOrphanTemps: %v", err)
 	}
@@ -246,8 +246,33 @@ func TestCleanOrphanTemps_DeletesOldTemps(t *testing.T) {
 	}
 }
 
+// fakeProcesses reports the PIDs in it as alive.
+type fakeProcesses map[int]bool
+
+func (f fakeProcesses) Alive(pid int) bool { return f[pid] }
+
+func TestCleanOrphanTemps_UsesProcessChecker(t *testing.T) {
+	defer func(p ProcessChecker) { Processes = p }(Processes)
+	Processes = fakeProcesses{100: true}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.json.tmp.100.aaaa"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "b.json.tmp.200.bbbb"), nil, 0644)
+
+	cleaned, skipped, err := CleanOrphanTemps([]string{dir})
+	if err != nil {
+		t.Fatalf("CleanOrphanTemps: %v", err)
+	}
+	if cleaned != 1 || skipped != 1 {
+		t.Errorf("cleaned, skipped = %d, %d; want 1, 1", cleaned, skipped)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json.tmp.100.aaaa")); err != nil {
+		t.Error("temp of a live writer was deleted")
+	}
+}
+
 func TestCleanOrphanTemps_SkipsNonExistentDirs(t *testing.T) {
-	cleaned, err := CleanOrphanTemps([]string{"/nonexistent/dir/xyz"})
+	cleaned, _, err := CleanOrphanTemps([]string{"/nonexistent/dir/xyz"})
 	if err != nil {
 		t.Fatalf("CleanOrphanTemps should skip nonexistent dirs: %v", err)
 	}
@@ -264,7 +289,7 @@ func TestCleanOrphanTemps_SkipsNonTempFiles(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cleaned, err := CleanOrphanTemps([]string{dir})
+	cleaned, _, err := CleanOrphanTemps([]string{dir})
 	if err != nil {
 		t.Fatalf("CleanOrphanTemps: %v", err)
 	}
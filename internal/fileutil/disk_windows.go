@@ -0,0 +1,19 @@
+//go:build windows
+
+package fileutil
+
+import "golang.org/x/sys/windows"
+
+// FreeSpace returns the bytes available to the current user (honoring
+// quotas) on the volume holding path.
+func FreeSpace(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var avail uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, nil, nil); err != nil {
+		return 0, err
+	}
+	return avail, nil
+}
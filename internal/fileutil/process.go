@@ -0,0 +1,15 @@
+package fileutil
+
+// ProcessChecker reports whether the process that wrote a temp file is
+// still running.
+type ProcessChecker interface {
+	Alive(pid int) bool
+}
+
+// Processes is the checker CleanOrphanTemps consults. Tests replace it.
+var Processes ProcessChecker = systemProcesses{}
+
+// systemProcesses checks the processes of the running system.
+type systemProcesses struct{}
+
+func (systemProcesses) Alive(pid int) bool { return isProcessAlive(pid) }
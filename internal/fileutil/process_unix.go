@@ -2,11 +2,46 @@
 
 package fileutil
 
-import "syscall"
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
 
-// isProcessAlive checks if a process with the given PID is alive.
-// Uses kill(pid, 0) on POSIX (returns ESRCH if dead).
+// isProcessAlive checks if a process with the given PID is alive. Where
+// procfs is mounted it is consulted first; otherwise kill(pid, 0) is used
+// (ESRCH if dead, EPERM if alive but owned by another user).
 func isProcessAlive(pid int) bool {
+	if alive, ok := procAlive("/proc", pid); ok {
+		return alive
+	}
 	err := syscall.Kill(pid, 0)
 	return err == nil || err == syscall.EPERM
 }
+
+// procAlive checks pid in the procfs mounted at root. procfs lists the PID
+// namespace it was mounted for, so inside a container the check matches
+// the container's own processes, and unlike kill(pid, 0) it sees zombies
+// (state Z or X) as dead. ok is false when procfs is unavailable or the
+// entry cannot be read.
+func procAlive(root string, pid int) (alive, ok bool) {
+	if _, err := os.Stat(filepath.Join(root, "self")); err != nil {
+		return false, false
+	}
+	data, err := os.ReadFile(filepath.Join(root, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, true
+		}
+		return false, false
+	}
+	// Format: "<pid> (<comm>) <state> ...", where comm may contain ')'.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 || i+2 >= len(data) {
+		return true, true
+	}
+	state := data[i+2]
+	return state != 'Z' && state != 'X', true
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcAlive(t *testing.T) {
+	root := t.TempDir()
+	if _, ok := procAlive(root, 1); ok {
+		t.Fatal("procAlive without a self entry should report procfs unavailable")
+	}
+	os.MkdirAll(filepath.Join(root, "self"), 0755)
+	for pid, stat := range map[string]string{
+		"10": "10 (sleep) S 1 10 10 0",
+		"11": "11 (odd) name)) R 1 11 11 0",
+		"12": "12 (defunct) Z 1 12 12 0",
+	} {
+		os.MkdirAll(filepath.Join(root, pid), 0755)
+		os.WriteFile(filepath.Join(root, pid, "stat"), []byte(stat), 0644)
+	}
+
+	for _, tc := range []struct {
+		pid   int
+		alive bool
+	}{{10, true}, {11, true}, {12, false}, {13, false}} {
+		alive, ok := procAlive(root, tc.pid)
+		if !ok || alive != tc.alive {
+			t.Errorf("procAlive(%d) = %v, %v; want %v, true", tc.pid, alive, ok, tc.alive)
+		}
+	}
+}
@@ -6,21 +6,46 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
 // ErrLocked is returned when the lockfile is already held by another process.
 var ErrLocked = errors.New("lock is held by another process")
 
+// HeartbeatInterval is how often a running runner refreshes heartbeat_at.
+const HeartbeatInterval = 30 * time.Second
+
+// HeartbeatStaleAfter is how old heartbeat_at may get before the holder is
+// considered wedged.
+const HeartbeatStaleAfter = 5 * HeartbeatInterval
+
 // Lock represents an acquired process lock backed by an OS file lock.
 type Lock struct {
-	fd *os.File
+	mu   sync.Mutex
+	fd   *os.File
+	info lockInfo
 }
 
 // lockInfo is the JSON structure written into the lockfile.
 type lockInfo struct {
-	PID        int    `json:"pid"`
-	AcquiredAt string `json:"acquired_at"`
+	PID         int    `json:"pid"`
+	AcquiredAt  string `json:"acquired_at"`
+	HeartbeatAt string `json:"heartbeat_at,omitempty"`
+}
+
+// Info describes the holder of a lockfile.
+type Info struct {
+	PID         int
+	AcquiredAt  time.Time
+	HeartbeatAt time.Time // zero if the holder predates heartbeats
+}
+
+// Stale reports whether the holder's heartbeat is older than
+// HeartbeatStaleAfter at now. Holders that never wrote a heartbeat are not
+// considered stale.
+func (i Info) Stale(now time.Time) bool {
+	return !i.HeartbeatAt.IsZero() && now.Sub(i.HeartbeatAt) > HeartbeatStaleAfter
 }
 
 // AcquireLock opens the lockfile at path and attempts to acquire an exclusive
@@ -64,9 +89,11 @@ func AcquireLock(path string) (*Lock, error) {
 		return nil, fmt.Errorf("seek lockfile: %w", err)
 	}
 
+	now := time.Now().UTC().Format(time.RFC3339)
 	info := lockInfo{
-		PID:        os.Getpid(),
-		AcquiredAt: time.Now().UTC().Format(time.RFC3339),
+		PID:         os.Getpid(),
+		AcquiredAt:  now,
+		HeartbeatAt: now,
 	}
 	data, _ := json.Marshal(info)
 	if _, err := fd.Write(data); err != nil {
@@ -78,7 +105,7 @@ func AcquireLock(path string) (*Lock, error) {
 		return nil, fmt.Errorf("fsync lockfile: %w", err)
 	}
 
-	return &Lock{fd: fd}, nil
+	return &Lock{fd: fd, info: info}, nil
 }
 
 // TryLock attempts to acquire the lock in a non-blocking manner.
@@ -95,8 +122,79 @@ func TryLock(path string) (*Lock, bool, error) {
 	return l, true, nil
 }
 
+// TryShared takes a shared lock on path without blocking. Any number of
+// holders may share it, but not while the lock is held exclusively
+// (AcquireLock, TryLock), and an exclusive lock cannot be taken while it is
+// shared. It returns (nil, false, nil) when the lock is held exclusively.
+// The lockfile's holder record is left untouched.
+func TryShared(path string) (*Lock, bool, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, false, fmt.Errorf("create lock directory %s: %w", dir, err)
+	}
+
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, fmt.Errorf("open lockfile %s: %w", path, err)
+	}
+	if err := trySharedLock(fd); err != nil {
+		fd.Close()
+		if isLockHeldError(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return &Lock{fd: fd}, true, nil
+}
+
+// Held reports whether the lock is held exclusively, such as by a running
+// runner. Unlike TryLock it neither creates nor writes the lockfile (it takes
+// a shared lock for an instant), so it works on a read-only mount. A missing
+// lockfile is not held.
+func Held(path string) (bool, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("open lockfile %s: %w", path, err)
+	}
+	defer fd.Close()
+	if err := trySharedLock(fd); err != nil {
+		if isLockHeldError(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return false, nil
+}
+
+// Heartbeat refreshes heartbeat_at in the lockfile. The new content is
+// written over the old in place (never truncated to empty first) so
+// concurrent readers always see a complete record; the file is not replaced
+// because the flock is tied to its inode.
+func (l *Lock) Heartbeat() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fd == nil {
+		return nil
+	}
+
+	l.info.HeartbeatAt = time.Now().UTC().Format(time.RFC3339)
+	data, _ := json.Marshal(l.info)
+	if _, err := l.fd.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("write heartbeat: %w", err)
+	}
+	if err := l.fd.Truncate(int64(len(data))); err != nil {
+		return fmt.Errorf("truncate lockfile: %w", err)
+	}
+	return nil
+}
+
 // Release closes the file descriptor, which releases the flock.
 func (l *Lock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	if l.fd == nil {
 		return nil
 	}
@@ -108,9 +206,16 @@ func (l *Lock) Release() error {
 // ReadLockInfo reads the PID and acquired_at timestamp from an existing
 // lockfile without attempting to acquire the lock.
 func ReadLockInfo(path string) (pid int, acquiredAt time.Time, err error) {
+	info, err := ReadInfo(path)
+	return info.PID, info.AcquiredAt, err
+}
+
+// ReadInfo reads the full holder record, including heartbeat_at, from an
+// existing lockfile without attempting to acquire the lock.
+func ReadInfo(path string) (Info, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return 0, time.Time{}, fmt.Errorf("read lockfile %s: %w", path, err)
+		return Info{}, fmt.Errorf("read lockfile %s: %w", path, err)
 	}
 
 	if len(data) == 0 {
@@ -118,25 +223,32 @@ func ReadLockInfo(path string) (pid int, acquiredAt time.Time, err error) {
 		time.Sleep(500 * time.Millisecond)
 		data, err = os.ReadFile(path)
 		if err != nil {
-			return 0, time.Time{}, fmt.Errorf("read lockfile %s (retry): %w", path, err)
+			return Info{}, fmt.Errorf("read lockfile %s (retry): %w", path, err)
 		}
 	}
 
 	if len(data) == 0 {
-		return 0, time.Time{}, fmt.Errorf("lockfile %s is empty", path)
+		return Info{}, fmt.Errorf("lockfile %s is empty", path)
 	}
 
-	var info lockInfo
-	if err := json.Unmarshal(data, &info); err != nil {
-		return 0, time.Time{}, fmt.Errorf("parse lockfile %s: %w", path, err)
+	var raw lockInfo
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Info{}, fmt.Errorf("parse lockfile %s: %w", path, err)
 	}
 
-	t, err := time.Parse(time.RFC3339, info.AcquiredAt)
+	info := Info{PID: raw.PID}
+	info.AcquiredAt, err = time.Parse(time.RFC3339, raw.AcquiredAt)
 	if err != nil {
-		return info.PID, time.Time{}, fmt.Errorf("parse acquired_at in %s: %w", path, err)
+		return info, fmt.Errorf("parse acquired_at in %s: %w", path, err)
+	}
+	if raw.HeartbeatAt != "" {
+		info.HeartbeatAt, err = time.Parse(time.RFC3339, raw.HeartbeatAt)
+		if err != nil {
+			return info, fmt.Errorf("parse heartbeat_at in %s: %w", path, err)
+		}
 	}
 
-	return info.PID, t, nil
+	return info, nil
 }
 
 // readHolderPID reads the PID from the lockfile fd. If the file is empty
@@ -100,6 +100,80 @@ func TestTryLock_ContentionReturnsFalse(t *testing.T) {
 	}
 }
 
+func TestHeld(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.lock")
+
+	if held, err := Held(path); err != nil || held {
+		t.Fatalf("Held on missing file = %v, %v; want false", held, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Held should not create the lockfile")
+	}
+
+	l, _, err := TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+	if held, err := Held(path); err != nil || !held {
+		t.Errorf("Held while locked = %v, %v; want true", held, err)
+	}
+	l.Release()
+	if held, err := Held(path); err != nil || held {
+		t.Errorf("Held after release = %v, %v; want false", held, err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TryShared
+// ---------------------------------------------------------------------------
+
+func TestTryShared_SharedHoldersCoexist(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.lock")
+
+	l1, ok, err := TryShared(path)
+	if err != nil || !ok {
+		t.Fatalf("first TryShared: ok=%v err=%v", ok, err)
+	}
+	defer l1.Release()
+
+	l2, ok, err := TryShared(path)
+	if err != nil || !ok {
+		t.Fatalf("second TryShared: ok=%v err=%v", ok, err)
+	}
+	defer l2.Release()
+
+	if _, ok, _ := TryLock(path); ok {
+		t.Error("TryLock should fail while shared holders remain")
+	}
+}
+
+func TestTryShared_BlockedByExclusive(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.lock")
+
+	l1, _, err := TryLock(path)
+	if err != nil {
+		t.Fatalf("TryLock: %v", err)
+	}
+
+	l2, ok, err := TryShared(path)
+	if err != nil {
+		t.Fatalf("TryShared: %v", err)
+	}
+	if ok || l2 != nil {
+		t.Error("TryShared should return nil, false while an exclusive lock is held")
+	}
+
+	l1.Release()
+	l3, ok, err := TryShared(path)
+	if err != nil || !ok {
+		t.Fatalf("TryShared after release: ok=%v err=%v", ok, err)
+	}
+	l3.Release()
+}
+
 // ---------------------------------------------------------------------------
 // Release
 // ---------------------------------------------------------------------------
@@ -180,3 +254,46 @@ func TestReadLockInfo_NonExistentFile(t *testing.T) {
 		t.Fatal("expected error for nonexistent lockfile")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Heartbeat
+// ---------------------------------------------------------------------------
+
+func TestHeartbeat_UpdatesLockfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	l, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	defer l.Release()
+
+	// Backdate the in-memory record so the refresh is observable.
+	l.info.HeartbeatAt = "2000-01-01T00:00:00Z"
+	if err := l.Heartbeat(); err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	info, err := ReadInfo(path)
+	if err != nil {
+		t.Fatalf("ReadInfo: %v", err)
+	}
+	if info.PID != os.Getpid() {
+		t.Errorf("PID = %d; want %d", info.PID, os.Getpid())
+	}
+	if time.Since(info.HeartbeatAt) > time.Minute {
+		t.Errorf("heartbeat_at = %v; want recent", info.HeartbeatAt)
+	}
+}
+
+func TestInfo_Stale(t *testing.T) {
+	now := time.Now()
+	if (Info{}).Stale(now) {
+		t.Error("missing heartbeat should not be stale")
+	}
+	if (Info{HeartbeatAt: now.Add(-time.Minute)}).Stale(now) {
+		t.Error("recent heartbeat reported stale")
+	}
+	if !(Info{HeartbeatAt: now.Add(-HeartbeatStaleAfter - time.Second)}).Stale(now) {
+		t.Error("old heartbeat not reported stale")
+	}
+}
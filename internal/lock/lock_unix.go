@@ -12,6 +12,10 @@ func tryExclusiveLock(fd *os.File) error {
 	return syscall.Flock(int(fd.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
 }
 
+func trySharedLock(fd *os.File) error {
+	return syscall.Flock(int(fd.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+}
+
 func isLockHeldError(err error) bool {
 	return errors.Is(err, syscall.EWOULDBLOCK) || errors.Is(err, syscall.EAGAIN)
 }
@@ -0,0 +1,306 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+)
+
+// bellInterval is the pause between repeated bells so terminals that
+// coalesce rapid bells still ring each one.
+const bellInterval = time.Second
+
+// execTimeout bounds how long an on_event_command hook may run.
+const execTimeout = 30 * time.Second
+
+// waitDelay bounds how long a killed hook's leftover children may keep its
+// output open before the wait returns anyway.
+const waitDelay = time.Second
+
+// pushoverAPI is the Pushover message endpoint.
+const pushoverAPI = "https://api.pushover.net/1/messages.json"
+
+// ── bell ────────────────────────────────────────────────────────────────
+
+// bellChannel rings the terminal bell. Failure events ring repeat times and
+// run the optional sound command, which is killed after timeout so a hung
+// command cannot stall the runner.
+type bellChannel struct {
+	repeat       int
+	soundCommand string
+	timeout      time.Duration // defaults to execTimeout
+	out          io.Writer     // defaults to ui.Writer()
+}
+
+func (c *bellChannel) Name() string { return "bell" }
+
+func (c *bellChannel) Notify(event Event) error {
+	out := c.out
+	if out == nil {
+		out = ui.Writer()
+	}
+
+	count := 1
+	if event.Failure() && c.repeat > 1 {
+		count = c.repeat
+	}
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(bellInterval)
+		}
+		fmt.Fprint(out, "\a")
+	}
+
+	if event.Failure() && c.soundCommand != "" {
+		timeout := c.timeout
+		if timeout <= 0 {
+			timeout = execTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := shellCommand(ctx, c.soundCommand).Run(); err != nil {
+			return fmt.Errorf("sound command: %w", err)
+		}
+	}
+	return nil
+}
+
+// shellCommand wraps a user-configured command line in the platform shell.
+// Killing the shell on ctx expiry leaves any background children holding its
+// output open, so the wait gives up on them after waitDelay.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// ── desktop ─────────────────────────────────────────────────────────────
+
+// desktopChannel sends a native desktop notification. Uses osascript on
+// macOS and notify-send on Linux.
+type desktopChannel struct{}
+
+func (c *desktopChannel) Name() string { return "desktop" }
+
+func (c *desktopChannel) Notify(event Event) error {
+	const title = "claude-autopilot"
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf(
+			`display notification %q with title %q`,
+			event.Text(), title,
+		)
+		return exec.Command("osascript", "-e", script).Run()
+
+	case "linux":
+		return exec.Command("notify-send", title, event.Text()).Run()
+
+	default:
+		return fmt.Errorf("desktop notifications not supported on %s", runtime.GOOS)
+	}
+}
+
+// ── webhook ─────────────────────────────────────────────────────────────
+
+// webhookChannel POSTs a JSON payload. On failure, it retries once after
+// retryDelay and returns an error only if both attempts fail.
+type webhookChannel struct {
+	url        string
+	retryDelay time.Duration
+}
+
+func (c *webhookChannel) Name() string { return "webhook" }
+
+func (c *webhookChannel) Notify(event Event) error {
+	payload, err := json.Marshal(map[string]string{
+		"text":    event.Text(),
+		"event":   string(event.Type),
+		"task_id": event.TaskID,
+		"summary": event.Summary,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal webhook payload: %w", err)
+	}
+
+	err = doWebhookPost(c.url, payload)
+	if err == nil {
+		return nil
+	}
+	log.Printf("WARN: webhook first attempt failed: %v; retrying in %v", err, c.retryDelay)
+
+	time.Sleep(c.retryDelay)
+	if retryErr := doWebhookPost(c.url, payload); retryErr != nil {
+		return fmt.Errorf("webhook failed after retry: %w (first: %v)", retryErr, err)
+	}
+	return nil
+}
+
+// doWebhookPost performs a single HTTP POST with a JSON body.
+func doWebhookPost(url string, payload []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ── ntfy ────────────────────────────────────────────────────────────────
+
+// ntfyChannel publishes to an ntfy topic. Failures are sent with high
+// priority so they break through the phone's quiet settings.
+type ntfyChannel struct {
+	server string
+	topic  string
+	token  string
+}
+
+func (c *ntfyChannel) Name() string { return "ntfy" }
+
+func (c *ntfyChannel) Notify(event Event) error {
+	server := strings.TrimRight(c.server, "/")
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, server+"/"+c.topic, strings.NewReader(event.Text()))
+	if err != nil {
+		return fmt.Errorf("build ntfy request: %w", err)
+	}
+	req.Header.Set("Title", "claude-autopilot")
+	if event.Failure() {
+		req.Header.Set("Priority", "high")
+		req.Header.Set("Tags", "warning")
+	} else {
+		req.Header.Set("Priority", "default")
+		req.Header.Set("Tags", "white_check_mark")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post ntfy: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ntfy returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ── pushover ────────────────────────────────────────────────────────────
+
+// pushoverChannel posts to the Pushover API. Failures use emergency priority
+// (2), which Pushover requires to carry retry and expire parameters in
+// seconds; retry is clamped to the API minimum of 30s and expire to the
+// maximum of 3h.
+type pushoverChannel struct {
+	endpoint string
+	user     string
+	token    string
+	retry    time.Duration
+	expire   time.Duration
+}
+
+func (c *pushoverChannel) Name() string { return "pushover" }
+
+func (c *pushoverChannel) Notify(event Event) error {
+	form := url.Values{}
+	form.Set("token", c.token)
+	form.Set("user", c.user)
+	form.Set("title", "claude-autopilot")
+	form.Set("message", event.Text())
+
+	if event.Failure() {
+		retry := c.retry
+		if retry < 30*time.Second {
+			retry = 30 * time.Second
+		}
+		expire := c.expire
+		if expire <= 0 || expire > 3*time.Hour {
+			expire = 3 * time.Hour
+		}
+		form.Set("priority", "2")
+		form.Set("retry", strconv.Itoa(int(retry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(expire.Seconds())))
+	} else {
+		form.Set("priority", "0")
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.PostForm(c.endpoint, form)
+	if err != nil {
+		return fmt.Errorf("post pushover: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pushover returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ── exec ────────────────────────────────────────────────────────────────
+
+// execChannel runs a user command for each event. Event details are passed
+// as AUTOPILOT_EVENT, AUTOPILOT_TASK_ID, AUTOPILOT_MESSAGE,
+// AUTOPILOT_SUMMARY and AUTOPILOT_TIME env vars, and the full event is written as JSON to stdin.
+// The command is killed after timeout.
+type execChannel struct {
+	command string
+	timeout time.Duration
+}
+
+func (c *execChannel) Name() string { return "exec" }
+
+func (c *execChannel) Notify(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, c.command)
+	cmd.Env = append(os.Environ(),
+		"AUTOPILOT_EVENT="+string(event.Type),
+		"AUTOPILOT_TASK_ID="+event.TaskID,
+		"AUTOPILOT_MESSAGE="+event.Message,
+		"AUTOPILOT_SUMMARY="+event.Summary,
+		"AUTOPILOT_TIME="+event.Time.Format(time.RFC3339),
+	)
+	cmd.Stdin = bytes.NewReader(append(payload, '\n'))
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("on_event_command: %w (output: %s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -1,114 +1,262 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
-	"os/exec"
-	"runtime"
+	"strings"
 	"time"
 
 	"github.com/hseinmoussa/claude-autopilot/internal/config"
 )
 
-// Notifier dispatches completion notifications through configured channels.
-type Notifier struct {
-	webhookURL     string
-	desktopEnabled bool
-	bellEnabled    bool
+// EventType identifies what happened in the runner.
+type EventType string
+
+// Event types emitted by the runner.
+const (
+	EventRunComplete EventType = "run_complete"
+	EventRunFailed   EventType = "run_failed"
+	EventTaskDone    EventType = "task_done"
+	EventTaskFailed  EventType = "task_failed"
+	EventRateLimited EventType = "rate_limited"
+	EventNeedsReview EventType = "task_needs_review"
+	EventDiskLow     EventType = "disk_low"
+	// EventQueueWaiting fires when every remaining task is waiting, e.g.
+	// for a rate-limit reset, and EventQueueResumed when work starts again.
+	EventQueueWaiting EventType = "queue_waiting"
+	EventQueueResumed EventType = "queue_resumed"
+)
+
+// allEventTypes lists every known event type, used to validate filters.
+var allEventTypes = []EventType{
+	EventRunComplete,
+	EventRunFailed,
+	EventTaskDone,
+	EventTaskFailed,
+	EventRateLimited,
+	EventNeedsReview,
+	EventDiskLow,
+	EventQueueWaiting,
+	EventQueueResumed,
 }
 
-// NewNotifier creates a Notifier from the given configuration.
-func NewNotifier(cfg *config.Config) *Notifier {
-	return &Notifier{
-		webhookURL:     cfg.WebhookURL,
-		desktopEnabled: cfg.NotificationDesktop,
-		bellEnabled:    cfg.NotificationBell,
-	}
+// DefaultEvents is the filter applied to channels that have no explicit
+// configuration: end-of-run notifications, and the queue pausing for low
+// disk space, which needs a person to act.
+const DefaultEvents = "run_complete,run_failed,disk_low"
+
+// Event describes a single notification-worthy occurrence.
+type Event struct {
+	Type    EventType `json:"type"`
+	TaskID  string    `json:"task_id,omitempty"` // empty for run-level events
+	Message string    `json:"message"`
+	Summary string    `json:"summary,omitempty"` // what a finished task reported doing, shortened
+	Time    time.Time `json:"time"`
 }
 
-// NotifyComplete sends a completion notification through all enabled channels.
-// Individual channel failures are logged as warnings but never cause a fatal
-// error.
-func (n *Notifier) NotifyComplete(summary string) {
-	if n.bellEnabled {
-		n.sendBell()
-	}
+// maxSummary is the length a task summary is cut to, in characters, so it
+// fits a phone notification.
+const maxSummary = 300
 
-	if n.desktopEnabled {
-		if err := n.sendDesktop("claude-autopilot", summary); err != nil {
-			log.Printf("WARN: desktop notification failed: %v", err)
-		}
+// Text is the notification body: the message, followed by the summary on
+// its own line when there is one.
+func (e Event) Text() string {
+	if e.Summary == "" {
+		return e.Message
 	}
+	return e.Message + "\n" + e.Summary
+}
 
-	if n.webhookURL != "" {
-		if err := n.sendWebhook(n.webhookURL, summary); err != nil {
-			log.Printf("WARN: webhook notification failed: %v", err)
-		}
+// shortenSummary collapses whitespace in s and cuts it to maxSummary
+// characters, ending a cut summary with "...".
+func shortenSummary(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	r := []rune(s)
+	if len(r) <= maxSummary {
+		return s
 	}
+	return strings.TrimSpace(string(r[:maxSummary-3])) + "..."
+}
+
+// Failure reports whether the event represents a failure, which channels use
+// to escalate (extra bells, high push priority).
+func (e Event) Failure() bool {
+	return e.Type == EventRunFailed || e.Type == EventTaskFailed
+}
+
+// Channel is a notification provider. Implementations should return an
+// error rather than log; the Notifier logs failures uniformly.
+type Channel interface {
+	// Name is the provider identifier used in notification_events filters.
+	Name() string
+	// Notify delivers a single event.
+	Notify(event Event) error
 }
 
-// sendBell prints the ASCII bell character to stdout.
-func (n *Notifier) sendBell() {
-	fmt.Fprint(os.Stdout, "\a")
+// registration pairs a channel with the set of events it accepts.
+type registration struct {
+	channel Channel
+	events  map[EventType]bool
 }
 
-// sendWebhook POSTs a JSON payload to the given URL. On failure, it retries
-// once after 5 seconds. Returns an error only if both attempts fail.
-func (n *Notifier) sendWebhook(url, message string) error {
-	payload, err := json.Marshal(map[string]string{
-		"text": message,
-	})
+// Notifier dispatches events to registered channels.
+type Notifier struct {
+	channels []registration
+}
+
+// NewNotifier creates a Notifier from the given configuration, registering
+// every channel the configuration enables. Per-channel event filters come
+// from the notification_events key; an invalid filter is logged and the
+// default filter is used instead.
+func NewNotifier(cfg *config.Config) *Notifier {
+	filters, err := ParseEventFilters(cfg.NotificationEvents)
 	if err != nil {
-		return fmt.Errorf("marshal webhook payload: %w", err)
+		log.Printf("WARN: notification_events: %v; using %q", err, DefaultEvents)
+		filters, _ = ParseEventFilters(DefaultEvents)
 	}
 
-	err = doWebhookPost(url, payload)
-	if err == nil {
-		return nil
+	n := &Notifier{}
+	if cfg.NotificationBell {
+		n.Register(&bellChannel{repeat: cfg.NotificationBellRepeat, soundCommand: cfg.NotificationSoundCommand}, filters.For("bell")...)
+	}
+	if cfg.NotificationDesktop {
+		n.Register(&desktopChannel{}, filters.For("desktop")...)
+	}
+	if cfg.WebhookURL != "" {
+		n.Register(&webhookChannel{url: cfg.WebhookURL, retryDelay: 5 * time.Second}, filters.For("webhook")...)
+	}
+	if cfg.NtfyTopic != "" {
+		n.Register(&ntfyChannel{server: cfg.NtfyServer, topic: cfg.NtfyTopic, token: cfg.NtfyToken}, filters.For("ntfy")...)
+	}
+	if cfg.PushoverUserKey != "" && cfg.PushoverAppToken != "" {
+		n.Register(&pushoverChannel{
+			endpoint: pushoverAPI,
+			user:     cfg.PushoverUserKey,
+			token:    cfg.PushoverAppToken,
+			retry:    cfg.PushoverRetry,
+			expire:   cfg.PushoverExpire,
+		}, filters.For("pushover")...)
+	}
+	if cfg.OnEventCommand != "" {
+		// The exec hook exists to drive custom integrations, so it sees
+		// every event unless given its own "exec:" filter entries.
+		events := allEventTypes
+		if perChannel, ok := filters.PerChannel["exec"]; ok {
+			events = perChannel
+		}
+		n.Register(&execChannel{command: cfg.OnEventCommand, timeout: execTimeout}, events...)
 	}
-	log.Printf("WARN: webhook first attempt failed: %v; retrying in 5s", err)
+	return n
+}
 
-	time.Sleep(5 * time.Second)
-	if retryErr := doWebhookPost(url, payload); retryErr != nil {
-		return fmt.Errorf("webhook failed after retry: %w (first: %v)", retryErr, err)
+// Register adds a channel that receives the given event types. With no
+// event types, the channel receives every event.
+func (n *Notifier) Register(ch Channel, events ...EventType) {
+	reg := registration{channel: ch}
+	if len(events) > 0 {
+		reg.events = make(map[EventType]bool, len(events))
+		for _, e := range events {
+			reg.events[e] = true
+		}
 	}
-	return nil
+	n.channels = append(n.channels, reg)
 }
 
-// doWebhookPost performs a single HTTP POST with a JSON body.
-func doWebhookPost(url string, payload []byte) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
-	if err != nil {
-		return fmt.Errorf("post webhook: %w", err)
+// Notify delivers event to every channel whose filter accepts it. Individual
+// channel failures are logged as warnings but never cause a fatal error.
+func (n *Notifier) Notify(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
 	}
-	defer resp.Body.Close()
+	event.Summary = shortenSummary(event.Summary)
+	for _, reg := range n.channels {
+		if reg.events != nil && !reg.events[event.Type] {
+			continue
+		}
+		if err := reg.channel.Notify(event); err != nil {
+			log.Printf("WARN: %s notification failed: %v", reg.channel.Name(), err)
+		}
+	}
+}
+
+// NotifyComplete sends a run_complete event.
+func (n *Notifier) NotifyComplete(summary string) {
+	n.Notify(Event{Type: EventRunComplete, Message: summary})
+}
+
+// NotifyFailure sends a run_failed event.
+func (n *Notifier) NotifyFailure(summary string) {
+	n.Notify(Event{Type: EventRunFailed, Message: summary})
+}
+
+// EventFilters holds the parsed notification_events setting: a global list
+// plus per-channel overrides.
+type EventFilters struct {
+	Global     []EventType
+	PerChannel map[string][]EventType
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+// For returns the events a named channel should receive. Channel-specific
+// entries replace the global list entirely.
+func (f EventFilters) For(channel string) []EventType {
+	if events, ok := f.PerChannel[channel]; ok {
+		return events
 	}
-	return nil
+	return f.Global
 }
 
-// sendDesktop sends a native desktop notification. Uses osascript on macOS
-// and notify-send on Linux.
-func (n *Notifier) sendDesktop(title, message string) error {
-	switch runtime.GOOS {
-	case "darwin":
-		script := fmt.Sprintf(
-			`display notification %q with title %q`,
-			message, title,
-		)
-		return exec.Command("osascript", "-e", script).Run()
+// ParseEventFilters parses a comma-separated filter spec. Each entry is
+// either an event type ("task_failed"), which applies to all channels, or
+// "<channel>:<event>", which applies to one channel only. The special event
+// "*" selects every event type. An empty spec yields DefaultEvents, as do
+// channels without entries when the spec has only per-channel ones.
+func ParseEventFilters(spec string) (EventFilters, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = DefaultEvents
+	}
 
-	case "linux":
-		return exec.Command("notify-send", title, message).Run()
+	f := EventFilters{PerChannel: make(map[string][]EventType)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
 
-	default:
-		return fmt.Errorf("desktop notifications not supported on %s", runtime.GOOS)
+		channel := ""
+		name := entry
+		if idx := strings.Index(entry, ":"); idx >= 0 {
+			channel = strings.TrimSpace(entry[:idx])
+			name = strings.TrimSpace(entry[idx+1:])
+		}
+
+		events, err := expandEvent(name)
+		if err != nil {
+			return EventFilters{}, err
+		}
+		if channel == "" {
+			f.Global = append(f.Global, events...)
+		} else {
+			f.PerChannel[channel] = append(f.PerChannel[channel], events...)
+		}
+	}
+	// Channels without entries of their own get the global list; with
+	// none given that is DefaultEvents, never everything.
+	if len(f.Global) == 0 {
+		defaults, _ := ParseEventFilters(DefaultEvents)
+		f.Global = defaults.Global
+	}
+	return f, nil
+}
+
+// expandEvent validates a single event name, expanding "*" to all types.
+func expandEvent(name string) ([]EventType, error) {
+	if name == "*" {
+		return append([]EventType(nil), allEventTypes...), nil
+	}
+	for _, e := range allEventTypes {
+		if string(e) == name {
+			return []EventType{e}, nil
+		}
 	}
+	return nil, fmt.Errorf("unknown notification event %q", name)
 }
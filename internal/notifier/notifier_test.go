@@ -0,0 +1,314 @@
+package notifier
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeChannel records delivered events.
+type fakeChannel struct {
+	name   string
+	events []Event
+	err    error
+}
+
+func (c *fakeChannel) Name() string { return c.name }
+
+func (c *fakeChannel) Notify(event Event) error {
+	c.events = append(c.events, event)
+	return c.err
+}
+
+// ---------------------------------------------------------------------------
+// Dispatch
+// ---------------------------------------------------------------------------
+
+func TestNotify_FiltersPerChannel(t *testing.T) {
+	n := &Notifier{}
+	all := &fakeChannel{name: "all"}
+	runOnly := &fakeChannel{name: "run"}
+	n.Register(all)
+	n.Register(runOnly, EventRunComplete, EventRunFailed)
+
+	n.Notify(Event{Type: EventTaskDone, TaskID: "a"})
+	n.NotifyFailure("boom")
+
+	if len(all.events) != 2 {
+		t.Fatalf("unfiltered channel got %d events; want 2", len(all.events))
+	}
+	if len(runOnly.events) != 1 || runOnly.events[0].Type != EventRunFailed {
+		t.Fatalf("filtered channel got %+v; want only run_failed", runOnly.events)
+	}
+	if runOnly.events[0].Time.IsZero() {
+		t.Error("Notify should stamp events with the current time")
+	}
+}
+
+func TestNotify_ShortensSummary(t *testing.T) {
+	n := &Notifier{}
+	ch := &fakeChannel{name: "all"}
+	n.Register(ch)
+
+	n.Notify(Event{Type: EventTaskDone, TaskID: "a", Message: "Task a completed", Summary: "Fixed the\n\nlogin bug. " + strings.Repeat("x", 400)})
+
+	got := ch.events[0]
+	if n := len([]rune(got.Summary)); n != maxSummary || !strings.HasSuffix(got.Summary, "...") {
+		t.Errorf("summary is %d characters (%q); want it cut to %d", n, got.Summary, maxSummary)
+	}
+	if !strings.HasPrefix(got.Text(), "Task a completed\nFixed the login bug. ") {
+		t.Errorf("Text() = %q", got.Text())
+	}
+	if (Event{Message: "done"}).Text() != "done" {
+		t.Error("Text() without a summary should be the message")
+	}
+}
+
+func TestNotify_ChannelErrorDoesNotStopDispatch(t *testing.T) {
+	n := &Notifier{}
+	broken := &fakeChannel{name: "broken", err: errors.New("down")}
+	ok := &fakeChannel{name: "ok"}
+	n.Register(broken)
+	n.Register(ok)
+
+	n.NotifyComplete("done")
+
+	if len(ok.events) != 1 {
+		t.Fatalf("second channel got %d events; want 1", len(ok.events))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// ParseEventFilters
+// ---------------------------------------------------------------------------
+
+func TestParseEventFilters_DefaultWhenEmpty(t *testing.T) {
+	f, err := ParseEventFilters("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := f.For("webhook")
+	if len(got) != 3 || got[0] != EventRunComplete || got[1] != EventRunFailed || got[2] != EventDiskLow {
+		t.Errorf("For(webhook) = %v; want default run events", got)
+	}
+}
+
+func TestParseEventFilters_QueueEvents(t *testing.T) {
+	f, err := ParseEventFilters("ntfy:queue_waiting,ntfy:queue_resumed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.For("ntfy"); len(got) != 2 || got[0] != EventQueueWaiting || got[1] != EventQueueResumed {
+		t.Errorf("For(ntfy) = %v; want [queue_waiting queue_resumed]", got)
+	}
+}
+
+func TestParseEventFilters_OnlyChannelEntries(t *testing.T) {
+	f, err := ParseEventFilters("webhook:task_failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.For("webhook"); len(got) != 1 || got[0] != EventTaskFailed {
+		t.Errorf("For(webhook) = %v; want [task_failed]", got)
+	}
+	for _, ch := range []string{"bell", "desktop", "ntfy", "pushover"} {
+		if got := f.For(ch); len(got) != 3 || got[0] != EventRunComplete || got[1] != EventRunFailed || got[2] != EventDiskLow {
+			t.Errorf("For(%s) = %v; want default run events", ch, got)
+		}
+	}
+}
+
+func TestParseEventFilters_ChannelOverride(t *testing.T) {
+	f, err := ParseEventFilters("run_complete, ntfy:task_failed, ntfy:run_failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.For("bell"); len(got) != 1 || got[0] != EventRunComplete {
+		t.Errorf("For(bell) = %v; want [run_complete]", got)
+	}
+	if got := f.For("ntfy"); len(got) != 2 || got[0] != EventTaskFailed {
+		t.Errorf("For(ntfy) = %v; want [task_failed run_failed]", got)
+	}
+}
+
+func TestParseEventFilters_Wildcard(t *testing.T) {
+	f, err := ParseEventFilters("*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.For("desktop"); len(got) != len(allEventTypes) {
+		t.Errorf("For(desktop) = %v; want all event types", got)
+	}
+}
+
+func TestParseEventFilters_UnknownEvent(t *testing.T) {
+	if _, err := ParseEventFilters("run_complete,task_exploded"); err == nil {
+		t.Fatal("expected error for unknown event")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Channels
+// ---------------------------------------------------------------------------
+
+func TestBellChannel_RepeatsOnlyOnFailure(t *testing.T) {
+	var buf bytes.Buffer
+	c := &bellChannel{repeat: 1, out: &buf}
+
+	if err := c.Notify(Event{Type: EventRunComplete}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "\a" {
+		t.Errorf("completion rang %q; want one bell", buf.String())
+	}
+}
+
+func TestBellChannel_SoundCommandTimesOut(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	c := &bellChannel{repeat: 1, soundCommand: "sleep 10", timeout: 100 * time.Millisecond, out: io.Discard}
+
+	start := time.Now()
+	if err := c.Notify(Event{Type: EventTaskFailed}); err == nil {
+		t.Error("a killed sound command should be reported")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Notify blocked for %s on a hung sound command", elapsed)
+	}
+}
+
+func TestNtfyChannel_FailureUsesHighPriority(t *testing.T) {
+	var gotPath, gotPriority, gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotPriority = r.Header.Get("Priority")
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	}))
+	defer srv.Close()
+
+	c := &ntfyChannel{server: srv.URL + "/", topic: "nightly", token: "tk"}
+	if err := c.Notify(Event{Type: EventTaskFailed, Message: "task x failed"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if gotPath != "/nightly" {
+		t.Errorf("path = %q; want /nightly", gotPath)
+	}
+	if gotPriority != "high" {
+		t.Errorf("Priority = %q; want high", gotPriority)
+	}
+	if gotAuth != "Bearer tk" {
+		t.Errorf("Authorization = %q; want Bearer tk", gotAuth)
+	}
+	if gotBody != "task x failed" {
+		t.Errorf("body = %q", gotBody)
+	}
+}
+
+func TestPushoverChannel_EmergencyClampsRetryAndExpire(t *testing.T) {
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+	}))
+	defer srv.Close()
+
+	c := &pushoverChannel{endpoint: srv.URL, user: "u", token: "t"}
+	if err := c.Notify(Event{Type: EventRunFailed, Message: "bad night"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if form.Get("priority") != "2" {
+		t.Errorf("priority = %q; want 2", form.Get("priority"))
+	}
+	if form.Get("retry") != "30" {
+		t.Errorf("retry = %q; want clamped to 30", form.Get("retry"))
+	}
+	if form.Get("expire") != "10800" {
+		t.Errorf("expire = %q; want 10800", form.Get("expire"))
+	}
+}
+
+func TestWebhookChannel_PayloadIncludesEvent(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	c := &webhookChannel{url: srv.URL}
+	if err := c.Notify(Event{Type: EventTaskDone, TaskID: "fix-auth", Message: "ok", Summary: "fixed it"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	for _, want := range []string{`"text":"ok\nfixed it"`, `"event":"task_done"`, `"task_id":"fix-auth"`, `"summary":"fixed it"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("payload %s missing %s", body, want)
+		}
+	}
+}
+
+func TestExecChannel_PassesEnvAndStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "hook.out")
+	c := &execChannel{
+		command: `printf '%s %s\n' "$AUTOPILOT_EVENT" "$AUTOPILOT_TASK_ID" > ` + out + ` && cat >> ` + out,
+		timeout: 5 * time.Second,
+	}
+
+	if err := c.Notify(Event{Type: EventRateLimited, TaskID: "fix-auth", Message: "wait"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	if !strings.HasPrefix(got, "rate_limited fix-auth\n") {
+		t.Errorf("env line = %q", got)
+	}
+	if !strings.Contains(got, `"type":"rate_limited"`) {
+		t.Errorf("stdin JSON missing from %q", got)
+	}
+}
+
+func TestExecChannel_TimeoutKillsBackgroundedHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	c := &execChannel{command: "sleep 10 & wait", timeout: 200 * time.Millisecond}
+
+	start := time.Now()
+	if err := c.Notify(Event{Type: EventRunComplete}); err == nil {
+		t.Error("a killed hook should be reported")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Notify blocked for %s on a hook whose child kept its output open", elapsed)
+	}
+}
+
+func TestExecChannel_ReportsFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	c := &execChannel{command: "echo nope >&2; exit 3", timeout: 5 * time.Second}
+	err := c.Notify(Event{Type: EventRunComplete})
+	if err == nil || !strings.Contains(err.Error(), "nope") {
+		t.Fatalf("err = %v; want failure including output", err)
+	}
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SourceFile returns the file part of a task's Source, without the
+// "#doc<n>" or "#<step>" suffix of multi-document and pipeline files.
+func SourceFile(source string) string {
+	if i := strings.LastIndex(source, "#"); i >= 0 {
+		return source[:i]
+	}
+	return source
+}
+
+// Archive moves the task file at path and the state, init records and logs
+// of ids (the tasks it defines) into archiveDir/<YYYY-MM-DD>/, dated by now.
+// The task file is moved first, so a failure part way never leaves a
+// defined task without its state. It returns the destination directory.
+func Archive(archiveDir, stateDir, logsDir, path string, ids []string, now time.Time) (string, error) {
+	dir := filepath.Join(archiveDir, now.Local().Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create archive dir: %w", err)
+	}
+	if path != "" {
+		if err := moveFile(path, uniquePath(filepath.Join(dir, filepath.Base(path)))); err != nil {
+			return "", fmt.Errorf("archive %s: %w", path, err)
+		}
+	}
+	for _, id := range ids {
+		for _, src := range taskDataFiles(stateDir, logsDir, id) {
+			err := moveFile(src, filepath.Join(dir, filepath.Base(src)))
+			if err != nil && !os.IsNotExist(err) {
+				return dir, fmt.Errorf("archive %s: %w", src, err)
+			}
+		}
+	}
+	return dir, nil
+}
+
+// uniquePath returns path, or path with a numeric suffix before its
+// extension if a file of that name already exists.
+func uniquePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+}
+
+// moveFile renames src to dst, copying across filesystems when a rename is
+// not possible (project-local task files may live on another volume).
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil || os.IsNotExist(err) {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// racyWindow is how recently a file may have changed and still be cached.
+// Filesystems with coarse timestamps can give a rewrite of the same size
+// the same mtime, so files changed within the window are always re-read.
+const racyWindow = 2 * time.Second
+
+// fileKey identifies a version of a file, plus the task defaults that were
+// applied when it was parsed.
+type fileKey struct {
+	size              int64
+	modTime           time.Time
+	defaultPriority   int
+	defaultMaxRetries int
+}
+
+// cache memoizes parsed task files and init records by path, so the runner
+// loop and its wait ticks do not re-parse an unchanged queue. An entry is
+// used only while its file's size and mtime are unchanged.
+var cache = struct {
+	sync.Mutex
+	tasks map[string]cachedTasks
+	inits map[string]cachedInit
+}{
+	tasks: make(map[string]cachedTasks),
+	inits: make(map[string]cachedInit),
+}
+
+type cachedTasks struct {
+	key   fileKey
+	tasks []Task
+}
+
+type cachedInit struct {
+	key       fileKey
+	createdAt time.Time
+}
+
+func keyOf(info os.FileInfo) (fileKey, bool) {
+	key := fileKey{
+		size:              info.Size(),
+		modTime:           info.ModTime(),
+		defaultPriority:   DefaultPriority,
+		defaultMaxRetries: DefaultMaxRetries,
+	}
+	return key, time.Since(info.ModTime()) > racyWindow
+}
+
+// parseTaskFileCached returns the tasks defined by the file at path, whose
+// stat is info, parsing it only when it changed since the last call.
+func parseTaskFileCached(path string, info os.FileInfo) ([]Task, error) {
+	key, stable := keyOf(info)
+	cache.Lock()
+	c, ok := cache.tasks[path]
+	cache.Unlock()
+	if ok && c.key == key {
+		return append([]Task(nil), c.tasks...), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := parseTaskFile(data, path)
+	if err != nil {
+		return nil, err
+	}
+	cache.Lock()
+	if stable {
+		cache.tasks[path] = cachedTasks{key: key, tasks: tasks}
+	} else {
+		delete(cache.tasks, path)
+	}
+	cache.Unlock()
+	return append([]Task(nil), tasks...), nil
+}
+
+// cachedCreatedAt returns the created_at of the task's init record in
+// stateDir if it was read before and has not changed since.
+func cachedCreatedAt(stateDir, id string) (time.Time, bool) {
+	path := filepath.Join(stateDir, id+".init.json")
+	cache.Lock()
+	c, ok := cache.inits[path]
+	cache.Unlock()
+	if !ok {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if key, _ := keyOf(info); key != c.key {
+		return time.Time{}, false
+	}
+	return c.createdAt, true
+}
+
+// rememberInit caches the created_at of the task's init record in stateDir.
+func rememberInit(stateDir, id string, createdAt time.Time) {
+	path := filepath.Join(stateDir, id+".init.json")
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	key, stable := keyOf(info)
+	if !stable {
+		return
+	}
+	cache.Lock()
+	cache.inits[path] = cachedInit{key: key, createdAt: createdAt}
+	cache.Unlock()
+}
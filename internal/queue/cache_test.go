@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadTasks_CachesUnchangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "task.yaml")
+	old := time.Now().Add(-time.Hour)
+	write := func(title string, mod time.Time) {
+		t.Helper()
+		writeYAML(t, path, "id: cached\ntitle: "+title+"\nworking_dir: /tmp\nprompt: hi\n")
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	title := func() string {
+		t.Helper()
+		tasks, err := LoadTasks(dir, "")
+		if err != nil || len(tasks) != 1 {
+			t.Fatalf("LoadTasks = %v, %v", tasks, err)
+		}
+		return tasks[0].Title
+	}
+
+	write("first", old)
+	if got := title(); got != "first" {
+		t.Fatalf("title = %q; want first", got)
+	}
+	// Same size and mtime: served from the cache.
+	write("other", old)
+	if got := title(); got != "first" {
+		t.Errorf("title = %q; want the cached first", got)
+	}
+	// A new mtime invalidates the entry.
+	write("other", old.Add(time.Minute))
+	if got := title(); got != "other" {
+		t.Errorf("title = %q; want other after the file changed", got)
+	}
+	// Files changed within the racy window are never cached.
+	write("fresh", time.Now())
+	write("fres2", time.Now())
+	if got := title(); got != "fres2" {
+		t.Errorf("title = %q; want fres2", got)
+	}
+}
+
+func TestLoadTasksAndInit_RecreatesRemovedInit(t *testing.T) {
+	taskDir, stateDir := t.TempDir(), t.TempDir()
+	writeYAML(t, filepath.Join(taskDir, "a.yaml"), "id: a\nworking_dir: /tmp\nprompt: hi\n")
+
+	if _, n, err := LoadTasksAndInit(taskDir, "", stateDir); err != nil || n != 1 {
+		t.Fatalf("first load = %d, %v; want 1 new init", n, err)
+	}
+	initPath := filepath.Join(stateDir, "a.init.json")
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(initPath, old, old)
+	if _, n, err := LoadTasksAndInit(taskDir, "", stateDir); err != nil || n != 0 {
+		t.Fatalf("second load = %d, %v; want 0 new inits", n, err)
+	}
+
+	os.Remove(initPath)
+	if _, n, err := LoadTasksAndInit(taskDir, "", stateDir); err != nil || n != 1 {
+		t.Errorf("load after removing the init = %d, %v; want it recreated", n, err)
+	}
+}
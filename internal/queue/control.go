@@ -2,23 +2,36 @@ package queue
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
 )
 
 // ControlCommand represents an operator-issued command to modify task state
 // outside of normal execution flow (e.g., retry a failed task, cancel a
 // running task).
 type ControlCommand struct {
+	ID          string    `json:"id,omitempty"` // UUID identifying the command across replays and duplicate appends
 	Op          string    `json:"op"`
-	TaskID      string    `json:"task_id"`
+	TaskID      string    `json:"task_id"` // for undo, the trash entry to restore
 	RequestedAt time.Time `json:"requested_at"`
 }
 
+// NewControlCommand returns a command for op on taskID with a fresh ID.
+// Appending the same command again (say, a retried append) is applied once.
+func NewControlCommand(op, taskID string) ControlCommand {
+	return ControlCommand{ID: newCommandID(), Op: op, TaskID: taskID, RequestedAt: time.Now().UTC()}
+}
+
 // AppendCommand appends a control command to the commands.jsonl file in the
 // given control directory. The file is flock-protected so multiple writers
 // (e.g., concurrent CLI invocations) can safely append.
@@ -41,6 +54,9 @@ func AppendCommand(controlDir string, cmd ControlCommand) error {
 	}
 	defer unlockFile(fd)
 
+	if cmd.ID == "" {
+		cmd.ID = newCommandID()
+	}
 	data, err := json.Marshal(cmd)
 	if err != nil {
 		return fmt.Errorf("marshal control command: %w", err)
@@ -71,7 +87,12 @@ func ReadCommands(controlDir string) ([]ControlCommand, error) {
 		return nil, fmt.Errorf("open commands file %s: %w", path, err)
 	}
 	defer f.Close()
+	return readCommandsFrom(f, path)
+}
 
+// readCommandsFrom parses control commands from f, the commands file at
+// path.
+func readCommandsFrom(f io.Reader, path string) ([]ControlCommand, error) {
 	var commands []ControlCommand
 	scanner := bufio.NewScanner(f)
 	lineNum := 0
@@ -119,3 +140,246 @@ func ClearCommands(controlDir string) error {
 
 	return nil
 }
+
+// newCommandID returns a random (version 4) UUID.
+func newCommandID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// appliedHistory is how many applied command IDs are remembered to reject
+// duplicates once their journal is gone.
+const appliedHistory = 1000
+
+// journalEntry is a line of the control journal: a claimed command, or the
+// ID of a command that has been applied.
+type journalEntry struct {
+	Command *ControlCommand `json:"command,omitempty"`
+	Done    string          `json:"done,omitempty"`
+}
+
+// ClaimCommands moves queued commands into the control journal
+// (journal.jsonl) and returns every journaled command not yet marked done,
+// oldest first. Under the commands file lock, the commands are appended to
+// the journal and synced before commands.jsonl is truncated, so a crash
+// leaves each command in at least one of the two files, and a command
+// already in the journal is never claimed twice. Commands left undone by a
+// crash are returned again on the next claim.
+func ClaimCommands(controlDir string) ([]ControlCommand, error) {
+	path := filepath.Join(controlDir, "commands.jsonl")
+	fd, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("open commands file %s: %w", path, err)
+	}
+	if fd != nil {
+		defer fd.Close()
+		if err := lockFileExclusive(fd); err != nil {
+			return nil, fmt.Errorf("lock commands file %s: %w", path, err)
+		}
+		defer unlockFile(fd)
+	}
+
+	pending, claimed, err := readJournal(controlDir)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := readApplied(controlDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range applied {
+		claimed[id] = true
+	}
+	if fd == nil {
+		return pending, nil
+	}
+
+	// Read through the locked handle: Windows locks are mandatory.
+	queued, err := readCommandsFrom(fd, path)
+	if err != nil {
+		return nil, err
+	}
+	var fresh []ControlCommand
+	for _, cmd := range queued {
+		if cmd.ID == "" {
+			cmd.ID = newCommandID() // queued by an older version
+		}
+		if claimed[cmd.ID] {
+			log.Printf("Skipping duplicate control command %s (%s %s)", cmd.ID, cmd.Op, cmd.TaskID)
+			continue
+		}
+		claimed[cmd.ID] = true
+		fresh = append(fresh, cmd)
+	}
+	if len(fresh) > 0 {
+		entries := make([]journalEntry, len(fresh))
+		for i := range fresh {
+			entries[i] = journalEntry{Command: &fresh[i]}
+		}
+		if err := appendJournal(controlDir, entries...); err != nil {
+			return nil, err
+		}
+	}
+	if len(queued) > 0 {
+		if err := fd.Truncate(0); err != nil {
+			return nil, fmt.Errorf("truncate commands file %s: %w", path, err)
+		}
+		if err := fd.Sync(); err != nil {
+			return nil, fmt.Errorf("fsync commands file after truncate: %w", err)
+		}
+	}
+	return append(pending, fresh...), nil
+}
+
+// MarkCommandDone records in the control journal that the command with id
+// has been applied.
+func MarkCommandDone(controlDir, id string) error {
+	return appendJournal(controlDir, journalEntry{Done: id})
+}
+
+// ClearJournal removes the control journal once every command in it has
+// been applied, adding their IDs to the applied history (applied.json,
+// the last appliedHistory IDs) so later duplicates are still rejected.
+func ClearJournal(controlDir string) error {
+	done, err := journalDone(controlDir)
+	if err != nil {
+		return err
+	}
+	if len(done) > 0 {
+		applied, err := readApplied(controlDir)
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]bool, len(applied))
+		for _, id := range applied {
+			seen[id] = true
+		}
+		for _, id := range done {
+			if !seen[id] {
+				seen[id] = true
+				applied = append(applied, id)
+			}
+		}
+		if len(applied) > appliedHistory {
+			applied = applied[len(applied)-appliedHistory:]
+		}
+		data, err := json.Marshal(applied)
+		if err != nil {
+			return fmt.Errorf("marshal applied commands: %w", err)
+		}
+		if err := fileutil.AtomicWrite(filepath.Join(controlDir, "applied.json"), append(data, '\n'), 0644); err != nil {
+			return fmt.Errorf("write applied commands: %w", err)
+		}
+	}
+	err = os.Remove(filepath.Join(controlDir, "journal.jsonl"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove control journal: %w", err)
+	}
+	return nil
+}
+
+// readApplied returns the IDs of recently applied commands, oldest first.
+func readApplied(controlDir string) ([]string, error) {
+	path := filepath.Join(controlDir, "applied.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		log.Printf("warning: ignoring unreadable %s: %v", path, err)
+		return nil, nil
+	}
+	return ids, nil
+}
+
+// journalDone returns the IDs marked done in the control journal, in order.
+func journalDone(controlDir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(controlDir, "journal.jsonl"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read control journal: %w", err)
+	}
+	var ids []string
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var e journalEntry
+		if json.Unmarshal(line, &e) == nil && e.Done != "" {
+			ids = append(ids, e.Done)
+		}
+	}
+	return ids, nil
+}
+
+// readJournal returns the journaled commands not yet marked done, in
+// order, and the IDs of every journaled command. A torn final line from a
+// crash mid-append is ignored.
+func readJournal(controlDir string) ([]ControlCommand, map[string]bool, error) {
+	path := filepath.Join(controlDir, "journal.jsonl")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, map[string]bool{}, nil
+		}
+		return nil, nil, fmt.Errorf("read control journal %s: %w", path, err)
+	}
+
+	claimed := make(map[string]bool)
+	done := make(map[string]bool)
+	var commands []ControlCommand
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var e journalEntry
+		if len(line) == 0 || json.Unmarshal(line, &e) != nil {
+			continue
+		}
+		switch {
+		case e.Command != nil:
+			claimed[e.Command.ID] = true
+			commands = append(commands, *e.Command)
+		case e.Done != "":
+			done[e.Done] = true
+		}
+	}
+	var pending []ControlCommand
+	for _, cmd := range commands {
+		if !done[cmd.ID] {
+			pending = append(pending, cmd)
+		}
+	}
+	return pending, claimed, nil
+}
+
+// appendJournal appends entries to the control journal and syncs it.
+func appendJournal(controlDir string, entries ...journalEntry) error {
+	path := filepath.Join(controlDir, "journal.jsonl")
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open control journal %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("marshal journal entry: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("write control journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("fsync control journal: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package queue
 import (
 	"os"
 	"path/filepath"
+	"regexp"
 	"testing"
 	"time"
 )
@@ -140,3 +141,88 @@ func TestAppendCommand_CreatesDirectory(t *testing.T) {
 		t.Fatalf("got %d commands; want 1", len(commands))
 	}
 }
+
+func TestClaimCommands_Journal(t *testing.T) {
+	dir := t.TempDir()
+	for _, id := range []string{"task-1", "task-2"} {
+		if err := AppendCommand(dir, ControlCommand{Op: "retry", TaskID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claimed, err := ClaimCommands(dir)
+	if err != nil {
+		t.Fatalf("ClaimCommands: %v", err)
+	}
+	if len(claimed) != 2 || claimed[0].ID == "" || claimed[0].ID == claimed[1].ID {
+		t.Fatalf("claimed = %+v; want two commands with distinct IDs", claimed)
+	}
+	if queued, _ := ReadCommands(dir); len(queued) != 0 {
+		t.Errorf("commands file still holds %d commands after claim", len(queued))
+	}
+
+	// A crash after the first command leaves the second to be replayed,
+	// along with anything queued since.
+	if err := MarkCommandDone(dir, claimed[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	AppendCommand(dir, ControlCommand{Op: "cancel", TaskID: "task-3"})
+	again, err := ClaimCommands(dir)
+	if err != nil {
+		t.Fatalf("second ClaimCommands: %v", err)
+	}
+	if len(again) != 2 || again[0].ID != claimed[1].ID || again[1].TaskID != "task-3" {
+		t.Errorf("replayed = %+v; want task-2 then task-3", again)
+	}
+
+	if err := ClearJournal(dir); err != nil {
+		t.Fatal(err)
+	}
+	if rest, err := ClaimCommands(dir); err != nil || len(rest) != 0 {
+		t.Errorf("ClaimCommands after ClearJournal = %+v, %v; want none", rest, err)
+	}
+}
+
+func TestClaimCommands_SkipsJournaledCommands(t *testing.T) {
+	dir := t.TempDir()
+	AppendCommand(dir, ControlCommand{ID: "abc", Op: "retry", TaskID: "task-1"})
+	if _, err := ClaimCommands(dir); err != nil {
+		t.Fatal(err)
+	}
+	// A crash between the journal write and the truncation leaves the
+	// command in both files; it must be claimed only once.
+	AppendCommand(dir, ControlCommand{ID: "abc", Op: "retry", TaskID: "task-1"})
+	claimed, err := ClaimCommands(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].ID != "abc" {
+		t.Errorf("claimed = %+v; want abc once", claimed)
+	}
+}
+
+func TestClaimCommands_SkipsAppliedDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	cmd := NewControlCommand("retry", "task-1")
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(cmd.ID) {
+		t.Errorf("ID = %q; want a version 4 UUID", cmd.ID)
+	}
+
+	// Racing appends of the same command within one batch.
+	AppendCommand(dir, cmd)
+	AppendCommand(dir, cmd)
+	claimed, err := ClaimCommands(dir)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimCommands = %+v, %v; want one command", claimed, err)
+	}
+	MarkCommandDone(dir, cmd.ID)
+	if err := ClearJournal(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// A late duplicate, after the journal is gone.
+	AppendCommand(dir, cmd)
+	if claimed, err := ClaimCommands(dir); err != nil || len(claimed) != 0 {
+		t.Errorf("ClaimCommands = %+v, %v; want the applied duplicate skipped", claimed, err)
+	}
+}
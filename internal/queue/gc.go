@@ -0,0 +1,116 @@
+package queue
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orphan is a task whose state outlived its definition.
+type Orphan struct {
+	ID      string
+	Source  string    // file that defined the task; empty for ad-hoc (exec) tasks
+	ModTime time.Time // last change to its state
+	// Unverified is set when the task was initialized before sources were
+	// recorded, so it may still be defined in another project's task
+	// directory.
+	Unverified bool
+}
+
+// FindOrphans returns, sorted by ID, the tasks with records in stateDir that
+// are not among tasks and whose recorded source file no longer defines
+// them. Ad-hoc tasks, which have state but no init record, are always
+// orphans.
+func FindOrphans(stateDir string, tasks []Task) ([]Orphan, error) {
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read state dir: %w", err)
+	}
+
+	live := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		live[t.ID] = true
+	}
+
+	latest := make(map[string]time.Time)
+	for _, e := range entries {
+		name := e.Name()
+		var id string
+		switch {
+		case strings.HasSuffix(name, ".state.json"):
+			id = strings.TrimSuffix(name, ".state.json")
+		case strings.HasSuffix(name, ".init.json"):
+			id = strings.TrimSuffix(name, ".init.json")
+		default:
+			continue
+		}
+		if live[id] || !IsValidID(id) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latest[id]) {
+			latest[id] = info.ModTime()
+		}
+	}
+
+	var orphans []Orphan
+	for id, mod := range latest {
+		o := Orphan{ID: id, ModTime: mod}
+		init, err := LoadInit(stateDir, id)
+		switch {
+		case err != nil:
+			continue // unreadable; leave it alone
+		case init == nil:
+			// ad-hoc task
+		case init.Source == "":
+			o.Unverified = true
+		default:
+			o.Source = init.Source
+			if definedIn(init.Source, id) {
+				continue
+			}
+		}
+		orphans = append(orphans, o)
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].ID < orphans[j].ID })
+	return orphans, nil
+}
+
+// definedIn reports whether the task file (or pipeline step) at source
+// still defines id. A file that exists but cannot be parsed counts as
+// defining it, so a typo never costs a task its state.
+func definedIn(source, id string) bool {
+	tasks, err := loadTasksFromFile(SourceFile(source))
+	if err != nil {
+		return true
+	}
+	for _, t := range tasks {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoveTaskData deletes a task's state and init records from stateDir and
+// its logs (including the rotated backup) from logsDir. It returns the
+// number of files removed.
+func RemoveTaskData(stateDir, logsDir, id string) (int, error) {
+	removed := 0
+	for _, path := range taskDataFiles(stateDir, logsDir, id) {
+		if err := os.Remove(path); err == nil {
+			removed++
+		} else if !os.IsNotExist(err) {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
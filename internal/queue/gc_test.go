@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindOrphans(t *testing.T) {
+	stateDir := t.TempDir()
+	taskDir := t.TempDir()
+
+	kept := filepath.Join(taskDir, "kept.yaml")
+	os.WriteFile(kept, []byte("id: moved\ntitle: Moved\nworking_dir: /tmp\nprompt: hi\n"), 0644)
+
+	for _, task := range []Task{
+		{ID: "live", Source: kept},
+		{ID: "deleted", Source: filepath.Join(taskDir, "gone.yaml")},
+		{ID: "moved", Source: kept},
+		{ID: "legacy"},
+	} {
+		task := task
+		if _, err := EnsureInit(stateDir, &task); err != nil {
+			t.Fatal(err)
+		}
+		SaveState(stateDir, &TaskState{ID: task.ID, Status: StatusDone})
+	}
+	SaveState(stateDir, &TaskState{ID: "adhoc", Status: StatusDone})
+
+	orphans, err := FindOrphans(stateDir, []Task{{ID: "live"}})
+	if err != nil {
+		t.Fatalf("FindOrphans: %v", err)
+	}
+	got := map[string]Orphan{}
+	for _, o := range orphans {
+		got[o.ID] = o
+	}
+	if len(got) != 3 {
+		t.Fatalf("orphans = %+v; want adhoc, deleted and legacy", orphans)
+	}
+	if o := got["deleted"]; o.Unverified || o.Source == "" || o.ModTime.IsZero() {
+		t.Errorf("deleted = %+v; want a verified orphan with its source", o)
+	}
+	if o, ok := got["adhoc"]; !ok || o.Unverified || o.Source != "" {
+		t.Errorf("adhoc = %+v, %v; want a verified orphan without source", o, ok)
+	}
+	if !got["legacy"].Unverified {
+		t.Error("legacy task without a recorded source should be unverified")
+	}
+}
+
+func TestRemoveTaskData(t *testing.T) {
+	stateDir, logsDir := t.TempDir(), t.TempDir()
+	task := Task{ID: "old"}
+	EnsureInit(stateDir, &task)
+	SaveState(stateDir, &TaskState{ID: "old", Status: StatusDone})
+	os.WriteFile(filepath.Join(logsDir, "old.log"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(logsDir, "old.log.1"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(logsDir, "other.log"), []byte("x"), 0644)
+
+	n, err := RemoveTaskData(stateDir, logsDir, "old")
+	if err != nil || n != 4 {
+		t.Fatalf("RemoveTaskData = %d, %v; want 4, nil", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(logsDir, "other.log")); err != nil {
+		t.Error("another task's log was removed")
+	}
+	if n, err := RemoveTaskData(stateDir, logsDir, "old"); err != nil || n != 0 {
+		t.Errorf("second RemoveTaskData = %d, %v; want 0, nil", n, err)
+	}
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph output formats.
+const (
+	GraphDOT     = "dot"
+	GraphMermaid = "mermaid"
+)
+
+// graphColors fills nodes by status; pending tasks keep the default.
+var graphColors = map[string]string{
+	StatusRunning:   "#fff3bf",
+	StatusWaiting:   "#d0ebff",
+	StatusDone:      "#d3f9d8",
+	StatusFailed:    "#ffe3e3",
+	StatusCancelled: "#e9ecef",
+}
+
+// Graph renders the dependency structure of tasks in format (GraphDOT or
+// GraphMermaid). Each task is a node labelled with its ID, priority and
+// status (statuses maps task IDs to status; missing ones are pending), an
+// edge runs from a dependency to the task waiting on it, and tasks sharing
+// a concurrency group are drawn together. Nodes keep the order of tasks,
+// which LoadTasks sorts by priority.
+func Graph(tasks []Task, statuses map[string]string, format string) (string, error) {
+	if format != GraphDOT && format != GraphMermaid {
+		return "", fmt.Errorf("unknown graph format %q (want dot or mermaid)", format)
+	}
+
+	names := make(map[string]string, len(tasks))
+	groups := make(map[string][]int)
+	var groupNames []string
+	for i, t := range tasks {
+		names[t.ID] = fmt.Sprintf("t%d", i)
+		if t.Group != "" {
+			if groups[t.Group] == nil {
+				groupNames = append(groupNames, t.Group)
+			}
+			groups[t.Group] = append(groups[t.Group], i)
+		}
+	}
+	sort.Strings(groupNames)
+
+	status := func(t Task) string {
+		if s := statuses[t.ID]; s != "" {
+			return s
+		}
+		return StatusPending
+	}
+	label := func(t Task) string {
+		l := fmt.Sprintf("%s\nP%d · %s", t.ID, t.Priority, status(t))
+		if t.Exclusive {
+			l += " · exclusive"
+		}
+		return l
+	}
+
+	var b strings.Builder
+	if format == GraphDOT {
+		b.WriteString("digraph queue {\n  rankdir=LR;\n  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+		node := func(indent string, t Task) {
+			attrs := fmt.Sprintf("label=%q", label(t))
+			if c := graphColors[status(t)]; c != "" {
+				attrs += fmt.Sprintf(", fillcolor=%q", c)
+			}
+			fmt.Fprintf(&b, "%s%s [%s];\n", indent, names[t.ID], attrs)
+		}
+		for i, g := range groupNames {
+			fmt.Fprintf(&b, "  subgraph cluster_%d {\n    label=%q;\n", i, "group: "+g)
+			for _, j := range groups[g] {
+				node("    ", tasks[j])
+			}
+			b.WriteString("  }\n")
+		}
+		for _, t := range tasks {
+			if t.Group == "" {
+				node("  ", t)
+			}
+		}
+		for _, t := range tasks {
+			for _, dep := range t.Dependencies() {
+				if from, ok := names[dep]; ok {
+					fmt.Fprintf(&b, "  %s -> %s;\n", from, names[t.ID])
+				}
+			}
+		}
+		b.WriteString("}\n")
+		return b.String(), nil
+	}
+
+	b.WriteString("flowchart LR\n")
+	node := func(indent string, t Task) {
+		l := strings.ReplaceAll(label(t), `"`, "#quot;")
+		fmt.Fprintf(&b, "%s%s[\"%s\"]\n", indent, names[t.ID], strings.ReplaceAll(l, "\n", "<br/>"))
+	}
+	for i, g := range groupNames {
+		fmt.Fprintf(&b, "  subgraph g%d [\"group: %s\"]\n", i, g)
+		for _, j := range groups[g] {
+			node("    ", tasks[j])
+		}
+		b.WriteString("  end\n")
+	}
+	for _, t := range tasks {
+		if t.Group == "" {
+			node("  ", t)
+		}
+	}
+	for _, t := range tasks {
+		for _, dep := range t.Dependencies() {
+			if from, ok := names[dep]; ok {
+				fmt.Fprintf(&b, "  %s --> %s\n", from, names[t.ID])
+			}
+		}
+	}
+	for _, s := range []string{StatusRunning, StatusWaiting, StatusDone, StatusFailed, StatusCancelled} {
+		var ids []string
+		for _, t := range tasks {
+			if status(t) == s {
+				ids = append(ids, names[t.ID])
+			}
+		}
+		if len(ids) > 0 {
+			fmt.Fprintf(&b, "  classDef %s fill:%s\n  class %s %s\n", s, graphColors[s], strings.Join(ids, ","), s)
+		}
+	}
+	return b.String(), nil
+}
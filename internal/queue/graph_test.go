@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	tasks := []Task{
+		{ID: "schema", Priority: 1},
+		{ID: "api", Priority: 5, DependsOn: []string{"schema"}, Group: "db"},
+		{ID: "docs", Priority: 10, Prompt: "Describe {{task:api.summary}}", Exclusive: true},
+	}
+	statuses := map[string]string{"schema": StatusDone}
+
+	dot, err := Graph(tasks, statuses, GraphDOT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`t0 [label="schema\nP1 · done", fillcolor="#d3f9d8"];`,
+		"subgraph cluster_0 {\n    label=\"group: db\";\n    t1 [label=\"api\\nP5 · pending\"];\n  }",
+		`t2 [label="docs\nP10 · pending · exclusive"];`,
+		"t0 -> t1;",
+		"t1 -> t2;",
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot output missing %q:\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := Graph(tasks, statuses, GraphMermaid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"flowchart LR\n",
+		"subgraph g0 [\"group: db\"]\n    t1[\"api<br/>P5 · pending\"]\n  end",
+		"t0 --> t1",
+		"t1 --> t2",
+		"class t0 done",
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid output missing %q:\n%s", want, mermaid)
+		}
+	}
+
+	if _, err := Graph(tasks, nil, "svg"); err == nil {
+		t.Error("an unknown format should fail")
+	}
+}
@@ -0,0 +1,36 @@
+package queue
+
+import (
+	"os"
+	"os/user"
+)
+
+// Origin kinds set by claude-autopilot itself. Integrations that write task
+// files may use their own, e.g. github or api.
+const (
+	OriginCLI  = "cli"  // claude-autopilot add
+	OriginPlan = "plan" // a subtask queued by a plan task
+	OriginFile = "file" // a task file without an origin, written by hand
+)
+
+// Origin records where a task came from, for the audit trail of a shared
+// queue. It is written into the task file by whatever creates the task and
+// copied into the task's init record when the task is first seen.
+type Origin struct {
+	Kind string `yaml:"kind" json:"kind"`
+	Ref  string `yaml:"ref,omitempty" json:"ref,omitempty"`   // what created it: the plan task ID, an issue URL, ...
+	Host string `yaml:"host,omitempty" json:"host,omitempty"` // machine it was created on
+	User string `yaml:"user,omitempty" json:"user,omitempty"` // account that created it
+}
+
+// NewOrigin returns an origin of kind with the current host and user.
+func NewOrigin(kind, ref string) *Origin {
+	o := &Origin{Kind: kind, Ref: ref}
+	o.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		o.User = u.Username
+	} else {
+		o.User = os.Getenv("USER")
+	}
+	return o
+}
@@ -0,0 +1,336 @@
+package queue
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// summaryRefRe matches a {{task:<id>.summary}} placeholder in a prompt.
+var summaryRefRe = regexp.MustCompile(`\{\{task:([a-z0-9-]+)\.summary\}\}`)
+
+// SummaryRefs returns the IDs of the tasks whose summaries prompt
+// references, in order of first use.
+func SummaryRefs(prompt string) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	for _, m := range summaryRefRe.FindAllStringSubmatch(prompt, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			ids = append(ids, m[1])
+		}
+	}
+	return ids
+}
+
+// ExpandSummaryRefs replaces each {{task:<id>.summary}} in prompt with
+// lookup(id).
+func ExpandSummaryRefs(prompt string, lookup func(id string) string) string {
+	return summaryRefRe.ReplaceAllStringFunc(prompt, func(ref string) string {
+		return lookup(summaryRefRe.FindStringSubmatch(ref)[1])
+	})
+}
+
+// Dependencies returns the IDs of the tasks that must be done before t can
+// start: its depends_on list followed by tasks whose summaries its prompt
+// uses.
+func (t *Task) Dependencies() []string {
+	deps := append([]string(nil), t.DependsOn...)
+	for _, id := range SummaryRefs(t.Prompt) {
+		if !containsString(deps, id) {
+			deps = append(deps, id)
+		}
+	}
+	return deps
+}
+
+// Pipeline is a pipeline definition file: an ordered set of steps sharing
+// defaults, compiled into one task per step.
+type Pipeline struct {
+	Name string `yaml:"name"` // prefixes every step's task ID
+	// PassContext appends the summaries of a step's dependencies to its
+	// prompt. Defaults to true.
+	PassContext *bool  `yaml:"pass_context,omitempty"`
+	Defaults    Task   `yaml:"defaults,omitempty"` // task fields applied to every step that leaves them unset
+	Steps       []Task `yaml:"steps"`
+}
+
+// IsPipelineFile reports whether name is a pipeline definition file
+// (pipeline.yaml or <name>.pipeline.yaml) rather than a task file.
+func IsPipelineFile(name string) bool {
+	for _, ext := range []string{".yaml", ".yml"} {
+		if name == "pipeline"+ext || strings.HasSuffix(name, ".pipeline"+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParsePipeline compiles a pipeline definition into tasks. Step N depends
+// on step N-1 unless it lists its own depends_on (by step ID; an empty list
+// makes it independent). Task IDs are "<name>-<step id>".
+func ParsePipeline(data []byte, source string) ([]Task, error) {
+	var p Pipeline
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	if !IsValidID(p.Name) {
+		return nil, fmt.Errorf("pipeline name must match [a-z0-9-] (got '%s')", p.Name)
+	}
+	if len(p.Steps) == 0 {
+		return nil, fmt.Errorf("pipeline '%s' has no steps", p.Name)
+	}
+	passContext := p.PassContext == nil || *p.PassContext
+
+	stepIndex := make(map[string]int, len(p.Steps))
+	for i, s := range p.Steps {
+		if !IsValidID(s.ID) {
+			return nil, fmt.Errorf("pipeline '%s' step %d: id must match [a-z0-9-] (got '%s')", p.Name, i+1, s.ID)
+		}
+		if _, dup := stepIndex[s.ID]; dup {
+			return nil, fmt.Errorf("pipeline '%s': duplicate step id '%s'", p.Name, s.ID)
+		}
+		stepIndex[s.ID] = i
+	}
+	taskID := func(step string) string { return p.Name + "-" + step }
+
+	tasks := make([]Task, len(p.Steps))
+	for i, s := range p.Steps {
+		t := s
+		if err := openPrompt(&t); err != nil {
+			return nil, fmt.Errorf("pipeline '%s' step '%s': %w", p.Name, s.ID, err)
+		}
+		applyStepDefaults(&t, &p.Defaults)
+		t.ID = taskID(s.ID)
+		t.Source = fmt.Sprintf("%s#%s", source, s.ID)
+		if t.Title == "" {
+			t.Title = fmt.Sprintf("%s: %s", p.Name, s.ID)
+		}
+
+		deps := s.DependsOn
+		if deps == nil && i > 0 {
+			deps = []string{p.Steps[i-1].ID}
+		}
+		t.DependsOn = nil
+		for _, d := range deps {
+			if _, ok := stepIndex[d]; !ok {
+				return nil, fmt.Errorf("pipeline '%s' step '%s': depends_on references unknown step '%s'", p.Name, s.ID, d)
+			}
+			t.DependsOn = append(t.DependsOn, taskID(d))
+		}
+		tasks[i] = t
+	}
+
+	if passContext {
+		for i := range tasks {
+			var b strings.Builder
+			for _, dep := range tasks[i].DependsOn {
+				tasks[stepIndex[strings.TrimPrefix(dep, p.Name+"-")]].ExportSummary = true
+				if containsString(SummaryRefs(tasks[i].Prompt), dep) {
+					continue
+				}
+				fmt.Fprintf(&b, "\n[Step %s]\n{{task:%s.summary}}\n", strings.TrimPrefix(dep, p.Name+"-"), dep)
+			}
+			if b.Len() > 0 {
+				tasks[i].Prompt = strings.TrimRight(tasks[i].Prompt, "\n") + "\n\nResults of the steps this one builds on:\n" + b.String()
+			}
+		}
+	}
+
+	for i := range tasks {
+		if err := applyDefaults(&tasks[i]); err != nil {
+			return nil, fmt.Errorf("step '%s': %w", p.Steps[i].ID, err)
+		}
+		if err := validateTask(&tasks[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCycles(tasks); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// applyStepDefaults fills the fields a pipeline step leaves unset from the
+// pipeline's defaults.
+func applyStepDefaults(t, d *Task) {
+	if t.WorkingDir == "" {
+		t.WorkingDir = d.WorkingDir
+	}
+	if t.Priority == 0 {
+		t.Priority = d.Priority
+	}
+	if !t.SkipPermissions {
+		t.SkipPermissions = d.SkipPermissions
+	}
+	if t.ContextFiles == nil {
+		t.ContextFiles = d.ContextFiles
+	}
+	if t.ContextCommands == nil {
+		t.ContextCommands = d.ContextCommands
+	}
+	if t.Model == "" {
+		t.Model = d.Model
+	}
+	if t.Agent == "" {
+		t.Agent = d.Agent
+	}
+	if t.Container == "" {
+		t.Container = d.Container
+		t.ContainerNet = d.ContainerNet
+		t.ContainerCPUs = d.ContainerCPUs
+		t.ContainerMem = d.ContainerMem
+	}
+	if t.ClaudeVersion == "" {
+		t.ClaudeVersion = d.ClaudeVersion
+	}
+	if t.Nice == 0 {
+		t.Nice = d.Nice
+	}
+	if t.MaxCPUTime == "" {
+		t.MaxCPUTime = d.MaxCPUTime
+	}
+	if t.MaxMemory == "" {
+		t.MaxMemory = d.MaxMemory
+	}
+	if t.MaxRetries == 0 {
+		t.MaxRetries = d.MaxRetries
+	}
+	if t.HangTimeout == "" {
+		t.HangTimeout = d.HangTimeout
+	}
+	if !t.NoHangDetection {
+		t.NoHangDetection = d.NoHangDetection
+	}
+	if t.Flags == nil {
+		t.Flags = d.Flags
+	}
+	if t.ResumeStrategy == "" {
+		t.ResumeStrategy = d.ResumeStrategy
+	}
+	if t.Tags == nil {
+		t.Tags = d.Tags
+	}
+	if t.RunDays == nil {
+		t.RunDays = d.RunDays
+	}
+	if t.Group == "" {
+		t.Group = d.Group
+	}
+	if !t.Exclusive {
+		t.Exclusive = d.Exclusive
+	}
+	if t.SkipDates == nil {
+		t.SkipDates = d.SkipDates
+	}
+	if t.AutoApprove == nil {
+		t.AutoApprove = d.AutoApprove
+	}
+	if t.Artifacts == nil {
+		t.Artifacts = d.Artifacts
+	}
+	if t.FailOnNoChanges == nil {
+		t.FailOnNoChanges = d.FailOnNoChanges
+	}
+	if t.MaxCostUSD == 0 {
+		t.MaxCostUSD = d.MaxCostUSD
+	}
+	if t.MaxTokens == 0 {
+		t.MaxTokens = d.MaxTokens
+	}
+	if t.Verify == "" {
+		t.Verify = d.Verify
+	}
+	if t.RetryOn == nil {
+		t.RetryOn = d.RetryOn
+	}
+	if t.FailFastOn == nil {
+		t.FailFastOn = d.FailFastOn
+	}
+	if !t.Review {
+		t.Review = d.Review
+	}
+	if t.ArchiveDone == nil {
+		t.ArchiveDone = d.ArchiveDone
+	}
+	if t.Origin == nil {
+		t.Origin = d.Origin
+	}
+}
+
+// validateDependencies checks that every dependency names a loaded task and
+// that dependencies do not form a cycle.
+func validateDependencies(tasks []Task) error {
+	byID := make(map[string]*Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	for _, t := range tasks {
+		for _, dep := range t.Dependencies() {
+			if _, ok := byID[dep]; !ok {
+				return fmt.Errorf("Task '%s' (%s): depends on unknown task '%s'", t.ID, t.Source, dep)
+			}
+		}
+	}
+	return checkCycles(tasks)
+}
+
+// checkCycles reports a dependency cycle among tasks. Dependencies on tasks
+// outside the list are ignored.
+func checkCycles(tasks []Task) error {
+	byID := make(map[string]*Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	mark := make(map[string]int, len(tasks))
+	var path []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch mark[id] {
+		case visiting:
+			start := 0
+			for path[start] != id {
+				start++
+			}
+			return fmt.Errorf("Dependency cycle: %s -> %s", strings.Join(path[start:], " -> "), id)
+		case visited:
+			return nil
+		}
+		t, ok := byID[id]
+		if !ok {
+			return nil
+		}
+		mark[id] = visiting
+		path = append(path, id)
+		for _, dep := range t.Dependencies() {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		mark[id] = visited
+		return nil
+	}
+	for _, t := range tasks {
+		if err := visit(t.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
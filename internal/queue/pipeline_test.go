@@ -0,0 +1,145 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePipeline_CompilesSteps(t *testing.T) {
+	data := []byte(`
+name: auth
+defaults:
+  working_dir: /repo
+  model: claude-sonnet-4-5
+  max_retries: 2
+  tags: [auth]
+steps:
+  - id: analyze
+    prompt: Find the weaknesses.
+  - id: implement
+    prompt: Fix them.
+    verify: go test ./...
+  - id: tests
+    prompt: "Cover {{task:auth-analyze.summary}}"
+    depends_on: [analyze, implement]
+    model: claude-opus-4
+  - id: docs
+    prompt: Update the README.
+    depends_on: []
+`)
+	tasks, err := ParsePipeline(data, "/x/pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 4 {
+		t.Fatalf("got %d tasks; want 4", len(tasks))
+	}
+	analyze, implement, tests, docs := tasks[0], tasks[1], tasks[2], tasks[3]
+
+	if analyze.ID != "auth-analyze" || analyze.WorkingDir != "/repo" || analyze.Model != "claude-sonnet-4-5" || analyze.MaxRetries != 2 || analyze.Priority != 10 {
+		t.Errorf("analyze = %+v", analyze)
+	}
+	if analyze.Source != "/x/pipeline.yaml#analyze" || analyze.Title != "auth: analyze" {
+		t.Errorf("analyze source/title = %q / %q", analyze.Source, analyze.Title)
+	}
+	if len(analyze.DependsOn) != 0 || !analyze.ExportSummary {
+		t.Errorf("analyze depends_on = %v, export_summary = %v", analyze.DependsOn, analyze.ExportSummary)
+	}
+	if strings.Join(implement.DependsOn, ",") != "auth-analyze" || implement.Verify != "go test ./..." || !implement.ExportSummary {
+		t.Errorf("implement = %+v", implement)
+	}
+	if !strings.Contains(implement.Prompt, "[Step analyze]\n{{task:auth-analyze.summary}}") {
+		t.Errorf("implement prompt = %q", implement.Prompt)
+	}
+	if strings.Join(tests.DependsOn, ",") != "auth-analyze,auth-implement" || tests.Model != "claude-opus-4" {
+		t.Errorf("tests = %+v", tests)
+	}
+	// A summary the prompt already uses is not appended again.
+	if strings.Count(tests.Prompt, "{{task:auth-analyze.summary}}") != 1 || !strings.Contains(tests.Prompt, "{{task:auth-implement.summary}}") {
+		t.Errorf("tests prompt = %q", tests.Prompt)
+	}
+	if len(docs.DependsOn) != 0 || docs.Prompt != "Update the README." || docs.ExportSummary {
+		t.Errorf("docs = %+v", docs)
+	}
+}
+
+func TestParsePipeline_NoContextPassing(t *testing.T) {
+	data := []byte(`
+name: p
+pass_context: false
+defaults: {working_dir: /repo}
+steps:
+  - {id: a, prompt: one}
+  - {id: b, prompt: two}
+`)
+	tasks, err := ParsePipeline(data, "p.pipeline.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[1].Prompt != "two" || tasks[0].ExportSummary || strings.Join(tasks[1].DependsOn, ",") != "p-a" {
+		t.Errorf("tasks = %+v", tasks)
+	}
+}
+
+func TestParsePipeline_Errors(t *testing.T) {
+	tests := map[string]string{
+		"name must match":          "name: Bad Name\nsteps: [{id: a, prompt: x, working_dir: /r}]",
+		"has no steps":             "name: p\nsteps: []",
+		"duplicate step id":        "name: p\ndefaults: {working_dir: /r}\nsteps: [{id: a, prompt: x}, {id: a, prompt: y}]",
+		"unknown step 'missing'":   "name: p\ndefaults: {working_dir: /r}\nsteps: [{id: a, prompt: x, depends_on: [missing]}]",
+		"missing required field":   "name: p\nsteps: [{id: a, prompt: x}]",
+		"step 1: id must match":    "name: p\ndefaults: {working_dir: /r}\nsteps: [{prompt: x}]",
+		"cycle: p-a -> p-b -> p-a": "name: p\ndefaults: {working_dir: /r}\nsteps: [{id: a, prompt: x, depends_on: [b]}, {id: b, prompt: y}]",
+	}
+	for want, doc := range tests {
+		if _, err := ParsePipeline([]byte(doc), "pipeline.yaml"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: error = %v; want %q", doc, err, want)
+		}
+	}
+}
+
+func TestLoadTasks_PipelineFilesAndDependencies(t *testing.T) {
+	root := t.TempDir()
+	taskDir := filepath.Join(root, "tasks")
+	if err := os.MkdirAll(taskDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "pipeline.yaml"), []byte("name: main\ndefaults: {working_dir: /r}\nsteps: [{id: a, prompt: x}, {id: b, prompt: y}]\n"), 0644)
+	os.WriteFile(filepath.Join(taskDir, "extra.pipeline.yaml"), []byte("name: extra\ndefaults: {working_dir: /r}\nsteps: [{id: a, prompt: x}]\n"), 0644)
+	os.WriteFile(filepath.Join(taskDir, "after.yaml"), []byte("id: after\nworking_dir: /r\nprompt: z\ndepends_on: [main-b, extra-a]\n"), 0644)
+
+	tasks, err := LoadTasks(taskDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	if strings.Join(ids, ",") != "after,extra-a,main-a,main-b" {
+		t.Errorf("ids = %v", ids)
+	}
+
+	os.WriteFile(filepath.Join(taskDir, "after.yaml"), []byte("id: after\nworking_dir: /r\nprompt: z\ndepends_on: [nope]\n"), 0644)
+	if _, err := LoadTasks(taskDir, ""); err == nil || !strings.Contains(err.Error(), "unknown task 'nope'") {
+		t.Errorf("unknown dependency: %v", err)
+	}
+}
+
+func TestValidateDependencies_Cycle(t *testing.T) {
+	tasks := []Task{
+		{ID: "a", Prompt: "x", DependsOn: []string{"b"}},
+		{ID: "b", Prompt: "{{task:c.summary}}"},
+		{ID: "c", Prompt: "x", DependsOn: []string{"a"}},
+	}
+	err := validateDependencies(tasks)
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("error = %v; want the cycle", err)
+	}
+	tasks[2].DependsOn = nil
+	if err := validateDependencies(tasks); err != nil {
+		t.Errorf("acyclic: %v", err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -19,6 +20,21 @@ import (
 
 var taskIDRe = regexp.MustCompile(`^[a-z0-9-]+$`)
 
+// claudeVersionRe matches a claude_version: a full version or a prefix of
+// one, like "2.0.14" or "2.0".
+var claudeVersionRe = regexp.MustCompile(`^v?[0-9]+(\.[0-9]+){0,2}$`)
+
+// sizeRe matches a container_memory or max_memory size, like 512m or 4g.
+var sizeRe = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// DefaultPriority and DefaultMaxRetries fill in tasks that leave priority
+// or max_retries unset. The CLI sets them from the default_priority and
+// default_max_retries config keys.
+var (
+	DefaultPriority   = 10
+	DefaultMaxRetries = 5
+)
+
 // IsValidID reports whether id matches the filesystem-safe task ID format.
 func IsValidID(id string) bool {
 	return len(id) > 0 && len(id) <= 64 && taskIDRe.MatchString(id)
@@ -35,8 +51,25 @@ func LoadTasks(globalDir, projectDir string) ([]Task, error) {
 // LoadTasksAndInit loads tasks like LoadTasks, and when stateDir is non-empty
 // it also ensures/reads each task's immutable init record so created_at is
 // canonicalized before sorting. The second return value is the count of newly
-// initialized tasks.
+// initialized tasks. Only the runner initializes tasks; other commands load
+// with LoadTasksReadOnly.
 func LoadTasksAndInit(globalDir, projectDir, stateDir string) ([]Task, int, error) {
+	return loadTasks(globalDir, projectDir, stateDir, true)
+}
+
+// LoadTasksReadOnly loads tasks like LoadTasksAndInit but never writes:
+// created_at comes from existing init records, and a task without one is
+// ordered as if first seen now, as its init record will say once the runner
+// creates it. It works on a read-only state directory.
+func LoadTasksReadOnly(globalDir, projectDir, stateDir string) ([]Task, error) {
+	tasks, _, err := loadTasks(globalDir, projectDir, stateDir, false)
+	return tasks, err
+}
+
+// loadTasks loads, validates and sorts tasks. With stateDir set, created_at
+// is read from the init records, which are created if missing when create is
+// set.
+func loadTasks(globalDir, projectDir, stateDir string, create bool) ([]Task, int, error) {
 	var allTasks []Task
 	initCount := 0
 
@@ -59,6 +92,23 @@ func LoadTasksAndInit(globalDir, projectDir, stateDir string) ([]Task, int, erro
 	// Canonicalize created_at from per-task init records before sorting.
 	if stateDir != "" {
 		for i := range allTasks {
+			if at, ok := cachedCreatedAt(stateDir, allTasks[i].ID); ok {
+				allTasks[i].CreatedAt = at
+				continue
+			}
+			if !create {
+				init, err := LoadInit(stateDir, allTasks[i].ID)
+				if err != nil {
+					return nil, 0, fmt.Errorf("read init record of task %q: %w", allTasks[i].ID, err)
+				}
+				if init != nil {
+					allTasks[i].CreatedAt = init.CreatedAt
+					rememberInit(stateDir, allTasks[i].ID, init.CreatedAt)
+				} else if allTasks[i].CreatedAt.IsZero() {
+					allTasks[i].CreatedAt = time.Now().UTC()
+				}
+				continue
+			}
 			created, err := EnsureInit(stateDir, &allTasks[i])
 			if err != nil {
 				return nil, 0, fmt.Errorf("initialize task %q: %w", allTasks[i].ID, err)
@@ -66,6 +116,7 @@ func LoadTasksAndInit(globalDir, projectDir, stateDir string) ([]Task, int, erro
 			if created {
 				initCount++
 			}
+			rememberInit(stateDir, allTasks[i].ID, allTasks[i].CreatedAt)
 		}
 	}
 
@@ -77,6 +128,9 @@ func LoadTasksAndInit(globalDir, projectDir, stateDir string) ([]Task, int, erro
 		}
 		seen[t.ID] = t.Source
 	}
+	if err := validateDependencies(allTasks); err != nil {
+		return nil, 0, err
+	}
 
 	// Sort: priority ASC, created_at ASC, id ASC.
 	sort.Slice(allTasks, func(i, j int) bool {
@@ -96,6 +150,8 @@ func LoadTasksAndInit(globalDir, projectDir, stateDir string) ([]Task, int, erro
 //  1. all YAML files in taskDir
 //  2. companion multi-task files beside the task dir:
 //     <parent>/tasks.yaml and <parent>/tasks.yml
+//  3. companion pipeline files beside the task dir:
+//     <parent>/pipeline.yaml and <parent>/pipeline.yml
 func loadTaskSourceGroup(taskDir string) ([]Task, error) {
 	var all []Task
 
@@ -106,7 +162,7 @@ func loadTaskSourceGroup(taskDir string) ([]Task, error) {
 	all = append(all, byDir...)
 
 	parent := filepath.Dir(taskDir)
-	for _, name := range []string{"tasks.yaml", "tasks.yml"} {
+	for _, name := range []string{"tasks.yaml", "tasks.yml", "pipeline.yaml", "pipeline.yml"} {
 		companion := filepath.Join(parent, name)
 		byFile, err := loadTasksFromFile(companion)
 		if err != nil {
@@ -119,8 +175,9 @@ func loadTaskSourceGroup(taskDir string) ([]Task, error) {
 }
 
 // loadTasksFromDir loads tasks from all *.yaml files in a directory, plus
-// tasks.yaml as a multi-document file. Non-existent directories are silently
-// skipped.
+// tasks.yaml as a multi-document file. Pipeline files (see IsPipelineFile)
+// are compiled into their steps' tasks. Non-existent directories are
+// silently skipped.
 func loadTasksFromDir(dir string) ([]Task, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -142,13 +199,12 @@ func loadTasksFromDir(dir string) ([]Task, error) {
 		}
 
 		path := filepath.Join(dir, name)
-		data, err := os.ReadFile(path)
+		info, err := os.Stat(path) // follows symlinked task files
 		if err != nil {
 			return nil, fmt.Errorf("read %s: %w", path, err)
 		}
 
-		// All YAML files support multi-document format (--- separators).
-		tasks, err := ParseMultiDocYAML(data, path)
+		tasks, err := parseTaskFileCached(path, info)
 		if err != nil {
 			return nil, fmt.Errorf("parse %s: %w", path, err)
 		}
@@ -160,7 +216,7 @@ func loadTasksFromDir(dir string) ([]Task, error) {
 }
 
 func loadTasksFromFile(path string) ([]Task, error) {
-	data, err := os.ReadFile(path)
+	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -168,13 +224,22 @@ func loadTasksFromFile(path string) ([]Task, error) {
 		return nil, fmt.Errorf("read %s: %w", path, err)
 	}
 
-	tasks, err := ParseMultiDocYAML(data, path)
+	tasks, err := parseTaskFileCached(path, info)
 	if err != nil {
 		return nil, fmt.Errorf("parse %s: %w", path, err)
 	}
 	return tasks, nil
 }
 
+// parseTaskFile parses a task file, or compiles a pipeline file.
+func parseTaskFile(data []byte, path string) ([]Task, error) {
+	if IsPipelineFile(filepath.Base(path)) {
+		return ParsePipeline(data, path)
+	}
+	// All task files support multi-document format (--- separators).
+	return ParseMultiDocYAML(data, path)
+}
+
 // ParseMultiDocYAML splits YAML data on "---" document separators and parses
 // each document as a Task. Empty documents are skipped. The source string is
 // attached to each parsed task for provenance tracking.
@@ -200,6 +265,10 @@ func ParseMultiDocYAML(data []byte, source string) ([]Task, error) {
 			t.Source = source
 		}
 
+		if err := openPrompt(&t); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i+1, err)
+		}
+
 		// Apply defaults and auto-generate missing fields.
 		if err := applyDefaults(&t); err != nil {
 			return nil, fmt.Errorf("document %d: %w", i+1, err)
@@ -231,6 +300,8 @@ func splitYAMLDocs(data []byte) [][]byte {
 
 // applyDefaults fills in auto-generated and default values for a Task.
 func applyDefaults(t *Task) error {
+	t.checksum = t.hash()
+
 	// Auto-generate title from prompt if missing.
 	if t.Title == "" && t.Prompt != "" {
 		t.Title = truncate(t.Prompt, 60)
@@ -246,12 +317,12 @@ func applyDefaults(t *Task) error {
 
 	// Default priority.
 	if t.Priority == 0 {
-		t.Priority = 10
+		t.Priority = DefaultPriority
 	}
 
 	// Default max retries.
 	if t.MaxRetries == 0 {
-		t.MaxRetries = 5
+		t.MaxRetries = DefaultMaxRetries
 	}
 
 	return nil
@@ -281,6 +352,71 @@ func validateTask(t *Task) error {
 	if !filepath.IsAbs(t.WorkingDir) {
 		return fmt.Errorf("Task '%s': working_dir must be absolute (got '%s'). Use 'add --dir' which resolves automatically.", label, t.WorkingDir)
 	}
+	switch t.ResumeStrategy {
+	case "", "native", "reprompt", "fresh":
+	default:
+		return fmt.Errorf("Task '%s' (%s): resume_strategy must be native, reprompt, or fresh (got '%s')", label, t.Source, t.ResumeStrategy)
+	}
+	if t.ClaudeVersion != "" && t.Agent != "" && t.Agent != "claude" {
+		return fmt.Errorf("Task '%s' (%s): claude_version only applies to agent claude (got agent '%s')", label, t.Source, t.Agent)
+	}
+	if t.Container != "" && t.ClaudeVersion != "" {
+		return fmt.Errorf("Task '%s' (%s): claude_version selects a host binary and cannot be combined with container", label, t.Source)
+	}
+	if t.Container == "" && (t.ContainerNet != "" || t.ContainerCPUs != 0 || t.ContainerMem != "") {
+		return fmt.Errorf("Task '%s' (%s): container_network, container_cpus and container_memory need container", label, t.Source)
+	}
+	if t.ContainerCPUs < 0 {
+		return fmt.Errorf("Task '%s' (%s): container_cpus must not be negative", label, t.Source)
+	}
+	if t.ContainerMem != "" && !sizeRe.MatchString(t.ContainerMem) {
+		return fmt.Errorf("Task '%s' (%s): container_memory must be a size like 512m or 4g (got '%s')", label, t.Source, t.ContainerMem)
+	}
+	if t.Container != "" && (t.Nice != 0 || t.MaxCPUTime != "" || t.MaxMemory != "") {
+		return fmt.Errorf("Task '%s' (%s): nice, max_cpu_time and max_memory apply to host processes; use container_cpus and container_memory with container", label, t.Source)
+	}
+	if t.Nice < 0 || t.Nice > 19 {
+		return fmt.Errorf("Task '%s' (%s): nice must be between 0 and 19 (got %d)", label, t.Source, t.Nice)
+	}
+	if t.MaxCPUTime != "" {
+		if d, err := time.ParseDuration(t.MaxCPUTime); err != nil || d <= 0 {
+			return fmt.Errorf("Task '%s' (%s): max_cpu_time must be a duration like 30m (got '%s')", label, t.Source, t.MaxCPUTime)
+		}
+	}
+	if t.MaxMemory != "" && !sizeRe.MatchString(t.MaxMemory) {
+		return fmt.Errorf("Task '%s' (%s): max_memory must be a size like 512m or 8g (got '%s')", label, t.Source, t.MaxMemory)
+	}
+	if t.HangTimeout != "" {
+		if d, err := time.ParseDuration(t.HangTimeout); err != nil || d <= 0 {
+			return fmt.Errorf("Task '%s' (%s): hang_timeout must be a duration like 45m (got '%s')", label, t.Source, t.HangTimeout)
+		}
+		if t.NoHangDetection {
+			return fmt.Errorf("Task '%s' (%s): hang_timeout cannot be combined with no_hang_detection", label, t.Source)
+		}
+	}
+	if err := t.validateSchedule(); err != nil {
+		return fmt.Errorf("Task '%s' (%s): %v", label, t.Source, err)
+	}
+	if t.ClaudeVersion != "" && !claudeVersionRe.MatchString(t.ClaudeVersion) {
+		return fmt.Errorf("Task '%s' (%s): claude_version must be a version like 2.0.14 or 2.0 (got '%s')", label, t.Source, t.ClaudeVersion)
+	}
+	if t.MaxCostUSD < 0 || t.MaxTokens < 0 {
+		return fmt.Errorf("Task '%s' (%s): max_cost_usd and max_tokens must not be negative", label, t.Source)
+	}
+	if t.Group != "" && !IsValidID(t.Group) {
+		return fmt.Errorf("Task '%s' (%s): concurrency_group must match [a-z0-9-] (got '%s')", label, t.Source, t.Group)
+	}
+	for _, p := range append(append([]string(nil), t.RetryOn...), t.FailFastOn...) {
+		if strings.TrimSpace(p) == "" {
+			return fmt.Errorf("Task '%s' (%s): retry_on and fail_fast_on patterns must not be empty", label, t.Source)
+		}
+	}
+	if t.Origin != nil && strings.TrimSpace(t.Origin.Kind) == "" {
+		return fmt.Errorf("Task '%s' (%s): origin needs a kind", label, t.Source)
+	}
+	if containsString(t.Dependencies(), t.ID) {
+		return fmt.Errorf("Task '%s' (%s): task depends on itself", label, t.Source)
+	}
 	return nil
 }
 
@@ -372,14 +508,54 @@ func LoadState(stateDir, taskID string) (*TaskState, error) {
 	if err := json.Unmarshal(data, &state); err != nil {
 		return nil, fmt.Errorf("parse state file %s: %w", path, err)
 	}
+	if err := openState(&state); err != nil {
+		return nil, fmt.Errorf("decrypt state file %s: %w", path, err)
+	}
 	return &state, nil
 }
 
+// LoadAllStates reads every state file in stateDir with a single directory
+// scan and returns the states by task ID. Tasks without a state file are
+// absent from the map. Files that cannot be read or parsed are skipped and
+// reported together in the returned error; the map is still usable. A
+// missing directory yields an empty map.
+func LoadAllStates(stateDir string) (map[string]*TaskState, error) {
+	entries, err := os.ReadDir(stateDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]*TaskState{}, nil
+		}
+		return map[string]*TaskState{}, fmt.Errorf("read state dir: %w", err)
+	}
+
+	states := make(map[string]*TaskState, len(entries))
+	var errs []error
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".state.json")
+		if !ok || e.IsDir() || !IsValidID(id) {
+			continue
+		}
+		st, err := LoadState(stateDir, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if st != nil {
+			states[id] = st
+		}
+	}
+	return states, errors.Join(errs...)
+}
+
 // SaveState writes the TaskState to disk using an atomic write for crash safety.
 // The state file is written to <stateDir>/<state.ID>.state.json.
 func SaveState(stateDir string, state *TaskState) error {
 	path := filepath.Join(stateDir, state.ID+".state.json")
-	data, err := json.MarshalIndent(state, "", "  ")
+	sealed, err := sealState(state)
+	if err != nil {
+		return fmt.Errorf("encrypt state for %s: %w", state.ID, err)
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal state for %s: %w", state.ID, err)
 	}
@@ -417,6 +593,11 @@ func EnsureInit(stateDir string, task *Task) (bool, error) {
 	init := TaskInit{
 		ID:        task.ID,
 		CreatedAt: task.CreatedAt,
+		Source:    task.Source,
+		Origin:    task.Origin,
+	}
+	if init.Origin == nil {
+		init.Origin = NewOrigin(OriginFile, "")
 	}
 
 	data, err := json.MarshalIndent(init, "", "  ")
@@ -190,6 +190,32 @@ working_dir: /tmp
 	}
 }
 
+func TestLoadTasksReadOnly_CreatesNoInit(t *testing.T) {
+	base := t.TempDir()
+	taskDir := filepath.Join(base, "tasks")
+	stateDir := filepath.Join(base, "state")
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeYAML(t, filepath.Join(taskDir, "a.yaml"), "id: task-a\nprompt: a\nworking_dir: /tmp\n")
+	writeYAML(t, filepath.Join(taskDir, "b.yaml"), "id: task-b\nprompt: b\nworking_dir: /tmp\n")
+	old := time.Now().UTC().Add(-2 * time.Hour).Format(time.RFC3339)
+	if err := os.WriteFile(filepath.Join(stateDir, "task-b.init.json"), []byte(`{"id":"task-b","created_at":"`+old+`"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := LoadTasksReadOnly(taskDir, "", stateDir)
+	if err != nil {
+		t.Fatalf("LoadTasksReadOnly: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].ID != "task-b" {
+		t.Fatalf("tasks = %+v; want task-b (initialized earlier) first", tasks)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "task-a.init.json")); !os.IsNotExist(err) {
+		t.Error("LoadTasksReadOnly created an init record")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // ParseMultiDocYAML
 // ---------------------------------------------------------------------------
@@ -289,6 +315,149 @@ working_dir: relative/path
 	}
 }
 
+func TestParseMultiDocYAML_InvalidResumeStrategy(t *testing.T) {
+	data := []byte(`
+id: bad-resume
+prompt: do it
+working_dir: /tmp
+resume_strategy: sometimes
+`)
+	_, err := ParseMultiDocYAML(data, "test.yaml")
+	if err == nil {
+		t.Fatal("expected error for invalid resume_strategy")
+	}
+	if !strings.Contains(err.Error(), "resume_strategy") {
+		t.Errorf("error = %v; want resume_strategy error", err)
+	}
+}
+
+func TestParseMultiDocYAML_InvalidClaudeVersion(t *testing.T) {
+	data := []byte(`
+id: bad-version
+prompt: do it
+working_dir: /tmp
+claude_version: latest
+`)
+	_, err := ParseMultiDocYAML(data, "test.yaml")
+	if err == nil {
+		t.Fatal("expected error for invalid claude_version")
+	}
+	if !strings.Contains(err.Error(), "claude_version") {
+		t.Errorf("error = %v; want claude_version error", err)
+	}
+}
+
+func TestParseMultiDocYAML_ClaudeVersionNeedsClaude(t *testing.T) {
+	data := []byte(`
+id: mixed
+prompt: do it
+working_dir: /tmp
+agent: aider
+claude_version: "2.0"
+`)
+	if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil || !strings.Contains(err.Error(), "agent claude") {
+		t.Errorf("err = %v; want claude_version/agent conflict", err)
+	}
+}
+
+func TestParseMultiDocYAML_InvalidConcurrencyGroup(t *testing.T) {
+	data := []byte(`
+id: grouped
+prompt: do it
+working_dir: /tmp
+concurrency_group: ../db
+`)
+	if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil || !strings.Contains(err.Error(), "concurrency_group") {
+		t.Errorf("err = %v; want concurrency_group error", err)
+	}
+}
+
+func TestParseMultiDocYAML_OutputPatterns(t *testing.T) {
+	data := []byte(`
+id: patterns
+prompt: do it
+working_dir: /tmp
+retry_on: [context deadline exceeded]
+fail_fast_on: ["compilation failed in vendor/"]
+`)
+	tasks, err := ParseMultiDocYAML(data, "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks[0].RetryOn) != 1 || tasks[0].FailFastOn[0] != "compilation failed in vendor/" {
+		t.Errorf("retry_on = %v, fail_fast_on = %v", tasks[0].RetryOn, tasks[0].FailFastOn)
+	}
+
+	data = append(data, "retry_on: [\"  \"]\n"...)
+	if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil || !strings.Contains(err.Error(), "retry_on") {
+		t.Errorf("err = %v; want an empty-pattern error", err)
+	}
+}
+
+func TestParseMultiDocYAML_HangDetection(t *testing.T) {
+	base := "id: build\nprompt: do it\nworking_dir: /tmp\n"
+	for _, tt := range []struct {
+		extra, err string
+	}{
+		{"hang_timeout: 45m\n", ""},
+		{"no_hang_detection: true\n", ""},
+		{"hang_timeout: soon\n", "hang_timeout must be a duration"},
+		{"hang_timeout: 45m\nno_hang_detection: true\n", "cannot be combined"},
+	} {
+		_, err := ParseMultiDocYAML([]byte(base+tt.extra), "test.yaml")
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%q: err = %v; want %q", tt.extra, err, tt.err)
+		}
+	}
+}
+
+func TestParseMultiDocYAML_ContainerOptions(t *testing.T) {
+	for name, extra := range map[string]string{
+		"network without container": "container_network: none\n",
+		"bad memory":                "container: node:20\ncontainer_memory: lots\n",
+		"pinned claude":             "container: node:20\nclaude_version: \"2.0\"\n",
+		"host limits":               "container: node:20\nmax_memory: 4g\n",
+	} {
+		data := []byte("id: boxed\nprompt: do it\nworking_dir: /tmp\n" + extra)
+		if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	data := []byte("id: boxed\nprompt: do it\nworking_dir: /tmp\ncontainer: node:20\ncontainer_network: none\ncontainer_cpus: 1.5\ncontainer_memory: 4g\n")
+	tasks, err := ParseMultiDocYAML(data, "test.yaml")
+	if err != nil {
+		t.Fatalf("ParseMultiDocYAML: %v", err)
+	}
+	if tasks[0].ContainerCPUs != 1.5 || tasks[0].ContainerMem != "4g" {
+		t.Errorf("task = %+v; want container limits parsed", tasks[0])
+	}
+}
+
+func TestParseMultiDocYAML_ResourceLimits(t *testing.T) {
+	for name, extra := range map[string]string{
+		"negative nice":  "nice: -5\n",
+		"nice too high":  "nice: 20\n",
+		"bad cpu time":   "max_cpu_time: forever\n",
+		"zero cpu time":  "max_cpu_time: 0s\n",
+		"bad max memory": "max_memory: lots\n",
+	} {
+		data := []byte("id: capped\nprompt: do it\nworking_dir: /tmp\n" + extra)
+		if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	data := []byte("id: capped\nprompt: do it\nworking_dir: /tmp\nnice: 10\nmax_cpu_time: 30m\nmax_memory: 8g\n")
+	tasks, err := ParseMultiDocYAML(data, "test.yaml")
+	if err != nil {
+		t.Fatalf("ParseMultiDocYAML: %v", err)
+	}
+	if tasks[0].Nice != 10 || tasks[0].MaxCPUTime != "30m" || tasks[0].MaxMemory != "8g" {
+		t.Errorf("task = %+v; want resource limits parsed", tasks[0])
+	}
+}
+
 func TestParseMultiDocYAML_DefaultPriority(t *testing.T) {
 	data := []byte(`
 id: no-priority
@@ -321,6 +490,48 @@ working_dir: /tmp
 	}
 }
 
+func TestParseMultiDocYAML_ConfiguredDefaults(t *testing.T) {
+	defer func(p, r int) { DefaultPriority, DefaultMaxRetries = p, r }(DefaultPriority, DefaultMaxRetries)
+	DefaultPriority, DefaultMaxRetries = 20, 2
+
+	tasks, err := ParseMultiDocYAML([]byte("id: a\nprompt: x\nworking_dir: /tmp\n---\nid: b\nprompt: y\nworking_dir: /tmp\npriority: 1\nmax_retries: 9\n"), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].Priority != 20 || tasks[0].MaxRetries != 2 {
+		t.Errorf("a = priority %d, max_retries %d; want 20, 2", tasks[0].Priority, tasks[0].MaxRetries)
+	}
+	if tasks[1].Priority != 1 || tasks[1].MaxRetries != 9 {
+		t.Errorf("b = priority %d, max_retries %d; want 1, 9", tasks[1].Priority, tasks[1].MaxRetries)
+	}
+}
+
+func TestChecksum_IgnoresConfiguredDefaults(t *testing.T) {
+	defer func(p, r int) { DefaultPriority, DefaultMaxRetries = p, r }(DefaultPriority, DefaultMaxRetries)
+	doc := []byte("id: a\nprompt: x\nworking_dir: /tmp\n")
+
+	before, err := ParseMultiDocYAML(doc, "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DefaultPriority, DefaultMaxRetries = 20, 2
+	after, err := ParseMultiDocYAML(doc, "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before[0].Checksum() != after[0].Checksum() {
+		t.Error("changing the configured defaults changed the checksum")
+	}
+
+	edited, err := ParseMultiDocYAML([]byte("id: a\nprompt: x\nworking_dir: /tmp\nmodel: opus\n"), "test.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited[0].Checksum() == after[0].Checksum() {
+		t.Error("an edited task kept its checksum")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Slugify
 // ---------------------------------------------------------------------------
@@ -458,6 +669,27 @@ func TestLoadState_NonExistentReturnsNil(t *testing.T) {
 	}
 }
 
+func TestLoadAllStates(t *testing.T) {
+	dir := t.TempDir()
+	SaveState(dir, &TaskState{ID: "a", Status: StatusDone})
+	SaveState(dir, &TaskState{ID: "b", Status: StatusWaiting, Attempt: 2})
+	EnsureInit(dir, &Task{ID: "c"})
+	os.WriteFile(filepath.Join(dir, "broken.state.json"), []byte("{"), 0644)
+
+	states, err := LoadAllStates(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken.state.json") {
+		t.Errorf("err = %v; want the broken file reported", err)
+	}
+	if len(states) != 2 || states["a"].Status != StatusDone || states["b"].Attempt != 2 {
+		t.Errorf("states = %v; want a and b", states)
+	}
+
+	states, err = LoadAllStates(filepath.Join(dir, "missing"))
+	if err != nil || len(states) != 0 {
+		t.Errorf("LoadAllStates(missing) = %v, %v; want empty", states, err)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // EnsureInit
 // ---------------------------------------------------------------------------
@@ -504,6 +736,31 @@ func TestEnsureInit_CreatesOnce(t *testing.T) {
 	}
 }
 
+func TestEnsureInit_RecordsOrigin(t *testing.T) {
+	dir := t.TempDir()
+	added := &Task{ID: "added", Origin: &Origin{Kind: "github", Ref: "https://github.com/o/r/issues/7", User: "alice"}}
+	handwritten := &Task{ID: "handwritten"}
+	for _, task := range []*Task{added, handwritten} {
+		if _, err := EnsureInit(dir, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	init, err := LoadInit(dir, "added")
+	if err != nil || init.Origin == nil || init.Origin.Kind != "github" || init.Origin.Ref != added.Origin.Ref {
+		t.Errorf("added origin = %+v, %v", init, err)
+	}
+	init, err = LoadInit(dir, "handwritten")
+	if err != nil || init.Origin == nil || init.Origin.Kind != OriginFile || init.Origin.Host == "" {
+		t.Errorf("hand-written origin = %+v, %v; want kind file with this host", init, err)
+	}
+
+	_, err = ParseMultiDocYAML([]byte("id: x\nprompt: p\nworking_dir: /tmp\norigin: {ref: r}\n"), "test.yaml")
+	if err == nil || !strings.Contains(err.Error(), "origin needs a kind") {
+		t.Errorf("err = %v; want missing origin kind", err)
+	}
+}
+
 func TestEnsureInit_SetsCreatedAtIfZero(t *testing.T) {
 	dir := t.TempDir()
 	task := &Task{
@@ -523,6 +780,51 @@ func TestEnsureInit_SetsCreatedAtIfZero(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Archive
+// ---------------------------------------------------------------------------
+
+func TestArchive(t *testing.T) {
+	home := t.TempDir()
+	taskDir, stateDir, logsDir := filepath.Join(home, "tasks"), filepath.Join(home, "state"), filepath.Join(home, "logs")
+	for _, d := range []string{taskDir, stateDir, logsDir} {
+		os.MkdirAll(d, 0755)
+	}
+	path := filepath.Join(taskDir, "old.yaml")
+	os.WriteFile(path, []byte("id: old\n"), 0644)
+	SaveState(stateDir, &TaskState{ID: "old", Status: StatusDone})
+	os.WriteFile(filepath.Join(logsDir, "old.log"), []byte("log\n"), 0644)
+
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.Local)
+	dir, err := Archive(filepath.Join(home, "archive"), stateDir, logsDir, path, []string{"old"}, now)
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if filepath.Base(dir) != "2026-03-04" {
+		t.Errorf("archive dir = %s; want one named by date", dir)
+	}
+	for _, name := range []string{"old.yaml", "old.state.json", "old.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s not archived: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("task file left in the queue")
+	}
+	if st, _ := LoadState(stateDir, "old"); st != nil {
+		t.Error("state left in the queue")
+	}
+
+	// A second file of the same name is kept alongside the first.
+	os.WriteFile(path, []byte("id: old\n"), 0644)
+	if _, err := Archive(filepath.Join(home, "archive"), stateDir, logsDir, path, []string{"old"}, now); err != nil {
+		t.Fatalf("second Archive: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old-2.yaml")); err != nil {
+		t.Errorf("second task file not archived as old-2.yaml: %v", err)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
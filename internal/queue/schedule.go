@@ -0,0 +1,75 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// weekdays maps run_days names to weekdays.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// skipDateLayout is the format of skip_dates entries.
+const skipDateLayout = "2006-01-02"
+
+// validateSchedule checks run_days and skip_dates.
+func (t *Task) validateSchedule() error {
+	for _, d := range t.RunDays {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("run_days entries must be mon, tue, wed, thu, fri, sat or sun (got '%s')", d)
+		}
+	}
+	for _, d := range t.SkipDates {
+		if _, err := time.Parse(skipDateLayout, d); err != nil {
+			return fmt.Errorf("skip_dates entries must be dates like 2026-12-25 (got '%s')", d)
+		}
+	}
+	return nil
+}
+
+// HeldUntil returns when the task may next start, given its run_days and
+// skip_dates in now's time zone: the zero time if it may start now,
+// otherwise the midnight that begins the next allowed day. Both only gate
+// starts; an attempt already running carries on past midnight.
+func (t *Task) HeldUntil(now time.Time) time.Time {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// An allowed day comes within a year unless skip_dates lists every
+	// one of them, in which case the hold is ignored.
+	for i := 0; i < 366; i++ {
+		if t.runsOn(day) {
+			if i == 0 {
+				return time.Time{}
+			}
+			return day
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return time.Time{}
+}
+
+// runsOn reports whether the task may start on day.
+func (t *Task) runsOn(day time.Time) bool {
+	date := day.Format(skipDateLayout)
+	for _, d := range t.SkipDates {
+		if d == date {
+			return false
+		}
+	}
+	if len(t.RunDays) == 0 {
+		return true
+	}
+	for _, d := range t.RunDays {
+		if weekdays[strings.ToLower(d)] == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
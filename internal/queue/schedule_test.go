@@ -0,0 +1,56 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeldUntil(t *testing.T) {
+	// 2026-10-14 is a Wednesday.
+	wed := time.Date(2026, 10, 14, 22, 30, 0, 0, time.UTC)
+	sat := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	if got := (&Task{}).HeldUntil(wed); !got.IsZero() {
+		t.Errorf("HeldUntil(no schedule) = %v; want zero", got)
+	}
+
+	weekend := &Task{RunDays: []string{"sat", "Sun"}}
+	if got := weekend.HeldUntil(wed); !got.Equal(sat) {
+		t.Errorf("HeldUntil(weekend, Wednesday) = %v; want %v", got, sat)
+	}
+	if got := weekend.HeldUntil(sat.Add(3 * time.Hour)); !got.IsZero() {
+		t.Errorf("HeldUntil(weekend, Saturday) = %v; want zero", got)
+	}
+
+	// A skipped Saturday moves the weekend task to Sunday.
+	weekend.SkipDates = []string{"2026-10-17"}
+	if got := weekend.HeldUntil(wed); !got.Equal(sat.AddDate(0, 0, 1)) {
+		t.Errorf("HeldUntil(weekend, Saturday skipped) = %v; want Sunday", got)
+	}
+
+	nightly := &Task{SkipDates: []string{"2026-10-14"}}
+	if got := nightly.HeldUntil(wed); !got.Equal(sat.AddDate(0, 0, -2)) {
+		t.Errorf("HeldUntil(today skipped) = %v; want Thursday", got)
+	}
+}
+
+func TestParseMultiDocYAML_Schedule(t *testing.T) {
+	for name, extra := range map[string]string{
+		"bad day":  "run_days: [someday]\n",
+		"bad date": "skip_dates: [12/25/2026]\n",
+	} {
+		data := []byte("id: weekly\nprompt: do it\nworking_dir: /tmp\n" + extra)
+		if _, err := ParseMultiDocYAML(data, "test.yaml"); err == nil {
+			t.Errorf("%s: expected a validation error", name)
+		}
+	}
+
+	data := []byte("id: weekly\nprompt: do it\nworking_dir: /tmp\nrun_days: [sat, sun]\nskip_dates: [\"2026-12-26\"]\n")
+	tasks, err := ParseMultiDocYAML(data, "test.yaml")
+	if err != nil {
+		t.Fatalf("ParseMultiDocYAML: %v", err)
+	}
+	if len(tasks[0].RunDays) != 2 || tasks[0].SkipDates[0] != "2026-12-26" {
+		t.Errorf("task = %+v; want the schedule parsed", tasks[0])
+	}
+}
@@ -0,0 +1,102 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/crypt"
+)
+
+// EncryptionKey, when set, encrypts prompts passed through SealPrompt and
+// the transcript-derived fields of saved task states. The CLI sets it from
+// the encryption_key_file config key.
+var EncryptionKey *crypt.Key
+
+// SealPrompt returns prompt encrypted for a task file, or unchanged when
+// encryption is off.
+func SealPrompt(prompt string) string {
+	if EncryptionKey == nil || prompt == "" {
+		return prompt
+	}
+	return EncryptionKey.Seal([]byte(prompt))
+}
+
+// openPrompt decrypts an encrypted task prompt in place.
+func openPrompt(t *Task) error {
+	if !crypt.IsSealed(t.Prompt) {
+		return nil
+	}
+	if EncryptionKey == nil {
+		return errors.New("prompt is encrypted; set encryption_key_file to the key it was written with")
+	}
+	plain, err := EncryptionKey.Open(t.Prompt)
+	if err != nil {
+		return fmt.Errorf("prompt: %w", err)
+	}
+	t.Prompt = string(plain)
+	return nil
+}
+
+// sealedFields are the parts of a TaskState that quote the session and so
+// may contain prompt or code content.
+type sealedFields struct {
+	LastNDJSONMessages []string    `json:"last_ndjson_messages,omitempty"`
+	ResumeContext      string      `json:"resume_context,omitempty"`
+	Checkpoint         *Checkpoint `json:"checkpoint,omitempty"`
+	Summary            string      `json:"summary,omitempty"`
+}
+
+// sealState returns the form of state to write: with encryption on, a copy
+// whose sensitive fields are moved into Sealed.
+func sealState(state *TaskState) (*TaskState, error) {
+	if EncryptionKey == nil {
+		return state, nil
+	}
+	fields := sealedFields{
+		LastNDJSONMessages: state.LastNDJSONMessages,
+		ResumeContext:      state.ResumeContext,
+		Checkpoint:         state.Checkpoint,
+		Summary:            state.Summary,
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	sealed := *state
+	sealed.LastNDJSONMessages, sealed.ResumeContext, sealed.Checkpoint, sealed.Summary = nil, "", nil, ""
+	sealed.Sealed = EncryptionKey.Seal(data)
+	return &sealed, nil
+}
+
+// openState restores the sealed fields of a loaded state. Without a key
+// they stay sealed (and are written back as they are), so turning
+// encryption off never loses them. Fields written in the clear since take
+// precedence.
+func openState(state *TaskState) error {
+	if state.Sealed == "" || EncryptionKey == nil {
+		return nil
+	}
+	data, err := EncryptionKey.Open(state.Sealed)
+	if err != nil {
+		return err
+	}
+	var fields sealedFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if state.LastNDJSONMessages == nil {
+		state.LastNDJSONMessages = fields.LastNDJSONMessages
+	}
+	if state.ResumeContext == "" {
+		state.ResumeContext = fields.ResumeContext
+	}
+	if state.Checkpoint == nil {
+		state.Checkpoint = fields.Checkpoint
+	}
+	if state.Summary == "" {
+		state.Summary = fields.Summary
+	}
+	state.Sealed = ""
+	return nil
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/crypt"
+)
+
+// withKey sets EncryptionKey to a fresh key for the rest of the test.
+func withKey(t *testing.T) *crypt.Key {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := crypt.GenerateKey(path); err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypt.LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	EncryptionKey = key
+	t.Cleanup(func() { EncryptionKey = nil })
+	return key
+}
+
+func TestSealedState_Roundtrip(t *testing.T) {
+	withKey(t)
+	dir := t.TempDir()
+	state := &TaskState{
+		ID:                 "secret",
+		Status:             StatusDone,
+		LastNDJSONMessages: []string{`{"type":"result","result":"the password is hunter2"}`},
+		Summary:            "hunter2",
+	}
+	if err := SaveState(dir, state); err != nil {
+		t.Fatal(err)
+	}
+	if state.Summary != "hunter2" || state.Sealed != "" {
+		t.Error("SaveState modified the caller's state")
+	}
+
+	raw, _ := os.ReadFile(filepath.Join(dir, "secret.state.json"))
+	if strings.Contains(string(raw), "hunter2") {
+		t.Errorf("state file contains plaintext:\n%s", raw)
+	}
+
+	loaded, err := LoadState(dir, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Summary != "hunter2" || len(loaded.LastNDJSONMessages) != 1 || loaded.Sealed != "" {
+		t.Errorf("loaded = %+v; want sealed fields restored", loaded)
+	}
+}
+
+func TestSealedState_WithoutKeyKeptSealed(t *testing.T) {
+	withKey(t)
+	dir := t.TempDir()
+	SaveState(dir, &TaskState{ID: "secret", Summary: "hunter2"})
+
+	EncryptionKey = nil
+	loaded, err := LoadState(dir, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Sealed == "" || loaded.Summary != "" {
+		t.Fatalf("loaded = %+v; want fields left sealed", loaded)
+	}
+	loaded.Attempt = 2
+	SaveState(dir, loaded)
+
+	withKey(t)
+	if _, err := LoadState(dir, "secret"); err == nil {
+		t.Error("LoadState with the wrong key succeeded")
+	}
+}
+
+func TestSealedPrompt(t *testing.T) {
+	withKey(t)
+	prompt := SealPrompt("rotate the hunter2 credentials")
+	if !crypt.IsSealed(prompt) {
+		t.Fatalf("SealPrompt = %q; want sealed", prompt)
+	}
+
+	data := []byte("id: rotate\nworking_dir: /tmp\nprompt: " + prompt + "\n")
+	tasks, err := ParseMultiDocYAML(data, "rotate.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks[0].Prompt != "rotate the hunter2 credentials" || tasks[0].Title != tasks[0].Prompt {
+		t.Errorf("task = %+v; want prompt decrypted and title derived from it", tasks[0])
+	}
+
+	EncryptionKey = nil
+	if _, err := ParseMultiDocYAML(data, "rotate.yaml"); err == nil || !strings.Contains(err.Error(), "encryption_key_file") {
+		t.Errorf("err = %v; want a hint to set encryption_key_file", err)
+	}
+}
@@ -1,6 +1,12 @@
 package queue
 
-import "time"
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
 
 // Valid task status values.
 const (
@@ -10,6 +16,9 @@ const (
 	StatusDone      = "done"
 	StatusFailed    = "failed"
 	StatusCancelled = "cancelled"
+	// StatusNeedsReview marks a review-mode task whose work waits on its
+	// review branch for approval.
+	StatusNeedsReview = "needs_review"
 )
 
 // Task defines a unit of work to be executed by the autopilot runner.
@@ -22,27 +31,150 @@ type Task struct {
 	SkipPermissions bool      `yaml:"skip_permissions,omitempty" json:"skip_permissions,omitempty"`
 	Prompt          string    `yaml:"prompt"            json:"prompt"`
 	ContextFiles    []string  `yaml:"context_files,omitempty" json:"context_files,omitempty"`
+	ContextCommands []string  `yaml:"context_commands,omitempty" json:"context_commands,omitempty"`
 	Model           string    `yaml:"model,omitempty"   json:"model,omitempty"`
+	Agent           string    `yaml:"agent,omitempty" json:"agent,omitempty"`                         // coding agent CLI to run: claude (default), gemini, aider or one from agents.yaml
+	ClaudeVersion   string    `yaml:"claude_version,omitempty" json:"claude_version,omitempty"`       // run with the pinned claude CLI of this version ('compat pin')
+	Container       string    `yaml:"container,omitempty" json:"container,omitempty"`                 // image to run the agent in, with working_dir mounted
+	ContainerNet    string    `yaml:"container_network,omitempty" json:"container_network,omitempty"` // e.g. none; default: the runtime's
+	ContainerCPUs   float64   `yaml:"container_cpus,omitempty" json:"container_cpus,omitempty"`
+	ContainerMem    string    `yaml:"container_memory,omitempty" json:"container_memory,omitempty"` // e.g. 4g
+	Nice            int       `yaml:"nice,omitempty" json:"nice,omitempty"`                         // scheduling niceness of the agent process, 0-19
+	MaxCPUTime      string    `yaml:"max_cpu_time,omitempty" json:"max_cpu_time,omitempty"`         // CPU time after which the agent process is killed, e.g. 30m
+	MaxMemory       string    `yaml:"max_memory,omitempty" json:"max_memory,omitempty"`             // memory limit of the agent process, e.g. 8g
 	MaxRetries      int       `yaml:"max_retries,omitempty" json:"max_retries"`
+	HangTimeout     string    `yaml:"hang_timeout,omitempty" json:"hang_timeout,omitempty"`           // silence after which the agent is killed, e.g. 45m; overrides the global hang_timeout
+	NoHangDetection bool      `yaml:"no_hang_detection,omitempty" json:"no_hang_detection,omitempty"` // never kill the agent for producing no output
 	EstimatedTokens int       `yaml:"estimated_tokens,omitempty" json:"estimated_tokens,omitempty"`
 	Flags           []string  `yaml:"flags,omitempty"   json:"flags,omitempty"`
+	ResumeStrategy  string    `yaml:"resume_strategy,omitempty" json:"resume_strategy,omitempty"` // native (default), reprompt, or fresh
+	Tags            []string  `yaml:"tags,omitempty"    json:"tags,omitempty"`
+	AutoApprove     []string  `yaml:"auto_approve,omitempty" json:"auto_approve,omitempty"`             // prompt_answers names this task may auto-answer
+	Artifacts       []string  `yaml:"artifacts,omitempty" json:"artifacts,omitempty"`                   // output files (globs, relative to working_dir) collected on completion
+	TeeOutput       string    `yaml:"tee_output,omitempty" json:"tee_output,omitempty"`                 // file (relative to working_dir) that also receives the raw agent output
+	FailOnNoChanges *bool     `yaml:"fail_on_no_changes,omitempty" json:"fail_on_no_changes,omitempty"` // overrides the global fail_on_no_changes
+	MaxCostUSD      float64   `yaml:"max_cost_usd,omitempty" json:"max_cost_usd,omitempty"`             // stop and fail once attempts have cost this much in total
+	MaxTokens       int       `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`                 // stop and fail once attempts have used this many tokens
+	ExportSummary   bool      `yaml:"export_summary,omitempty" json:"export_summary,omitempty"`         // keep the final assistant message for {{task:<id>.summary}}
+	DependsOn       []string  `yaml:"depends_on,omitempty" json:"depends_on,omitempty"`                 // tasks that must be done before this one starts
+	Group           string    `yaml:"concurrency_group,omitempty" json:"concurrency_group,omitempty"`   // tasks sharing a group never run at the same time, across instances
+	Exclusive       bool      `yaml:"exclusive,omitempty" json:"exclusive,omitempty"`                   // run alone: other instances drain their running tasks first
+	RunDays         []string  `yaml:"run_days,omitempty" json:"run_days,omitempty"`                     // weekdays the task may start on, e.g. [sat, sun]; default every day
+	SkipDates       []string  `yaml:"skip_dates,omitempty" json:"skip_dates,omitempty"`                 // dates (YYYY-MM-DD) the task does not start on
+	Verify          string    `yaml:"verify,omitempty" json:"verify,omitempty"`                         // shell command that must succeed for a completion to count
+	RetryOn         []string  `yaml:"retry_on,omitempty" json:"retry_on,omitempty"`                     // output patterns that make a failure retried, even one not normally retried
+	FailFastOn      []string  `yaml:"fail_fast_on,omitempty" json:"fail_fast_on,omitempty"`             // output patterns that fail the task at once, without retries
+	Plan            bool      `yaml:"plan,omitempty" json:"plan,omitempty"`                             // ask for a plan and queue its subtasks instead of doing the work
+	Review          bool      `yaml:"review,omitempty" json:"review,omitempty"`                         // work in a worktree and wait for approval instead of changing working_dir
+	ArchiveDone     *bool     `yaml:"archive_done,omitempty" json:"archive_done,omitempty"`             // overrides the global archive_done
+	Origin          *Origin   `yaml:"origin,omitempty" json:"origin,omitempty"`                         // who or what created the task
 	Source          string    `yaml:"-"                 json:"source,omitempty"`
+
+	checksum string // Checksum of the definition as written, taken before defaults are applied
+}
+
+// HasAnyTag reports whether the task carries at least one of tags. An empty
+// tags list matches every task.
+func (t *Task) HasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, want := range tags {
+		for _, have := range t.Tags {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checksum hashes the task's definition as written, so a change to any field
+// (not only the prompt) can be noticed. A loaded task is hashed before
+// defaults are applied, so changing default_priority or default_max_retries
+// does not make every task look edited. created_at and origin are left out:
+// they come from the init record, not the file.
+func (t *Task) Checksum() string {
+	if t.checksum != "" {
+		return t.checksum
+	}
+	return t.hash()
+}
+
+// hash hashes the task's fields as they are now.
+func (t *Task) hash() string {
+	c := *t
+	c.CreatedAt, c.Origin, c.checksum = time.Time{}, nil, ""
+	data, err := yaml.Marshal(&c)
+	if err != nil {
+		return ""
+	}
+	h := sha256.Sum256(data)
+	return fmt.Sprintf("%x", h[:8])
 }
 
 // TaskState holds the mutable runtime state for a task. It is stored separately
 // from the task definition so that task YAML files remain user-editable.
 type TaskState struct {
-	ID                 string     `json:"id"`
-	Status             string     `json:"status"`
-	Attempt            int        `json:"attempt"`
-	StartedAt          *time.Time `json:"started_at,omitempty"`
-	EndedAt            *time.Time `json:"ended_at,omitempty"`
-	LastRateLimitedAt  *time.Time `json:"last_rate_limited_at,omitempty"`
-	ResumeAt           *time.Time `json:"resume_at,omitempty"`
-	PromptHash         string     `json:"prompt_hash,omitempty"`
-	GitCommit          string     `json:"git_commit,omitempty"`
-	SessionID          string     `json:"session_id,omitempty"`
-	LastNDJSONMessages []string   `json:"last_ndjson_messages,omitempty"`
+	ID                 string      `json:"id"`
+	Status             string      `json:"status"`
+	Attempt            int         `json:"attempt"`
+	StartedAt          *time.Time  `json:"started_at,omitempty"`
+	EndedAt            *time.Time  `json:"ended_at,omitempty"`
+	LastRateLimitedAt  *time.Time  `json:"last_rate_limited_at,omitempty"`
+	ResumeAt           *time.Time  `json:"resume_at,omitempty"`
+	PromptHash         string      `json:"prompt_hash,omitempty"`
+	TaskChecksum       string      `json:"task_checksum,omitempty"` // Task.Checksum of the definition the last attempt ran with
+	PromptTokens       int         `json:"prompt_tokens,omitempty"` // estimated size of the last prompt sent
+	GitCommit          string      `json:"git_commit,omitempty"`    // HEAD before the first attempt
+	DiffStat           string      `json:"diff_stat,omitempty"`     // git diff --stat against git_commit after completion
+	DiffSummary        string      `json:"diff_summary,omitempty"`  // one-line summary of the completion diff
+	NoChanges          bool        `json:"no_changes,omitempty"`    // completed without changing any files
+	SessionID          string      `json:"session_id,omitempty"`
+	LastNDJSONMessages []string    `json:"last_ndjson_messages,omitempty"`
+	ResumeContext      string      `json:"resume_context,omitempty"` // readable summary of the last attempt's transcript
+	Checkpoint         *Checkpoint `json:"checkpoint,omitempty"`     // structured progress of the last attempt
+	Artifacts          []string    `json:"artifacts,omitempty"`      // paths of the artifact copies from the last completed attempt
+	Summary            string      `json:"summary,omitempty"`        // final assistant message, kept when export_summary is set
+	PlanFile           string      `json:"plan_file,omitempty"`      // pipeline file holding the subtasks of a plan task
+	Worktree           string      `json:"worktree,omitempty"`       // git worktree a review-mode task runs in
+	ReviewBranch       string      `json:"review_branch,omitempty"`  // branch holding a review-mode task's work
+	Attempts           []Attempt   `json:"attempts,omitempty"`       // per-attempt history, oldest first
+	ControlID          string      `json:"control_id,omitempty"`     // last control command applied, so a replay is skipped
+	Sealed             string      `json:"sealed,omitempty"`         // encrypted session-derived fields (see encryption_key_file)
+}
+
+// Checkpoint is the progress of an interrupted attempt, extracted from its
+// tool calls so that a re-prompted retry knows what was already done.
+type Checkpoint struct {
+	FilesModified []string `json:"files_modified,omitempty"` // in order of first modification
+	Commands      []string `json:"commands,omitempty"`       // most recent shell commands, oldest first
+	Plan          string   `json:"plan,omitempty"`           // last todo list, or the last assistant prose
+}
+
+// Empty reports whether the checkpoint captured nothing.
+func (c *Checkpoint) Empty() bool {
+	return c == nil || (len(c.FilesModified) == 0 && len(c.Commands) == 0 && c.Plan == "")
+}
+
+// Attempt records the outcome of a single invocation of the Claude CLI. The
+// history is kept across automatic and manual retries; StartedAt/EndedAt on
+// TaskState describe only the current attempt.
+type Attempt struct {
+	Number    int       `json:"number"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	ExitCode  int       `json:"exit_code"`
+	Result    string    `json:"result"` // detection result, or "interrupted" / "resume_failed" / "budget_exceeded"
+	Reason    string    `json:"reason,omitempty"`
+	SessionID string    `json:"session_id,omitempty"`
+	CostUSD   float64   `json:"cost_usd,omitempty"`
+	Tokens    int       `json:"tokens,omitempty"`  // input + output tokens, cache reads excluded
+	Failure   string    `json:"failure,omitempty"` // failure category (auth, network, ...), when the output showed one
+	// Superseded marks a run that failed before doing any work (a session
+	// that could not be resumed) and was re-run as attempt Number, so it is
+	// not an attempt of its own.
+	Superseded bool `json:"superseded,omitempty"`
 }
 
 // TaskInit is the immutable record created once per task to anchor its identity
@@ -50,6 +182,17 @@ type TaskState struct {
 type TaskInit struct {
 	ID        string    `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source,omitempty"` // file that defined the task, for orphan detection
+	Origin    *Origin   `json:"origin,omitempty"` // where the task came from; host and user that first saw it for a hand-written file
+}
+
+// IsValidStatus reports whether s is a known task status.
+func IsValidStatus(s string) bool {
+	switch s {
+	case StatusPending, StatusRunning, StatusWaiting, StatusDone, StatusFailed, StatusCancelled, StatusNeedsReview:
+		return true
+	}
+	return false
 }
 
 // validTransitions defines the allowed state machine transitions.
@@ -60,10 +203,15 @@ var validTransitions = map[string]map[string]bool{
 		StatusCancelled: true,
 	},
 	StatusRunning: {
-		StatusDone:      true,
-		StatusFailed:    true,
-		StatusWaiting:   true,
-		StatusCancelled: true,
+		StatusDone:        true,
+		StatusFailed:      true,
+		StatusWaiting:     true,
+		StatusCancelled:   true,
+		StatusNeedsReview: true,
+	},
+	StatusNeedsReview: {
+		StatusDone:   true, // approved
+		StatusFailed: true, // rejected
 	},
 	StatusWaiting: {
 		StatusRunning:   true,
@@ -14,6 +14,10 @@ func TestValidTransition_AllValid(t *testing.T) {
 		{StatusRunning, StatusFailed},
 		{StatusRunning, StatusWaiting},
 		{StatusRunning, StatusCancelled},
+		{StatusRunning, StatusNeedsReview},
+		// From needs_review
+		{StatusNeedsReview, StatusDone},
+		{StatusNeedsReview, StatusFailed},
 		// From waiting
 		{StatusWaiting, StatusRunning},
 		{StatusWaiting, StatusCancelled},
@@ -57,6 +61,9 @@ func TestValidTransition_AllInvalid(t *testing.T) {
 		{StatusCancelled, StatusDone},
 		{StatusCancelled, StatusFailed},
 		{StatusCancelled, StatusWaiting},
+		{StatusPending, StatusNeedsReview},
+		{StatusNeedsReview, StatusRunning},
+		{StatusNeedsReview, StatusPending},
 		// Unknown status
 		{"unknown", StatusRunning},
 		{StatusRunning, "unknown"},
@@ -72,12 +79,13 @@ func TestValidTransition_AllInvalid(t *testing.T) {
 func TestStatusConstants(t *testing.T) {
 	// Verify all status constants have expected values.
 	statuses := map[string]string{
-		"pending":   StatusPending,
-		"running":   StatusRunning,
-		"waiting":   StatusWaiting,
-		"done":      StatusDone,
-		"failed":    StatusFailed,
-		"cancelled": StatusCancelled,
+		"pending":      StatusPending,
+		"running":      StatusRunning,
+		"waiting":      StatusWaiting,
+		"done":         StatusDone,
+		"failed":       StatusFailed,
+		"cancelled":    StatusCancelled,
+		"needs_review": StatusNeedsReview,
 	}
 	for expected, got := range statuses {
 		if got != expected {
@@ -85,3 +93,19 @@ func TestStatusConstants(t *testing.T) {
 		}
 	}
 }
+
+func TestHasAnyTag(t *testing.T) {
+	task := Task{Tags: []string{"backend", "db"}}
+	if !task.HasAnyTag(nil) {
+		t.Error("empty filter should match every task")
+	}
+	if !task.HasAnyTag([]string{"frontend", "db"}) {
+		t.Error("expected match on db")
+	}
+	if task.HasAnyTag([]string{"frontend"}) {
+		t.Error("unexpected match on frontend")
+	}
+	if (&Task{}).HasAnyTag([]string{"backend"}) {
+		t.Error("untagged task should not match a tag filter")
+	}
+}
@@ -0,0 +1,292 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+)
+
+// TrashLimit is the number of undoable operations kept; older ones are
+// pruned as new ones are recorded.
+const TrashLimit = 50
+
+// trashEntryFile names the record of an operation inside its trash entry.
+const trashEntryFile = "entry.json"
+
+// TrashEntry is one undoable operation: a cancel (of one or more tasks) or
+// a removal of their state and logs. It lives in a directory of its own in
+// the trash, next to copies of the files it changed.
+type TrashEntry struct {
+	Name  string      `json:"-"`  // directory name; sorts oldest first
+	Op    string      `json:"op"` // "cancel" or "remove"
+	At    time.Time   `json:"at"`
+	Tasks []TrashTask `json:"tasks"`
+}
+
+// TrashTask is one task's part of a TrashEntry.
+type TrashTask struct {
+	ID     string   `json:"id"`
+	Status string   `json:"status,omitempty"` // status before the operation
+	Files  []string `json:"files,omitempty"`  // file names saved in the entry
+	Source string   `json:"source,omitempty"` // task file removed with the task, restored to this path
+}
+
+// Snapshot records the prior state of the tasks an operation changes, so
+// the operation can be undone. The entry is written as each task is added,
+// so a crash part way still leaves the tasks changed so far restorable.
+type Snapshot struct {
+	trashDir string
+	dir      string // created by the first add
+	entry    TrashEntry
+}
+
+// NewSnapshot starts recording an op performed at now.
+func NewSnapshot(trashDir, op string, now time.Time) *Snapshot {
+	return &Snapshot{trashDir: trashDir, entry: TrashEntry{Op: op, At: now.UTC()}}
+}
+
+// Copy saves a copy of the state file of task id, whose status is about to
+// change from status. A task with no state file is recorded without one.
+func (s *Snapshot) Copy(stateDir, id, status string) error {
+	data, err := os.ReadFile(filepath.Join(stateDir, id+".state.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	t := TrashTask{ID: id, Status: status}
+	if err == nil {
+		t.Files = []string{id + ".state.json"}
+	}
+	if err := s.add(t); err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+	return fileutil.AtomicWrite(filepath.Join(s.dir, id+".state.json"), data, 0644)
+}
+
+// Move moves the state, init records and logs of task id into the trash in
+// place of deleting them. It returns the number of files moved.
+func (s *Snapshot) Move(stateDir, logsDir, id string) (int, error) {
+	return s.move(stateDir, logsDir, id, "")
+}
+
+// move is Move, also moving the task file source first when it is set.
+func (s *Snapshot) move(stateDir, logsDir, id, source string) (int, error) {
+	t := TrashTask{ID: id, Source: source}
+	var srcs []string
+	for _, src := range taskDataFiles(stateDir, logsDir, id) {
+		if _, err := os.Lstat(src); err == nil {
+			t.Files = append(t.Files, filepath.Base(src))
+			srcs = append(srcs, src)
+		}
+	}
+	if st, err := LoadState(stateDir, id); err == nil && st != nil {
+		t.Status = st.Status
+	}
+	if err := s.add(t); err != nil {
+		return 0, err
+	}
+	if source != "" {
+		if err := moveFile(source, filepath.Join(s.dir, trashedTaskFile(id, source))); err != nil {
+			return 0, err
+		}
+	}
+	for i, src := range srcs {
+		if err := moveFile(src, filepath.Join(s.dir, filepath.Base(src))); err != nil && !os.IsNotExist(err) {
+			return i, err
+		}
+	}
+	return len(srcs), nil
+}
+
+// RemoveTask takes task out of the queue: its task file, state, init
+// records and logs move into a "remove" trash entry made at now, so undo
+// brings them back. A task whose file defines other tasks too is refused,
+// as is one without a task file. The caller must hold the runner lock.
+func RemoveTask(trashDir, stateDir, logsDir string, task *Task, now time.Time) error {
+	if task.Source == "" {
+		return fmt.Errorf("task '%s' has no task file", task.ID)
+	}
+	if file := SourceFile(task.Source); file != task.Source {
+		return fmt.Errorf("task '%s' is defined in %s together with other tasks; edit that file instead", task.ID, file)
+	}
+	_, err := NewSnapshot(trashDir, "remove", now).move(stateDir, logsDir, task.ID, task.Source)
+	return err
+}
+
+// trashedTaskFile names the copy of task id's file source in a trash entry.
+func trashedTaskFile(id, source string) string {
+	return id + ".task" + filepath.Ext(source)
+}
+
+// add appends t to the entry and writes it, creating the entry directory
+// on first use.
+func (s *Snapshot) add(t TrashTask) error {
+	if s.dir == "" {
+		if err := os.MkdirAll(s.trashDir, 0755); err != nil {
+			return fmt.Errorf("create trash dir: %w", err)
+		}
+		dir := uniquePath(filepath.Join(s.trashDir, s.entry.At.Format("20060102T150405.000000000Z")+"-"+s.entry.Op))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			return fmt.Errorf("create trash entry: %w", err)
+		}
+		s.dir = dir
+		pruneTrash(s.trashDir)
+	}
+	s.entry.Tasks = append(s.entry.Tasks, t)
+	data, err := json.MarshalIndent(s.entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileutil.AtomicWrite(filepath.Join(s.dir, trashEntryFile), append(data, '\n'), 0644)
+}
+
+// taskDataFiles lists the files that hold a task's records and logs.
+func taskDataFiles(stateDir, logsDir, id string) []string {
+	return []string{
+		filepath.Join(stateDir, id+".state.json"),
+		filepath.Join(stateDir, id+".init.json"),
+		filepath.Join(logsDir, id+".log"),
+		filepath.Join(logsDir, id+".log.1"),
+	}
+}
+
+// ListTrash returns the undoable operations in trashDir, newest first.
+// Entry directories without a readable record are skipped.
+func ListTrash(trashDir string) ([]TrashEntry, error) {
+	dirents, err := os.ReadDir(trashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []TrashEntry
+	for _, d := range dirents {
+		if !d.IsDir() {
+			continue
+		}
+		e, err := readTrashEntry(trashDir, d.Name())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name > entries[j].Name })
+	return entries, nil
+}
+
+func readTrashEntry(trashDir, name string) (TrashEntry, error) {
+	var e TrashEntry
+	data, err := os.ReadFile(filepath.Join(trashDir, name, trashEntryFile))
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, err
+	}
+	e.Name = name
+	return e, nil
+}
+
+// pruneTrash removes all but the newest TrashLimit entries.
+func pruneTrash(trashDir string) {
+	dirents, err := os.ReadDir(trashDir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, d := range dirents {
+		if d.IsDir() {
+			names = append(names, d.Name())
+		}
+	}
+	sort.Strings(names)
+	for len(names) > TrashLimit {
+		os.RemoveAll(filepath.Join(trashDir, names[0]))
+		names = names[1:]
+	}
+}
+
+// Undo reverses the trash entry name and deletes it. A cancelled task gets
+// its prior state back if it is still cancelled; a removed task gets its
+// files back if it has no state again since. It returns the IDs restored
+// and, for the rest, why they were skipped. An entry that no longer exists
+// (already undone) restores nothing. The caller must hold the runner lock.
+func Undo(trashDir, stateDir, logsDir, name string) (restored []string, skipped map[string]string, err error) {
+	e, err := readTrashEntry(trashDir, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("read trash entry %s: %w", name, err)
+	}
+	dir := filepath.Join(trashDir, name)
+	skipped = make(map[string]string)
+	for _, t := range e.Tasks {
+		current, err := LoadState(stateDir, t.ID)
+		if err != nil {
+			return restored, skipped, err
+		}
+		switch e.Op {
+		case "cancel":
+			if current == nil || current.Status != StatusCancelled {
+				skipped[t.ID] = "no longer cancelled"
+				continue
+			}
+			if len(t.Files) == 0 {
+				err = SaveState(stateDir, &TaskState{ID: t.ID, Status: StatusPending})
+			} else {
+				var data []byte
+				if data, err = os.ReadFile(filepath.Join(dir, t.Files[0])); err == nil {
+					err = fileutil.AtomicWrite(filepath.Join(stateDir, t.Files[0]), data, 0644)
+				}
+			}
+		case "remove":
+			if current != nil {
+				skipped[t.ID] = "has state again"
+				continue
+			}
+			if t.Source != "" {
+				if _, statErr := os.Lstat(t.Source); statErr == nil {
+					skipped[t.ID] = "its task file exists again"
+					continue
+				}
+				if err = moveFile(filepath.Join(dir, trashedTaskFile(t.ID, t.Source)), t.Source); err != nil && !os.IsNotExist(err) {
+					return restored, skipped, fmt.Errorf("restore %s: %w", t.ID, err)
+				}
+				err = nil
+			}
+			for _, name := range t.Files {
+				dst := filepath.Join(stateDir, name)
+				if filepath.Ext(name) != ".json" {
+					dst = filepath.Join(logsDir, name)
+				}
+				if err = moveFile(filepath.Join(dir, name), dst); err != nil {
+					if !os.IsNotExist(err) {
+						break
+					}
+					err = nil
+					continue
+				}
+				// Restart the retention clock, or the runner would remove
+				// the restored state again straight away.
+				now := time.Now()
+				os.Chtimes(dst, now, now)
+			}
+		default:
+			return restored, skipped, fmt.Errorf("trash entry %s: unknown op %q", name, e.Op)
+		}
+		if err != nil {
+			return restored, skipped, fmt.Errorf("restore %s: %w", t.ID, err)
+		}
+		restored = append(restored, t.ID)
+	}
+	return restored, skipped, os.RemoveAll(dir)
+}
@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUndo_Cancel(t *testing.T) {
+	trashDir, stateDir := t.TempDir(), t.TempDir()
+	SaveState(stateDir, &TaskState{ID: "a", Status: StatusFailed, Attempt: 4})
+	SaveState(stateDir, &TaskState{ID: "b", Status: StatusWaiting})
+
+	snap := NewSnapshot(trashDir, "cancel", time.Now())
+	for _, id := range []string{"a", "b", "c"} {
+		st, _ := LoadState(stateDir, id)
+		status := StatusPending
+		if st != nil {
+			status = st.Status
+		}
+		if err := snap.Copy(stateDir, id, status); err != nil {
+			t.Fatal(err)
+		}
+		SaveState(stateDir, &TaskState{ID: id, Status: StatusCancelled})
+	}
+	// b was retried after the cancel; undo must leave it alone.
+	SaveState(stateDir, &TaskState{ID: "b", Status: StatusPending})
+
+	entries, err := ListTrash(trashDir)
+	if err != nil || len(entries) != 1 || len(entries[0].Tasks) != 3 {
+		t.Fatalf("ListTrash = %+v, %v; want one entry with three tasks", entries, err)
+	}
+	restored, skipped, err := Undo(trashDir, stateDir, t.TempDir(), entries[0].Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 || skipped["b"] == "" {
+		t.Errorf("restored %v, skipped %v; want a and c restored, b skipped", restored, skipped)
+	}
+	if st, _ := LoadState(stateDir, "a"); st.Status != StatusFailed || st.Attempt != 4 {
+		t.Errorf("a = %+v; want its failed state back", st)
+	}
+	if st, _ := LoadState(stateDir, "c"); st.Status != StatusPending {
+		t.Errorf("c = %+v; want pending", st)
+	}
+	if entries, _ := ListTrash(trashDir); len(entries) != 0 {
+		t.Errorf("entry kept after undo: %+v", entries)
+	}
+	if restored, _, err := Undo(trashDir, stateDir, t.TempDir(), entries[0].Name); err != nil || restored != nil {
+		t.Errorf("second Undo = %v, %v; want a no-op", restored, err)
+	}
+}
+
+func TestUndo_Remove(t *testing.T) {
+	trashDir, stateDir, logsDir := t.TempDir(), t.TempDir(), t.TempDir()
+	SaveState(stateDir, &TaskState{ID: "gone", Status: StatusDone})
+	os.WriteFile(filepath.Join(logsDir, "gone.log"), []byte("output\n"), 0644)
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	os.Chtimes(filepath.Join(stateDir, "gone.state.json"), old, old)
+
+	snap := NewSnapshot(trashDir, "remove", time.Now())
+	if n, err := snap.Move(stateDir, logsDir, "gone"); err != nil || n != 2 {
+		t.Fatalf("Move = %d, %v; want 2 files", n, err)
+	}
+	if st, _ := LoadState(stateDir, "gone"); st != nil {
+		t.Fatal("state still present after Move")
+	}
+
+	entries, _ := ListTrash(trashDir)
+	if len(entries) != 1 || entries[0].Tasks[0].Status != StatusDone {
+		t.Fatalf("trash = %+v", entries)
+	}
+	if _, _, err := Undo(trashDir, stateDir, logsDir, entries[0].Name); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := LoadState(stateDir, "gone"); st == nil || st.Status != StatusDone {
+		t.Errorf("state = %+v; want it restored", st)
+	}
+	if data, _ := os.ReadFile(filepath.Join(logsDir, "gone.log")); string(data) != "output\n" {
+		t.Errorf("log = %q; want it restored", data)
+	}
+	if info, _ := os.Stat(filepath.Join(stateDir, "gone.state.json")); time.Since(info.ModTime()) > time.Hour {
+		t.Error("restored state kept its old mtime; the runner would remove it again")
+	}
+}
+
+func TestRemoveTask_AndUndo(t *testing.T) {
+	trashDir, stateDir, logsDir, tasksDir := t.TempDir(), t.TempDir(), t.TempDir(), t.TempDir()
+	file := filepath.Join(tasksDir, "fix.yaml")
+	os.WriteFile(file, []byte("id: fix\nprompt: p\n"), 0644)
+	SaveState(stateDir, &TaskState{ID: "fix", Status: StatusFailed})
+
+	if err := RemoveTask(trashDir, stateDir, logsDir, &Task{ID: "other", Source: file + "#doc2"}, time.Now()); err == nil {
+		t.Error("a task sharing its file should not be removed")
+	}
+	if err := RemoveTask(trashDir, stateDir, logsDir, &Task{ID: "fix", Source: file}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatal("task file still present after RemoveTask")
+	}
+	if st, _ := LoadState(stateDir, "fix"); st != nil {
+		t.Fatal("state still present after RemoveTask")
+	}
+
+	entries, _ := ListTrash(trashDir)
+	if len(entries) != 1 || entries[0].Op != "remove" {
+		t.Fatalf("trash = %+v", entries)
+	}
+	if restored, _, err := Undo(trashDir, stateDir, logsDir, entries[0].Name); err != nil || len(restored) != 1 {
+		t.Fatalf("Undo = %v, %v", restored, err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "id: fix\nprompt: p\n" {
+		t.Errorf("task file = %q; want it restored", data)
+	}
+	if st, _ := LoadState(stateDir, "fix"); st == nil || st.Status != StatusFailed {
+		t.Errorf("state = %+v; want it restored", st)
+	}
+}
+
+func TestSnapshot_PrunesOldEntries(t *testing.T) {
+	trashDir, stateDir := t.TempDir(), t.TempDir()
+	start := time.Now()
+	for i := 0; i < TrashLimit+3; i++ {
+		if err := NewSnapshot(trashDir, "cancel", start.Add(time.Duration(i)*time.Second)).Copy(stateDir, "t", StatusPending); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := ListTrash(trashDir)
+	if len(entries) != TrashLimit {
+		t.Fatalf("kept %d entries; want %d", len(entries), TrashLimit)
+	}
+	if !entries[0].At.Equal(start.Add(time.Duration(TrashLimit+2) * time.Second).UTC()) {
+		t.Errorf("newest entry at %v; want the last one recorded", entries[0].At)
+	}
+}
@@ -0,0 +1,123 @@
+package resume
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+)
+
+// Checkpoint limits. Files are capped only when rendering the prompt.
+const (
+	maxCheckpointCommands = 5
+	maxCommandBytes       = 200
+	maxPlanBytes          = 1500
+	maxPromptFiles        = 50
+)
+
+// BuildCheckpoint extracts structured progress from the output lines of an
+// attempt: every file touched by an editing tool, the most recent shell
+// commands, and the plan. The plan is the last TodoWrite list when the
+// session kept one, otherwise the last assistant prose. It returns nil when
+// nothing was captured.
+func BuildCheckpoint(lines []string) *queue.Checkpoint {
+	cp := &queue.Checkpoint{}
+	seen := make(map[string]bool)
+	var todos, prose string
+
+	for _, e := range transcript.Parse(lines) {
+		switch e.Kind {
+		case transcript.Text:
+			prose = strings.TrimSpace(e.Text)
+		case transcript.ToolUse:
+			if path := transcript.EditedFile(e); path != "" && !seen[path] {
+				seen[path] = true
+				cp.FilesModified = append(cp.FilesModified, path)
+			}
+			switch e.Tool {
+			case "Bash":
+				if cmd, _ := e.Input["command"].(string); strings.TrimSpace(cmd) != "" {
+					cp.Commands = append(cp.Commands, truncate(strings.TrimSpace(cmd), maxCommandBytes))
+				}
+			case "TodoWrite":
+				if t := renderTodos(e.Input["todos"]); t != "" {
+					todos = t
+				}
+			}
+		}
+	}
+
+	if len(cp.Commands) > maxCheckpointCommands {
+		cp.Commands = cp.Commands[len(cp.Commands)-maxCheckpointCommands:]
+	}
+	cp.Plan = todos
+	if cp.Plan == "" {
+		cp.Plan = prose
+	}
+	cp.Plan = truncate(cp.Plan, maxPlanBytes)
+
+	if cp.Empty() {
+		return nil
+	}
+	return cp
+}
+
+// renderTodos formats a TodoWrite todos input as one "[status] item" line
+// per entry.
+func renderTodos(v interface{}) string {
+	items, _ := v.([]interface{})
+	var b strings.Builder
+	for _, it := range items {
+		m, _ := it.(map[string]interface{})
+		content, _ := m["content"].(string)
+		if content == "" {
+			continue
+		}
+		status, _ := m["status"].(string)
+		if status == "" {
+			status = "pending"
+		}
+		fmt.Fprintf(&b, "[%s] %s\n", status, content)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// BuildCheckpointPrompt is BuildResumePromptFromContext led by a structured
+// checkpoint. The condensed context still follows as recent activity.
+func BuildCheckpointPrompt(attempt int, cp *queue.Checkpoint, context string, originalPrompt string) string {
+	if cp.Empty() {
+		return BuildResumePromptFromContext(attempt, context, originalPrompt)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[RESUMED — attempt %d. Previous session expired.\n", attempt)
+	if len(cp.FilesModified) > 0 {
+		b.WriteString("Files already modified:\n")
+		for i, f := range cp.FilesModified {
+			if i == maxPromptFiles {
+				fmt.Fprintf(&b, "- ... and %d more\n", len(cp.FilesModified)-maxPromptFiles)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", f)
+		}
+		b.WriteString("\n")
+	}
+	if len(cp.Commands) > 0 {
+		b.WriteString("Last commands run:\n")
+		for _, c := range cp.Commands {
+			fmt.Fprintf(&b, "- %s\n", c)
+		}
+		b.WriteString("\n")
+	}
+	if cp.Plan != "" {
+		fmt.Fprintf(&b, "Plan at interruption:\n%s\n\n", cp.Plan)
+	}
+	if strings.TrimSpace(context) != "" {
+		fmt.Fprintf(&b, "Recent activity:\n%s\n\n", context)
+	}
+	b.WriteString("Continue from where you left off. Check the current state of the modified files " +
+		"before editing them again and do not redo completed work.]\n\n")
+	fmt.Fprintf(&b, "Original task:\n%s", originalPrompt)
+	return b.String()
+}
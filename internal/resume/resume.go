@@ -3,6 +3,9 @@ package resume
 import (
 	"fmt"
 	"strings"
+	"unicode/utf8"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
 )
 
 // ResumeStrategy determines how a rate-limited session should be continued.
@@ -13,6 +16,16 @@ const (
 	NativeResume ResumeStrategy = iota
 	// RePrompt re-sends the prompt with context about the previous attempt.
 	RePrompt
+	// Fresh re-sends the original prompt unchanged, discarding the previous
+	// session and its context.
+	Fresh
+)
+
+// Task-level resume_strategy values.
+const (
+	PreferNative   = "native"
+	PreferReprompt = "reprompt"
+	PreferFresh    = "fresh"
 )
 
 // String returns a human-readable label for the strategy.
@@ -22,6 +35,8 @@ func (s ResumeStrategy) String() string {
 		return "native_resume"
 	case RePrompt:
 		return "re_prompt"
+	case Fresh:
+		return "fresh"
 	default:
 		return "unknown"
 	}
@@ -37,24 +52,141 @@ func DetermineStrategy(hasSessionID bool, supportsResume bool) ResumeStrategy {
 	return RePrompt
 }
 
-// BuildResumePrompt constructs a prompt that instructs Claude to continue from
-// where a previous rate-limited session left off. The lastMessages slice
-// contains recent output lines from the interrupted session (up to the last 20
-// lines are included for context).
-func BuildResumePrompt(attempt int, lastMessages []string, originalPrompt string) string {
-	// Take at most the last 20 lines for context.
-	contextLines := lastMessages
-	if len(contextLines) > 20 {
-		contextLines = contextLines[len(contextLines)-20:]
+// SelectStrategy applies a task's resume_strategy preference. "native" (the
+// default when empty) uses DetermineStrategy and so still falls back to
+// re-prompting without a session; "reprompt" never uses --resume; "fresh"
+// starts over as if it were the first attempt.
+func SelectStrategy(preference string, hasSessionID bool, supportsResume bool) ResumeStrategy {
+	switch preference {
+	case PreferFresh:
+		return Fresh
+	case PreferReprompt:
+		return RePrompt
+	default:
+		return DetermineStrategy(hasSessionID, supportsResume)
+	}
+}
+
+// sessionNotFoundPatterns match CLI errors for a --resume session ID that is
+// unknown or has expired. Matching is case-insensitive.
+var sessionNotFoundPatterns = []string{
+	"no conversation found with session id",
+	"session not found",
+	"invalid session id",
+	"session has expired",
+}
+
+// IsSessionNotFound reports whether stderr from a --resume invocation says
+// the session could not be found, in which case the caller should clear the
+// session ID and fall back to re-prompting.
+func IsSessionNotFound(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	for _, p := range sessionNotFoundPatterns {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultMaxContextBytes caps the resume context extracted from a transcript.
+const DefaultMaxContextBytes = 4000
+
+// maxEntryBytes caps any single transcript entry so one large tool result
+// cannot crowd out everything else.
+const maxEntryBytes = 600
+
+// ExtractContext builds a readable summary of an interrupted session from its
+// raw output lines. Stream-json lines are parsed so that assistant prose, tool
+// calls, and tool results appear as plain text instead of JSON; other lines
+// are kept verbatim. The most recent entries are kept, up to maxBytes in
+// total (DefaultMaxContextBytes when maxBytes <= 0).
+func ExtractContext(lines []string, maxBytes int) string {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxContextBytes
+	}
+
+	var rendered []string
+	for _, e := range transcript.Parse(lines) {
+		var s string
+		switch e.Kind {
+		case transcript.Text:
+			s = "Assistant: " + e.Text
+		case transcript.ToolUse:
+			s = "Tool: " + transcript.ToolSummary(e)
+		case transcript.ToolResult:
+			if e.IsError {
+				s = "Tool error: " + e.Text
+			} else {
+				s = "Tool result: " + e.Text
+			}
+		case transcript.Result:
+			if strings.TrimSpace(e.Text) == "" {
+				continue
+			}
+			s = "Final: " + e.Text
+		case transcript.Raw:
+			s = "Output: " + e.Text
+		default:
+			continue
+		}
+		rendered = append(rendered, truncate(strings.TrimSpace(s), maxEntryBytes))
+	}
+
+	// Walk backwards so the most recent entries survive the cap.
+	var kept []string
+	total := 0
+	for i := len(rendered) - 1; i >= 0; i-- {
+		if total+len(rendered[i])+1 > maxBytes {
+			if len(kept) == 0 {
+				kept = append(kept, truncate(rendered[i], maxBytes))
+			}
+			break
+		}
+		kept = append(kept, rendered[i])
+		total += len(rendered[i]) + 1
+	}
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	return strings.Join(kept, "\n")
+}
+
+// truncate shortens s to at most n bytes, cutting on a rune boundary and
+// marking the cut.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	const marker = " [...]"
+	cut := n - len(marker)
+	if cut < 0 {
+		cut = 0
 	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
 
-	lastOutput := strings.Join(contextLines, "\n")
+// BuildResumePrompt constructs a prompt that instructs Claude to continue from
+// where a previous rate-limited session left off. lastMessages holds output
+// lines from the interrupted session; they are condensed with ExtractContext.
+func BuildResumePrompt(attempt int, lastMessages []string, originalPrompt string) string {
+	return BuildResumePromptFromContext(attempt, ExtractContext(lastMessages, DefaultMaxContextBytes), originalPrompt)
+}
 
+// BuildResumePromptFromContext is BuildResumePrompt for a context already
+// extracted with ExtractContext.
+func BuildResumePromptFromContext(attempt int, context string, originalPrompt string) string {
+	if strings.TrimSpace(context) == "" {
+		context = "(no output captured)"
+	}
 	return fmt.Sprintf(
 		"[RESUMED — attempt %d. Previous session expired.\n"+
-			"Last output before interruption: %s.\n"+
+			"Progress before interruption:\n%s\n\n"+
 			"Continue from where you left off. Do not redo completed work.]\n\n"+
 			"Original task:\n%s",
-		attempt, lastOutput, originalPrompt,
+		attempt, context, originalPrompt,
 	)
 }
@@ -0,0 +1,128 @@
+package resume
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractContext_RendersTranscript(t *testing.T) {
+	lines := []string{
+		`{"type":"system","subtype":"init","session_id":"abc"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Running the tests."},` +
+			`{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","content":"ok  pkg/auth"}]}}`,
+	}
+
+	got := ExtractContext(lines, 0)
+	want := "Assistant: Running the tests.\nTool: Bash(command=go test ./...)\nTool result: ok  pkg/auth"
+	if got != want {
+		t.Errorf("ExtractContext =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExtractContext_KeepsMostRecentWithinCap(t *testing.T) {
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, `{"type":"assistant","message":"step `+strings.Repeat("x", 40)+`"}`)
+	}
+	lines = append(lines, `{"type":"assistant","message":"latest step"}`)
+
+	got := ExtractContext(lines, 200)
+	if len(got) > 200 {
+		t.Errorf("context is %d bytes; want <= 200", len(got))
+	}
+	if !strings.HasSuffix(got, "Assistant: latest step") {
+		t.Errorf("most recent entry missing: %q", got)
+	}
+}
+
+func TestExtractContext_TruncatesLargeEntries(t *testing.T) {
+	big := strings.Repeat("y", 5000)
+	got := ExtractContext([]string{`{"type":"user","message":{"content":[{"type":"tool_result","content":"` + big + `"}]}}`}, 0)
+	if len(got) > maxEntryBytes || !strings.HasSuffix(got, "[...]") {
+		t.Errorf("entry not truncated: %d bytes", len(got))
+	}
+}
+
+func TestBuildResumePrompt_IncludesContextAndTask(t *testing.T) {
+	p := BuildResumePrompt(2, []string{`{"type":"assistant","message":"edited auth.go"}`}, "Fix login")
+	for _, want := range []string{"attempt 2", "Assistant: edited auth.go", "Original task:\nFix login"} {
+		if !strings.Contains(p, want) {
+			t.Errorf("prompt missing %q:\n%s", want, p)
+		}
+	}
+	if strings.Contains(p, `{"type"`) {
+		t.Errorf("prompt still contains raw JSON:\n%s", p)
+	}
+}
+
+func TestSelectStrategy(t *testing.T) {
+	tests := []struct {
+		pref       string
+		hasSession bool
+		want       ResumeStrategy
+	}{
+		{"", true, NativeResume},
+		{PreferNative, false, RePrompt},
+		{PreferReprompt, true, RePrompt},
+		{PreferFresh, true, Fresh},
+	}
+	for _, tt := range tests {
+		if got := SelectStrategy(tt.pref, tt.hasSession, true); got != tt.want {
+			t.Errorf("SelectStrategy(%q, %v) = %v; want %v", tt.pref, tt.hasSession, got, tt.want)
+		}
+	}
+}
+
+func TestIsSessionNotFound(t *testing.T) {
+	if !IsSessionNotFound("Error: No conversation found with session ID: 550e8400") {
+		t.Error("expected Claude Code's missing-session error to match")
+	}
+	if IsSessionNotFound("Error: rate limit exceeded") {
+		t.Error("unrelated error should not match")
+	}
+}
+
+func TestBuildCheckpoint_FilesCommandsAndPlan(t *testing.T) {
+	lines := []string{
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"TodoWrite","input":{"todos":[` +
+			`{"content":"Fix handler","status":"completed"},{"content":"Add tests","status":"in_progress"}]}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"auth.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Write","input":{"file_path":"auth_test.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"auth.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Read","input":{"file_path":"main.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Now running the tests."}]}}`,
+	}
+
+	cp := BuildCheckpoint(lines)
+	if cp == nil {
+		t.Fatal("BuildCheckpoint returned nil")
+	}
+	if got := strings.Join(cp.FilesModified, ","); got != "auth.go,auth_test.go" {
+		t.Errorf("FilesModified = %q", got)
+	}
+	if len(cp.Commands) != 1 || cp.Commands[0] != "go test ./..." {
+		t.Errorf("Commands = %q", cp.Commands)
+	}
+	if cp.Plan != "[completed] Fix handler\n[in_progress] Add tests" {
+		t.Errorf("Plan = %q; want the todo list over prose", cp.Plan)
+	}
+
+	p := BuildCheckpointPrompt(2, cp, "Assistant: Now running the tests.", "Fix login")
+	for _, want := range []string{"attempt 2", "Files already modified:\n- auth.go\n- auth_test.go", "[in_progress] Add tests", "Recent activity:", "Original task:\nFix login"} {
+		if !strings.Contains(p, want) {
+			t.Errorf("prompt missing %q:\n%s", want, p)
+		}
+	}
+}
+
+func TestBuildCheckpoint_ProseFallbackAndEmpty(t *testing.T) {
+	cp := BuildCheckpoint([]string{`{"type":"assistant","message":"I will refactor the parser next."}`})
+	if cp == nil || cp.Plan != "I will refactor the parser next." {
+		t.Errorf("checkpoint = %+v; want prose plan", cp)
+	}
+	if cp := BuildCheckpoint([]string{`{"type":"system","subtype":"init"}`}); cp != nil {
+		t.Errorf("checkpoint = %+v; want nil", cp)
+	}
+}
@@ -0,0 +1,151 @@
+// Package review keeps the work of review-mode tasks in a git worktree on a
+// dedicated branch until a human approves (merges) or rejects (discards) it.
+package review
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// ownFiles keeps autopilot's own files (the directory lock, project-local
+// tasks) out of review commits.
+const ownFiles = ":(exclude).autopilot"
+
+// Branch returns the branch a review task's work is committed to.
+func Branch(taskID string) string { return "autopilot/" + taskID }
+
+// Prepare checks out a new worktree at path on branch, starting at base in
+// the repository at repoDir. An existing worktree or branch of that name is
+// replaced.
+func Prepare(repoDir, path, branch, base string) error {
+	if base == "" {
+		return errors.New("review needs working_dir to be a git repository with at least one commit")
+	}
+	if err := Discard(repoDir, path, branch); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("create worktree dir: %w", err)
+	}
+	if _, err := git(repoDir, "worktree", "add", "-B", branch, path, base); err != nil {
+		return fmt.Errorf("create worktree: %w", err)
+	}
+	return nil
+}
+
+// Exists reports whether path is the root of a git worktree.
+func Exists(path string) bool {
+	_, err := os.Stat(filepath.Join(path, ".git"))
+	return err == nil
+}
+
+// Commit commits every change in the worktree at path. It reports whether
+// there was anything to commit.
+func Commit(path, message string) (bool, error) {
+	if _, err := git(path, "add", "-A", "--", ".", ownFiles); err != nil {
+		return false, fmt.Errorf("stage changes: %w", err)
+	}
+	if _, err := git(path, "diff", "--cached", "--quiet"); err == nil {
+		return false, nil
+	}
+	if _, err := git(path, withIdentity(path, "commit", "-q", "--no-verify", "-m", message)...); err != nil {
+		return false, fmt.Errorf("commit changes: %w", err)
+	}
+	return true, nil
+}
+
+// Diff returns what branch changed since base: a --stat summary when stat
+// is set, otherwise the full patch.
+func Diff(repoDir, base, branch string, stat bool) (string, error) {
+	args := []string{"diff", base + "..." + branch}
+	if stat {
+		args = append(args, "--stat")
+	}
+	return git(repoDir, args...)
+}
+
+// Approve merges branch into the branch checked out at repoDir, then
+// removes the worktree and branch. A merge that fails (for example on a
+// conflict) is aborted and leaves both in place.
+func Approve(repoDir, path, branch string) error {
+	if _, err := git(repoDir, withIdentity(repoDir, "merge", "--no-edit", branch)...); err != nil {
+		git(repoDir, "merge", "--abort")
+		return fmt.Errorf("merge %s: %w", branch, err)
+	}
+	return Discard(repoDir, path, branch)
+}
+
+// Decide applies a reviewer's decision to a task awaiting review whose
+// working_dir is repoDir: approval merges its branch and marks it done,
+// rejection discards the branch and marks it failed. The caller saves st.
+func Decide(st *queue.TaskState, repoDir string, approve bool) error {
+	if st.Status != queue.StatusNeedsReview {
+		return fmt.Errorf("task %s is %s, not awaiting review", st.ID, st.Status)
+	}
+	to := queue.StatusFailed
+	if approve {
+		if err := Approve(repoDir, st.Worktree, st.ReviewBranch); err != nil {
+			return err
+		}
+		to = queue.StatusDone
+	} else if err := Discard(repoDir, st.Worktree, st.ReviewBranch); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	st.Status = to
+	st.EndedAt = &now
+	st.Worktree, st.ReviewBranch = "", ""
+	return nil
+}
+
+// Discard removes the worktree at path and deletes branch. Either may
+// already be gone.
+func Discard(repoDir, path, branch string) error {
+	if Exists(path) {
+		if _, err := git(repoDir, "worktree", "remove", "--force", path); err != nil {
+			return fmt.Errorf("remove worktree: %w", err)
+		}
+	}
+	if _, err := git(repoDir, "worktree", "prune"); err != nil {
+		return fmt.Errorf("prune worktrees: %w", err)
+	}
+	if _, err := git(repoDir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		if _, err := git(repoDir, "branch", "-D", branch); err != nil {
+			return fmt.Errorf("delete branch: %w", err)
+		}
+	}
+	return nil
+}
+
+// withIdentity prefixes a committing git command with a claude-autopilot
+// identity when the repository at dir has no user.email configured.
+func withIdentity(dir string, args ...string) []string {
+	if email, _ := git(dir, "config", "user.email"); email != "" {
+		return args
+	}
+	return append([]string{"-c", "user.name=claude-autopilot", "-c", "user.email=claude-autopilot@localhost"}, args...)
+}
+
+// git runs git in dir and returns its trimmed stdout. Errors carry git's
+// own message.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
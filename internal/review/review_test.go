@@ -0,0 +1,113 @@
+package review
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newRepo creates a git repository with one commit and returns its path
+// and HEAD.
+func newRepo(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=t", "-c", "user.email=t@example.com", "commit", "-q", "--allow-empty", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	head, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, head
+}
+
+func TestApprove_MergesWorktreeBranch(t *testing.T) {
+	repo, base := newRepo(t)
+	wt := filepath.Join(t.TempDir(), "worktrees", "fix-bug")
+	branch := Branch("fix-bug")
+
+	if err := Prepare(repo, wt, branch, base); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if !Exists(wt) {
+		t.Fatal("worktree not created")
+	}
+	if committed, err := Commit(wt, "nothing"); err != nil || committed {
+		t.Fatalf("Commit on a clean worktree = %v, %v; want false, nil", committed, err)
+	}
+
+	os.WriteFile(filepath.Join(wt, "fix.txt"), []byte("fixed\n"), 0644)
+	os.MkdirAll(filepath.Join(wt, ".autopilot"), 0755)
+	os.WriteFile(filepath.Join(wt, ".autopilot", "dir.lock"), []byte("1"), 0644)
+	if committed, err := Commit(wt, "Fix the bug"); err != nil || !committed {
+		t.Fatalf("Commit = %v, %v; want true, nil", committed, err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "fix.txt")); !os.IsNotExist(err) {
+		t.Fatal("change reached the repository before approval")
+	}
+
+	patch, err := Diff(repo, base, branch, false)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if !strings.Contains(patch, "+fixed") || strings.Contains(patch, ".autopilot") {
+		t.Errorf("Diff = %q; want fix.txt only", patch)
+	}
+
+	if err := Approve(repo, wt, branch); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(repo, "fix.txt")); err != nil || string(data) != "fixed\n" {
+		t.Errorf("fix.txt after approve = %q, %v", data, err)
+	}
+	if Exists(wt) {
+		t.Error("worktree kept after approve")
+	}
+	if _, err := git(repo, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		t.Error("branch kept after approve")
+	}
+}
+
+func TestDiscard_DropsWork(t *testing.T) {
+	repo, base := newRepo(t)
+	wt := filepath.Join(t.TempDir(), "wt")
+	branch := Branch("drop")
+	if err := Prepare(repo, wt, branch, base); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	os.WriteFile(filepath.Join(wt, "junk.txt"), []byte("junk\n"), 0644)
+	if _, err := Commit(wt, "junk"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Discard(repo, wt, branch); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if Exists(wt) {
+		t.Error("worktree kept after discard")
+	}
+	if _, err := os.Stat(filepath.Join(repo, "junk.txt")); !os.IsNotExist(err) {
+		t.Error("discarded change reached the repository")
+	}
+	if err := Discard(repo, wt, branch); err != nil {
+		t.Errorf("second Discard: %v", err)
+	}
+}
+
+func TestPrepare_NeedsBase(t *testing.T) {
+	if err := Prepare(t.TempDir(), filepath.Join(t.TempDir(), "wt"), Branch("x"), ""); err == nil {
+		t.Error("Prepare without a base commit should fail")
+	}
+}
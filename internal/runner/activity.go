@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+)
+
+// thinkingAllowance is how many hang timeouts a session may spend sending
+// only heartbeats before it is treated as hung.
+const thinkingAllowance = 3
+
+// activity tracks what a running session last did, for hang detection. Work
+// is output that shows the session moving: prose, tool calls and their
+// results, the final result, or any line from a CLI without stream-json.
+// Heartbeats are other signs of life, such as stream-json lines carrying
+// only thinking, partial-message deltas or system messages, and stderr: the
+// CLI is alive, though not visibly getting anywhere.
+type activity struct {
+	mu       sync.Mutex
+	lastWork time.Time
+	lastBeat time.Time // last output of any kind, work included
+}
+
+func newActivity(now time.Time) *activity {
+	return &activity{lastWork: now, lastBeat: now}
+}
+
+// work records output that shows progress.
+func (a *activity) work(at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastWork, a.lastBeat = at, at
+}
+
+// beat records a sign of life that shows no progress.
+func (a *activity) beat(at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastBeat = at
+}
+
+// output records a stream-json line by the entries parsed from it: work if
+// any of them is, else a heartbeat.
+func (a *activity) output(entries []transcript.Entry, at time.Time) {
+	for _, e := range entries {
+		if e.Kind != transcript.System {
+			a.work(at)
+			return
+		}
+	}
+	a.beat(at)
+}
+
+// silence is how long the session has produced no output at all.
+func (a *activity) silence(now time.Time) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return now.Sub(a.lastBeat)
+}
+
+// hung reports whether the session looks wedged at now: silent for
+// timeout, or sending only heartbeats for thinkingAllowance times as long.
+// The returned message says which, for the log.
+func (a *activity) hung(now time.Time, timeout time.Duration) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if silence := now.Sub(a.lastBeat); silence >= timeout {
+		return "has produced no output for " + silence.Truncate(time.Second).String(), true
+	}
+	if idle := now.Sub(a.lastWork); idle >= thinkingAllowance*timeout {
+		return "has sent only heartbeats, with no text or tool use, for " + idle.Truncate(time.Second).String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,101 @@
+package runner
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/config"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// Permission prompt actions (config key prompt_action).
+const (
+	PromptActionKill   = "kill"
+	PromptActionAnswer = "answer"
+)
+
+// answerSettleDelay is how long output must pause at a matching prompt
+// before it is answered, so a prompt is not answered while still being
+// drawn.
+const answerSettleDelay = 2 * time.Second
+
+// maxTailBytes bounds the output kept for prompt matching.
+const maxTailBytes = 4096
+
+// allowedAnswers returns the prompt answers task may use: the prompt_answers
+// entries named in its auto_approve list, when prompt_action is "answer".
+// Unknown names are logged and ignored.
+func (r *Runner) allowedAnswers(task *queue.Task, skipPerms bool) []config.PromptAnswer {
+	if skipPerms || r.Config.PromptAction != PromptActionAnswer || len(task.AutoApprove) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]config.PromptAnswer, len(r.PromptAnswers))
+	for _, a := range r.PromptAnswers {
+		byName[a.Name] = a
+	}
+	var allowed []config.PromptAnswer
+	for _, name := range task.AutoApprove {
+		a, ok := byName[name]
+		if !ok {
+			log.Printf("WARN: task %s auto_approve %q matches no prompt_answers entry", task.ID, name)
+			continue
+		}
+		allowed = append(allowed, a)
+	}
+	return allowed
+}
+
+// matchAnswer returns the first answer whose pattern appears in text,
+// ignoring case.
+func matchAnswer(answers []config.PromptAnswer, text string) (config.PromptAnswer, bool) {
+	lower := strings.ToLower(text)
+	for _, a := range answers {
+		if strings.Contains(lower, strings.ToLower(a.Pattern)) {
+			return a, true
+		}
+	}
+	return config.PromptAnswer{}, false
+}
+
+// tailBuffer keeps the current output line: the trailing partial line a
+// prompt waits on, or the last complete line when output stopped at a line
+// end. Earlier lines are dropped as soon as another one starts, so text that
+// merely mentioned a prompt cannot answer a different one shown later.
+type tailBuffer struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.data = append(t.data, p...)
+	if len(t.data) > 1 {
+		if i := bytes.LastIndexByte(t.data[:len(t.data)-1], '\n'); i >= 0 {
+			t.data = append([]byte(nil), t.data[i+1:]...)
+		}
+	}
+	if len(t.data) > maxTailBytes {
+		t.data = append([]byte(nil), t.data[len(t.data)-maxTailBytes:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the buffered output.
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.data)
+}
+
+// Reset forgets the buffered output, so an answered prompt is not matched
+// again.
+func (t *tailBuffer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.data = nil
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// archiveDone moves done tasks with archive_done in effect into the
+// archive directory, together with their task files, state and logs.
+func (r *Runner) archiveDone(stateDir string) {
+	tasks, err := queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir)
+	if err != nil {
+		log.Printf("WARN: archive: load tasks: %v", err)
+		return
+	}
+	states, _ := queue.LoadAllStates(stateDir)
+	statuses := make(map[string]string, len(states))
+	for id, st := range states {
+		statuses[id] = st.Status
+	}
+
+	groups := archivable(tasks, statuses, r.Config.ArchiveDone)
+	paths := make([]string, 0, len(groups))
+	for path := range groups {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		dir, err := queue.Archive(r.Paths.ArchiveDir(), stateDir, r.Paths.LogsDir(), path, groups[path], time.Now())
+		if err != nil {
+			log.Printf("WARN: %v", err)
+			continue
+		}
+		log.Printf("Archived %s (%d task(s)) to %s", path, len(groups[path]), dir)
+	}
+}
+
+// archivable returns, by task file, the IDs of the tasks to archive. A file
+// is archived only once every task it defines is done and set to archive,
+// since a task left in a file would otherwise run again without its state,
+// and a task is kept while another task that stays behind depends on it.
+func archivable(tasks []queue.Task, statuses map[string]string, archiveDone bool) map[string][]string {
+	files := make(map[string][]string)
+	ok := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		path := queue.SourceFile(t.Source)
+		files[path] = append(files[path], t.ID)
+		enabled := archiveDone
+		if t.ArchiveDone != nil {
+			enabled = *t.ArchiveDone
+		}
+		ok[t.ID] = enabled && statuses[t.ID] == queue.StatusDone
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, ids := range files {
+			whole := true
+			for _, id := range ids {
+				whole = whole && ok[id]
+			}
+			if whole {
+				continue
+			}
+			for _, id := range ids {
+				if ok[id] {
+					ok[id], changed = false, true
+				}
+			}
+		}
+		for i := range tasks {
+			if ok[tasks[i].ID] {
+				continue
+			}
+			for _, dep := range tasks[i].Dependencies() {
+				if ok[dep] {
+					ok[dep], changed = false, true
+				}
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for path, ids := range files {
+		if path != "" && ok[ids[0]] {
+			groups[path] = ids
+		}
+	}
+	return groups
+}
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// collectArtifacts copies the files matching task.Artifacts (plain paths or
+// glob patterns, relative to the working directory unless absolute) to
+// <artifacts>/<task>/<attempt>/, keeping their paths relative to the working
+// directory. It returns the copies' paths. Patterns that match nothing and
+// files that cannot be copied are logged and skipped; a missing artifact
+// does not fail a completed task.
+func (r *Runner) collectArtifacts(task *queue.Task, attempt int) []string {
+	if len(task.Artifacts) == 0 {
+		return nil
+	}
+	destDir := filepath.Join(r.Paths.ArtifactsDir(), task.ID, strconv.Itoa(attempt))
+
+	seen := make(map[string]bool)
+	var copied []string
+	for _, ref := range task.Artifacts {
+		matches, err := expandArtifact(task.WorkingDir, ref)
+		if err != nil {
+			log.Printf("WARN: task %s artifact '%s': %v", task.ID, ref, err)
+			continue
+		}
+		if len(matches) == 0 {
+			log.Printf("WARN: task %s artifact '%s' matched no files", task.ID, ref)
+			continue
+		}
+		for _, src := range matches {
+			if seen[src] {
+				continue
+			}
+			seen[src] = true
+
+			rel, err := filepath.Rel(task.WorkingDir, src)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				rel = filepath.Base(src)
+			}
+			dst := filepath.Join(destDir, rel)
+			if err := copyFile(src, dst); err != nil {
+				log.Printf("WARN: task %s artifact %s: %v", task.ID, src, err)
+				continue
+			}
+			copied = append(copied, dst)
+		}
+	}
+	if len(copied) > 0 {
+		log.Printf("Task %s: collected %d artifact(s) in %s", task.ID, len(copied), destDir)
+	}
+	return copied
+}
+
+// expandArtifact resolves one artifacts entry to regular files.
+func expandArtifact(workingDir, ref string) ([]string, error) {
+	resolved := ref
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(workingDir, resolved)
+	}
+	if hasGlobMeta(ref) {
+		return globContext(resolved)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file (use a glob such as '%s/**' for directories)", ref)
+	}
+	return []string{resolved}, nil
+}
+
+// copyFile copies src to dst, creating dst's directory and keeping the
+// source's permission bits.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// resultBudgetExceeded is the attempt result recorded when max_cost_usd or
+// max_tokens stops a task. Such tasks are failed without a retry.
+const resultBudgetExceeded = "budget_exceeded"
+
+// tokenUsage mirrors the usage object of assistant and result messages.
+type tokenUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+// total counts input and output tokens. Cache reads are left out: they
+// re-read the same context on every turn and would dwarf the real work.
+func (u tokenUsage) total() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.OutputTokens
+}
+
+// usageMeter totals an attempt's tokens as messages stream in. The CLI
+// repeats a message's usage on each content block it emits, so usage is
+// kept per message ID.
+type usageMeter struct {
+	byMessage map[string]int
+	sum       int
+}
+
+// addAssistant records the usage of an assistant message, if it has any.
+func (m *usageMeter) addAssistant(raw json.RawMessage) {
+	var msg struct {
+		Message struct {
+			ID    string      `json:"id"`
+			Usage *tokenUsage `json:"usage"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Message.Usage == nil {
+		return
+	}
+	if m.byMessage == nil {
+		m.byMessage = make(map[string]int)
+	}
+	n := msg.Message.Usage.total()
+	if msg.Message.ID == "" {
+		m.sum += n
+		return
+	}
+	m.sum += n - m.byMessage[msg.Message.ID]
+	m.byMessage[msg.Message.ID] = n
+}
+
+// addResult takes the session totals from the result message when they are
+// higher than what the assistant messages added up to.
+func (m *usageMeter) addResult(u *tokenUsage) {
+	if u != nil && u.total() > m.sum {
+		m.sum = u.total()
+	}
+}
+
+// spent returns the cost and tokens of a task's recorded attempts.
+func spent(state *queue.TaskState) (float64, int) {
+	var cost float64
+	var tokens int
+	for _, a := range state.Attempts {
+		cost += a.CostUSD
+		tokens += a.Tokens
+	}
+	return cost, tokens
+}
+
+// overBudget explains how cost and tokens reach the task's limits, or
+// returns "" when they are within them (or no limits are set).
+func overBudget(task *queue.Task, cost float64, tokens int) string {
+	if task.MaxCostUSD > 0 && cost >= task.MaxCostUSD {
+		return fmt.Sprintf("cost $%.2f reached max_cost_usd $%.2f", cost, task.MaxCostUSD)
+	}
+	if task.MaxTokens > 0 && tokens >= task.MaxTokens {
+		return fmt.Sprintf("%d tokens reached max_tokens %d", tokens, task.MaxTokens)
+	}
+	return ""
+}
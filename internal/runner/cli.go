@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/compat"
+	"github.com/hseinmoussa/claude-autopilot/internal/detector"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// cli is the agent binary a task runs with, and the adapter and detector
+// that match it.
+type cli struct {
+	path     string
+	adapter  compat.CLIAdapter
+	detector *detector.Detector
+}
+
+// cliFor returns the CLI for task: the claude on PATH that the runner
+// started with, the pinned binary its claude_version selects, or the other
+// agent it names. Pins and agents.yaml are read on every call, so changes
+// to them need no reload. On error the default CLI is returned alongside it.
+func (r *Runner) cliFor(task *queue.Task) (cli, error) {
+	c := cli{path: "claude", adapter: r.Adapter, detector: r.Detector}
+	if task.Agent != "" && task.Agent != compat.DefaultAgent {
+		defined, err := compat.LoadAgents(r.Paths.AgentsFile())
+		if err != nil {
+			return c, fmt.Errorf("load agents: %w", err)
+		}
+		agent, ok := compat.LookupAgent(defined, task.Agent)
+		if !ok {
+			return c, fmt.Errorf("unknown agent '%s' (known: %s)", task.Agent, strings.Join(compat.AgentNames(defined), ", "))
+		}
+		c.path, c.adapter = agent.Command, agent.Adapter
+		if r.Detector != nil {
+			c.detector = r.Detector.WithExitCode(agent.Adapter.RateLimitExitCode()).WithPatterns(agent.RateLimitPatterns)
+		}
+		return c, nil
+	}
+	if task.ClaudeVersion == "" {
+		return c, nil
+	}
+	pins, err := compat.LoadPins(r.Paths.ClaudeVersionsFile())
+	if err != nil {
+		return c, fmt.Errorf("load pinned claude versions: %w", err)
+	}
+	b := compat.SelectBinary(pins, task.ClaudeVersion)
+	if b == nil {
+		return c, fmt.Errorf("no claude %s is pinned (see 'compat list' and 'compat pin')", task.ClaudeVersion)
+	}
+	c.path = b.Path
+	if c.adapter, err = compat.AdapterFor(b.Path, b.Version); err != nil {
+		log.Printf("WARN: task %s: %v", task.ID, err)
+	}
+	if r.Detector != nil {
+		c.detector = r.Detector.WithExitCode(c.adapter.RateLimitExitCode())
+	}
+	return c, nil
+}
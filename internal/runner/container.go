@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// containerEnv are the host variables passed into task containers, so the
+// agent inside can authenticate. Only those that are set are passed.
+var containerEnv = []string{
+	"ANTHROPIC_API_KEY",
+	"ANTHROPIC_AUTH_TOKEN",
+	"ANTHROPIC_BASE_URL",
+	"CLAUDE_CODE_OAUTH_TOKEN",
+	"CLAUDE_CODE_USE_BEDROCK",
+	"CLAUDE_CODE_USE_VERTEX",
+	"GEMINI_API_KEY",
+	"OPENAI_API_KEY",
+}
+
+// containerRuntime returns the container_runtime setting, or else docker or
+// podman, whichever is found on PATH first.
+func (r *Runner) containerRuntime() (string, error) {
+	if r.Config != nil && r.Config.ContainerRuntime != "" {
+		return r.Config.ContainerRuntime, nil
+	}
+	for _, name := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("tasks with a container need docker or podman on PATH (or set container_runtime)")
+}
+
+// containerName names the container of one attempt of task, unique across
+// runners so a leftover container can be removed by name.
+func containerName(task *queue.Task, attempt int) string {
+	return fmt.Sprintf("autopilot-%s-%d-%d", task.ID, os.Getpid(), attempt)
+}
+
+// containerArgs builds the `run` arguments that execute command with args
+// in task's container image. The working directory is mounted at the same
+// path, plus any extra directories (the repository behind a review
+// worktree, whose git data the worktree points to). Files are written as
+// the host user, and tty allocates a terminal for prompt answering.
+func containerArgs(task *queue.Task, name string, mounts []string, tty bool, command string, args []string) []string {
+	run := []string{"run", "--rm", "--init", "--name", name}
+	if tty {
+		run = append(run, "-i", "-t")
+	}
+	for _, dir := range append([]string{task.WorkingDir}, mounts...) {
+		run = append(run, "-v", dir+":"+dir)
+	}
+	run = append(run, "-w", task.WorkingDir)
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		run = append(run, "--user", strconv.Itoa(uid)+":"+strconv.Itoa(gid))
+	}
+	if task.ContainerNet != "" {
+		run = append(run, "--network", task.ContainerNet)
+	}
+	if task.ContainerCPUs > 0 {
+		run = append(run, "--cpus", strconv.FormatFloat(task.ContainerCPUs, 'f', -1, 64))
+	}
+	if task.ContainerMem != "" {
+		run = append(run, "--memory", task.ContainerMem)
+	}
+	for _, key := range containerEnv {
+		if _, ok := os.LookupEnv(key); ok {
+			run = append(run, "-e", key)
+		}
+	}
+	run = append(run, task.Container, command)
+	return append(run, args...)
+}
+
+// removeContainer removes a task container that outlived its attempt, as
+// happens when the runtime client is killed rather than stopped. A
+// container already gone with --rm is not an error worth reporting.
+func removeContainer(runtime, name string) {
+	exec.Command(runtime, "rm", "-f", name).Run()
+}
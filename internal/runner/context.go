@@ -0,0 +1,296 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// contextCommandTimeout bounds each context_commands entry. A variable so
+// tests can shorten it.
+var contextCommandTimeout = 60 * time.Second
+
+// contextCommandWaitDelay bounds how long a timed-out command's leftover
+// children (the go test under "go test | tail") may keep its output open.
+const contextCommandWaitDelay = time.Second
+
+// Binary context file policies.
+const (
+	BinarySkip  = "skip"
+	BinaryError = "error"
+)
+
+// contextLimits bounds how much file content is embedded in a prompt.
+// Zero values disable the corresponding limit.
+type contextLimits struct {
+	MaxFileBytes  int64
+	MaxTotalBytes int64
+	Binary        string // BinarySkip or BinaryError
+}
+
+// contextFile is a resolved context file ready to embed.
+type contextFile struct {
+	Label string // path as shown in the [File: ...] header
+	Data  []byte
+}
+
+// loadContextFiles expands refs (plain paths, directories, or glob patterns,
+// relative to workingDir unless absolute) into file contents, enforcing the
+// given limits. Directories contribute their regular files non-recursively;
+// use a "dir/**" pattern for recursion. Files matched more than once are
+// included once, in first-match order.
+func loadContextFiles(workingDir string, refs []string, limits contextLimits) ([]contextFile, error) {
+	var files []contextFile
+	seen := make(map[string]bool)
+	var total int64
+
+	for _, ref := range refs {
+		paths, err := expandContextRef(workingDir, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			if seen[p.path] {
+				continue
+			}
+			seen[p.path] = true
+
+			info, err := os.Stat(p.path)
+			if err != nil {
+				return nil, fmt.Errorf("stat context file '%s': %w", p.label, err)
+			}
+			if limits.MaxFileBytes > 0 && info.Size() > limits.MaxFileBytes {
+				return nil, fmt.Errorf("Context file '%s' is %d bytes, over the per-file limit of %d (context_max_file_bytes)",
+					p.label, info.Size(), limits.MaxFileBytes)
+			}
+
+			data, err := os.ReadFile(p.path)
+			if err != nil {
+				return nil, fmt.Errorf("read context file '%s': %w", p.label, err)
+			}
+
+			if isBinary(data) {
+				if limits.Binary == BinaryError {
+					return nil, fmt.Errorf("Context file '%s' looks binary; remove it from context_files or set context_binary to skip", p.label)
+				}
+				log.Printf("WARN: skipping binary context file '%s'", p.label)
+				continue
+			}
+
+			total += int64(len(data))
+			if limits.MaxTotalBytes > 0 && total > limits.MaxTotalBytes {
+				return nil, fmt.Errorf("Context files exceed the total limit of %d bytes at '%s' (context_max_total_bytes)",
+					limits.MaxTotalBytes, p.label)
+			}
+
+			files = append(files, contextFile{Label: p.label, Data: data})
+		}
+	}
+
+	return files, nil
+}
+
+// runContextCommand runs a context_commands entry through the platform shell
+// in workingDir and returns its stdout. A non-zero exit is not an error —
+// failing test output is often exactly the context wanted — but it is noted
+// after the output. Output longer than maxBytes keeps only its tail.
+func runContextCommand(workingDir, command string, maxBytes int64) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), contextCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = workingDir
+	cmd.WaitDelay = contextCommandWaitDelay
+
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("Context command '%s' timed out after %v", command, contextCommandTimeout)
+	}
+
+	result := string(out)
+	if maxBytes > 0 && int64(len(result)) > maxBytes {
+		// Start the tail on a rune boundary so no half character is kept.
+		cut := int64(len(result)) - maxBytes
+		for cut < int64(len(result)) && !utf8.RuneStart(result[cut]) {
+			cut++
+		}
+		result = "[... truncated ...]\n" + result[cut:]
+	}
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", fmt.Errorf("run context command '%s': %w", command, err)
+		}
+		result = strings.TrimRight(result, "\n") + fmt.Sprintf("\n[exit status %d]", exitErr.ExitCode())
+	}
+	return result, nil
+}
+
+// contextPath pairs a resolved filesystem path with its display label.
+type contextPath struct {
+	path  string
+	label string
+}
+
+// expandContextRef resolves a single context_files entry.
+func expandContextRef(workingDir, ref string) ([]contextPath, error) {
+	resolved := ref
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(workingDir, resolved)
+	}
+
+	if !hasGlobMeta(ref) {
+		info, err := os.Stat(resolved)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("Context file '%s' not found in %s", ref, workingDir)
+			}
+			return nil, fmt.Errorf("stat context file '%s': %w", ref, err)
+		}
+		if !info.IsDir() {
+			return []contextPath{{path: resolved, label: ref}}, nil
+		}
+
+		entries, err := os.ReadDir(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("read context directory '%s': %w", ref, err)
+		}
+		var out []contextPath
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			out = append(out, contextPath{
+				path:  filepath.Join(resolved, e.Name()),
+				label: filepath.ToSlash(filepath.Join(ref, e.Name())),
+			})
+		}
+		return out, nil
+	}
+
+	matches, err := globContext(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("context pattern '%s': %w", ref, err)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("Context pattern '%s' matched no files in %s", ref, workingDir)
+	}
+
+	out := make([]contextPath, 0, len(matches))
+	for _, m := range matches {
+		label := m
+		if rel, err := filepath.Rel(workingDir, m); err == nil && !strings.HasPrefix(rel, "..") {
+			label = filepath.ToSlash(rel)
+		}
+		out = append(out, contextPath{path: m, label: label})
+	}
+	return out, nil
+}
+
+// globContext returns the regular files matching pattern, which may contain
+// "**" segments matching any number of directories. Results are sorted.
+func globContext(pattern string) ([]string, error) {
+	pattern = filepath.Clean(pattern)
+	segs := strings.Split(filepath.ToSlash(pattern), "/")
+
+	// Walk from the longest literal prefix to keep the traversal small.
+	prefixLen := 0
+	for prefixLen < len(segs) && !hasGlobMeta(segs[prefixLen]) {
+		prefixLen++
+	}
+	root := filepath.FromSlash(strings.Join(segs[:prefixLen], "/"))
+	if root == "" {
+		root = "/"
+	}
+	rest := segs[prefixLen:]
+
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		ok, err := matchSegments(rest, strings.Split(filepath.ToSlash(rel), "/"))
+		if err != nil {
+			return err
+		}
+		if ok {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" pattern segment matches zero or more path segments.
+func matchSegments(pattern, path []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				ok, err := matchSegments(pattern[1:], path[i:])
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(path) == 0 {
+			return false, nil
+		}
+		ok, err := filepath.Match(pattern[0], path[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0, nil
+}
+
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// isBinary reports whether data looks like a binary file: it contains a NUL
+// byte in its first 8KB or is not valid UTF-8.
+func isBinary(data []byte) bool {
+	head := data
+	if len(head) > 8192 {
+		head = head[:8192]
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return true
+	}
+	return !utf8.Valid(data)
+}
@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// verifyTimeout bounds a task's verify command.
+const verifyTimeout = 10 * time.Minute
+
+// blockingDependency returns the first dependency of task that is not done
+// yet, with its status. States of tasks outside the current selection are
+// read from stateDir; a dependency that has never run counts as pending.
+func blockingDependency(task *queue.Task, states map[string]*queue.TaskState, stateDir string) (string, string) {
+	for _, id := range task.Dependencies() {
+		st, ok := states[id]
+		if !ok {
+			st, _ = queue.LoadState(stateDir, id)
+		}
+		status := queue.StatusPending
+		if st != nil {
+			status = st.Status
+		}
+		if status != queue.StatusDone {
+			return id, status
+		}
+	}
+	return "", ""
+}
+
+// runVerify runs a task's verify command through the platform shell in
+// workingDir. It returns an error describing the failure, including the
+// tail of the command's output, when the command does not exit 0.
+func runVerify(workingDir, command string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = workingDir
+
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("verify command timed out after %v", verifyTimeout)
+	}
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("run verify command: %w", err)
+	}
+	msg := fmt.Sprintf("verify command exited with status %d", exitErr.ExitCode())
+	if last := lastLine(string(out)); last != "" {
+		msg += ": " + last
+	}
+	return errors.New(msg)
+}
+
+// lastLine returns the last non-blank line of s, capped at 200 bytes.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\r\n\t "), "\n")
+	last := strings.TrimSpace(lines[len(lines)-1])
+	if len(last) > 200 {
+		last = last[:200] + "..."
+	}
+	return last
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ownFiles excludes autopilot's own files in the working directory (the
+// directory lock, project-local tasks) from diffs.
+const ownFiles = ":(exclude).autopilot"
+
+// maxDiffStatFiles bounds the per-file lines kept from git diff --stat.
+const maxDiffStatFiles = 50
+
+// diffSummary describes what a task changed in its working directory since
+// the pre-run commit.
+type diffSummary struct {
+	Stat      string // git diff --stat output, per-file lines capped
+	Untracked int    // new files git does not track yet
+}
+
+// gitDiffSummary compares the working tree in dir against base, including
+// commits made since and new untracked files. It returns false when dir is
+// not a git repository or base is unknown.
+func gitDiffSummary(dir, base string) (diffSummary, bool) {
+	if base == "" {
+		return diffSummary{}, false
+	}
+	stat, err := gitOutput(dir, "diff", "--stat", base, "--", ".", ownFiles)
+	if err != nil {
+		return diffSummary{}, false
+	}
+	untracked, err := gitOutput(dir, "ls-files", "--others", "--exclude-standard", "--", ".", ownFiles)
+	if err != nil {
+		return diffSummary{}, false
+	}
+
+	d := diffSummary{Stat: capDiffStat(stat)}
+	if untracked != "" {
+		d.Untracked = len(strings.Split(untracked, "\n"))
+	}
+	return d, true
+}
+
+// Empty reports whether nothing changed.
+func (d diffSummary) Empty() bool {
+	return d.Stat == "" && d.Untracked == 0
+}
+
+// Line summarizes the diff in one line, e.g. "2 files changed, 10
+// insertions(+), 1 untracked file".
+func (d diffSummary) Line() string {
+	if d.Empty() {
+		return "no changes"
+	}
+	var parts []string
+	if d.Stat != "" {
+		lines := strings.Split(d.Stat, "\n")
+		parts = append(parts, strings.TrimSpace(lines[len(lines)-1]))
+	}
+	if d.Untracked == 1 {
+		parts = append(parts, "1 untracked file")
+	} else if d.Untracked > 1 {
+		parts = append(parts, fmt.Sprintf("%d untracked files", d.Untracked))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// capDiffStat keeps the first maxDiffStatFiles file lines of a --stat
+// output plus its closing summary line.
+func capDiffStat(stat string) string {
+	lines := strings.Split(stat, "\n")
+	if len(lines) <= maxDiffStatFiles+1 {
+		return stat
+	}
+	kept := append([]string(nil), lines[:maxDiffStatFiles]...)
+	kept = append(kept, fmt.Sprintf(" ... %d more files", len(lines)-1-maxDiffStatFiles), lines[len(lines)-1])
+	return strings.Join(kept, "\n")
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
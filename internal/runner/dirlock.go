@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// Working-directory lock policies (config key dir_lock_policy).
+const (
+	DirLockWait = "wait"
+	DirLockSkip = "skip"
+	DirLockOff  = "off"
+)
+
+// dirLockRetryDelay is how long a task is deferred under the "wait" policy
+// before the working directory lock is tried again.
+const dirLockRetryDelay = time.Minute
+
+// dirLockPath returns the advisory lock guarding a task's working directory.
+func dirLockPath(workingDir string) string {
+	return filepath.Join(workingDir, ".autopilot", "dir.lock")
+}
+
+// dirLockPolicy returns the configured policy, defaulting to wait.
+func (r *Runner) dirLockPolicy() string {
+	if r.Config == nil || r.Config.DirLockPolicy == "" {
+		return DirLockWait
+	}
+	return r.Config.DirLockPolicy
+}
+
+// acquireDirLock takes the working directory lock for task. It returns
+// ok=false only when another process holds the lock; a nil lock with ok=true
+// means the policy is off or the lock could not be created, in which case
+// the task runs unguarded.
+func (r *Runner) acquireDirLock(task *queue.Task) (*lock.Lock, bool) {
+	if r.dirLockPolicy() == DirLockOff || task.WorkingDir == "" {
+		return nil, true
+	}
+
+	path := dirLockPath(task.WorkingDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("WARN: working directory lock for %s: %v", task.ID, err)
+		return nil, true
+	}
+	lk, ok, err := lock.TryLock(path)
+	if err != nil {
+		log.Printf("WARN: working directory lock for %s: %v", task.ID, err)
+		return nil, true
+	}
+	return lk, ok
+}
+
+// deferForDirLock handles a task whose working directory is locked by
+// another instance. Under "wait" the task is parked as waiting for
+// dirLockRetryDelay without consuming an attempt; under "skip" it is
+// remembered in skipped and left pending for the rest of the run.
+func (r *Runner) deferForDirLock(task *queue.Task, state *queue.TaskState, stateDir string, skipped map[string]bool) {
+	holder := "another instance"
+	if info, err := lock.ReadInfo(dirLockPath(task.WorkingDir)); err == nil && info.PID > 0 {
+		holder = fmt.Sprintf("PID %d", info.PID)
+	}
+
+	if r.dirLockPolicy() == DirLockSkip {
+		log.Printf("Task %s: %s is in use by %s; skipping for this run", task.ID, task.WorkingDir, holder)
+		skipped[task.ID] = true
+		return
+	}
+
+	resumeAt := time.Now().Add(dirLockRetryDelay).UTC()
+	state.Status = queue.StatusWaiting
+	state.ResumeAt = &resumeAt
+	if err := r.saveState(stateDir, state); err != nil {
+		log.Printf("WARN: save state for %s: %v", task.ID, err)
+	}
+	log.Printf("Task %s: %s is in use by %s; retrying at %s", task.ID, task.WorkingDir, holder, resumeAt.Local().Format("15:04:05"))
+}
@@ -0,0 +1,34 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+)
+
+// diskRecheckInterval is how often a queue paused for low disk space checks
+// again. Task file and control command changes wake it sooner.
+const diskRecheckInterval = time.Minute
+
+// lowDisk returns why the queue should pause: the first of dirs with less
+// free space than min_free_disk_mb, or "" when all have enough. A build
+// filling the disk would fail the task and could truncate state writes.
+// Directories whose free space cannot be read are not checked.
+func (r *Runner) lowDisk(dirs ...string) string {
+	if r.Config == nil || r.Config.MinFreeDiskMB <= 0 {
+		return ""
+	}
+	min := uint64(r.Config.MinFreeDiskMB) << 20
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		free, err := fileutil.FreeSpace(dir)
+		if err != nil || free >= min {
+			continue
+		}
+		return fmt.Sprintf("%s has %d MB free, below min_free_disk_mb %d", dir, free>>20, r.Config.MinFreeDiskMB)
+	}
+	return ""
+}
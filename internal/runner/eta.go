@@ -0,0 +1,48 @@
+package runner
+
+import (
+	"log"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/estimate"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// recordDuration adds a completed task's running time to the history that
+// queue ETAs are estimated from. dir is the task's working directory, which
+// for a review-mode task is not the worktree it ran in.
+func (r *Runner) recordDuration(task *queue.Task, dir string, state *queue.TaskState) {
+	if r.Paths.Home == "" {
+		return
+	}
+	run := estimate.Run{
+		At:         time.Now().UTC(),
+		TaskID:     task.ID,
+		Tags:       task.Tags,
+		WorkingDir: dir,
+		Duration:   estimate.RunTime(state),
+	}
+	if err := estimate.Record(r.Paths.DurationHistory(), run); err != nil {
+		log.Printf("WARN: record task duration: %v", err)
+	}
+}
+
+// queueETA predicts when the selected tasks still to run will be finished
+// if the queue resumes at start. It returns false when nothing is left or
+// there is no history to estimate from.
+func (r *Runner) queueETA(start time.Time) (time.Time, bool) {
+	runs, err := estimate.Load(r.Paths.DurationHistory())
+	if err != nil || len(runs) == 0 {
+		return time.Time{}, false
+	}
+	tasks, err := queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir)
+	if err != nil {
+		return time.Time{}, false
+	}
+	states, _ := queue.LoadAllStates(r.Paths.StateDir())
+	rem := estimate.New(runs).Queue(r.selectTasks(tasks), states, time.Now())
+	if rem.Tasks == 0 {
+		return time.Time{}, false
+	}
+	return start.Add(rem.Duration), true
+}
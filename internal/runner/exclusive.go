@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"log"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// acquireRunLock takes the run lock task needs across instances: shared
+// for an ordinary task, exclusive for an exclusive one. An exclusive task
+// first closes the drain gate so no new task starts anywhere, then waits
+// for the running ones to finish; the gate stays closed across deferrals
+// until the task has run or left the queue. It returns ok=false when the
+// task has to wait; a nil lock with ok=true means the lock could not be
+// created and the task runs unguarded.
+func (r *Runner) acquireRunLock(task *queue.Task) (*lock.Lock, bool) {
+	if r.Paths.ConfigDir == "" {
+		return nil, true
+	}
+
+	if !task.Exclusive {
+		if r.drainGate != nil {
+			// This instance is draining for its own exclusive task.
+			return nil, false
+		}
+		gate, ok, err := lock.TryShared(r.Paths.DrainLockPath())
+		if err != nil {
+			log.Printf("WARN: drain lock for %s: %v", task.ID, err)
+			return nil, true
+		}
+		if !ok {
+			return nil, false
+		}
+		gate.Release()
+		lk, ok, err := lock.TryShared(r.Paths.RunLockPath())
+		if err != nil {
+			log.Printf("WARN: run lock for %s: %v", task.ID, err)
+			return nil, true
+		}
+		return lk, ok
+	}
+
+	if r.drainGate == nil {
+		gate, ok, err := lock.TryLock(r.Paths.DrainLockPath())
+		if err != nil {
+			log.Printf("WARN: drain lock for %s: %v", task.ID, err)
+			return nil, true
+		}
+		if !ok {
+			// Another exclusive task is draining or running.
+			return nil, false
+		}
+		r.drainGate, r.drainFor = gate, task.ID
+	} else if r.drainFor != task.ID {
+		return nil, false
+	}
+	lk, ok, err := lock.TryLock(r.Paths.RunLockPath())
+	if err != nil {
+		log.Printf("WARN: run lock for %s: %v", task.ID, err)
+		return nil, true
+	}
+	return lk, ok
+}
+
+// deferForRunLock parks a task that cannot start while tasks are draining
+// for an exclusive one, or while an exclusive task runs, as waiting for
+// dirLockRetryDelay without consuming an attempt.
+func (r *Runner) deferForRunLock(task *queue.Task, state *queue.TaskState, stateDir string) {
+	resumeAt := time.Now().Add(dirLockRetryDelay).UTC()
+	state.Status = queue.StatusWaiting
+	state.ResumeAt = &resumeAt
+	if err := r.saveState(stateDir, state); err != nil {
+		log.Printf("WARN: save state for %s: %v", task.ID, err)
+	}
+	at := resumeAt.Local().Format("15:04:05")
+	if task.Exclusive {
+		log.Printf("Task %s is exclusive: waiting for running tasks to drain; retrying at %s", task.ID, at)
+	} else {
+		log.Printf("Task %s: an exclusive task is draining the queue or running; retrying at %s", task.ID, at)
+	}
+}
+
+// releaseDrainGate reopens the drain gate unless the exclusive task it was
+// closed for is still among queued, the tasks the queue may yet start.
+func (r *Runner) releaseDrainGate(queued ...[]queue.Task) {
+	if r.drainGate == nil {
+		return
+	}
+	for _, tasks := range queued {
+		for _, t := range tasks {
+			if t.ID == r.drainFor && t.Exclusive {
+				return
+			}
+		}
+	}
+	r.drainGate.Release()
+	r.drainGate, r.drainFor = nil, ""
+}
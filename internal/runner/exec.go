@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// RunOnce executes a single ad-hoc task in the foreground with the same
+// retry and rate-limit handling as the queue, but without queueing it:
+// state lives in a temporary directory that is removed on return, and the
+// runner lock is not taken, so it can be used alongside a running queue.
+// The task log, duration and rate-limit history and event log records are
+// still written as for any task; orphan state cleanup removes the log.
+// It returns an exit code suitable for os.Exit.
+func (r *Runner) RunOnce(task *queue.Task) int {
+	stateDir, err := os.MkdirTemp("", "claude-autopilot-exec-")
+	if err != nil {
+		log.Printf("ERROR: create temporary state dir: %v", err)
+		return ExitFatal
+	}
+	defer os.RemoveAll(stateDir)
+
+	r.promptPatterns = append([]string(nil), r.PromptPatterns...)
+
+	if !r.YesFlag && !r.checkFirstRun() {
+		fmt.Fprintln(os.Stderr, "First-run acknowledgement declined. Exiting.")
+		return ExitOK
+	}
+
+	r.watchSignals()
+
+	state := &queue.TaskState{ID: task.ID, Status: queue.StatusPending}
+	for {
+		if r.ShuttingDown.Load() {
+			return ExitSignal
+		}
+
+		dirLock, ok := r.acquireDirLock(task)
+		if !ok {
+			if r.dirLockPolicy() == DirLockSkip {
+				fmt.Fprintf(os.Stderr, "%s is in use by another claude-autopilot instance.\n", task.WorkingDir)
+				return ExitLocked
+			}
+			log.Printf("%s is in use by another claude-autopilot instance; waiting", task.WorkingDir)
+			if !r.sleepUntil(time.Now().Add(dirLockRetryDelay), task, state.Attempt) {
+				return ExitSignal
+			}
+			continue
+		}
+
+		code := r.executeTask(task, state, stateDir)
+		if dirLock != nil {
+			dirLock.Release()
+		}
+		if code == ExitSignal {
+			return ExitSignal
+		}
+
+		switch state.Status {
+		case queue.StatusDone:
+			return ExitOK
+		case queue.StatusWaiting:
+			if state.ResumeAt != nil && !r.sleepUntil(*state.ResumeAt, task, state.Attempt) {
+				return ExitWaitAbandoned
+			}
+		default:
+			return ExitFailed
+		}
+	}
+}
+
+// sleepUntil waits until t, showing a countdown for task. It returns false
+// if a shutdown was requested while waiting.
+func (r *Runner) sleepUntil(t time.Time, task *queue.Task, attempt int) bool {
+	if r.ShuttingDown.Load() {
+		return false
+	}
+	ov := &overview{resumeAt: t, waiting: []waitingEntry{{id: task.ID, attempt: attempt, resumeAt: t}}}
+	return r.waitForWake(t, nil, ov, false)
+}
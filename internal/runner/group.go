@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// acquireGroupLock takes the lock of task's concurrency group. It returns
+// ok=false only when another process holds it; a nil lock with ok=true
+// means the task has no group or the lock could not be created, in which
+// case the task runs unguarded.
+func (r *Runner) acquireGroupLock(task *queue.Task) (*lock.Lock, bool) {
+	if task.Group == "" {
+		return nil, true
+	}
+
+	path := r.Paths.GroupLockPath(task.Group)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Printf("WARN: concurrency group lock for %s: %v", task.ID, err)
+		return nil, true
+	}
+	lk, ok, err := lock.TryLock(path)
+	if err != nil {
+		log.Printf("WARN: concurrency group lock for %s: %v", task.ID, err)
+		return nil, true
+	}
+	return lk, ok
+}
+
+// deferForGroup parks a task whose concurrency group is busy in another
+// instance as waiting for dirLockRetryDelay, without consuming an attempt.
+// Unlike a busy working directory there is no skip policy: the group exists
+// to order the tasks, not to drop them.
+func (r *Runner) deferForGroup(task *queue.Task, state *queue.TaskState, stateDir string) {
+	holder := "another instance"
+	if info, err := lock.ReadInfo(r.Paths.GroupLockPath(task.Group)); err == nil && info.PID > 0 {
+		holder = fmt.Sprintf("PID %d", info.PID)
+	}
+
+	resumeAt := time.Now().Add(dirLockRetryDelay).UTC()
+	state.Status = queue.StatusWaiting
+	state.ResumeAt = &resumeAt
+	if err := r.saveState(stateDir, state); err != nil {
+		log.Printf("WARN: save state for %s: %v", task.ID, err)
+	}
+	log.Printf("Task %s: concurrency group %s is in use by %s; retrying at %s", task.ID, task.Group, holder, resumeAt.Local().Format("15:04:05"))
+}
+
+// releaseLocks releases the locks a picked task holds; nil ones are
+// skipped.
+func releaseLocks(locks ...*lock.Lock) {
+	for _, lk := range locks {
+		if lk != nil {
+			lk.Release()
+		}
+	}
+}
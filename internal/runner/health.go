@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+)
+
+// healthInterval is how often health.json is rewritten, so its updated_at
+// keeps moving while the runner is alive even when nothing else changes.
+const healthInterval = 5 * time.Second
+
+// Runner phases reported in health.json.
+const (
+	PhaseStarting = "starting"
+	PhaseRunning  = "running" // a task's agent is running
+	PhaseWaiting  = "waiting" // every remaining task waits for a rate-limit reset
+	PhasePaused   = "paused"  // new tasks are held back (usage pacing, low disk space)
+	PhaseIdle     = "idle"    // watch mode with nothing to run
+	PhaseStopped  = "stopped"
+)
+
+// Health is the runner snapshot in health.json, for monitors that cannot
+// use the HTTP API. A runner whose updated_at is older than a few
+// healthInterval ticks, or whose last_output_at stops moving while running,
+// is likely wedged.
+type Health struct {
+	PID          int                  `json:"pid"`
+	Phase        string               `json:"phase"`
+	StartedAt    time.Time            `json:"started_at"`
+	UpdatedAt    time.Time            `json:"updated_at"`
+	TaskID       string               `json:"task_id,omitempty"`
+	Attempt      int                  `json:"attempt,omitempty"`
+	LastOutputAt *time.Time           `json:"last_output_at,omitempty"`
+	Progress     *transcript.Progress `json:"progress,omitempty"` // of the running task, from its stream-json tool calls
+	NextResumeAt *time.Time           `json:"next_resume_at,omitempty"`
+	Reason       string               `json:"reason,omitempty"`
+}
+
+// healthState guards the Health being reported and serializes its writes.
+type healthState struct {
+	mu sync.Mutex
+	h  Health
+}
+
+// setPhase moves the snapshot to phase, clearing the details of the
+// previous one, lets update fill in the new details, and writes it.
+func (r *Runner) setPhase(phase string, update func(h *Health)) {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+	r.health.h = Health{PID: os.Getpid(), Phase: phase, StartedAt: r.health.h.StartedAt}
+	if update != nil {
+		update(&r.health.h)
+	}
+	r.writeHealthLocked()
+}
+
+// noteOutput records when the running task last produced output. The next
+// tick writes it.
+func (r *Runner) noteOutput(at time.Time) {
+	at = at.UTC()
+	r.health.mu.Lock()
+	r.health.h.LastOutputAt = &at
+	r.health.mu.Unlock()
+}
+
+// noteProgress records the running task's progress. The next tick writes
+// it.
+func (r *Runner) noteProgress(p transcript.Progress) {
+	r.health.mu.Lock()
+	r.health.h.Progress = &p
+	r.health.mu.Unlock()
+}
+
+// writeHealth rewrites health.json with a fresh updated_at.
+func (r *Runner) writeHealth() {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+	r.writeHealthLocked()
+}
+
+func (r *Runner) writeHealthLocked() {
+	if r.Paths.Home == "" {
+		return
+	}
+	r.health.h.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(r.health.h, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := fileutil.AtomicWrite(r.Paths.HealthFile(), append(data, '\n'), 0644); err != nil {
+		log.Printf("WARN: write health file: %v", err)
+	}
+}
+
+// ReadHealth reads the snapshot a runner wrote to path.
+func ReadHealth(path string) (*Health, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var h Health
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
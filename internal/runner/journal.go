@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"log"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// The event log is rotated at eventLogMaxBytes, keeping eventLogBackups
+// older files.
+const (
+	eventLogMaxBytes = 10 * 1024 * 1024
+	eventLogBackups  = 5
+)
+
+// openJournal starts the append-only event log. Failing to open it only
+// costs the record, so the run goes on without one.
+func (r *Runner) openJournal() {
+	w, err := events.OpenLog(r.Paths.EventLog(), eventLogMaxBytes, eventLogBackups)
+	if err != nil {
+		log.Printf("WARN: %v; events.jsonl will not be written", err)
+		return
+	}
+	r.journal = w
+}
+
+// record writes ev to the event log only.
+func (r *Runner) record(ev events.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	r.journal.Emit(ev)
+}
+
+// saveState saves st and records its status change, if any, in the event
+// log.
+func (r *Runner) saveState(stateDir string, st *queue.TaskState) error {
+	if err := queue.SaveState(stateDir, st); err != nil {
+		return err
+	}
+	r.observeState(st)
+	return nil
+}
+
+// observeState records a state_changed event when st's status differs
+// from the one the runner last saw for the task, which also catches
+// changes made outside the runner during the run. The first sighting of a
+// task is only remembered.
+func (r *Runner) observeState(st *queue.TaskState) {
+	if r.statuses == nil {
+		r.statuses = make(map[string]string)
+	}
+	prev, seen := r.statuses[st.ID]
+	r.statuses[st.ID] = st.Status
+	if !seen || prev == st.Status {
+		return
+	}
+	r.record(events.Event{Type: events.StateChanged, TaskID: st.ID, Attempt: st.Attempt, From: prev, Status: st.Status})
+}
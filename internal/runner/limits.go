@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// limits are the resource limits a task sets on its agent process:
+// scheduling priority, CPU time and memory. applyLimits enforces them in
+// the way the platform allows.
+type limits struct {
+	nice    int
+	cpuTime time.Duration
+	memory  uint64 // bytes
+}
+
+// taskLimits returns the limits task sets.
+func taskLimits(task *queue.Task) (limits, error) {
+	l := limits{nice: task.Nice}
+	if task.MaxCPUTime != "" {
+		d, err := time.ParseDuration(task.MaxCPUTime)
+		if err != nil {
+			return l, fmt.Errorf("max_cpu_time: %w", err)
+		}
+		l.cpuTime = d
+	}
+	if task.MaxMemory != "" {
+		n, err := parseSize(task.MaxMemory)
+		if err != nil {
+			return l, fmt.Errorf("max_memory: %w", err)
+		}
+		l.memory = n
+	}
+	return l, nil
+}
+
+func (l limits) empty() bool {
+	return l.nice == 0 && l.cpuTime == 0 && l.memory == 0
+}
+
+// parseSize parses a byte size with an optional b, k, m or g suffix (powers
+// of 1024), as docker does.
+func parseSize(s string) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	shift := 0
+	switch {
+	case strings.HasSuffix(s, "k"):
+		shift = 10
+	case strings.HasSuffix(s, "m"):
+		shift = 20
+	case strings.HasSuffix(s, "g"):
+		shift = 30
+	}
+	s = strings.TrimRight(s, "bkmg")
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,37 @@
+package runner
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyLimits sets the nice value and rlimits of the started process pid.
+// Processes it starts from then on inherit them. RLIMIT_DATA bounds each
+// process's heap and private mappings rather than the whole tree, and
+// unlike RLIMIT_AS it leaves the address space runtimes like V8 reserve up
+// front alone. RLIMIT_CPU sends SIGXCPU at the limit and SIGKILL shortly
+// after.
+func applyLimits(pid int, l limits) (release func(), err error) {
+	release = func() {}
+	if l.nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, l.nice); err != nil {
+			return release, fmt.Errorf("set nice %d: %w", l.nice, err)
+		}
+	}
+	if l.cpuTime > 0 {
+		secs := uint64(l.cpuTime.Seconds())
+		if secs == 0 {
+			secs = 1
+		}
+		if err := unix.Prlimit(pid, unix.RLIMIT_CPU, &unix.Rlimit{Cur: secs, Max: secs + 10}, nil); err != nil {
+			return release, fmt.Errorf("set cpu time limit: %w", err)
+		}
+	}
+	if l.memory > 0 {
+		if err := unix.Prlimit(pid, unix.RLIMIT_DATA, &unix.Rlimit{Cur: l.memory, Max: l.memory}, nil); err != nil {
+			return release, fmt.Errorf("set memory limit: %w", err)
+		}
+	}
+	return release, nil
+}
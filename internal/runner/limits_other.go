@@ -0,0 +1,26 @@
+//go:build !linux && !windows
+
+package runner
+
+import (
+	"fmt"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyLimits sets the nice value of the started process pid. CPU time and
+// memory limits need prlimit, which this platform does not have for
+// another process.
+func applyLimits(pid int, l limits) (release func(), err error) {
+	release = func() {}
+	if l.nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, l.nice); err != nil {
+			return release, fmt.Errorf("set nice %d: %w", l.nice, err)
+		}
+	}
+	if l.cpuTime > 0 || l.memory > 0 {
+		return release, fmt.Errorf("max_cpu_time and max_memory are not supported on %s", runtime.GOOS)
+	}
+	return release, nil
+}
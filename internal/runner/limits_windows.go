@@ -0,0 +1,56 @@
+//go:build windows
+
+package runner
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// applyLimits puts the started process pid in a job object carrying the
+// limits. Processes it starts join the job, so the memory and CPU time
+// limits cover the whole tree. nice maps to a priority class: 1-9 below
+// normal, 10 and up idle. release closes the job handle once the attempt
+// is over.
+func applyLimits(pid int, l limits) (release func(), err error) {
+	release = func() {}
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return release, fmt.Errorf("create job object: %w", err)
+	}
+	release = func() { windows.CloseHandle(job) }
+
+	var info windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION
+	switch {
+	case l.nice >= 10:
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PRIORITY_CLASS
+		info.BasicLimitInformation.PriorityClass = windows.IDLE_PRIORITY_CLASS
+	case l.nice > 0:
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_PRIORITY_CLASS
+		info.BasicLimitInformation.PriorityClass = windows.BELOW_NORMAL_PRIORITY_CLASS
+	}
+	if l.cpuTime > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_TIME
+		info.BasicLimitInformation.PerJobUserTimeLimit = l.cpuTime.Nanoseconds() / 100
+	}
+	if l.memory > 0 {
+		info.BasicLimitInformation.LimitFlags |= windows.JOB_OBJECT_LIMIT_JOB_MEMORY
+		info.JobMemoryLimit = uintptr(l.memory)
+	}
+	if _, err := windows.SetInformationJobObject(job, windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)), uint32(unsafe.Sizeof(info))); err != nil {
+		return release, fmt.Errorf("set job limits: %w", err)
+	}
+
+	proc, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return release, fmt.Errorf("open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(proc)
+	if err := windows.AssignProcessToJobObject(job, proc); err != nil {
+		return release, fmt.Errorf("assign process to job: %w", err)
+	}
+	return release, nil
+}
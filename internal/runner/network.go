@@ -0,0 +1,28 @@
+package runner
+
+import (
+	"net"
+	"time"
+)
+
+// networkRecheckInterval is how often a queue paused for a network outage
+// probes again.
+const networkRecheckInterval = 30 * time.Second
+
+// networkProbeTimeout bounds one reachability probe.
+const networkProbeTimeout = 5 * time.Second
+
+// networkReachable reports whether the network_probe address accepts a TCP
+// connection. With no probe configured the network is assumed up, so
+// network failures count as ordinary ones.
+func (r *Runner) networkReachable() bool {
+	if r.Config == nil || r.Config.NetworkProbe == "" {
+		return true
+	}
+	conn, err := net.DialTimeout("tcp", r.Config.NetworkProbe, networkProbeTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+)
+
+// expandSummaries replaces each {{task:<id>.summary}} in prompt with the
+// summary exported by that task. Every referenced task must be done and
+// have export_summary set.
+func expandSummaries(prompt, stateDir string) (string, error) {
+	summaries := make(map[string]string)
+	for _, id := range queue.SummaryRefs(prompt) {
+		st, err := queue.LoadState(stateDir, id)
+		if err != nil {
+			return "", fmt.Errorf("load state for referenced task %s: %w", id, err)
+		}
+		switch {
+		case st == nil:
+			return "", fmt.Errorf("prompt references task %s, which is unknown or has not run", id)
+		case st.Status != queue.StatusDone:
+			return "", fmt.Errorf("prompt references task %s, which is %s rather than done", id, st.Status)
+		case st.Summary == "":
+			return "", fmt.Errorf("prompt references task %s, which exported no summary (set export_summary: true on it)", id)
+		}
+		summaries[id] = st.Summary
+	}
+	if len(summaries) == 0 {
+		return prompt, nil
+	}
+	return queue.ExpandSummaryRefs(prompt, func(id string) string { return summaries[id] }), nil
+}
+
+// finalSummary extracts the last assistant message from a session's
+// stdout, preferring the result message's text.
+func finalSummary(stdoutLines []string) string {
+	return strings.TrimSpace(transcript.LastText(transcript.Parse(stdoutLines)))
+}
@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+)
+
+// The overview lists at most overviewWaiting waiting tasks and
+// overviewFailures recent failures.
+const (
+	overviewWaiting  = 5
+	overviewFailures = 3
+	overviewReason   = 60 // longer failure reasons are cut, so lines do not wrap
+)
+
+// overview is the status block shown while every task is waiting: the
+// waiting tasks by resume time, how many tasks are pending behind
+// dependencies or schedules, and the latest failures.
+type overview struct {
+	resumeAt time.Time // when the runner wakes
+	eta      string    // predicted end of the queue, if known
+	waiting  []waitingEntry
+	pending  int
+	failures []failureEntry
+}
+
+type waitingEntry struct {
+	id       string
+	attempt  int
+	resumeAt time.Time
+}
+
+type failureEntry struct {
+	id     string
+	at     time.Time
+	reason string
+}
+
+// newOverview builds the overview from the loaded tasks and their states.
+func newOverview(resumeAt time.Time, tasks []queue.Task, states map[string]*queue.TaskState) *overview {
+	o := &overview{resumeAt: resumeAt}
+	for _, t := range tasks {
+		st := states[t.ID]
+		switch {
+		case st == nil || st.Status == queue.StatusPending:
+			o.pending++
+		case st.Status == queue.StatusWaiting && st.ResumeAt != nil:
+			o.waiting = append(o.waiting, waitingEntry{id: t.ID, attempt: st.Attempt, resumeAt: *st.ResumeAt})
+		case st.Status == queue.StatusFailed:
+			f := failureEntry{id: t.ID}
+			if st.EndedAt != nil {
+				f.at = *st.EndedAt
+			}
+			if n := len(st.Attempts); n > 0 {
+				f.reason = firstNonEmpty(st.Attempts[n-1].Failure, st.Attempts[n-1].Reason)
+			}
+			o.failures = append(o.failures, f)
+		}
+	}
+	sort.SliceStable(o.waiting, func(i, j int) bool { return o.waiting[i].resumeAt.Before(o.waiting[j].resumeAt) })
+	sort.SliceStable(o.failures, func(i, j int) bool { return o.failures[i].at.After(o.failures[j].at) })
+	return o
+}
+
+// lines renders the overview at now.
+func (o *overview) lines(now time.Time) []string {
+	head := "Waiting — next resume " + ui.Relative(o.resumeAt.Sub(now))
+	if o.eta != "" {
+		head += "; queue done ~" + o.eta
+	}
+	lines := []string{head}
+
+	for i, w := range o.waiting {
+		if i == overviewWaiting {
+			lines = append(lines, fmt.Sprintf("  … and %d more waiting", len(o.waiting)-i))
+			break
+		}
+		lines = append(lines, fmt.Sprintf("  %-30s attempt %-3d resumes %s (%s)",
+			w.id, w.attempt, clock(now, w.resumeAt), ui.Relative(w.resumeAt.Sub(now))))
+	}
+	if o.pending > 0 {
+		lines = append(lines, fmt.Sprintf("Pending: %d (waiting on dependencies or schedules)", o.pending))
+	}
+	if len(o.failures) > 0 {
+		lines = append(lines, "Recent failures:")
+		for i, f := range o.failures {
+			if i == overviewFailures {
+				break
+			}
+			at := "-"
+			if !f.at.IsZero() {
+				at = clock(now, f.at)
+			}
+			reason := f.reason
+			if len(reason) > overviewReason {
+				reason = reason[:overviewReason-3] + "..."
+			}
+			lines = append(lines, strings.TrimRight(fmt.Sprintf("  %-30s %-12s %s", f.id, at, reason), " "))
+		}
+	}
+	return lines
+}
+
+// show prints the overview. On a terminal it redraws in place over the
+// drawn lines printed last time and returns how many lines it drew now.
+func (o *overview) show(now time.Time, drawn int) int {
+	if ui.Quiet() {
+		return 0
+	}
+	lines := o.lines(now)
+	if !ui.Interactive() {
+		ui.Println(strings.Join(lines, "\n"))
+		return 0
+	}
+	if drawn > 0 {
+		ui.Printf("\033[%dA\033[J", drawn) // back to the block's first line, then clear
+	}
+	ui.Println(strings.Join(lines, "\n"))
+	return len(lines)
+}
+
+// clock formats t as a time of day in the display time zone, with the
+// date when it is not the same day as now.
+func clock(now, t time.Time) string {
+	t, now = ui.In(t), ui.In(now)
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("Jan 02 15:04")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
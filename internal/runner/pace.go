@@ -0,0 +1,40 @@
+package runner
+
+import (
+	"log"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/usage"
+)
+
+// recordRateLimit adds an observed rate limit to the usage history used for
+// window prediction.
+func (r *Runner) recordRateLimit(taskID string, at time.Time, resetAt *time.Time) {
+	if r.Paths.Home == "" {
+		return
+	}
+	ev := usage.Event{At: at, ResetAt: resetAt, TaskID: taskID}
+	if err := usage.Record(r.Paths.RateLimitHistory(), ev); err != nil {
+		log.Printf("WARN: record rate limit: %v", err)
+	}
+}
+
+// paceUntil reports whether new task starts should be held back because now
+// is within pace_before_reset of the predicted end of the current usage
+// window, and if so until when. A task started that close to the reset is
+// likely to be cut off mid-way by the limit.
+func (r *Runner) paceUntil(now time.Time) (time.Time, bool) {
+	if r.Config == nil || r.Config.PaceBeforeReset <= 0 || r.Paths.Home == "" {
+		return time.Time{}, false
+	}
+	events, err := usage.Load(r.Paths.RateLimitHistory())
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return time.Time{}, false
+	}
+	w, ok := usage.Predict(events, r.Config.UsageWindow, now)
+	if !ok || w.End.Sub(now) > r.Config.PaceBeforeReset {
+		return time.Time{}, false
+	}
+	return w.End, true
+}
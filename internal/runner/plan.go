@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"gopkg.in/yaml.v3"
+)
+
+// maxPlanSubtasks bounds how many subtasks one plan may queue.
+const maxPlanSubtasks = 20
+
+// planInstructions wraps a plan task's prompt. The reply is parsed by
+// parsePlan.
+const planInstructions = `Do not change any files yet. Break the work described below into between 2 and %d subtasks that can each be completed in one focused session, in the order they should be done.
+
+Reply with only a JSON array in a ` + "```json" + ` block, one object per subtask:
+  {"id": "short-kebab-case-id", "title": "...", "prompt": "complete, self-contained instructions", "depends_on": ["id of an earlier subtask", ...]}
+List in depends_on every subtask whose result this one needs; leave it empty for subtasks that can start immediately.
+
+Work to plan:
+%s`
+
+// subtask is one entry of a plan reply.
+type subtask struct {
+	ID        string   `json:"id" yaml:"id"`
+	Title     string   `json:"title" yaml:"title,omitempty"`
+	Prompt    string   `json:"prompt" yaml:"prompt"`
+	DependsOn []string `json:"depends_on" yaml:"depends_on"` // always written, so a step never defaults to its predecessor
+}
+
+// planFile is the pipeline file a plan is written to.
+type planFile struct {
+	Name     string     `yaml:"name"`
+	Defaults queue.Task `yaml:"defaults"`
+	Steps    []subtask  `yaml:"steps"`
+}
+
+// planPrompt turns a plan task's prompt into a request for a plan.
+func planPrompt(prompt string) string {
+	return fmt.Sprintf(planInstructions, maxPlanSubtasks, prompt)
+}
+
+// parsePlan extracts the subtask list from a plan reply: the first ```json
+// block, or else the outermost [...] in the text.
+func parsePlan(reply string) ([]subtask, error) {
+	raw := reply
+	if i := strings.Index(raw, "```json"); i >= 0 {
+		raw = raw[i+len("```json"):]
+		if j := strings.Index(raw, "```"); j >= 0 {
+			raw = raw[:j]
+		}
+	} else if i, j := strings.Index(raw, "["), strings.LastIndex(raw, "]"); i >= 0 && j > i {
+		raw = raw[i : j+1]
+	} else {
+		return nil, errors.New("reply contains no JSON subtask list")
+	}
+
+	var subtasks []subtask
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &subtasks); err != nil {
+		return nil, fmt.Errorf("parse subtask list: %w", err)
+	}
+	switch {
+	case len(subtasks) == 0:
+		return nil, errors.New("plan has no subtasks")
+	case len(subtasks) > maxPlanSubtasks:
+		return nil, fmt.Errorf("plan has %d subtasks; at most %d are allowed", len(subtasks), maxPlanSubtasks)
+	}
+	for i := range subtasks {
+		if strings.TrimSpace(subtasks[i].Prompt) == "" {
+			return nil, fmt.Errorf("subtask %q has no prompt", subtasks[i].ID)
+		}
+		if subtasks[i].DependsOn == nil {
+			subtasks[i].DependsOn = []string{}
+		}
+	}
+	return subtasks, nil
+}
+
+// writePlan queues the subtasks of a completed plan task as a pipeline file
+// named after it in the global task directory, with the plan task's own
+// settings as defaults. It returns the file's path and the number of
+// subtasks. Nothing is written if the plan does not compile or its task IDs
+// collide with other tasks.
+func (r *Runner) writePlan(task *queue.Task, reply string) (string, int, error) {
+	subtasks, err := parsePlan(reply)
+	if err != nil {
+		return "", 0, err
+	}
+
+	defaults := *task
+	defaults.ID, defaults.Title, defaults.Prompt, defaults.Source = "", "", "", ""
+	defaults.CreatedAt = time.Time{}
+	defaults.Plan, defaults.ExportSummary, defaults.DependsOn = false, false, nil
+	defaults.Origin = queue.NewOrigin(queue.OriginPlan, task.ID)
+	for i := range subtasks {
+		subtasks[i].Prompt = queue.SealPrompt(subtasks[i].Prompt)
+	}
+
+	data, err := yaml.Marshal(planFile{Name: task.ID, Defaults: defaults, Steps: subtasks})
+	if err != nil {
+		return "", 0, fmt.Errorf("encode plan: %w", err)
+	}
+	header := fmt.Sprintf("# Generated by plan task %s on %s.\n", task.ID, time.Now().UTC().Format(time.RFC3339))
+	data = append([]byte(header), data...)
+
+	path := filepath.Join(r.Paths.TasksDir(), task.ID+".pipeline.yaml")
+	compiled, err := queue.ParsePipeline(data, path)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid plan: %w", err)
+	}
+
+	// A replan replaces the file's own tasks; any other clash would stop
+	// the queue from loading.
+	existing, err := queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir)
+	if err != nil {
+		return "", 0, fmt.Errorf("load tasks: %w", err)
+	}
+	taken := make(map[string]string, len(existing))
+	for _, t := range existing {
+		if !strings.HasPrefix(t.Source, path+"#") {
+			taken[t.ID] = t.Source
+		}
+	}
+	for _, t := range compiled {
+		if src, ok := taken[t.ID]; ok {
+			return "", 0, fmt.Errorf("subtask ID %s is already used by %s", t.ID, src)
+		}
+	}
+
+	if err := fileutil.AtomicWrite(path, data, 0644); err != nil {
+		return "", 0, fmt.Errorf("write plan: %w", err)
+	}
+	return path, len(compiled), nil
+}
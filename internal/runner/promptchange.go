@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"log"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// promptChangeReset is the prompt_change_action (and task_change_action)
+// that starts an edited task over; "warn" only logs.
+const promptChangeReset = "reset"
+
+// checkPromptChange compares a task's prompt with the one its last attempt
+// was sent. An edited task that would otherwise carry on from that attempt
+// (pending after a retry or crash, waiting, or failed) is logged once per
+// edit; with prompt_change_action reset its attempt count and session are
+// cleared and a failed task is re-queued. It reports whether st changed.
+func (r *Runner) checkPromptChange(task *queue.Task, st *queue.TaskState, warned map[string]string) bool {
+	switch st.Status {
+	case queue.StatusPending, queue.StatusWaiting, queue.StatusFailed:
+	default:
+		return false
+	}
+	hash := hashPrompt(task.Prompt)
+	if st.PromptHash == "" || st.PromptHash == hash {
+		return false
+	}
+
+	if r.Config == nil || r.Config.PromptChangeAction != promptChangeReset {
+		if warned[task.ID] != hash {
+			warned[task.ID] = hash
+			log.Printf("WARN: task %s prompt changed since attempt %d; continuing from it (set prompt_change_action=reset to start over)", task.ID, st.Attempt)
+		}
+		return false
+	}
+
+	log.Printf("Task %s prompt changed since attempt %d; resetting attempts and session", task.ID, st.Attempt)
+	st.PromptHash = hash
+	st.TaskChecksum = task.Checksum()
+	resetAttempts(st)
+	return true
+}
+
+// checkTaskChange does the same as checkPromptChange for edits to the rest
+// of a task's definition (working_dir, flags, model, verify, and so on),
+// compared by checksum, under task_change_action. A task whose prompt also
+// changed is left to checkPromptChange. A task edited while it runs is only
+// logged: the running attempt carries on with the definition it started
+// with. It reports whether st changed.
+func (r *Runner) checkTaskChange(task *queue.Task, st *queue.TaskState, warned map[string]string) bool {
+	switch st.Status {
+	case queue.StatusPending, queue.StatusWaiting, queue.StatusFailed, queue.StatusRunning:
+	default:
+		return false
+	}
+	sum := task.Checksum()
+	if st.TaskChecksum == "" || st.TaskChecksum == sum {
+		return false
+	}
+	if st.PromptHash != "" && st.PromptHash != hashPrompt(task.Prompt) {
+		return false
+	}
+
+	if st.Status == queue.StatusRunning {
+		if warned[task.ID] != sum {
+			warned[task.ID] = sum
+			log.Printf("WARN: task %s (%s) was edited while attempt %d is running; that attempt carries on with the old definition", task.ID, task.Source, st.Attempt)
+		}
+		return false
+	}
+
+	if r.Config == nil || r.Config.TaskChangeAction != promptChangeReset {
+		if warned[task.ID] != sum {
+			warned[task.ID] = sum
+			log.Printf("WARN: task %s (%s) was edited since attempt %d started; continuing from it with the new definition (set task_change_action=reset to start over)", task.ID, task.Source, st.Attempt)
+		}
+		return false
+	}
+
+	log.Printf("Task %s was edited since attempt %d started; resetting attempts and session", task.ID, st.Attempt)
+	st.TaskChecksum = sum
+	resetAttempts(st)
+	return true
+}
+
+// resetAttempts starts an edited task over: its attempt count and session
+// are cleared and a failed task is re-queued. The attempt history stays.
+func resetAttempts(st *queue.TaskState) {
+	st.Attempt = 0
+	st.SessionID = ""
+	st.ResumeContext = ""
+	st.Checkpoint = nil
+	st.LastNDJSONMessages = nil
+	if st.Status == queue.StatusFailed {
+		st.Status = queue.StatusPending
+		st.ResumeAt = nil
+	}
+}
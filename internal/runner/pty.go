@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/creack/pty"
+)
+
+// ptySize is the terminal size reported to the CLI when it runs on a PTY.
+// It is wide so that progress output is not wrapped mid-line.
+var ptySize = &pty.Winsize{Rows: 50, Cols: 200}
+
+// ansiEscape matches terminal control sequences: CSI (colors, cursor
+// movement), OSC (titles, hyperlinks) and two-byte escapes.
+var ansiEscape = regexp.MustCompile(`\x1b(?:\[[0-?]*[ -/]*[@-~]|\][^\x07\x1b]*(?:\x07|\x1b\\)|[@-Z\\-_])`)
+
+// cleanTerminalLine turns a line read from a PTY into what a pipe would
+// have produced: escape sequences are removed, and of a line redrawn with
+// carriage returns (spinners, progress bars) only the final text is kept.
+func cleanTerminalLine(line string) string {
+	line = ansiEscape.ReplaceAllString(strings.TrimSuffix(line, "\r"), "")
+	if i := strings.LastIndexByte(line, '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	return line
+}
+
+// startCommand starts cmd and returns its output stream. Normally stdout is
+// a pipe and stderr goes to the given writer. With usePTY the CLI runs on a
+// pseudo-terminal instead: stdout and stderr arrive merged on the returned
+// reader, and input can be written to the returned terminal, which the
+// caller closes after cmd.Wait.
+func startCommand(cmd *exec.Cmd, stderr io.Writer, usePTY bool) (io.Reader, *os.File, error) {
+	if usePTY {
+		ptmx, err := pty.StartWithSize(cmd, ptySize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("start on pty: %w", err)
+		}
+		return ptmx, ptmx, nil
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("create stdout pipe: %w", err)
+	}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return stdout, nil, nil
+}
@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"log"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/config"
+	"github.com/hseinmoussa/claude-autopilot/internal/detector"
+	"github.com/hseinmoussa/claude-autopilot/internal/notifier"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// reload re-reads the config file, environment and matchers for the reload
+// control op, between tasks. If either fails to load, the current settings
+// stay in place. Task files need no reload: they are read every loop.
+func (r *Runner) reload() {
+	cfg, err := r.Paths.Load(nil)
+	if err != nil {
+		log.Printf("WARN: reload: %v; keeping the current settings", err)
+		return
+	}
+	matchers, err := r.Paths.LoadMatchers()
+	if err != nil {
+		log.Printf("WARN: reload: %v; keeping the current settings", err)
+		return
+	}
+
+	pending := startupOnlyChanges(r.Config, &cfg)
+	r.Config = &cfg
+	r.Notifier = notifier.NewNotifier(&cfg)
+	exitCode := -1
+	if r.Adapter != nil {
+		exitCode = r.Adapter.RateLimitExitCode()
+	}
+	r.Detector = detector.NewDetector(matchers.RateLimitPatterns, exitCode).
+		WithFailurePatterns(matchers.FailurePatternsByCategory())
+	r.PromptPatterns = matchers.PromptPatterns
+	r.PromptAnswers = matchers.PromptAnswers
+	r.promptPatterns = append([]string(nil), r.PromptPatterns...)
+	queue.DefaultPriority, queue.DefaultMaxRetries = r.Paths.TaskDefaults()
+
+	log.Printf("Control: reloaded config and matchers")
+	if len(pending) > 0 {
+		log.Printf("WARN: reload: %s only take effect when the runner restarts", strings.Join(pending, ", "))
+	}
+}
+
+// startupOnlyChanges lists the keys that differ between old and new but are
+// only read when a run starts.
+func startupOnlyChanges(old, new *config.Config) []string {
+	if old == nil {
+		return nil
+	}
+	var keys []string
+	for _, c := range []struct {
+		key      string
+		old, new interface{}
+	}{
+		{"http_listen", old.HTTPListen, new.HTTPListen},
+		{"http_token", old.HTTPToken, new.HTTPToken},
+		{"http_tls_cert", old.HTTPTLSCert, new.HTTPTLSCert},
+		{"http_tls_key", old.HTTPTLSKey, new.HTTPTLSKey},
+		{"http_client_ca", old.HTTPClientCA, new.HTTPClientCA},
+		{"state_retention", old.StateRetention, new.StateRetention},
+		{"encryption_key_file", old.EncryptionKeyFile, new.EncryptionKeyFile},
+	} {
+		if c.old != c.new {
+			keys = append(keys, c.key)
+		}
+	}
+	return keys
+}
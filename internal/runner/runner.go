@@ -1,40 +1,53 @@
 package runner
 
 import (
-	"bufio"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/rand"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
+	"unicode/utf8"
 
 	"github.com/hseinmoussa/claude-autopilot/internal/compat"
 	"github.com/hseinmoussa/claude-autopilot/internal/config"
 	"github.com/hseinmoussa/claude-autopilot/internal/detector"
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
 	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
 	"github.com/hseinmoussa/claude-autopilot/internal/lock"
 	"github.com/hseinmoussa/claude-autopilot/internal/notifier"
 	"github.com/hseinmoussa/claude-autopilot/internal/queue"
 	"github.com/hseinmoussa/claude-autopilot/internal/resume"
+	"github.com/hseinmoussa/claude-autopilot/internal/review"
+	"github.com/hseinmoussa/claude-autopilot/internal/runs"
+	"github.com/hseinmoussa/claude-autopilot/internal/server"
+	"github.com/hseinmoussa/claude-autopilot/internal/tasklog"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
 )
 
-// Exit codes returned by Run.
+// Exit codes returned by Run and RunOnce, and used by the CLI for the
+// matching startup failures.
 const (
-	ExitOK     = 0
-	ExitFailed = 1
-	ExitFatal  = 2
-	ExitSignal = 130
+	ExitOK            = 0   // every selected task finished
+	ExitFailed        = 1   // at least one task failed
+	ExitFatal         = 2   // unexpected startup or I/O error
+	ExitLocked        = 3   // another instance holds the runner lock
+	ExitConfig        = 4   // invalid configuration
+	ExitWaitAbandoned = 5   // stopped (signal or --max-wait) while tasks were waiting for a rate-limit reset
+	ExitSignal        = 130 // interrupted while a task was running
 )
 
 // NDJSONMessage is the envelope for parsing Claude Code's NDJSON output.
@@ -67,7 +80,10 @@ type SystemMessage struct {
 
 // ResultMessage signals that Claude Code has finished producing output.
 type ResultMessage struct {
-	// We only need to detect type="result"; no additional fields required.
+	TotalCostUSD float64     `json:"total_cost_usd"`
+	Result       string      `json:"result"`
+	IsError      bool        `json:"is_error"`
+	Usage        *tokenUsage `json:"usage"`
 }
 
 // Runner is the core execution engine for claude-autopilot. It manages
@@ -79,13 +95,58 @@ type Runner struct {
 	Detector       *detector.Detector
 	Notifier       *notifier.Notifier
 	Lock           *lock.Lock
+	Paths          config.Paths // where tasks, state, logs and the runner lock live
 	ProjectDir     string
 	YesFlag        bool
 	PromptPatterns []string
+	PromptAnswers  []config.PromptAnswer // replies available to tasks' auto_approve lists
+	Tags           []string              // restrict the run to tasks with any of these tags
+	Only           []string              // restrict the run to these task IDs or glob patterns
+	Exclude        []string              // skip these task IDs or glob patterns
+	Watch          bool                  // keep running when the queue drains, waiting for new tasks
+	MaxWait        time.Duration         // exit instead of waiting longer than this for a rate-limit reset (0 = no limit)
+	Events         *events.Writer        // lifecycle event stream (nil = none)
+	TeeDir         string                // also copy each task's raw output to <TeeDir>/<id>.log, unless it sets tee_output
+	IfNotRunning   bool                  // exit with ExitOK instead of ExitLocked when another instance holds the runner lock
 	ShuttingDown   atomic.Bool
 
 	// promptPatterns are used for hang detection when skip_permissions is false.
 	promptPatterns []string
+
+	// stopCh is closed when a shutdown signal arrives.
+	stopCh chan struct{}
+
+	// hub feeds lifecycle events to the HTTP listener's clients, when
+	// http_listen is set.
+	hub *events.Hub
+
+	// health is the snapshot written to health.json.
+	health healthState
+
+	// networkDown pauses the queue after a task failed on a network error
+	// and network_probe could not be reached either.
+	networkDown bool
+
+	// dirTurns is the start count at which each working directory last
+	// started a task, for scheduling=round_robin_by_dir.
+	dirTurns   map[string]int
+	startCount int
+
+	// journal is the append-only event log, events.jsonl; statuses holds
+	// the status last seen for each task, to record changes in it.
+	journal  *events.Writer
+	statuses map[string]string
+
+	// progressAt is when the main loop last showed progress (Unix nanos):
+	// a scheduler iteration, a tick of a wait, or task output or a hang
+	// check. The lock heartbeat only moves while it does, so a runner wedged
+	// anywhere else lets heartbeat_at go stale.
+	progressAt atomic.Int64
+
+	// drainGate is held while this instance waits for other instances to
+	// finish their tasks so that exclusive task drainFor can start.
+	drainGate *lock.Lock
+	drainFor  string
 }
 
 // Run is the main execution loop. It acquires the runner lock, loads tasks,
@@ -95,40 +156,65 @@ func (r *Runner) Run() int {
 	runStarted := time.Now()
 
 	// Step 1: Ensure directory structure.
-	if err := config.EnsureDirs(); err != nil {
+	if err := r.Paths.EnsureDirs(); err != nil {
 		log.Printf("ERROR: failed to create directories: %v", err)
 		return ExitFatal
 	}
 
 	// Step 2: Acquire runner lock.
-	lockPath := filepath.Join(config.BaseDir(), "runner.lock")
-	lk, err := lock.AcquireLock(lockPath)
+	lk, err := lock.AcquireLock(r.Paths.LockPath())
 	if err != nil {
 		if errors.Is(err, lock.ErrLocked) {
+			if r.IfNotRunning {
+				ui.Infof("Another claude-autopilot instance is already running; nothing to do.")
+				return ExitOK
+			}
 			fmt.Fprintf(os.Stderr, "Another claude-autopilot instance is already running.\n%v\n", err)
-			return ExitFatal
+			return ExitLocked
 		}
 		log.Printf("ERROR: failed to acquire lock: %v", err)
 		return ExitFatal
 	}
 	r.Lock = lk
 	defer r.Lock.Release()
+	defer r.releaseDrainGate()
+
+	r.openJournal()
+	defer r.journal.Close()
+
+	r.health.h.StartedAt = runStarted.UTC()
+	r.setPhase(PhaseStarting, nil)
+	defer r.setPhase(PhaseStopped, nil)
+
+	// Keep heartbeat_at and health.json fresh so status/doctor and external
+	// monitors can spot a wedged runner.
+	r.progress()
+	stopHeartbeat := make(chan struct{})
+	defer close(stopHeartbeat)
+	go r.heartbeat(stopHeartbeat)
 
 	// Step 3: Clean orphan temp files.
-	base := config.BaseDir()
 	cleanDirs := []string{
-		base,
-		filepath.Join(base, "state"),
-		filepath.Join(base, "tasks"),
-		filepath.Join(base, "control"),
+		r.Paths.Home,
+		r.Paths.StateDir(),
+		r.Paths.TasksDir(),
+		r.Paths.ControlDir(),
 	}
 	if r.ProjectDir != "" {
 		cleanDirs = append(cleanDirs, r.ProjectDir)
 	}
-	if n, err := fileutil.CleanOrphanTemps(cleanDirs); err != nil {
+	if n, skipped, err := fileutil.CleanOrphanTemps(cleanDirs); err != nil {
 		log.Printf("WARN: orphan temp cleanup: %v", err)
-	} else if n > 0 {
-		log.Printf("Cleaned %d orphan temp file(s)", n)
+	} else {
+		if n > 0 {
+			log.Printf("Cleaned %d orphan temp file(s)", n)
+		}
+		if skipped > 0 {
+			log.Printf("Kept %d temp file(s) whose writer still looks alive", skipped)
+		}
+	}
+	if r.Config.StateRetention > 0 {
+		r.removeOrphanState(r.Config.StateRetention)
 	}
 
 	r.promptPatterns = append([]string(nil), r.PromptPatterns...)
@@ -139,21 +225,67 @@ func (r *Runner) Run() int {
 		return ExitOK
 	}
 
+	// Serve the HTTP endpoints, if configured, for as long as the run lasts.
+	if r.Config.HTTPListen != "" {
+		sec := server.Security{
+			Token:        r.Config.HTTPToken,
+			CertFile:     r.Config.HTTPTLSCert,
+			KeyFile:      r.Config.HTTPTLSKey,
+			ClientCAFile: r.Config.HTTPClientCA,
+		}
+		tokenSource := "http_token"
+		if sec.Token == "" {
+			tok, err := server.LoadOrCreateToken(r.Paths.HTTPTokenFile())
+			if err != nil {
+				log.Printf("ERROR: http listener: %v", err)
+				return ExitFatal
+			}
+			sec.Token = tok
+			tokenSource = r.Paths.HTTPTokenFile()
+		}
+
+		r.hub = events.NewHub()
+		srv := server.New(r.Config.HTTPListen, r.hub, server.Source{
+			Tasks: func() ([]queue.Task, error) {
+				tasks, _, err := queue.LoadTasksAndInit(r.Paths.TasksDir(), r.ProjectDir, r.Paths.StateDir())
+				return r.selectTasks(tasks), err
+			},
+			StateDir:   r.Paths.StateDir(),
+			SummaryLog: filepath.Join(r.Paths.LogsDir(), "summary.log"),
+		}, sec)
+		if err := srv.Start(); err != nil {
+			log.Printf("ERROR: %v", err)
+			return ExitFatal
+		}
+		defer srv.Close()
+		if r.Config.HTTPClientCA != "" {
+			log.Printf("Serving %s (client certificates required)", srv.URL())
+		} else {
+			log.Printf("Serving %s (token in %s)", srv.URL(), tokenSource)
+		}
+	}
+
 	// Setup signal handler for graceful shutdown.
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
-	go func() {
-		<-sigCh
-		r.ShuttingDown.Store(true)
-	}()
+	r.watchSignals()
 
 	// Main loop.
-	stateDir := filepath.Join(base, "state")
-	controlDir := filepath.Join(base, "control")
-	globalTaskDir := filepath.Join(base, "tasks")
+	stateDir := r.Paths.StateDir()
+	controlDir := r.Paths.ControlDir()
+	globalTaskDir := r.Paths.TasksDir()
 	anyFailed := false
+	ranSinceIdle := false
+	dirSkipped := make(map[string]bool)     // tasks skipped under dir_lock_policy=skip
+	blocked := make(map[string]bool)        // tasks already reported as blocked by a failed dependency
+	promptWarned := make(map[string]string) // prompt hash last reported as changed, by task
+	var waitingSince time.Time              // when every task last started waiting; zero while there is work
+	taskWarned := make(map[string]string)   // task checksum last reported as changed, by task
+	diskPaused := false                     // the queue is paused for low disk space
+
+	watcher := newQueueWatcher(controlDir, globalTaskDir, r.ProjectDir)
+	defer watcher.Close()
 
 	for {
+		r.progress()
 		if r.ShuttingDown.Load() {
 			return ExitSignal
 		}
@@ -170,33 +302,48 @@ func (r *Runner) Run() int {
 			return ExitFatal
 		}
 		if initCount > 0 {
-			fmt.Printf("ℹ Initialized state for %d new tasks\n", initCount)
+			ui.Infof("Initialized state for %d new tasks", initCount)
+		}
+
+		if len(tasks) == 0 && !r.Watch {
+			ui.Println("No tasks found. Add tasks with 'claude-autopilot add' or create YAML files in ~/.claude-autopilot/tasks/")
+			return ExitOK
 		}
 
-		if len(tasks) == 0 {
-			fmt.Println("No tasks found. Add tasks with 'claude-autopilot add' or create YAML files in ~/.claude-autopilot/tasks/")
+		tasks = r.selectTasks(tasks)
+		if len(tasks) == 0 && !r.Watch {
+			ui.Println("No tasks match the --tag/--only/--exclude filters.")
 			return ExitOK
 		}
 
 		// Load states.
+		allStates, err := queue.LoadAllStates(stateDir)
+		if err != nil {
+			log.Printf("WARN: load states: %v", err)
+		}
 		states := make(map[string]*queue.TaskState, len(tasks))
 		for i := range tasks {
-			st, err := queue.LoadState(stateDir, tasks[i].ID)
-			if err != nil {
-				log.Printf("WARN: load state for %s: %v", tasks[i].ID, err)
-			}
+			st := allStates[tasks[i].ID]
 			if st == nil {
 				st = &queue.TaskState{
 					ID:     tasks[i].ID,
 					Status: queue.StatusPending,
 				}
-			} else if st.Status == queue.StatusRunning {
+			} else {
+				r.observeState(st)
+			}
+			if st.Status == queue.StatusRunning {
 				// Crash recovery: stale running tasks are put back to pending.
 				st.Status = queue.StatusPending
-				if err := queue.SaveState(stateDir, st); err != nil {
+				if err := r.saveState(stateDir, st); err != nil {
 					log.Printf("WARN: crash recovery save for %s: %v", tasks[i].ID, err)
 				}
 			}
+			if r.checkPromptChange(&tasks[i], st, promptWarned) || r.checkTaskChange(&tasks[i], st, taskWarned) {
+				if err := r.saveState(stateDir, st); err != nil {
+					log.Printf("WARN: save reset state for %s: %v", tasks[i].ID, err)
+				}
+			}
 			states[tasks[i].ID] = st
 		}
 
@@ -204,9 +351,32 @@ func (r *Runner) Run() int {
 		now := time.Now()
 		var actionable []queue.Task
 		var waitingFuture []queue.Task
+		var heldUntil time.Time // earliest day a task held by run_days/skip_dates may start
 
 		for _, t := range tasks {
 			st := states[t.ID]
+			if dirSkipped[t.ID] && st.Status != queue.StatusFailed {
+				continue
+			}
+			// Tasks start once everything they depend on is done. A failed
+			// or cancelled dependency holds them until it is retried.
+			if st.Status == queue.StatusPending || st.Status == queue.StatusWaiting {
+				if dep, depStatus := blockingDependency(&t, states, stateDir); dep != "" {
+					if (depStatus == queue.StatusFailed || depStatus == queue.StatusCancelled) && !blocked[t.ID] {
+						log.Printf("Task %s is blocked: dependency %s is %s", t.ID, dep, depStatus)
+						blocked[t.ID] = true
+					}
+					continue
+				}
+				// Outside its run_days or on a skip date it waits for
+				// the next day it may start.
+				if until := t.HeldUntil(now); !until.IsZero() {
+					if heldUntil.IsZero() || until.Before(heldUntil) {
+						heldUntil = until
+					}
+					continue
+				}
+			}
 			switch st.Status {
 			case queue.StatusPending:
 				actionable = append(actionable, t)
@@ -223,15 +393,112 @@ func (r *Runner) Run() int {
 				anyFailed = true
 			case queue.StatusCancelled:
 				// skip
+			case queue.StatusNeedsReview:
+				// waits for approve or reject
 			}
 		}
 
-		// Step 8: Pick and execute highest-priority actionable task.
+		// Hold new task starts while the network is down.
+		if len(actionable) > 0 && r.networkDown {
+			if !r.networkReachable() {
+				r.setPhase(PhasePaused, func(h *Health) { h.Reason = "network unreachable" })
+				if !r.waitForWake(time.Now().Add(networkRecheckInterval), watcher, nil, true) {
+					return ExitSignal
+				}
+				continue
+			}
+			log.Printf("Network is reachable again; resuming the queue")
+			r.networkDown = false
+		}
+
+		// Hold new task starts just before a predicted usage-window reset.
 		if len(actionable) > 0 {
-			task := actionable[0] // already sorted by priority
+			if until, ok := r.paceUntil(time.Now()); ok {
+				ui.Printf("Usage window predicted to reset at %s; holding new tasks until then.\n", ui.Resume(until))
+				r.setPhase(PhasePaused, func(h *Health) {
+					h.NextResumeAt = &until
+					h.Reason = "usage window reset predicted"
+				})
+				if !r.waitForWake(until, watcher, nil, true) {
+					return ExitWaitAbandoned
+				}
+				continue
+			}
+		}
+
+		// An exclusive task that ran or left the queue no longer holds
+		// other instances back.
+		r.releaseDrainGate(actionable, waitingFuture)
+
+		// Step 8: Pick and execute the first actionable task in
+		// scheduling order whose working directory and concurrency group
+		// are not in use by another instance, and that is not held back by
+		// an exclusive task.
+		var task queue.Task
+		var dirLock, groupLock, runLock *lock.Lock
+		picked := false
+		for _, t := range r.scheduleOrder(actionable) {
+			lk, ok := r.acquireDirLock(&t)
+			if !ok {
+				r.deferForDirLock(&t, states[t.ID], stateDir, dirSkipped)
+				continue
+			}
+			glk, ok := r.acquireGroupLock(&t)
+			if !ok {
+				releaseLocks(lk)
+				r.deferForGroup(&t, states[t.ID], stateDir)
+				continue
+			}
+			rlk, ok := r.acquireRunLock(&t)
+			if !ok {
+				releaseLocks(lk, glk)
+				r.deferForRunLock(&t, states[t.ID], stateDir)
+				continue
+			}
+			task, dirLock, groupLock, runLock, picked = t, lk, glk, rlk, true
+			break
+		}
+		if !picked && len(actionable) > 0 {
+			// Every candidate was deferred; re-evaluate so the new
+			// waiting states are picked up.
+			continue
+		}
+
+		if picked {
+			// Pause rather than start a task that could fill the disk.
+			if reason := r.lowDisk(stateDir, task.WorkingDir); reason != "" {
+				releaseLocks(dirLock, groupLock, runLock)
+				if !diskPaused {
+					log.Printf("WARN: pausing the queue: %s", reason)
+					r.notify(notifier.EventDiskLow, task.ID, "Queue paused: "+reason)
+					r.setPhase(PhasePaused, func(h *Health) { h.Reason = reason })
+					diskPaused = true
+				}
+				if !r.waitForWake(time.Now().Add(diskRecheckInterval), watcher, nil, true) {
+					return ExitSignal
+				}
+				continue
+			}
+			if diskPaused {
+				log.Printf("Disk space recovered; resuming the queue")
+				diskPaused = false
+			}
+
 			st := states[task.ID]
 
+			if !waitingSince.IsZero() {
+				r.notify(notifier.EventQueueResumed, task.ID, fmt.Sprintf("Queue resumed with task %s after waiting %s",
+					task.ID, time.Since(waitingSince).Truncate(time.Second)))
+				waitingSince = time.Time{}
+			}
+
+			r.noteDirStart(task.WorkingDir)
 			exitResult := r.executeTask(&task, st, stateDir)
+			releaseLocks(dirLock, groupLock, runLock)
+			if task.Exclusive {
+				r.releaseDrainGate()
+			}
+			ranSinceIdle = true
 
 			// Reload state after execution.
 			st, _ = queue.LoadState(stateDir, task.ID)
@@ -246,99 +513,284 @@ func (r *Runner) Run() int {
 			continue
 		}
 
-		// Step 10: Only waiting tasks with future resume_at.
-		if len(waitingFuture) > 0 {
+		// Step 10: Only waiting tasks with future resume_at, or tasks
+		// held for another day.
+		if len(waitingFuture) > 0 || !heldUntil.IsZero() {
 			earliest := r.findEarliestResume(waitingFuture, states)
+			if !heldUntil.IsZero() && (earliest == nil || heldUntil.Before(*earliest)) {
+				earliest = &heldUntil
+			}
 			if earliest == nil {
 				// No valid resume times; exit.
 				break
 			}
 
-			fmt.Printf("All tasks waiting. Next resume at %s\n", earliest.Format(time.RFC3339))
+			if r.MaxWait > 0 && time.Until(*earliest) > r.MaxWait {
+				ui.Printf("All tasks waiting. Next resume at %s is beyond --max-wait %s; exiting.\n",
+					ui.Resume(*earliest), r.MaxWait)
+				r.printSummary(stateDir, runStarted)
+				return ExitWaitAbandoned
+			}
 
-			// Sleep loop with short ticks.
-			for {
-				if r.ShuttingDown.Load() {
-					return ExitSignal
+			ui.Printf("All tasks waiting. Next resume at %s\n", ui.Resume(*earliest))
+			if waitingSince.IsZero() {
+				waitingSince = time.Now()
+				why := fmt.Sprintf("%d rate limited or deferred", len(waitingFuture))
+				if len(waitingFuture) == 0 {
+					why = "held by run_days or skip_dates"
 				}
+				r.notify(notifier.EventQueueWaiting, "", fmt.Sprintf("All tasks waiting (%s); resumes at %s", why, ui.Resume(*earliest)))
+			}
+			r.setPhase(PhaseWaiting, func(h *Health) { h.NextResumeAt = earliest })
 
-				until := time.Until(*earliest)
-				if until <= 0 {
-					break
-				}
+			// Sleep until the earliest resume time, waking early for
+			// control commands and task file changes.
+			if !r.waitForWake(*earliest, watcher, newOverview(*earliest, tasks, states), true) {
+				return ExitWaitAbandoned
+			}
+			// Loop back to apply control commands and pick tasks.
+			continue
+		}
 
-				tick := 30 * time.Second
-				if until < tick {
-					tick = until
-				}
+		// Step 11: No actionable and no waiting tasks. A wait that ends
+		// this way (the waiting tasks were cancelled) never resumed.
+		waitingSince = time.Time{}
+		if !r.Watch {
+			break
+		}
 
-				r.showCountdown(*earliest, &waitingFuture[0], states[waitingFuture[0].ID].Attempt)
-				time.Sleep(tick)
+		// Watch mode: report the drained queue once, then sleep until
+		// tasks or control commands change.
+		if ranSinceIdle {
+			r.finishRun(stateDir, runStarted, anyFailed)
+			ranSinceIdle = false
+			anyFailed = false
+		}
+		clear(dirSkipped)
+		clear(blocked)
+		ui.Println("Queue empty. Watching for new tasks...")
+		r.setPhase(PhaseIdle, nil)
+		if !r.waitForWake(time.Time{}, watcher, nil, true) {
+			return ExitSignal
+		}
+		runStarted = time.Now()
+	}
 
-				// Process control commands on each tick.
-				if err := r.processControlCommands(controlDir, stateDir); err != nil {
-					log.Printf("WARN: processing control commands during wait: %v", err)
-				}
-				// Re-evaluate queue every tick.
-				break
-			}
-			// After wait completes, loop back to pick tasks.
-			continue
+	r.finishRun(stateDir, runStarted, anyFailed)
+
+	if anyFailed {
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// durationOr returns d, or def when d is unset (a Config not built from
+// defaults).
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// heartbeat refreshes the lockfile heartbeat every lock.HeartbeatInterval,
+// as long as the main loop made progress since the last one, and
+// health.json every healthInterval until stop is closed.
+func (r *Runner) heartbeat(stop <-chan struct{}) {
+	t := time.NewTicker(lock.HeartbeatInterval)
+	defer t.Stop()
+	h := time.NewTicker(healthInterval)
+	defer h.Stop()
+	var seen int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			seen = r.beat(seen)
+		case <-h.C:
+			r.writeHealth()
 		}
+	}
+}
+
+// progress records that the main loop is still moving; see progressAt.
+func (r *Runner) progress() {
+	r.progressAt.Store(time.Now().UnixNano())
+}
 
-		// Step 11: No actionable and no waiting tasks.
-		break
+// beat refreshes the lockfile heartbeat if progress was recorded since
+// seen, the mark of the previous beat, and returns the mark to compare with
+// next time. Without progress heartbeat_at is left to go stale.
+func (r *Runner) beat(seen int64) int64 {
+	mark := r.progressAt.Load()
+	if mark == seen {
+		return seen
 	}
+	if err := r.Lock.Heartbeat(); err != nil {
+		log.Printf("WARN: lock heartbeat: %v", err)
+	}
+	return mark
+}
 
-	// Print summary and exit.
+// finishRun prints the run summary, archives finished tasks and sends the
+// end-of-run notification.
+func (r *Runner) finishRun(stateDir string, runStarted time.Time, anyFailed bool) {
 	r.printSummary(stateDir, runStarted)
+	r.archiveDone(stateDir)
 
 	if r.Notifier != nil {
-		r.Notifier.NotifyComplete("claude-autopilot run completed")
+		if anyFailed {
+			r.Notifier.NotifyFailure("claude-autopilot run completed with failed tasks")
+		} else {
+			r.Notifier.NotifyComplete("claude-autopilot run completed")
+		}
 	}
+}
 
-	if anyFailed {
-		return ExitFailed
+// watchSignals sets ShuttingDown on SIGTERM or SIGINT so the current task
+// can be checkpointed before exit.
+func (r *Runner) watchSignals() {
+	r.stopCh = make(chan struct{})
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigCh
+		r.ShuttingDown.Store(true)
+		close(r.stopCh)
+	}()
+}
+
+// selectTasks returns the tasks this run is restricted to by tag and by the
+// --only/--exclude ID patterns, preserving order. Filtering never touches
+// task state; excluded tasks are simply not considered this run.
+func (r *Runner) selectTasks(tasks []queue.Task) []queue.Task {
+	if len(r.Tags) == 0 && len(r.Only) == 0 && len(r.Exclude) == 0 {
+		return tasks
 	}
-	return ExitOK
+	var selected []queue.Task
+	for i := range tasks {
+		if !tasks[i].HasAnyTag(r.Tags) {
+			continue
+		}
+		if len(r.Only) > 0 && !matchesAnyID(tasks[i].ID, r.Only) {
+			continue
+		}
+		if matchesAnyID(tasks[i].ID, r.Exclude) {
+			continue
+		}
+		selected = append(selected, tasks[i])
+	}
+	return selected
+}
+
+// matchesAnyID reports whether id equals or glob-matches any of patterns.
+func matchesAnyID(id string, patterns []string) bool {
+	for _, p := range patterns {
+		if p == id {
+			return true
+		}
+		if ok, err := path.Match(p, id); err == nil && ok {
+			return true
+		}
+	}
+	return false
 }
 
 // executeTask runs a single task through the Claude Code CLI and manages
 // its lifecycle: pre-run state setup, subprocess execution, output parsing,
 // result detection, and post-run state transitions.
 func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir string) int {
+	rerun := false
+	for {
+		// Each run gets the task as queued, never a review attempt's
+		// worktree copy of it.
+		code, again := r.runAttempt(task, state, stateDir, rerun)
+		if !again {
+			return code
+		}
+		rerun = true
+	}
+}
+
+// runAttempt runs one attempt of task. With rerun it runs the current
+// attempt again instead of starting a new one, after a resume that failed
+// before any work; it reports again when that is needed.
+func (r *Runner) runAttempt(task *queue.Task, state *queue.TaskState, stateDir string, rerun bool) (int, bool) {
 	now := time.Now().UTC()
 
 	if !filepath.IsAbs(task.WorkingDir) {
 		log.Printf("ERROR: Task '%s': working_dir must be absolute (got '%s'). Use 'add --dir' which resolves automatically.", task.ID, task.WorkingDir)
 		state.Status = queue.StatusFailed
 		state.EndedAt = &now
-		_ = queue.SaveState(stateDir, state)
-		return ExitFailed
+		_ = r.saveState(stateDir, state)
+		return ExitFailed, false
 	}
 	if info, err := os.Stat(task.WorkingDir); err != nil || !info.IsDir() {
 		log.Printf("ERROR: task %s working_dir does not exist: %s", task.ID, task.WorkingDir)
 		state.Status = queue.StatusFailed
 		state.EndedAt = &now
-		_ = queue.SaveState(stateDir, state)
-		return ExitFailed
+		_ = r.saveState(stateDir, state)
+		return ExitFailed, false
+	}
+	claude, err := r.cliFor(task)
+	if err != nil {
+		log.Printf("ERROR: task %s: %v", task.ID, err)
+		state.Status = queue.StatusFailed
+		state.EndedAt = &now
+		_ = r.saveState(stateDir, state)
+		return ExitFailed, false
 	}
 
 	// Pre-run: set state to running.
 	state.Status = queue.StatusRunning
-	state.Attempt++
-	state.StartedAt = &now
+	if !rerun {
+		state.Attempt++
+	}
+	startedAt := now
+	state.StartedAt = &startedAt
 	state.EndedAt = nil
 	state.PromptHash = hashPrompt(task.Prompt)
-	state.GitCommit = r.currentGitCommit(task.WorkingDir)
+	state.TaskChecksum = task.Checksum()
+	// Retries keep the first attempt's commit so the completion diff covers
+	// everything the task changed.
+	if state.Attempt == 1 || state.GitCommit == "" {
+		state.GitCommit = r.currentGitCommit(task.WorkingDir)
+	}
+	state.DiffStat, state.DiffSummary, state.NoChanges = "", "", false
+
+	// A review-mode task works in its own worktree, so working_dir is only
+	// changed once the work is approved. Retries continue in the same one.
+	// A plan task changes nothing; review applies to its subtasks. Only this
+	// attempt's copy of the task points at the worktree: repoDir stays the
+	// task's own working directory, and executeTask hands every re-run the
+	// original task. A re-run keeps the worktree the failed resume left.
+	repoDir := task.WorkingDir
+	if task.Review && !task.Plan {
+		worktree := filepath.Join(r.Paths.WorktreesDir(), task.ID)
+		branch := review.Branch(task.ID)
+		if (state.Attempt == 1 && !rerun) || !review.Exists(worktree) {
+			if err := review.Prepare(repoDir, worktree, branch, state.GitCommit); err != nil {
+				log.Printf("ERROR: task %s: prepare review worktree: %v", task.ID, err)
+				state.Status = queue.StatusFailed
+				state.EndedAt = &now
+				_ = r.saveState(stateDir, state)
+				return ExitFailed, false
+			}
+		}
+		state.Worktree, state.ReviewBranch = worktree, branch
+		reviewed := *task
+		reviewed.WorkingDir = worktree
+		task = &reviewed
+	}
 
-	if err := queue.SaveState(stateDir, state); err != nil {
+	if err := r.saveState(stateDir, state); err != nil {
 		log.Printf("ERROR: save pre-run state for %s: %v", task.ID, err)
-		return ExitFatal
+		return ExitFatal, false
 	}
 
-	logDir := filepath.Join(config.BaseDir(), "logs")
-	logPath := filepath.Join(logDir, fmt.Sprintf("%s.log", task.ID))
+	logDir := r.Paths.LogsDir()
+	logPath := r.Paths.TaskLog(task.ID)
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		log.Printf("WARN: could not create log dir %s: %v", logDir, err)
 	}
@@ -347,6 +799,34 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 	}
 
 	log.Printf("Running task %s (attempt %d): %s", task.ID, state.Attempt, task.Title)
+	r.setPhase(PhaseRunning, func(h *Health) {
+		h.TaskID = task.ID
+		h.Attempt = state.Attempt
+	})
+	if !rerun {
+		r.emit(events.Event{Type: events.TaskStarted, TaskID: task.ID, Attempt: state.Attempt, Title: task.Title, WorkingDir: task.WorkingDir})
+	}
+
+	// A fresh retry forgets the previous session entirely.
+	if state.Attempt > 1 && r.resumeStrategy(task, state) == resume.Fresh {
+		state.SessionID = ""
+		state.ResumeContext = ""
+		state.Checkpoint = nil
+		state.LastNDJSONMessages = nil
+	}
+
+	// Earlier attempts may already have used up the task's budget.
+	spentCost, spentTokens := spent(state)
+	if reason := overBudget(task, spentCost, spentTokens); reason != "" {
+		log.Printf("ERROR: task %s %s; not running (raise the limit to retry)", task.ID, reason)
+		state.Status = queue.StatusFailed
+		now := time.Now().UTC()
+		state.EndedAt = &now
+		r.saveState(stateDir, state)
+		r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s stopped: %s", task.ID, reason))
+		r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: reason})
+		return ExitFailed, false
+	}
 
 	// Build the prompt, prepending context files if any.
 	prompt, err := r.buildPromptWithContext(task, state)
@@ -355,14 +835,31 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 		state.Status = queue.StatusFailed
 		now := time.Now().UTC()
 		state.EndedAt = &now
-		queue.SaveState(stateDir, state)
-		return ExitFailed
+		r.saveState(stateDir, state)
+		return ExitFailed, false
+	}
+
+	// Guard against prompts that will not fit in the model's context.
+	state.PromptTokens = estimateTokens(prompt)
+	if limit := r.Config.PromptTokenLimit; limit > 0 && state.PromptTokens > limit {
+		if r.Config.PromptLimitAction == "fail" {
+			log.Printf("ERROR: task %s prompt is ~%d tokens, over prompt_token_limit %d; not running", task.ID, state.PromptTokens, limit)
+			state.Status = queue.StatusFailed
+			now := time.Now().UTC()
+			state.EndedAt = &now
+			r.saveState(stateDir, state)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s prompt too large (~%d tokens)", task.ID, state.PromptTokens))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: "prompt too large"})
+			return ExitFailed, false
+		}
+		log.Printf("WARN: task %s prompt is ~%d tokens, over prompt_token_limit %d", task.ID, state.PromptTokens, limit)
 	}
 
 	// Determine session ID for resume.
 	sessionID := ""
-	if state.SessionID != "" && r.Adapter.SupportsResume() {
-		strategy := resume.DetermineStrategy(true, r.Adapter.SupportsResume())
+	if state.Attempt > 1 {
+		strategy := r.resumeStrategy(task, state)
+		log.Printf("Task %s resume strategy: %s", task.ID, strategy)
 		if strategy == resume.NativeResume {
 			sessionID = state.SessionID
 		}
@@ -372,70 +869,157 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 	skipPerms := r.Config.SkipPermissions || task.SkipPermissions
 
 	// Build CLI arguments.
-	args := r.Adapter.BuildArgs(prompt, task.Model, sessionID, skipPerms, task.Flags)
+	flags, warnings := claude.adapter.CheckFlags(task.Flags)
+	for _, w := range warnings {
+		log.Printf("WARN: task %s: %s", task.ID, w)
+	}
+	args := claude.adapter.BuildArgs(prompt, task.Model, sessionID, skipPerms, flags)
+
+	// Tasks allowed to auto-answer permission prompts run on a PTY so the
+	// answers can be typed in; use_pty puts every task on one.
+	answers := r.allowedAnswers(task, skipPerms)
+	usePTY := r.Config.UsePTY || len(answers) > 0
 
-	// Spawn subprocess.
-	cmd := exec.Command("claude", args...)
+	// Spawn subprocess, inside the task's container if it has one.
+	name, argv := claude.path, args
+	if task.Container != "" {
+		runtime, err := r.containerRuntime()
+		if err != nil {
+			log.Printf("ERROR: task %s: %v", task.ID, err)
+			state.Status = queue.StatusFailed
+			now := time.Now().UTC()
+			state.EndedAt = &now
+			r.saveState(stateDir, state)
+			return ExitFailed, false
+		}
+		var mounts []string
+		if repoDir != task.WorkingDir {
+			mounts = append(mounts, repoDir)
+		}
+		container := containerName(task, state.Attempt)
+		name, argv = runtime, containerArgs(task, container, mounts, usePTY, claude.path, args)
+		defer removeContainer(runtime, container)
+	}
+	cmd := exec.Command(name, argv...)
 	cmd.Dir = task.WorkingDir
 	cmd.Env = os.Environ()
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Printf("ERROR: create stdout pipe for %s: %v", task.ID, err)
-		state.Status = queue.StatusFailed
-		queue.SaveState(stateDir, state)
-		return ExitFailed
+	killGrace := durationOr(r.Config.KillGracePeriod, 10*time.Second)
+
+	// Rate-limit messages are recognized as they stream, so a CLI that
+	// reports a limit and then retries or sits idle is stopped straight away
+	// instead of when it exits or trips the hang timeout.
+	var streamedLimit atomic.Pointer[detector.RateLimitResult]
+	checkStreamed := func(source, text string) {
+		res, ok := claude.detector.DetectLine(text)
+		if !ok {
+			return
+		}
+		res.Reason = "streamed " + source + " " + res.Reason
+		if !streamedLimit.CompareAndSwap(nil, &res) {
+			return
+		}
+		log.Printf("Task %s reported a rate limit while running (%s); stopping it", task.ID, res.Reason)
+		cmd.Process.Signal(syscall.SIGTERM)
+		time.AfterFunc(killGrace, func() {
+			cmd.Process.Kill()
+		})
 	}
 
-	var stderrBuf strings.Builder
-	cmd.Stderr = &stderrBuf
+	// Open per-task log file, in the configured log_format.
+	// With encryption enabled every line is sealed on its way to disk.
+	var tlog *tasklog.Writer
+	logFile, logErr := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if logErr != nil {
+		log.Printf("WARN: cannot open log file %s: %v", logPath, logErr)
+	} else {
+		defer logFile.Close()
+		var logOut io.Writer = logFile
+		if queue.EncryptionKey != nil {
+			logOut = queue.EncryptionKey.LineWriter(logFile)
+		}
+		tlog = tasklog.NewWriter(logOut, r.Config.LogFormat, state.Attempt)
+		if rerun {
+			tlog.Note("session could not be resumed; re-prompting")
+		} else {
+			tlog.Start(task.ID)
+		}
+	}
 
-	if err := cmd.Start(); err != nil {
+	// A copy of the raw output, stdout and stderr, where the user wants it.
+	// It is written as is, even with encryption enabled.
+	var tee io.Writer = io.Discard
+	if path := r.teePath(task, repoDir); path != "" {
+		if tw, err := openTee(path, task.ID); err != nil {
+			log.Printf("WARN: task %s: %v", task.ID, err)
+		} else {
+			defer tw.Close()
+			tee = tw
+			if queue.EncryptionKey != nil {
+				log.Printf("WARN: task %s: output is copied to %s unencrypted", task.ID, path)
+			}
+		}
+	}
+
+	// What the session last did, for hang detection. Stderr is only a
+	// heartbeat: the CLI is alive, but the session may not be moving.
+	act := newActivity(time.Now())
+
+	stderrBuf := &lineWriter{onLine: func(line string) {
+		act.beat(time.Now())
+		tlog.Line(tasklog.Stderr, line)
+		r.emit(events.Event{Type: events.OutputChunk, TaskID: task.ID, Attempt: state.Attempt, Stream: "stderr", Output: line})
+		checkStreamed("stderr", line)
+	}}
+
+	output, term, err := startCommand(cmd, io.MultiWriter(stderrBuf, tee), usePTY)
+	if err != nil {
 		log.Printf("ERROR: start claude for %s: %v", task.ID, err)
 		state.Status = queue.StatusFailed
 		now := time.Now().UTC()
 		state.EndedAt = &now
-		queue.SaveState(stateDir, state)
-		return ExitFailed
+		r.saveState(stateDir, state)
+		return ExitFailed, false
 	}
 
-	// Open per-task log file.
-	logFile, logErr := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if logErr != nil {
-		log.Printf("WARN: cannot open log file %s: %v", logPath, logErr)
-	} else {
-		fmt.Fprintf(logFile, "\n[%s] attempt=%d task=%s\n", time.Now().UTC().Format(time.RFC3339), state.Attempt, task.ID)
-	}
-	defer func() {
-		if logFile != nil {
-			logFile.Close()
+	// Resource limits are applied as soon as the process exists; it has not
+	// had time to start anything that would escape them.
+	if l, err := taskLimits(task); err != nil {
+		log.Printf("WARN: task %s: resource limits: %v", task.ID, err)
+	} else if !l.empty() {
+		release, err := applyLimits(cmd.Process.Pid, l)
+		if err != nil {
+			log.Printf("WARN: task %s: resource limits: %v", task.ID, err)
 		}
-	}()
+		defer release()
+	}
 
-	// Read stdout line by line.
-	scanner := bufio.NewScanner(stdout)
-	scanner.Buffer(make([]byte, 1024*1024), 1024*1024) // 1MB line buffer
+	var costUSD float64
+	var usage usageMeter
+	var budgetReason string // set once max_cost_usd or max_tokens stops the session
+
+	// Read stdout line by line. The tail keeps the current line, including a
+	// trailing partial one where an interactive prompt waits for its answer.
+	tail := &tailBuffer{}
+	scanner := newLineReader(io.TeeReader(output, io.MultiWriter(tail, tee)), maxOutputLine)
 
 	var stdoutBuf strings.Builder
 	var lastLines []string
 	const maxLastLines = 20
-	lastOutputTime := time.Now()
 	var lastOutputMu sync.Mutex
-	streamJSON := r.Adapter.SupportsStreamJSON()
+	streamJSON := claude.adapter.SupportsStreamJSON()
 	gotResult := false
+	var progress transcript.Progress
 
 	// Hang detection goroutine.
-	hangTimeout := r.Config.HangTimeout
-	if hangTimeout == 0 {
-		hangTimeout = 10 * time.Minute
-	}
+	hangTimeout := r.hangTimeout(task)
 
 	hangDone := make(chan struct{})
 	defer close(hangDone)
 
 	go func() {
-		promptSilenceGate := 30 * time.Second
-		ticker := time.NewTicker(5 * time.Second)
+		promptSilenceGate := durationOr(r.Config.PromptSilenceGate, 30*time.Second)
+		ticker := time.NewTicker(durationOr(r.Config.HangCheckInterval, 5*time.Second))
 		defer ticker.Stop()
 
 		for {
@@ -446,10 +1030,26 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 				if r.ShuttingDown.Load() {
 					return
 				}
-
-				lastOutputMu.Lock()
-				silence := time.Since(lastOutputTime)
-				lastOutputMu.Unlock()
+				// Supervising a quiet task is progress: the hang timeout
+				// bounds it.
+				r.progress()
+
+				silence := act.silence(time.Now())
+
+				// Answer whitelisted prompts once output settles.
+				if len(answers) > 0 && silence >= answerSettleDelay {
+					if a, ok := matchAnswer(answers, tail.String()); ok {
+						tail.Reset()
+						if _, err := term.Write([]byte(a.Response)); err != nil {
+							log.Printf("WARN: task %s: answer prompt %q: %v", task.ID, a.Name, err)
+						} else {
+							log.Printf("AUDIT: task %s auto-answered prompt %q (pattern %q) with %q", task.ID, a.Name, a.Pattern, a.Response)
+							tlog.Note(fmt.Sprintf("auto-answered prompt %q with %q", a.Name, a.Response))
+							act.work(time.Now())
+						}
+						continue
+					}
+				}
 
 				// If skip_permissions is false, check for prompt-like patterns
 				// with a shorter silence gate.
@@ -464,11 +1064,12 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 					}
 				}
 
-				// General hang timeout.
-				if silence >= hangTimeout {
-					log.Printf("WARN: task %s has produced no output for %v. Killing.", task.ID, silence)
+				// General hang timeout: no output at all, or heartbeats
+				// only for much longer.
+				if msg, hung := act.hung(time.Now(), hangTimeout); hangTimeout > 0 && hung {
+					log.Printf("WARN: task %s %s. Killing.", task.ID, msg)
 					cmd.Process.Signal(syscall.SIGTERM)
-					time.AfterFunc(10*time.Second, func() {
+					time.AfterFunc(killGrace, func() {
 						cmd.Process.Kill()
 					})
 					return
@@ -482,7 +1083,7 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 	defer close(shutdownDone)
 
 	go func() {
-		ticker := time.NewTicker(500 * time.Millisecond)
+		ticker := time.NewTicker(durationOr(r.Config.ShutdownPollInterval, 500*time.Millisecond))
 		defer ticker.Stop()
 		for {
 			select {
@@ -492,8 +1093,8 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 				if r.ShuttingDown.Load() {
 					log.Printf("Shutdown signal received; terminating task %s", task.ID)
 					cmd.Process.Signal(syscall.SIGTERM)
-					// Wait up to 10s then SIGKILL.
-					time.AfterFunc(10*time.Second, func() {
+					// Wait up to kill_grace_period then SIGKILL.
+					time.AfterFunc(killGrace, func() {
 						cmd.Process.Kill()
 					})
 					return
@@ -503,11 +1104,27 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 	}()
 
 	for scanner.Scan() {
+		r.progress()
 		line := scanner.Text()
+		if term != nil {
+			line = cleanTerminalLine(line)
+		}
+		size, cut := scanner.Cut()
+		if cut {
+			log.Printf("WARN: task %s: output line of %d bytes cut to %d", task.ID, size, maxOutputLine)
+			tlog.Note(fmt.Sprintf("output line of %d bytes cut to %d", size, maxOutputLine))
+		}
 
-		lastOutputMu.Lock()
-		lastOutputTime = time.Now()
-		lastOutputMu.Unlock()
+		// Without stream-json every line is work; with it, lines carrying
+		// only thinking, deltas or system messages are heartbeats.
+		var entries []transcript.Entry
+		if streamJSON {
+			entries = transcript.ParseLine(line)
+			act.output(entries, time.Now())
+		} else {
+			act.work(time.Now())
+		}
+		r.noteOutput(time.Now())
 
 		stdoutBuf.WriteString(line)
 		stdoutBuf.WriteString("\n")
@@ -521,29 +1138,75 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 		lastOutputMu.Unlock()
 
 		// Log to per-task log file.
-		if logFile != nil {
-			fmt.Fprintln(logFile, line)
-		}
+		tlog.Line(tasklog.Stdout, line)
+		r.emit(events.Event{Type: events.OutputChunk, TaskID: task.ID, Attempt: state.Attempt, Stream: "stdout", Output: line})
 
-		// Parse NDJSON if supported.
+		// Parse NDJSON if supported. Only error results and non-JSON lines
+		// come from the CLI itself; other stdout is the model's own output and
+		// may legitimately mention rate limits.
 		if streamJSON {
+			changed := false
+			for _, e := range entries {
+				changed = progress.Add(e) || changed
+			}
+			if changed {
+				r.noteProgress(progress)
+			}
+
 			var msg NDJSONMessage
-			if err := json.Unmarshal([]byte(line), &msg); err == nil {
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				// A cut line is JSON that no longer parses, not a CLI message.
+				if !cut {
+					checkStreamed("stdout", line)
+				}
+			} else {
 				switch msg.Type {
 				case "system":
 					var sysMsg SystemMessage
 					if err := json.Unmarshal(msg.Rest, &sysMsg); err == nil && sysMsg.SessionID != "" {
 						state.SessionID = sysMsg.SessionID
 					}
+				case "assistant":
+					usage.addAssistant(msg.Rest)
 				case "result":
 					gotResult = true
+					var resMsg ResultMessage
+					if err := json.Unmarshal(msg.Rest, &resMsg); err == nil {
+						costUSD = resMsg.TotalCostUSD
+						usage.addResult(resMsg.Usage)
+						if resMsg.IsError {
+							checkStreamed("result", resMsg.Result)
+						}
+					}
+				}
+			}
+
+			if budgetReason == "" {
+				if reason := overBudget(task, spentCost+costUSD, spentTokens+usage.sum); reason != "" {
+					budgetReason = reason
+					log.Printf("WARN: task %s %s. Killing.", task.ID, reason)
+					cmd.Process.Signal(syscall.SIGTERM)
+					time.AfterFunc(killGrace, func() {
+						cmd.Process.Kill()
+					})
 				}
 			}
 		}
 	}
 
+	// A read error ends the loop early. The output read so far still goes
+	// to detection, and a CLI left blocked on its output is killed by the
+	// hang timeout. On a PTY, EIO just means the CLI closed the terminal.
+	if err := scanner.Err(); err != nil && !(term != nil && errors.Is(err, syscall.EIO)) {
+		log.Printf("WARN: task %s: read output: %v", task.ID, err)
+		tlog.Note("read output: " + err.Error())
+	}
+
 	// Wait for process to exit.
 	cmdErr := cmd.Wait()
+	if term != nil {
+		term.Close()
+	}
 	exitCode := 0
 	if cmdErr != nil {
 		var exitErr *exec.ExitError
@@ -557,39 +1220,177 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 	stderrStr := stderrBuf.String()
 	stdoutStr := stdoutBuf.String()
 
-	// Save last NDJSON messages for resume context.
+	// A stale session makes --resume fail before any work happens. Run the
+	// attempt again straight away with the re-prompt strategy instead of
+	// spending another; the previous attempt's resume context is left
+	// intact. The failed resume is recorded as superseded, so the attempt
+	// number stays the re-run's.
+	if sessionID != "" && exitCode != 0 && !r.ShuttingDown.Load() && resume.IsSessionNotFound(stderrStr) {
+		log.Printf("WARN: task %s session %s could not be resumed; retrying with re-prompt", task.ID, sessionID)
+		recordAttempt(state, startedAt, exitCode, "resume_failed", "session not found", costUSD, usage.sum)
+		state.Attempts[len(state.Attempts)-1].Superseded = true
+		state.SessionID = ""
+		return ExitOK, true
+	}
+
+	// Save last NDJSON messages, a condensed transcript and a structured
+	// checkpoint for resume context.
+	stdoutLines := strings.Split(stdoutStr, "\n")
 	state.LastNDJSONMessages = lastLines
+	state.ResumeContext = resume.ExtractContext(stdoutLines, resume.DefaultMaxContextBytes)
+	state.Checkpoint = resume.BuildCheckpoint(stdoutLines)
 
 	// If we got a shutdown signal during execution, save state and return.
 	if r.ShuttingDown.Load() {
 		// Preserve running -> pending for clean restart.
 		if state.Status == queue.StatusRunning {
+			recordAttempt(state, startedAt, exitCode, "interrupted", "runner shut down", costUSD, usage.sum)
 			state.Status = queue.StatusPending
 			state.Attempt-- // don't count interrupted attempt
 			state.EndedAt = nil
 		}
-		queue.SaveState(stateDir, state)
-		return ExitSignal
+		r.saveState(stateDir, state)
+		return ExitSignal, false
 	}
 
 	_ = gotResult // used for future enhancements
 
 	// Run detection.
-	result := r.Detector.Detect(exitCode, stdoutStr, stderrStr)
+	result := claude.detector.Detect(exitCode, stdoutStr, stderrStr)
+	if early := streamedLimit.Load(); early != nil && exitCode != 0 {
+		if result.Result != detector.RateLimited {
+			result = *early
+		} else if result.ResetTime == nil {
+			result.ResetTime = early.ResetTime
+		}
+	}
+
+	// Record what a completed task changed. With fail_on_no_changes, an
+	// empty diff turns the completion into a failure so it is retried.
+	if result.Result == detector.Completed {
+		if diff, ok := gitDiffSummary(task.WorkingDir, state.GitCommit); ok {
+			state.DiffStat = diff.Stat
+			state.DiffSummary = diff.Line()
+			state.NoChanges = diff.Empty()
+			if state.NoChanges && r.failOnNoChanges(task) {
+				result = detector.RateLimitResult{Result: detector.Failed, Reason: "completed without changing any files"}
+			}
+		} else if r.failOnNoChanges(task) {
+			log.Printf("WARN: task %s: fail_on_no_changes needs a git working directory; ignoring", task.ID)
+		}
+	}
+
+	// A verify command must pass for a completion to count. A plan task's
+	// verify command is for its subtasks.
+	if result.Result == detector.Completed && task.Verify != "" && !task.Plan {
+		if err := runVerify(task.WorkingDir, task.Verify); err != nil {
+			log.Printf("WARN: task %s: %v", task.ID, err)
+			tlog.Note(err.Error())
+			result = detector.RateLimitResult{Result: detector.Failed, Reason: err.Error()}
+		} else {
+			log.Printf("Task %s verify command passed", task.ID)
+		}
+	}
+
+	// A plan task is done once its subtasks are queued.
+	if result.Result == detector.Completed && task.Plan {
+		if path, n, err := r.writePlan(task, finalSummary(stdoutLines)); err != nil {
+			log.Printf("WARN: task %s: plan rejected: %v", task.ID, err)
+			result = detector.RateLimitResult{Result: detector.Failed, Reason: "plan rejected: " + err.Error()}
+		} else {
+			state.PlanFile = path
+			log.Printf("Task %s queued %d subtasks in %s", task.ID, n, path)
+		}
+	}
+
+	// A review-mode task's changes are committed to its review branch and
+	// wait there for approval. One that changed nothing is simply done.
+	needsReview := false
+	if result.Result == detector.Completed && task.Review && !task.Plan {
+		committed, err := review.Commit(task.WorkingDir, fmt.Sprintf("%s\n\nclaude-autopilot task %s, attempt %d", task.Title, task.ID, state.Attempt))
+		switch {
+		case err != nil:
+			log.Printf("WARN: task %s: %v", task.ID, err)
+			result = detector.RateLimitResult{Result: detector.Failed, Reason: err.Error()}
+		case committed:
+			needsReview = true
+		default:
+			if err := review.Discard(repoDir, state.Worktree, state.ReviewBranch); err != nil {
+				log.Printf("WARN: task %s: %v", task.ID, err)
+			}
+			state.Worktree, state.ReviewBranch = "", ""
+		}
+	}
+
+	// retry_on and fail_fast_on decide what a failure whose output shows
+	// one of their patterns does; fail_fast_on wins when both match. An
+	// unknown result that matches counts as a failure.
+	var failFast, forceRetry string
+	if result.Result == detector.Failed || result.Result == detector.Unknown {
+		if failFast = matchOutput(task.FailFastOn, result.Reason, stderrStr, stdoutStr); failFast == "" {
+			forceRetry = matchOutput(task.RetryOn, result.Reason, stderrStr, stdoutStr)
+		}
+		if failFast != "" || forceRetry != "" {
+			result.Result = detector.Failed
+		}
+	}
+
+	// A session stopped for its budget fails outright; retrying would only
+	// spend more.
+	attemptResult := result.Result.String()
+	if budgetReason != "" {
+		result = detector.RateLimitResult{Result: detector.Failed, Reason: budgetReason}
+		attemptResult = resultBudgetExceeded
+	}
 
 	log.Printf("Task %s exit_code=%d detection=%s reason=%q",
-		task.ID, exitCode, result.Result, result.Reason)
+		task.ID, exitCode, attemptResult, result.Reason)
+	recordAttempt(state, startedAt, exitCode, attemptResult, result.Reason, costUSD, usage.sum)
+	if result.Category != "" {
+		state.Attempts[len(state.Attempts)-1].Failure = string(result.Category)
+	}
+
+	r.record(events.Event{Type: events.Detection, TaskID: task.ID, Attempt: state.Attempt, Result: result.Result.String(), Reason: result.Reason, ResumeAt: result.ResetTime})
 
 	// Transition based on detection result.
 	switch result.Result {
 	case detector.Completed:
 		state.Status = queue.StatusDone
+		if needsReview {
+			state.Status = queue.StatusNeedsReview
+		}
 		log.Printf("Task %s completed successfully", task.ID)
+		r.recordDuration(task, repoDir, state)
+		state.Artifacts = r.collectArtifacts(task, state.Attempt)
+		summary := finalSummary(stdoutLines)
+		if task.ExportSummary {
+			state.Summary = summary
+			if state.Summary == "" {
+				log.Printf("WARN: task %s has export_summary set but produced no assistant message", task.ID)
+			}
+		}
+		doneMsg := fmt.Sprintf("Task %s completed", task.ID)
+		if state.NoChanges {
+			log.Printf("WARN: task %s completed without changing any files", task.ID)
+			doneMsg += " with no file changes"
+		} else if state.DiffSummary != "" {
+			log.Printf("Task %s changes: %s", task.ID, state.DiffSummary)
+			doneMsg += fmt.Sprintf(" (%s)", state.DiffSummary)
+		}
+		if needsReview {
+			log.Printf("Task %s is ready for review on branch %s", task.ID, state.ReviewBranch)
+			r.notifySummary(notifier.EventNeedsReview, task.ID, fmt.Sprintf("Task %s is ready for review (%s)", task.ID, state.DiffSummary), summary)
+			r.emit(events.Event{Type: events.NeedsReview, TaskID: task.ID, Attempt: state.Attempt, Changes: state.DiffSummary, Branch: state.ReviewBranch})
+			break
+		}
+		r.notifySummary(notifier.EventTaskDone, task.ID, doneMsg, summary)
+		r.emit(events.Event{Type: events.TaskDone, TaskID: task.ID, Attempt: state.Attempt, Changes: state.DiffSummary})
 
 	case detector.RateLimited:
 		state.Status = queue.StatusWaiting
 		now := time.Now().UTC()
 		state.LastRateLimitedAt = &now
+		r.recordRateLimit(task.ID, now, result.ResetTime)
 
 		if result.ResetTime != nil {
 			state.ResumeAt = result.ResetTime
@@ -600,18 +1401,49 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 			state.ResumeAt = &resumeAt
 			log.Printf("Task %s rate limited; backoff %v, resume at %s", task.ID, backoff, resumeAt.Format(time.RFC3339))
 		}
+		r.notify(notifier.EventRateLimited, task.ID, fmt.Sprintf("Task %s rate limited; resumes at %s", task.ID, ui.Resume(*state.ResumeAt)))
+		r.emit(events.Event{Type: events.RateLimited, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason, ResumeAt: state.ResumeAt})
 
 	case detector.Failed:
-		if state.Attempt < task.MaxRetries {
+		if budgetReason != "" {
+			state.Status = queue.StatusFailed
+			log.Printf("Task %s stopped: %s", task.ID, budgetReason)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s stopped: %s", task.ID, budgetReason))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: budgetReason})
+		} else if failFast != "" {
+			state.Status = queue.StatusFailed
+			log.Printf("Task %s failed; output matched fail_fast_on pattern %q, not retrying", task.ID, failFast)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s failed: output matched %q", task.ID, failFast))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: fmt.Sprintf("fail_fast_on matched %q", failFast)})
+		} else if result.Category == detector.FailureNetwork && !r.networkReachable() {
+			// An outage, not the task's fault: the attempt does not count,
+			// and no task starts until the network is back.
+			state.Status = queue.StatusPending
+			state.Attempt--
+			r.networkDown = true
+			log.Printf("WARN: task %s failed on a network error and %s is unreachable; pausing the queue", task.ID, r.Config.NetworkProbe)
+		} else if result.Category != "" && !r.retryable(result.Category) && forceRetry == "" {
+			// Retrying would fail the same way (no_retry_failures).
+			state.Status = queue.StatusFailed
+			log.Printf("Task %s failed (%s); not retrying", task.ID, result.Category)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s failed: %s", task.ID, result.Reason))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason})
+		} else if state.Attempt < task.MaxRetries {
 			state.Status = queue.StatusWaiting
 			backoff := exponentialBackoff(state.Attempt)
 			resumeAt := time.Now().Add(backoff)
 			state.ResumeAt = &resumeAt
 			log.Printf("Task %s failed (attempt %d/%d); retry in %v",
 				task.ID, state.Attempt, task.MaxRetries, backoff)
+			if forceRetry != "" {
+				log.Printf("Task %s output matched retry_on pattern %q", task.ID, forceRetry)
+			}
+			r.emit(events.Event{Type: events.TaskRetrying, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason, ResumeAt: state.ResumeAt})
 		} else {
 			state.Status = queue.StatusFailed
 			log.Printf("Task %s failed after %d attempts; giving up", task.ID, state.Attempt)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s failed after %d attempts", task.ID, state.Attempt))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason})
 		}
 
 	default: // Unknown
@@ -622,81 +1454,217 @@ func (r *Runner) executeTask(task *queue.Task, state *queue.TaskState, stateDir
 			resumeAt := time.Now().Add(backoff)
 			state.ResumeAt = &resumeAt
 			log.Printf("Task %s unknown result; retry once (attempt %d)", task.ID, state.Attempt)
+			r.emit(events.Event{Type: events.TaskRetrying, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason, ResumeAt: state.ResumeAt})
 		} else {
 			state.Status = queue.StatusFailed
 			log.Printf("Task %s unknown result after retry; marking failed", task.ID)
+			r.notify(notifier.EventTaskFailed, task.ID, fmt.Sprintf("Task %s failed with an unknown result", task.ID))
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: task.ID, Attempt: state.Attempt, Reason: result.Reason})
 		}
 	}
 
 	now = time.Now().UTC()
 	state.EndedAt = &now
 
-	if err := queue.SaveState(stateDir, state); err != nil {
+	if err := r.saveState(stateDir, state); err != nil {
 		log.Printf("ERROR: save post-run state for %s: %v", task.ID, err)
 	}
 
-	return ExitOK
+	return ExitOK, false
 }
 
-// buildPromptWithContext prepends context file contents to the task prompt.
-// Each context file is formatted as:
+// retryable reports whether a failure of category is retried, that is, not
+// listed in no_retry_failures.
+func (r *Runner) retryable(category detector.FailureCategory) bool {
+	if r.Config == nil {
+		return true
+	}
+	noRetry, err := config.ParseFailureList(r.Config.NoRetryFailures)
+	if err != nil {
+		return true
+	}
+	for _, c := range noRetry {
+		if c == category {
+			return false
+		}
+	}
+	return true
+}
+
+// hangTimeout returns how long task may produce no output before it is
+// killed: its hang_timeout, else the global one. 0 means never, for a task
+// with no_hang_detection.
+func (r *Runner) hangTimeout(task *queue.Task) time.Duration {
+	if task.NoHangDetection {
+		return 0
+	}
+	if d, err := time.ParseDuration(task.HangTimeout); err == nil && d > 0 {
+		return d
+	}
+	return durationOr(r.Config.HangTimeout, 10*time.Minute)
+}
+
+// matchOutput returns the first of patterns found in any of texts,
+// ignoring case, or "" if none is.
+func matchOutput(patterns []string, texts ...string) string {
+	for _, p := range patterns {
+		lower := strings.ToLower(p)
+		for _, text := range texts {
+			if strings.Contains(strings.ToLower(text), lower) {
+				return p
+			}
+		}
+	}
+	return ""
+}
+
+// failOnNoChanges reports whether an empty completion diff fails task,
+// honoring the task's fail_on_no_changes override. Plan tasks change
+// nothing by design.
+func (r *Runner) failOnNoChanges(task *queue.Task) bool {
+	if task.Plan {
+		return false
+	}
+	if task.FailOnNoChanges != nil {
+		return *task.FailOnNoChanges
+	}
+	return r.Config.FailOnNoChanges
+}
+
+// recordAttempt appends the just-finished attempt to the task's history.
+func recordAttempt(state *queue.TaskState, startedAt time.Time, exitCode int, result, reason string, costUSD float64, tokens int) {
+	state.Attempts = append(state.Attempts, queue.Attempt{
+		Number:    state.Attempt,
+		StartedAt: startedAt,
+		EndedAt:   time.Now().UTC(),
+		ExitCode:  exitCode,
+		Result:    result,
+		Reason:    reason,
+		SessionID: state.SessionID,
+		CostUSD:   costUSD,
+		Tokens:    tokens,
+	})
+}
+
+// emit sends a lifecycle event to the event stream and the HTTP listener,
+// whichever are enabled.
+func (r *Runner) emit(ev events.Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now().UTC()
+	}
+	r.Events.Emit(ev)
+	// Output lines stay out of the event log: they would crowd the
+	// lifecycle records out of its rotation, and the per-task log already
+	// keeps them (sealed, with encryption at rest).
+	if ev.Type != events.OutputChunk {
+		r.journal.Emit(ev)
+	}
+	r.hub.Publish(ev)
+}
+
+// notify sends an event through the notifier, if one is configured.
+func (r *Runner) notify(eventType notifier.EventType, taskID, message string) {
+	if r.Notifier == nil {
+		return
+	}
+	r.Notifier.Notify(notifier.Event{Type: eventType, TaskID: taskID, Message: message})
+}
+
+// notifySummary is notify for a finished task, adding the summary it gave
+// in its final message.
+func (r *Runner) notifySummary(eventType notifier.EventType, taskID, message, summary string) {
+	if r.Notifier == nil {
+		return
+	}
+	r.Notifier.Notify(notifier.Event{Type: eventType, TaskID: taskID, Message: message, Summary: summary})
+}
+
+// buildPromptWithContext expands {{task:<id>.summary}} references in the
+// task prompt, wraps it in planning instructions for plan tasks, and
+// prepends context file contents and context command output to it. Each is
+// formatted as:
 //
 //	[File: <path>]
 //	<contents>
+//
+//	[Command: <command>]
+//	<stdout>
 func (r *Runner) buildPromptWithContext(task *queue.Task, state *queue.TaskState) (string, error) {
-	if len(task.ContextFiles) == 0 {
-		return r.maybeWrapResume(task.Prompt, state, task), nil
+	prompt, err := expandSummaries(task.Prompt, r.Paths.StateDir())
+	if err != nil {
+		return "", err
+	}
+	if task.Plan {
+		prompt = planPrompt(prompt)
+	}
+	if len(task.ContextFiles) == 0 && len(task.ContextCommands) == 0 {
+		return r.maybeWrapResume(prompt, state, task), nil
+	}
+
+	limits := contextLimits{
+		MaxFileBytes:  int64(r.Config.ContextMaxFileBytes),
+		MaxTotalBytes: int64(r.Config.ContextMaxTotalBytes),
+		Binary:        r.Config.ContextBinary,
+	}
+	files, err := loadContextFiles(task.WorkingDir, task.ContextFiles, limits)
+	if err != nil {
+		return "", err
 	}
 
 	var b strings.Builder
-	for _, fileRef := range task.ContextFiles {
-		resolvedPath := fileRef
-		// Resolve relative paths against the task working directory.
-		if !filepath.IsAbs(resolvedPath) {
-			resolvedPath = filepath.Join(task.WorkingDir, resolvedPath)
-		}
+	for _, f := range files {
+		b.WriteString(fmt.Sprintf("[File: %s]\n", f.Label))
+		b.Write(f.Data)
+		b.WriteString("\n\n")
+	}
 
-		data, err := os.ReadFile(resolvedPath)
+	for _, command := range task.ContextCommands {
+		out, err := runContextCommand(task.WorkingDir, command, limits.MaxFileBytes)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return "", fmt.Errorf("Context file '%s' not found in %s", fileRef, task.WorkingDir)
-			}
-			return "", fmt.Errorf("read context file '%s': %w", fileRef, err)
+			return "", err
 		}
-
-		b.WriteString(fmt.Sprintf("[File: %s]\n", fileRef))
-		b.Write(data)
+		b.WriteString(fmt.Sprintf("[Command: %s]\n", command))
+		b.WriteString(out)
 		b.WriteString("\n\n")
 	}
 
-	b.WriteString(r.maybeWrapResume(task.Prompt, state, task))
+	b.WriteString(r.maybeWrapResume(prompt, state, task))
 	return b.String(), nil
 }
 
+// resumeStrategy returns how a retry of task continues from its previous
+// attempt, honoring the task's resume_strategy field and what the CLI it
+// runs with supports.
+func (r *Runner) resumeStrategy(task *queue.Task, state *queue.TaskState) resume.ResumeStrategy {
+	claude, _ := r.cliFor(task)
+	return resume.SelectStrategy(task.ResumeStrategy, state.SessionID != "", claude.adapter.SupportsResume())
+}
+
 // maybeWrapResume wraps the prompt with resume context if this is a retry
-// and native resume is not being used.
+// using the re-prompt strategy.
 func (r *Runner) maybeWrapResume(prompt string, state *queue.TaskState, task *queue.Task) string {
-	if state.Attempt <= 1 {
+	if state.Attempt <= 1 || r.resumeStrategy(task, state) != resume.RePrompt {
 		return prompt
 	}
 
-	// If we have a session ID and native resume is supported, the prompt
-	// is sent as-is (the --resume flag handles continuation).
-	if state.SessionID != "" && r.Adapter.SupportsResume() {
-		strategy := resume.DetermineStrategy(true, r.Adapter.SupportsResume())
-		if strategy == resume.NativeResume {
-			return prompt
-		}
+	if !state.Checkpoint.Empty() {
+		return resume.BuildCheckpointPrompt(state.Attempt, state.Checkpoint, state.ResumeContext, prompt)
+	}
+	// States written before resume_context existed fall back to the raw
+	// last lines.
+	if state.ResumeContext != "" {
+		return resume.BuildResumePromptFromContext(state.Attempt, state.ResumeContext, prompt)
 	}
-
-	// Use re-prompt strategy: wrap with resume context.
 	return resume.BuildResumePrompt(state.Attempt, state.LastNDJSONMessages, prompt)
 }
 
-// processControlCommands reads and applies queued control commands, then
-// clears the command file.
+// processControlCommands applies queued control commands exactly once. They
+// are claimed into the control journal, each is marked done there after its
+// state is saved, and the journal is removed once all are applied. A
+// command interrupted by a crash is replayed on the next call unless its
+// state save already happened, which the state's control_id records.
 func (r *Runner) processControlCommands(controlDir, stateDir string) error {
-	commands, err := queue.ReadCommands(controlDir)
+	commands, err := queue.ClaimCommands(controlDir)
 	if err != nil {
 		return err
 	}
@@ -704,73 +1672,161 @@ func (r *Runner) processControlCommands(controlDir, stateDir string) error {
 		return nil
 	}
 
+	var tasks []queue.Task // loaded for the first approve, reject or remove
 	for _, cmd := range commands {
-		st, err := queue.LoadState(stateDir, cmd.TaskID)
-		if err != nil {
-			log.Printf("WARN: control cmd %s for %s: load state: %v", cmd.Op, cmd.TaskID, err)
-			continue
+		ev := events.Event{Type: events.ControlOp, Op: cmd.Op, TaskID: cmd.TaskID}
+		switch cmd.Op {
+		case "undo":
+			r.applyUndo(cmd.TaskID, stateDir)
+			ev.TaskID, ev.Reason = "", "trash entry "+cmd.TaskID
+		case "reload":
+			r.reload()
+		case "remove":
+			r.applyRemove(cmd.TaskID, stateDir, &tasks)
+		default:
+			r.applyControlCommand(cmd, stateDir, &tasks)
 		}
-		if st == nil {
-			st = &queue.TaskState{
-				ID:     cmd.TaskID,
-				Status: queue.StatusPending,
-			}
+		r.record(ev)
+		if err := queue.MarkCommandDone(controlDir, cmd.ID); err != nil {
+			return err
 		}
+	}
 
-		switch cmd.Op {
-		case "retry":
-			if st.Status == queue.StatusFailed || st.Status == queue.StatusCancelled {
-				if queue.ValidTransition(st.Status, queue.StatusPending) {
-					st.Status = queue.StatusPending
-					st.Attempt = 0
-					st.ResumeAt = nil
-					log.Printf("Control: retrying task %s", cmd.TaskID)
-				}
+	return queue.ClearJournal(controlDir)
+}
+
+// applyControlCommand applies one control command to its task's state.
+// tasks is loaded on first use.
+func (r *Runner) applyControlCommand(cmd queue.ControlCommand, stateDir string, tasks *[]queue.Task) {
+	st, err := queue.LoadState(stateDir, cmd.TaskID)
+	if err != nil {
+		log.Printf("WARN: control cmd %s for %s: load state: %v", cmd.Op, cmd.TaskID, err)
+		return
+	}
+	if st == nil {
+		st = &queue.TaskState{
+			ID:     cmd.TaskID,
+			Status: queue.StatusPending,
+		}
+	}
+	if cmd.ID != "" && st.ControlID == cmd.ID {
+		return // applied before a crash that lost only its journal mark
+	}
+
+	switch cmd.Op {
+	case "retry":
+		if st.Status == queue.StatusFailed || st.Status == queue.StatusCancelled {
+			if queue.ValidTransition(st.Status, queue.StatusPending) {
+				st.Status = queue.StatusPending
+				st.Attempt = 0
+				st.ResumeAt = nil
+				log.Printf("Control: retrying task %s", cmd.TaskID)
+			}
+		}
+	case "cancel":
+		if queue.ValidTransition(st.Status, queue.StatusCancelled) {
+			snap := queue.NewSnapshot(r.Paths.TrashDir(), "cancel", time.Now())
+			if err := snap.Copy(stateDir, cmd.TaskID, st.Status); err != nil {
+				log.Printf("WARN: control cmd cancel for %s: snapshot for undo: %v", cmd.TaskID, err)
 			}
-		case "cancel":
-			if queue.ValidTransition(st.Status, queue.StatusCancelled) {
-				st.Status = queue.StatusCancelled
-				log.Printf("Control: cancelled task %s", cmd.TaskID)
+			st.Status = queue.StatusCancelled
+			log.Printf("Control: cancelled task %s", cmd.TaskID)
+		}
+	case "approve", "reject":
+		if *tasks == nil {
+			if *tasks, err = queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir); err != nil {
+				log.Printf("WARN: control cmd %s for %s: load tasks: %v", cmd.Op, cmd.TaskID, err)
+				return
 			}
-		default:
-			log.Printf("WARN: unknown control op %q for task %s", cmd.Op, cmd.TaskID)
-			continue
 		}
-
-		if err := queue.SaveState(stateDir, st); err != nil {
-			log.Printf("WARN: control cmd %s for %s: save state: %v", cmd.Op, cmd.TaskID, err)
+		var task *queue.Task
+		for i := range *tasks {
+			if (*tasks)[i].ID == cmd.TaskID {
+				task = &(*tasks)[i]
+			}
+		}
+		if task == nil {
+			log.Printf("WARN: control cmd %s: unknown task %s", cmd.Op, cmd.TaskID)
+			return
 		}
+		changes := st.DiffSummary
+		if err := review.Decide(st, task.WorkingDir, cmd.Op == "approve"); err != nil {
+			log.Printf("WARN: control cmd %s for %s: %v", cmd.Op, cmd.TaskID, err)
+			return
+		}
+		if st.Status == queue.StatusDone {
+			log.Printf("Control: approved task %s", cmd.TaskID)
+			r.emit(events.Event{Type: events.TaskDone, TaskID: cmd.TaskID, Attempt: st.Attempt, Changes: changes})
+		} else {
+			log.Printf("Control: rejected task %s", cmd.TaskID)
+			r.emit(events.Event{Type: events.TaskFailed, TaskID: cmd.TaskID, Attempt: st.Attempt, Reason: "rejected in review"})
+		}
+	default:
+		log.Printf("WARN: unknown control op %q for task %s", cmd.Op, cmd.TaskID)
+		return
 	}
 
-	return queue.ClearCommands(controlDir)
+	st.ControlID = cmd.ID
+	if err := r.saveState(stateDir, st); err != nil {
+		log.Printf("WARN: control cmd %s for %s: save state: %v", cmd.Op, cmd.TaskID, err)
+	}
+}
+
+// applyUndo reverses the trash entry name (queued by 'undo' in place of a
+// task ID). Replays are harmless: an undone entry no longer exists.
+func (r *Runner) applyUndo(name, stateDir string) {
+	restored, skipped, err := queue.Undo(r.Paths.TrashDir(), stateDir, r.Paths.LogsDir(), name)
+	if err != nil {
+		log.Printf("WARN: control cmd undo %s: %v", name, err)
+	}
+	for _, id := range restored {
+		log.Printf("Control: undo restored task %s", id)
+	}
+	for id, why := range skipped {
+		log.Printf("Control: undo skipped task %s (%s)", id, why)
+	}
 }
 
-// showCountdown displays a countdown timer to the next resume time.
-func (r *Runner) showCountdown(resumeAt time.Time, task *queue.Task, attempt int) {
-	remaining := time.Until(resumeAt).Truncate(time.Second)
-	if remaining < 0 {
-		remaining = 0
+// applyRemove takes task id out of the queue for 'task remove'. A replay
+// finds the task gone and does nothing.
+func (r *Runner) applyRemove(id, stateDir string, tasks *[]queue.Task) {
+	if *tasks == nil {
+		var err error
+		if *tasks, err = queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir); err != nil {
+			log.Printf("WARN: control cmd remove for %s: load tasks: %v", id, err)
+			return
+		}
+	}
+	for i := range *tasks {
+		if (*tasks)[i].ID != id {
+			continue
+		}
+		if err := queue.RemoveTask(r.Paths.TrashDir(), stateDir, r.Paths.LogsDir(), &(*tasks)[i], time.Now()); err != nil {
+			log.Printf("WARN: control cmd remove for %s: %v", id, err)
+			return
+		}
+		log.Printf("Control: removed task %s", id)
+		return
 	}
-	fmt.Printf("\r  Waiting for %s (attempt %d) — resumes in %v  ",
-		task.ID, attempt, remaining)
 }
 
 // printSummary prints a completion summary of all tasks.
 func (r *Runner) printSummary(stateDir string, runStarted time.Time) {
-	base := config.BaseDir()
-	globalTaskDir := filepath.Join(base, "tasks")
-
-	tasks, _, err := queue.LoadTasksAndInit(globalTaskDir, r.ProjectDir, stateDir)
+	tasks, _, err := queue.LoadTasksAndInit(r.Paths.TasksDir(), r.ProjectDir, stateDir)
 	if err != nil {
 		log.Printf("WARN: could not load tasks for summary: %v", err)
 		return
 	}
+	tasks = r.selectTasks(tasks)
 
-	var done, failed, cancelled, pending, waiting int
+	states, _ := queue.LoadAllStates(stateDir)
+	var done, failed, cancelled, pending, waiting, needsReview int
+	rec := runs.Record{ID: runs.ID(runStarted), StartedAt: runStarted.UTC()}
 	for _, t := range tasks {
-		st, _ := queue.LoadState(stateDir, t.ID)
+		st := states[t.ID]
 		if st == nil {
 			pending++
+			rec.Tasks = append(rec.Tasks, runs.Task{ID: t.ID, Status: queue.StatusPending})
 			continue
 		}
 		switch st.Status {
@@ -784,6 +1840,8 @@ func (r *Runner) printSummary(stateDir string, runStarted time.Time) {
 			pending++
 		case queue.StatusWaiting:
 			waiting++
+		case queue.StatusNeedsReview:
+			needsReview++
 		}
 
 		retries := st.Attempt - 1
@@ -792,29 +1850,104 @@ func (r *Runner) printSummary(stateDir string, runStarted time.Time) {
 		}
 		duration := formatTaskDuration(st.StartedAt, st.EndedAt)
 		line := fmt.Sprintf("Task %s: %s (%s, %d retries)", t.ID, strings.ToUpper(st.Status), duration, retries)
-		fmt.Println(line)
-		_ = appendSummaryLog(line)
+		ui.Println(line)
+		_ = r.appendSummaryLog(line)
+		if (st.Status == queue.StatusDone || st.Status == queue.StatusNeedsReview) && st.DiffSummary != "" {
+			diffLine := "  changes: " + st.DiffSummary
+			if st.NoChanges {
+				diffLine += " (suspicious: completed without modifying files)"
+			}
+			ui.Println(diffLine)
+			_ = r.appendSummaryLog(diffLine)
+		}
+		if n := len(st.Attempts); st.Status == queue.StatusFailed && n > 0 && st.Attempts[n-1].Failure != "" {
+			failLine := "  failure: " + st.Attempts[n-1].Reason
+			ui.Println(failLine)
+			_ = r.appendSummaryLog(failLine)
+		}
+		for _, a := range st.Artifacts {
+			ui.Printf("  artifact: %s\n", a)
+			_ = r.appendSummaryLog("  artifact: " + a)
+		}
+		rec.Tasks = append(rec.Tasks, summaryTask(st, retries))
 	}
 
-	fmt.Println()
-	fmt.Println("=== Run Summary ===")
-	fmt.Printf("  Done:      %d\n", done)
-	fmt.Printf("  Failed:    %d\n", failed)
-	fmt.Printf("  Cancelled: %d\n", cancelled)
-	fmt.Printf("  Pending:   %d\n", pending)
-	fmt.Printf("  Waiting:   %d\n", waiting)
-	fmt.Printf("  Total:     %d\n", len(tasks))
-	fmt.Printf("  Elapsed:   %s\n", time.Since(runStarted).Truncate(time.Second))
-
-	_ = appendSummaryLog(fmt.Sprintf("Run completed: done=%d failed=%d cancelled=%d pending=%d waiting=%d total=%d elapsed=%s",
+	ui.Println()
+	ui.Println("=== Run Summary ===")
+	ui.Printf("  Done:      %d\n", done)
+	ui.Printf("  Failed:    %d\n", failed)
+	ui.Printf("  Cancelled: %d\n", cancelled)
+	ui.Printf("  Pending:   %d\n", pending)
+	ui.Printf("  Waiting:   %d\n", waiting)
+	if needsReview > 0 {
+		ui.Printf("  Review:    %d (see 'claude-autopilot review')\n", needsReview)
+	}
+	ui.Printf("  Total:     %d\n", len(tasks))
+	ui.Printf("  Elapsed:   %s\n", time.Since(runStarted).Truncate(time.Second))
+
+	_ = r.appendSummaryLog(fmt.Sprintf("Run completed: done=%d failed=%d cancelled=%d pending=%d waiting=%d total=%d elapsed=%s",
 		done, failed, cancelled, pending, waiting, len(tasks), time.Since(runStarted).Truncate(time.Second)))
+	totals := events.Summary{
+		Done: done, Failed: failed, Cancelled: cancelled, Pending: pending, Waiting: waiting, NeedsReview: needsReview,
+		Total: len(tasks), ElapsedSeconds: time.Since(runStarted).Truncate(time.Second).Seconds(),
+	}
+	r.emit(events.Event{Type: events.RunSummary, Summary: &totals})
+
+	rec.EndedAt, rec.Totals = time.Now().UTC(), totals
+	if err := runs.Append(r.Paths.RunHistory(), rec); err != nil {
+		log.Printf("WARN: record run summary: %v", err)
+	}
+}
+
+// summaryTask is the run-history entry for a task with state st.
+func summaryTask(st *queue.TaskState, retries int) runs.Task {
+	t := runs.Task{ID: st.ID, Status: st.Status, Retries: retries, Artifacts: st.Artifacts}
+	if st.StartedAt != nil && st.EndedAt != nil && st.EndedAt.After(*st.StartedAt) {
+		t.Duration = st.EndedAt.Sub(*st.StartedAt)
+	}
+	if st.Status == queue.StatusDone || st.Status == queue.StatusNeedsReview {
+		t.Changes, t.NoChanges = st.DiffSummary, st.NoChanges
+	}
+	if n := len(st.Attempts); st.Status == queue.StatusFailed && n > 0 && st.Attempts[n-1].Failure != "" {
+		t.Failure = st.Attempts[n-1].Reason
+	}
+	return t
+}
+
+// removeOrphanState deletes the state and logs of tasks that were removed
+// from their task files more than retention ago. Tasks whose source was
+// never recorded are left for 'clean --orphan-state'.
+func (r *Runner) removeOrphanState(retention time.Duration) {
+	tasks, err := queue.LoadTasks(r.Paths.TasksDir(), r.ProjectDir)
+	if err != nil {
+		return // reported when the queue is loaded
+	}
+	orphans, err := queue.FindOrphans(r.Paths.StateDir(), tasks)
+	if err != nil {
+		log.Printf("WARN: orphan state cleanup: %v", err)
+		return
+	}
+	removed := 0
+	for _, o := range orphans {
+		if o.Unverified || time.Since(o.ModTime) < retention {
+			continue
+		}
+		if _, err := queue.RemoveTaskData(r.Paths.StateDir(), r.Paths.LogsDir(), o.ID); err != nil {
+			log.Printf("WARN: remove state of deleted task %s: %v", o.ID, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Printf("Removed the state of %d deleted task(s) (state_retention %s)", removed, retention)
+	}
 }
 
 // checkFirstRun checks for the .first-run-ack file. If it does not exist,
 // prompts the user for acknowledgement. Returns true if the user acknowledged
 // (or the file already exists), false if declined.
 func (r *Runner) checkFirstRun() bool {
-	ackPath := filepath.Join(config.BaseDir(), ".first-run-ack")
+	ackPath := filepath.Join(r.Paths.ConfigDir, ".first-run-ack")
 
 	if _, err := os.Stat(ackPath); err == nil {
 		return true
@@ -895,6 +2028,13 @@ func hashPrompt(prompt string) string {
 	return fmt.Sprintf("%x", h[:8])
 }
 
+// estimateTokens approximates the token count of a prompt using the common
+// heuristic of ~4 characters per token. It is deliberately rough: the guard
+// only needs to catch prompts that are wildly oversized.
+func estimateTokens(prompt string) int {
+	return (utf8.RuneCountInString(prompt) + 3) / 4
+}
+
 // exponentialBackoff calculates the retry delay for a given attempt number.
 // Base delay is 5 minutes, doubling each attempt, capped at 300 minutes
 // (5 hours). A random jitter of +/-20% is applied.
@@ -938,8 +2078,8 @@ func rotateLogIfNeeded(path string, maxBytes int64) error {
 	return os.Rename(path, backup)
 }
 
-func appendSummaryLog(line string) error {
-	logDir := filepath.Join(config.BaseDir(), "logs")
+func (r *Runner) appendSummaryLog(line string) error {
+	logDir := r.Paths.LogsDir()
 	if err := os.MkdirAll(logDir, 0755); err != nil {
 		return err
 	}
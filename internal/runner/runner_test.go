@@ -1,10 +1,25 @@
 package runner
 
 import (
+	"encoding/json"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/compat"
+	"github.com/hseinmoussa/claude-autopilot/internal/config"
+	"github.com/hseinmoussa/claude-autopilot/internal/detector"
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
 )
 
 func TestRotateLogIfNeeded(t *testing.T) {
@@ -31,3 +46,1526 @@ func TestFormatTaskDuration(t *testing.T) {
 		t.Fatalf("expected duration, got %q", got)
 	}
 }
+
+func TestRecordAttempt_AppendsHistory(t *testing.T) {
+	state := &queue.TaskState{ID: "t", Attempt: 1, SessionID: "s1"}
+	started := time.Now().Add(-time.Minute).UTC()
+
+	recordAttempt(state, started, 75, "rate_limited", "pattern match", 0.12, 1500)
+	state.Attempt = 2
+	recordAttempt(state, started, 0, "completed", "", 0.30, 0)
+
+	if len(state.Attempts) != 2 {
+		t.Fatalf("got %d attempts; want 2", len(state.Attempts))
+	}
+	first := state.Attempts[0]
+	if first.Number != 1 || first.ExitCode != 75 || first.Result != "rate_limited" || first.SessionID != "s1" || first.CostUSD != 0.12 || first.Tokens != 1500 {
+		t.Errorf("first attempt = %+v", first)
+	}
+	if !first.StartedAt.Equal(started) || first.EndedAt.Before(started) {
+		t.Errorf("first attempt times = %v .. %v", first.StartedAt, first.EndedAt)
+	}
+	if state.Attempts[1].Number != 2 {
+		t.Errorf("second attempt number = %d", state.Attempts[1].Number)
+	}
+}
+
+func writeContextFixture(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func contextLabels(files []contextFile) []string {
+	var labels []string
+	for _, f := range files {
+		labels = append(labels, f.Label)
+	}
+	return labels
+}
+
+func TestLoadContextFiles_GlobAndDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFixture(t, dir, "proto/a.proto", "a")
+	writeContextFixture(t, dir, "proto/v1/b.proto", "b")
+	writeContextFixture(t, dir, "proto/readme.md", "r")
+	writeContextFixture(t, dir, "docs/one.md", "1")
+	writeContextFixture(t, dir, "docs/nested/two.md", "2")
+
+	files, err := loadContextFiles(dir, []string{"proto/**/*.proto", "docs", "proto/a.proto"}, contextLimits{})
+	if err != nil {
+		t.Fatalf("loadContextFiles: %v", err)
+	}
+
+	got := strings.Join(contextLabels(files), ",")
+	want := "proto/a.proto,proto/v1/b.proto,docs/one.md"
+	if got != want {
+		t.Errorf("labels = %s; want %s", got, want)
+	}
+}
+
+func TestLoadContextFiles_Errors(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFixture(t, dir, "big.txt", strings.Repeat("x", 100))
+	writeContextFixture(t, dir, "small.txt", strings.Repeat("y", 60))
+	writeContextFixture(t, dir, "blob.bin", "ab\x00cd")
+
+	cases := []struct {
+		name   string
+		refs   []string
+		limits contextLimits
+		want   string
+	}{
+		{"missing file", []string{"nope.txt"}, contextLimits{}, "not found"},
+		{"empty glob", []string{"*.go"}, contextLimits{}, "matched no files"},
+		{"per-file cap", []string{"big.txt"}, contextLimits{MaxFileBytes: 50}, "per-file limit"},
+		{"total cap", []string{"big.txt", "small.txt"}, contextLimits{MaxTotalBytes: 120}, "total limit"},
+		{"binary error", []string{"blob.bin"}, contextLimits{Binary: BinaryError}, "looks binary"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := loadContextFiles(dir, tc.refs, tc.limits)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v; want containing %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadContextFiles_SkipsBinary(t *testing.T) {
+	dir := t.TempDir()
+	writeContextFixture(t, dir, "blob.bin", "ab\x00cd")
+	writeContextFixture(t, dir, "notes.txt", "hello")
+
+	files, err := loadContextFiles(dir, []string{"*"}, contextLimits{Binary: BinarySkip})
+	if err != nil {
+		t.Fatalf("loadContextFiles: %v", err)
+	}
+	if got := strings.Join(contextLabels(files), ","); got != "notes.txt" {
+		t.Errorf("labels = %s; want notes.txt", got)
+	}
+}
+
+func TestRunContextCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	dir := t.TempDir()
+	writeContextFixture(t, dir, "marker.txt", "here")
+
+	out, err := runContextCommand(dir, "ls", 0)
+	if err != nil {
+		t.Fatalf("runContextCommand: %v", err)
+	}
+	if !strings.Contains(out, "marker.txt") {
+		t.Errorf("output %q should list working dir", out)
+	}
+
+	out, err = runContextCommand(dir, "echo failing tests; exit 2", 0)
+	if err != nil {
+		t.Fatalf("non-zero exit should not error: %v", err)
+	}
+	if !strings.Contains(out, "failing tests") || !strings.Contains(out, "[exit status 2]") {
+		t.Errorf("output = %q", out)
+	}
+
+	out, err = runContextCommand(dir, "printf 0123456789", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(out, "6789") || !strings.Contains(out, "truncated") {
+		t.Errorf("truncated output = %q", out)
+	}
+
+	// Keeping the last 3 bytes of "aéé" would start inside the first é.
+	out, err = runContextCommand(dir, "printf 'a\\303\\251\\303\\251'", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !utf8.ValidString(out) || !strings.HasSuffix(out, "\n\u00e9") {
+		t.Errorf("output cut inside a character: %q", out)
+	}
+}
+
+func TestRunContextCommand_TimeoutIgnoresBackgroundChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	defer func(d time.Duration) { contextCommandTimeout = d }(contextCommandTimeout)
+	contextCommandTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	if _, err := runContextCommand(t.TempDir(), "sleep 10 | cat", 0); err == nil {
+		t.Error("a timed-out command should be reported")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runContextCommand blocked for %s on a pipeline that outlived its shell", elapsed)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if got := estimateTokens(""); got != 0 {
+		t.Errorf("estimateTokens(\"\") = %d; want 0", got)
+	}
+	if got := estimateTokens(strings.Repeat("a", 400)); got != 100 {
+		t.Errorf("estimateTokens(400 chars) = %d; want 100", got)
+	}
+	// Multi-byte runes count as single characters.
+	if got := estimateTokens(strings.Repeat("é", 8)); got != 2 {
+		t.Errorf("estimateTokens(8 runes) = %d; want 2", got)
+	}
+}
+
+func TestSelectTasks_OnlyAndExclude(t *testing.T) {
+	tasks := []queue.Task{
+		{ID: "fix-auth-1a2b", Tags: []string{"backend"}},
+		{ID: "fix-ui-3c4d", Tags: []string{"frontend"}},
+		{ID: "docs-5e6f", Tags: []string{"backend"}},
+	}
+	ids := func(ts []queue.Task) string {
+		var out []string
+		for _, t := range ts {
+			out = append(out, t.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	r := &Runner{Only: []string{"fix-auth-1a2b"}}
+	if got := ids(r.selectTasks(tasks)); got != "fix-auth-1a2b" {
+		t.Errorf("--only = %s", got)
+	}
+
+	r = &Runner{Exclude: []string{"fix-*"}}
+	if got := ids(r.selectTasks(tasks)); got != "docs-5e6f" {
+		t.Errorf("--exclude fix-* = %s", got)
+	}
+
+	r = &Runner{Tags: []string{"backend"}, Exclude: []string{"docs-5e6f"}}
+	if got := ids(r.selectTasks(tasks)); got != "fix-auth-1a2b" {
+		t.Errorf("--tag backend --exclude docs = %s", got)
+	}
+}
+
+func TestSleepUntil_StopsOnShutdown(t *testing.T) {
+	r := &Runner{}
+	r.ShuttingDown.Store(true)
+	if r.sleepUntil(time.Now().Add(time.Hour), &queue.Task{ID: "t"}, 1) {
+		t.Fatal("sleepUntil should return false when shutting down")
+	}
+}
+
+func TestWaitForWake_WakesOnControlCommand(t *testing.T) {
+	controlDir := t.TempDir()
+	w := newQueueWatcher(controlDir)
+	if w == nil {
+		t.Skip("file watching unavailable")
+	}
+	defer w.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(filepath.Join(controlDir, "commands.jsonl"), []byte("{}\n"), 0644)
+	}()
+
+	r := &Runner{}
+	start := time.Now()
+	if !r.waitForWake(time.Now().Add(time.Minute), w, &overview{}, true) {
+		t.Fatal("waitForWake reported shutdown")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("woke after %v; want prompt wake on control command", elapsed)
+	}
+}
+
+func TestWaitForWake_IgnoresUnrelatedFiles(t *testing.T) {
+	controlDir := t.TempDir()
+	w := newQueueWatcher(controlDir)
+	if w == nil {
+		t.Skip("file watching unavailable")
+	}
+	defer w.Close()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		os.WriteFile(filepath.Join(controlDir, ".tmp-123"), []byte("x"), 0644)
+	}()
+
+	r := &Runner{}
+	start := time.Now()
+	r.waitForWake(time.Now().Add(500*time.Millisecond), w, &overview{}, true)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("woke after %v on an unrelated file", elapsed)
+	}
+}
+
+func TestDirLock_ContendedPolicies(t *testing.T) {
+	workDir := t.TempDir()
+	stateDir := t.TempDir()
+	task := &queue.Task{ID: "t", WorkingDir: workDir}
+
+	r := &Runner{Config: &config.Config{DirLockPolicy: DirLockWait}}
+	held, ok := r.acquireDirLock(task)
+	if !ok || held == nil {
+		t.Fatal("first acquire should succeed")
+	}
+	defer held.Release()
+
+	if _, ok := r.acquireDirLock(task); ok {
+		t.Fatal("second acquire should report contention")
+	}
+
+	state := &queue.TaskState{ID: "t", Status: queue.StatusPending, Attempt: 1}
+	r.deferForDirLock(task, state, stateDir, map[string]bool{})
+	if state.Status != queue.StatusWaiting || state.ResumeAt == nil || state.Attempt != 1 {
+		t.Errorf("wait policy state = %+v", state)
+	}
+
+	r.Config.DirLockPolicy = DirLockSkip
+	skipped := map[string]bool{}
+	state = &queue.TaskState{ID: "t", Status: queue.StatusPending}
+	r.deferForDirLock(task, state, stateDir, skipped)
+	if !skipped["t"] || state.Status != queue.StatusPending {
+		t.Errorf("skip policy: skipped=%v state=%+v", skipped, state)
+	}
+
+	r.Config.DirLockPolicy = DirLockOff
+	if lk, ok := r.acquireDirLock(task); !ok || lk != nil {
+		t.Errorf("off policy should run unguarded")
+	}
+}
+
+func TestGroupLock_Contended(t *testing.T) {
+	stateDir := t.TempDir()
+	r := &Runner{Paths: config.At(t.TempDir())}
+
+	if lk, ok := r.acquireGroupLock(&queue.Task{ID: "free"}); !ok || lk != nil {
+		t.Fatal("a task without a group should run unguarded")
+	}
+
+	task := &queue.Task{ID: "t", Group: "db"}
+	held, ok := r.acquireGroupLock(&queue.Task{ID: "other", Group: "db"})
+	if !ok || held == nil {
+		t.Fatal("first acquire should succeed")
+	}
+	if _, ok := r.acquireGroupLock(task); ok {
+		t.Fatal("second acquire of the group should report contention")
+	}
+	if lk, ok := r.acquireGroupLock(&queue.Task{ID: "u", Group: "cache"}); !ok || lk == nil {
+		t.Fatal("another group should be free")
+	} else {
+		lk.Release()
+	}
+
+	state := &queue.TaskState{ID: "t", Status: queue.StatusPending, Attempt: 1}
+	r.deferForGroup(task, state, stateDir)
+	if state.Status != queue.StatusWaiting || state.ResumeAt == nil || state.Attempt != 1 {
+		t.Errorf("deferred state = %+v", state)
+	}
+
+	held.Release()
+	if lk, ok := r.acquireGroupLock(task); !ok || lk == nil {
+		t.Error("acquire after release should succeed")
+	} else {
+		lk.Release()
+	}
+}
+
+func TestRun_IfNotRunning(t *testing.T) {
+	paths := config.At(t.TempDir())
+	if err := paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	held, err := lock.AcquireLock(paths.LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	if code := (&Runner{Paths: paths}).Run(); code != ExitLocked {
+		t.Errorf("Run with the lock held = %d; want ExitLocked", code)
+	}
+	if code := (&Runner{Paths: paths, IfNotRunning: true}).Run(); code != ExitOK {
+		t.Errorf("Run --if-not-running with the lock held = %d; want ExitOK", code)
+	}
+}
+
+func TestBeat_StalledLoopLetsHeartbeatGoStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runner.lock")
+	lk, err := lock.AcquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk.Release()
+	r := &Runner{Lock: lk}
+
+	// Back-date the lockfile before each beat to see whether it is written.
+	old := time.Now().Add(-time.Hour)
+	beatWrote := func(seen int64) (int64, bool) {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		seen = r.beat(seen)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seen, info.ModTime().After(old)
+	}
+
+	r.progress()
+	seen, wrote := beatWrote(0)
+	if !wrote {
+		t.Fatal("a beat after progress should refresh the heartbeat")
+	}
+	for i := 0; i < 3; i++ {
+		if seen, wrote = beatWrote(seen); wrote {
+			t.Fatal("a stalled loop kept the heartbeat fresh")
+		}
+	}
+	r.progress()
+	if _, wrote = beatWrote(seen); !wrote {
+		t.Error("progress after a stall should refresh the heartbeat again")
+	}
+}
+
+func TestRunLock_ExclusiveDrains(t *testing.T) {
+	home := t.TempDir()
+	stateDir := t.TempDir()
+	a := &Runner{Paths: config.At(home)}
+	b := &Runner{Paths: config.At(home)}
+	ordinary := &queue.Task{ID: "ordinary"}
+	excl := &queue.Task{ID: "excl", Exclusive: true}
+
+	running, ok := a.acquireRunLock(ordinary)
+	if !ok || running == nil {
+		t.Fatal("an ordinary task should start when nothing is exclusive")
+	}
+	if _, ok := b.acquireRunLock(excl); ok {
+		t.Fatal("an exclusive task should wait for running tasks to drain")
+	}
+	if b.drainGate == nil || b.drainFor != "excl" {
+		t.Fatal("a waiting exclusive task should close the drain gate")
+	}
+	if _, ok := a.acquireRunLock(&queue.Task{ID: "next"}); ok {
+		t.Fatal("no task should start while the queue drains")
+	}
+
+	state := &queue.TaskState{ID: "excl", Status: queue.StatusPending, Attempt: 1}
+	b.deferForRunLock(excl, state, stateDir)
+	if state.Status != queue.StatusWaiting || state.ResumeAt == nil || state.Attempt != 1 {
+		t.Errorf("deferred state = %+v", state)
+	}
+
+	running.Release()
+	lk, ok := b.acquireRunLock(excl)
+	if !ok || lk == nil {
+		t.Fatal("the exclusive task should start once the queue drained")
+	}
+	lk.Release()
+
+	b.releaseDrainGate([]queue.Task{*excl})
+	if b.drainGate == nil {
+		t.Fatal("the gate should stay closed while the exclusive task is queued")
+	}
+	b.releaseDrainGate()
+	if lk, ok := a.acquireRunLock(ordinary); !ok || lk == nil {
+		t.Error("ordinary tasks should start again after the exclusive task")
+	} else {
+		lk.Release()
+	}
+}
+
+func TestOverview(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+	tasks := []queue.Task{{ID: "late"}, {ID: "soon"}, {ID: "blocked"}, {ID: "broken"}, {ID: "done"}}
+	states := map[string]*queue.TaskState{
+		"late":   {Status: queue.StatusWaiting, Attempt: 2, ResumeAt: at(2 * time.Hour)},
+		"soon":   {Status: queue.StatusWaiting, Attempt: 1, ResumeAt: at(90 * time.Second)},
+		"broken": {Status: queue.StatusFailed, EndedAt: at(-time.Minute), Attempts: []queue.Attempt{{Reason: "exit code 1", Failure: "auth"}}},
+		"done":   {Status: queue.StatusDone},
+	}
+
+	lines := newOverview(*states["soon"].ResumeAt, tasks, states).lines(now)
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"Waiting — next resume in 1m 30s",
+		"Pending: 1 ",
+		"Recent failures:",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("overview missing %q:\n%s", want, got)
+		}
+	}
+	if len(lines) != 6 || !strings.HasPrefix(lines[1], "  soon ") || !strings.HasPrefix(lines[2], "  late ") {
+		t.Errorf("want waiting tasks by resume time:\n%s", got)
+	}
+	if !strings.HasPrefix(lines[5], "  broken ") || !strings.HasSuffix(lines[5], " auth") {
+		t.Errorf("failure line = %q; want its category", lines[5])
+	}
+}
+
+func TestSaveState_RecordsStatusChanges(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	r.openJournal()
+	stateDir := r.Paths.StateDir()
+
+	st := &queue.TaskState{ID: "a", Status: queue.StatusPending}
+	r.observeState(st)
+	st.Status, st.Attempt = queue.StatusRunning, 1
+	if err := r.saveState(stateDir, st); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.saveState(stateDir, st); err != nil { // no change
+		t.Fatal(err)
+	}
+	st.Status = queue.StatusDone
+	if err := r.saveState(stateDir, st); err != nil {
+		t.Fatal(err)
+	}
+	r.journal.Close()
+
+	data, err := os.ReadFile(r.Paths.EventLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var ev events.Event
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		got = append(got, ev.From+">"+ev.Status)
+	}
+	if strings.Join(got, " ") != "pending>running running>done" {
+		t.Errorf("recorded transitions %v", got)
+	}
+}
+
+func TestEmit_KeepsOutputOutOfEventLog(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	r.openJournal()
+	r.emit(events.Event{Type: events.TaskStarted, TaskID: "a", Attempt: 1})
+	r.emit(events.Event{Type: events.OutputChunk, TaskID: "a", Stream: "stdout", Output: "secret transcript line"})
+	r.journal.Close()
+
+	data, err := os.ReadFile(r.Paths.EventLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret transcript line") || strings.Contains(string(data), string(events.OutputChunk)) {
+		t.Errorf("output reached the event log:\n%s", data)
+	}
+	if !strings.Contains(string(data), string(events.TaskStarted)) {
+		t.Errorf("lifecycle event missing from the event log:\n%s", data)
+	}
+}
+
+func TestPaceUntil_NearPredictedReset(t *testing.T) {
+	r := &Runner{
+		Paths:  config.At(t.TempDir()),
+		Config: &config.Config{UsageWindow: 5 * time.Hour, PaceBeforeReset: 10 * time.Minute},
+	}
+	now := time.Now()
+
+	if _, ok := r.paceUntil(now); ok {
+		t.Fatal("no history should not pace")
+	}
+
+	reset := now.Add(5 * time.Minute)
+	r.recordRateLimit("t", now.Add(-time.Hour), &reset)
+	until, ok := r.paceUntil(now)
+	if !ok || !until.Equal(reset) {
+		t.Errorf("paceUntil = %v, %v; want %v", until, ok, reset)
+	}
+
+	if _, ok := r.paceUntil(now.Add(-time.Hour)); ok {
+		t.Error("an hour before the reset should not pace")
+	}
+
+	r.Config.PaceBeforeReset = 0
+	if _, ok := r.paceUntil(now); ok {
+		t.Error("pace_before_reset=0 should disable pacing")
+	}
+}
+
+func TestLineWriter_SplitsAcrossWrites(t *testing.T) {
+	var lines []string
+	w := &lineWriter{onLine: func(l string) { lines = append(lines, l) }}
+	w.Write([]byte("rate li"))
+	w.Write([]byte("mit hit\nsecond\nparti"))
+	w.Write([]byte("al"))
+
+	if strings.Join(lines, "|") != "rate limit hit|second" {
+		t.Errorf("lines = %q", lines)
+	}
+	if w.String() != "rate limit hit\nsecond\npartial" {
+		t.Errorf("String() = %q", w.String())
+	}
+}
+
+func TestLineReader_CutsLongLines(t *testing.T) {
+	long := strings.Repeat("x", 200*1024) // longer than the read buffer too
+	l := newLineReader(strings.NewReader("short\r\n"+long+"\nafter\nno newline"), 10)
+
+	var got []string
+	for l.Scan() {
+		line := l.Text()
+		if size, cut := l.Cut(); cut {
+			line += "(" + strconv.Itoa(size) + ")"
+		}
+		got = append(got, line)
+	}
+	if l.Err() != nil {
+		t.Fatalf("Err() = %v at the end of the output", l.Err())
+	}
+	if want := "short|xxxxxxxxxx(204801)|after|no newline"; strings.Join(got, "|") != want {
+		t.Errorf("lines = %q; want %q", strings.Join(got, "|"), want)
+	}
+}
+
+func TestTeeOutput(t *testing.T) {
+	dir := t.TempDir()
+	r := &Runner{}
+	if got := r.teePath(&queue.Task{ID: "a"}, dir); got != "" {
+		t.Errorf("teePath without tee_output or TeeDir = %q", got)
+	}
+	r.TeeDir = filepath.Join(dir, "tee")
+	if got := r.teePath(&queue.Task{ID: "a"}, dir); got != filepath.Join(dir, "tee", "a.log") {
+		t.Errorf("teePath with TeeDir = %q", got)
+	}
+	task := &queue.Task{ID: "a", TeeOutput: filepath.Join("transcripts", "a.txt")}
+	path := r.teePath(task, dir)
+	if path != filepath.Join(dir, "transcripts", "a.txt") {
+		t.Fatalf("teePath with tee_output = %q; want it relative to the working dir", path)
+	}
+
+	tw, err := openTee(path, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Write([]byte("one\n"))
+	tw.Close()
+	// A failed write is swallowed so reading the output goes on.
+	if n, err := tw.Write([]byte("two\n")); n != 4 || err != nil {
+		t.Errorf("Write after Close = %d, %v; want 4, nil", n, err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "one\n" {
+		t.Errorf("tee file = %q", data)
+	}
+}
+
+func TestAllowedAnswers_RequiresAnswerModeAndWhitelist(t *testing.T) {
+	r := &Runner{
+		Config: &config.Config{PromptAction: PromptActionAnswer},
+		PromptAnswers: []config.PromptAnswer{
+			{Name: "proceed", Pattern: "Do you want to proceed?", Response: "y\n"},
+			{Name: "trust", Pattern: "Trust this folder", Response: "1\n"},
+		},
+	}
+	task := &queue.Task{ID: "t", AutoApprove: []string{"proceed", "missing"}}
+
+	got := r.allowedAnswers(task, false)
+	if len(got) != 1 || got[0].Name != "proceed" {
+		t.Fatalf("allowedAnswers = %+v; want only proceed", got)
+	}
+	if a, ok := matchAnswer(got, "...\nDO YOU WANT TO PROCEED? (y/n) "); !ok || a.Name != "proceed" {
+		t.Errorf("matchAnswer on a partial prompt line = %+v, %v", a, ok)
+	}
+	if _, ok := matchAnswer(got, "Trust this folder?"); ok {
+		t.Error("prompt not in the task's whitelist should not match")
+	}
+
+	if got := r.allowedAnswers(task, true); got != nil {
+		t.Errorf("skip_permissions tasks should not answer prompts: %+v", got)
+	}
+	r.Config.PromptAction = PromptActionKill
+	if got := r.allowedAnswers(task, false); got != nil {
+		t.Errorf("kill mode should not answer prompts: %+v", got)
+	}
+}
+
+func TestTailBuffer_KeepsMostRecent(t *testing.T) {
+	tail := &tailBuffer{}
+	tail.Write([]byte(strings.Repeat("x", maxTailBytes)))
+	tail.Write([]byte("Proceed? "))
+	if s := tail.String(); len(s) != maxTailBytes || !strings.HasSuffix(s, "Proceed? ") {
+		t.Errorf("tail = %d bytes ending %q", len(s), s[len(s)-9:])
+	}
+	tail.Reset()
+	if tail.String() != "" {
+		t.Error("Reset should empty the buffer")
+	}
+}
+
+func TestTailBuffer_KeepsOnlyCurrentLine(t *testing.T) {
+	answers := []config.PromptAnswer{{Name: "proceed", Pattern: "Do you want to proceed?", Response: "y\n"}}
+
+	tail := &tailBuffer{}
+	tail.Write([]byte("The tool asks \"Do you want to proceed?\" before editing.\n"))
+	tail.Write([]byte("Trust this folder? "))
+	if a, ok := matchAnswer(answers, tail.String()); ok {
+		t.Errorf("stale output answered a different prompt with %q", a.Response)
+	}
+
+	tail.Reset()
+	tail.Write([]byte("Do you want to proceed?\nworking...\n"))
+	if _, ok := matchAnswer(answers, tail.String()); ok {
+		t.Errorf("tail = %q; a finished line should not match", tail.String())
+	}
+
+	tail.Reset()
+	tail.Write([]byte("editing\nDo you want"))
+	tail.Write([]byte(" to proceed? (y/n)\r\n"))
+	if _, ok := matchAnswer(answers, tail.String()); !ok {
+		t.Errorf("tail = %q; the last line should match when output stops at a line end", tail.String())
+	}
+}
+
+func TestCleanTerminalLine(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{`{"type":"result"}` + "\r", `{"type":"result"}`},
+		{"\x1b[32mok\x1b[0m done", "ok done"},
+		{"\x1b]0;claude\x07working", "working"},
+		{"Thinking.\rThinking..\rDone", "Done"},
+	}
+	for _, tt := range tests {
+		if got := cleanTerminalLine(tt.in); got != tt.want {
+			t.Errorf("cleanTerminalLine(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCollectArtifacts_CopiesPerAttempt(t *testing.T) {
+	wd := t.TempDir()
+	writeContextFixture(t, wd, "report.md", "# report")
+	writeContextFixture(t, wd, "coverage/unit.out", "mode: set")
+	writeContextFixture(t, wd, "coverage/sub/int.out", "mode: set")
+
+	r := &Runner{Paths: config.At(t.TempDir())}
+	task := &queue.Task{ID: "gen", WorkingDir: wd, Artifacts: []string{"report.md", "coverage/**/*.out", "missing.txt"}}
+
+	got := r.collectArtifacts(task, 2)
+	base := filepath.Join(r.Paths.ArtifactsDir(), "gen", "2")
+	want := []string{
+		filepath.Join(base, "report.md"),
+		filepath.Join(base, "coverage", "sub", "int.out"),
+		filepath.Join(base, "coverage", "unit.out"),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("collectArtifacts =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if data, err := os.ReadFile(want[0]); err != nil || string(data) != "# report" {
+		t.Errorf("copied report = %q, %v", data, err)
+	}
+}
+
+func TestGitDiffSummary(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	writeContextFixture(t, dir, "main.go", "package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	base, _ := gitOutput(dir, "rev-parse", "HEAD")
+
+	if d, ok := gitDiffSummary(dir, base); !ok || !d.Empty() || d.Line() != "no changes" {
+		t.Errorf("clean tree: %+v, %v", d, ok)
+	}
+
+	writeContextFixture(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+	writeContextFixture(t, dir, "new.go", "package main\n")
+	writeContextFixture(t, dir, ".autopilot/dir.lock", "123")
+	d, ok := gitDiffSummary(dir, base)
+	if !ok || d.Empty() {
+		t.Fatalf("changed tree: %+v, %v", d, ok)
+	}
+	if want := "1 file changed, 2 insertions(+), 1 untracked file"; d.Line() != want {
+		t.Errorf("Line() = %q; want %q", d.Line(), want)
+	}
+
+	if _, ok := gitDiffSummary(t.TempDir(), base); ok {
+		t.Error("non-repository should report ok=false")
+	}
+}
+
+func TestUsageMeter_CountsEachMessageOnce(t *testing.T) {
+	var m usageMeter
+	// The CLI repeats a message's usage on each of its content blocks.
+	m.addAssistant(json.RawMessage(`{"type":"assistant","message":{"id":"m1","usage":{"input_tokens":100,"cache_read_input_tokens":9000,"output_tokens":5}}}`))
+	m.addAssistant(json.RawMessage(`{"type":"assistant","message":{"id":"m1","usage":{"input_tokens":100,"cache_read_input_tokens":9000,"output_tokens":20}}}`))
+	m.addAssistant(json.RawMessage(`{"type":"assistant","message":{"id":"m2","usage":{"input_tokens":10,"cache_creation_input_tokens":50,"output_tokens":30}}}`))
+	m.addAssistant(json.RawMessage(`{"type":"assistant","message":"plain text"}`))
+	if m.sum != 210 {
+		t.Errorf("sum = %d; want 210", m.sum)
+	}
+
+	m.addResult(&tokenUsage{InputTokens: 100, OutputTokens: 50})
+	if m.sum != 210 {
+		t.Errorf("lower result usage changed sum to %d", m.sum)
+	}
+	m.addResult(&tokenUsage{InputTokens: 200, OutputTokens: 100})
+	if m.sum != 300 {
+		t.Errorf("sum after result = %d; want 300", m.sum)
+	}
+}
+
+func TestOverBudget(t *testing.T) {
+	task := &queue.Task{ID: "t", MaxCostUSD: 1.50, MaxTokens: 1000}
+	if reason := overBudget(task, 1.49, 999); reason != "" {
+		t.Errorf("under budget: %q", reason)
+	}
+	if reason := overBudget(task, 1.50, 0); !strings.Contains(reason, "max_cost_usd") {
+		t.Errorf("at cost limit: %q", reason)
+	}
+	if reason := overBudget(task, 0, 1000); !strings.Contains(reason, "max_tokens 1000") {
+		t.Errorf("at token limit: %q", reason)
+	}
+	if reason := overBudget(&queue.Task{ID: "t"}, 100, 1e6); reason != "" {
+		t.Errorf("no limits: %q", reason)
+	}
+
+	state := &queue.TaskState{Attempts: []queue.Attempt{{CostUSD: 0.5, Tokens: 400}, {CostUSD: 0.25, Tokens: 100}}}
+	if cost, tokens := spent(state); cost != 0.75 || tokens != 500 {
+		t.Errorf("spent = %v, %d; want 0.75, 500", cost, tokens)
+	}
+}
+
+func TestExpandSummaries(t *testing.T) {
+	stateDir := t.TempDir()
+	queue.SaveState(stateDir, &queue.TaskState{ID: "analyze", Status: queue.StatusDone, Summary: "Found 3 issues."})
+	queue.SaveState(stateDir, &queue.TaskState{ID: "quiet", Status: queue.StatusDone})
+	queue.SaveState(stateDir, &queue.TaskState{ID: "later", Status: queue.StatusPending})
+
+	got, err := expandSummaries("Fix these:\n{{task:analyze.summary}}\nAgain: {{task:analyze.summary}}", stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Fix these:\nFound 3 issues.\nAgain: Found 3 issues." {
+		t.Errorf("expanded = %q", got)
+	}
+
+	for _, ref := range []string{"missing", "quiet", "later"} {
+		if _, err := expandSummaries("{{task:"+ref+".summary}}", stateDir); err == nil {
+			t.Errorf("%s: expected an error", ref)
+		}
+	}
+}
+
+func TestBlockingDependency(t *testing.T) {
+	stateDir := t.TempDir()
+	queue.SaveState(stateDir, &queue.TaskState{ID: "analyze", Status: queue.StatusDone})
+	queue.SaveState(stateDir, &queue.TaskState{ID: "broken", Status: queue.StatusFailed})
+	states := map[string]*queue.TaskState{"fresh": {ID: "fresh", Status: queue.StatusWaiting}}
+
+	tests := []struct {
+		task       queue.Task
+		dep, state string
+	}{
+		{queue.Task{Prompt: "{{task:analyze.summary}}", DependsOn: []string{"analyze"}}, "", ""},
+		{queue.Task{Prompt: "x", DependsOn: []string{"analyze", "broken"}}, "broken", queue.StatusFailed},
+		{queue.Task{Prompt: "{{task:fresh.summary}}"}, "fresh", queue.StatusWaiting},
+		{queue.Task{Prompt: "x", DependsOn: []string{"never-ran"}}, "never-ran", queue.StatusPending},
+	}
+	for _, tt := range tests {
+		dep, state := blockingDependency(&tt.task, states, stateDir)
+		if dep != tt.dep || state != tt.state {
+			t.Errorf("%+v: blocked by %q (%q); want %q (%q)", tt.task.DependsOn, dep, state, tt.dep, tt.state)
+		}
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh syntax")
+	}
+	dir := t.TempDir()
+	if err := runVerify(dir, "true"); err != nil {
+		t.Errorf("passing command: %v", err)
+	}
+	err := runVerify(dir, "echo checking; echo '2 tests failed' >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "status 3: 2 tests failed") {
+		t.Errorf("failing command: %v", err)
+	}
+}
+
+func TestFinalSummary(t *testing.T) {
+	lines := []string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"Looking around."}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"The parser needs a rewrite."}]}}`,
+	}
+	if got := finalSummary(lines); got != "The parser needs a rewrite." {
+		t.Errorf("finalSummary = %q", got)
+	}
+	lines = append(lines, `{"type":"result","result":"Done: parser rewrite planned."}`)
+	if got := finalSummary(lines); got != "Done: parser rewrite planned." {
+		t.Errorf("finalSummary with result = %q", got)
+	}
+}
+
+func TestParsePlan(t *testing.T) {
+	reply := "Here is the plan.\n```json\n" + `[
+  {"id": "schema", "title": "Schema", "prompt": "Add the users table."},
+  {"id": "api", "prompt": "Add the endpoints.", "depends_on": ["schema"]}
+]` + "\n```\nGood luck."
+	subtasks, err := parsePlan(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subtasks) != 2 || subtasks[0].DependsOn == nil || len(subtasks[0].DependsOn) != 0 || subtasks[1].DependsOn[0] != "schema" {
+		t.Errorf("subtasks = %+v", subtasks)
+	}
+
+	// Without a fence, the outermost array is used.
+	if subtasks, err := parsePlan(`Plan: [{"id": "a", "prompt": "x"}]`); err != nil || len(subtasks) != 1 {
+		t.Errorf("unfenced: %+v, %v", subtasks, err)
+	}
+
+	for _, bad := range []string{"no plan here", "```json\n[]\n```", `[{"id": "a"}]`, "[not json]"} {
+		if _, err := parsePlan(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestWritePlan_QueuesSubtasks(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	planner := &queue.Task{ID: "big", Prompt: "Build it all", WorkingDir: "/repo", Model: "m", MaxRetries: 2, Plan: true, Verify: "make test"}
+	reply := `[{"id": "one", "prompt": "first"}, {"id": "two", "prompt": "second", "depends_on": ["one"]}, {"id": "side", "prompt": "third"}]`
+
+	path, n, err := r.writePlan(planner, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 || filepath.Base(path) != "big.pipeline.yaml" {
+		t.Errorf("writePlan = %s, %d", path, n)
+	}
+	tasks, err := queue.LoadTasks(r.Paths.TasksDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := make(map[string]queue.Task)
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+	two, side := byID["big-two"], byID["big-side"]
+	if two.WorkingDir != "/repo" || two.Model != "m" || two.Verify != "make test" || two.Plan {
+		t.Errorf("big-two = %+v", two)
+	}
+	if len(two.DependsOn) != 1 || two.DependsOn[0] != "big-one" || len(side.DependsOn) != 0 {
+		t.Errorf("depends_on: two=%v side=%v", two.DependsOn, side.DependsOn)
+	}
+
+	// Replanning replaces the file; clashing with another task does not.
+	if _, _, err := r.writePlan(planner, reply); err != nil {
+		t.Errorf("replan: %v", err)
+	}
+	os.WriteFile(filepath.Join(r.Paths.TasksDir(), "other.yaml"), []byte("id: big-extra\nworking_dir: /repo\nprompt: x\n"), 0644)
+	if _, _, err := r.writePlan(planner, `[{"id": "extra", "prompt": "clash"}]`); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Errorf("clash: %v", err)
+	}
+	if _, _, err := r.writePlan(planner, `[{"id": "a", "prompt": "x", "depends_on": ["b"]}, {"id": "b", "prompt": "y", "depends_on": ["a"]}]`); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("cycle: %v", err)
+	}
+}
+
+func TestProcessControlCommands_Reject(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	if out, err := exec.Command("git", "-C", repo, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v\n%s", err, out)
+	}
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(r.Paths.TasksDir(), "t.yaml"), []byte("id: reviewed\nworking_dir: "+repo+"\nprompt: x\n---\nid: queued\nworking_dir: "+repo+"\nprompt: y\n"), 0644)
+	stateDir := r.Paths.StateDir()
+	queue.SaveState(stateDir, &queue.TaskState{ID: "reviewed", Status: queue.StatusNeedsReview, ReviewBranch: "autopilot/reviewed", Worktree: filepath.Join(t.TempDir(), "gone")})
+	queue.SaveState(stateDir, &queue.TaskState{ID: "queued", Status: queue.StatusPending})
+	for _, cc := range []queue.ControlCommand{{Op: "reject", TaskID: "reviewed"}, {Op: "approve", TaskID: "queued"}} {
+		if err := queue.AppendCommand(r.Paths.ControlDir(), cc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.processControlCommands(r.Paths.ControlDir(), stateDir); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := queue.LoadState(stateDir, "reviewed"); st.Status != queue.StatusFailed || st.ReviewBranch != "" {
+		t.Errorf("rejected task = %+v", st)
+	}
+	if st, _ := queue.LoadState(stateDir, "queued"); st.Status != queue.StatusPending {
+		t.Errorf("approving a task not in review changed it to %s", st.Status)
+	}
+}
+
+func TestProcessControlCommands_SkipsReplay(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	stateDir, controlDir := r.Paths.StateDir(), r.Paths.ControlDir()
+
+	// The retry was saved before a crash lost its journal mark; the task
+	// has failed again since, and the replay must not retry it a second time.
+	queue.SaveState(stateDir, &queue.TaskState{ID: "t", Status: queue.StatusFailed, Attempt: 3, ControlID: "c1"})
+	queue.AppendCommand(controlDir, queue.ControlCommand{ID: "c1", Op: "retry", TaskID: "t"})
+	queue.AppendCommand(controlDir, queue.ControlCommand{ID: "c2", Op: "cancel", TaskID: "other"})
+
+	if err := r.processControlCommands(controlDir, stateDir); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := queue.LoadState(stateDir, "t"); st.Status != queue.StatusFailed || st.Attempt != 3 {
+		t.Errorf("replayed retry changed the task: %+v", st)
+	}
+	if st, _ := queue.LoadState(stateDir, "other"); st == nil || st.Status != queue.StatusCancelled || st.ControlID != "c2" {
+		t.Errorf("cancel not applied: %+v", st)
+	}
+	if _, err := os.Stat(filepath.Join(controlDir, "journal.jsonl")); !os.IsNotExist(err) {
+		t.Error("journal kept after every command was applied")
+	}
+}
+
+func TestProcessControlCommands_CancelThenUndo(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	stateDir, controlDir := r.Paths.StateDir(), r.Paths.ControlDir()
+	queue.SaveState(stateDir, &queue.TaskState{ID: "t", Status: queue.StatusWaiting, Attempt: 2, SessionID: "s1"})
+
+	queue.AppendCommand(controlDir, queue.NewControlCommand("cancel", "t"))
+	if err := r.processControlCommands(controlDir, stateDir); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := queue.ListTrash(r.Paths.TrashDir())
+	if len(entries) != 1 || entries[0].Op != "cancel" {
+		t.Fatalf("trash = %+v; want the cancel recorded", entries)
+	}
+
+	queue.AppendCommand(controlDir, queue.NewControlCommand("undo", entries[0].Name))
+	if err := r.processControlCommands(controlDir, stateDir); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := queue.LoadState(stateDir, "t"); st.Status != queue.StatusWaiting || st.Attempt != 2 || st.SessionID != "s1" {
+		t.Errorf("undo restored %+v; want the waiting state back", st)
+	}
+}
+
+func TestProcessControlCommands_Reload(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir()), Config: &config.Config{HangTimeout: 10 * time.Minute}}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Paths.SetConfigValue("hang_timeout", "3m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Paths.SetConfigValue("http_listen", "127.0.0.1:8787"); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(r.Paths.MatchersFile(), []byte("rate_limit_patterns:\n  - \"quota exhausted\"\n"), 0644)
+
+	queue.AppendCommand(r.Paths.ControlDir(), queue.NewControlCommand("reload", ""))
+	if err := r.processControlCommands(r.Paths.ControlDir(), r.Paths.StateDir()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Config.HangTimeout != 3*time.Minute {
+		t.Errorf("hang_timeout = %s after reload; want 3m", r.Config.HangTimeout)
+	}
+	if r.Notifier == nil || r.Detector == nil {
+		t.Fatal("reload did not rebuild the notifier and detector")
+	}
+	if _, ok := r.Detector.DetectLine("Error: quota exhausted"); !ok {
+		t.Error("reloaded detector does not use the new matchers")
+	}
+
+	// A broken config file keeps the settings in effect.
+	os.WriteFile(r.Paths.ConfigFile(), []byte("hang_timeout: [\n"), 0644)
+	r.reload()
+	if r.Config.HangTimeout != 3*time.Minute {
+		t.Errorf("hang_timeout = %s after a failed reload; want 3m kept", r.Config.HangTimeout)
+	}
+}
+
+func TestStartupOnlyChanges(t *testing.T) {
+	old := &config.Config{HTTPListen: ":8787", HangTimeout: time.Minute}
+	changed := &config.Config{HTTPListen: ":9090", HangTimeout: time.Hour}
+	if got := startupOnlyChanges(old, changed); len(got) != 1 || got[0] != "http_listen" {
+		t.Errorf("startupOnlyChanges = %v; want [http_listen]", got)
+	}
+}
+
+func TestCheckPromptChange(t *testing.T) {
+	task := &queue.Task{ID: "edit-me", Prompt: "new prompt"}
+	stale := func(status string) *queue.TaskState {
+		return &queue.TaskState{ID: task.ID, Status: status, Attempt: 2, PromptHash: hashPrompt("old prompt"), SessionID: "s1"}
+	}
+
+	r := &Runner{Config: &config.Config{PromptChangeAction: "warn"}}
+	st := stale(queue.StatusWaiting)
+	if r.checkPromptChange(task, st, map[string]string{}) || st.Attempt != 2 || st.SessionID != "s1" {
+		t.Errorf("warn changed state: %+v", st)
+	}
+
+	r.Config.PromptChangeAction = "reset"
+	st = stale(queue.StatusFailed)
+	if !r.checkPromptChange(task, st, map[string]string{}) {
+		t.Fatal("reset reported no change")
+	}
+	if st.Status != queue.StatusPending || st.Attempt != 0 || st.SessionID != "" || st.PromptHash != hashPrompt("new prompt") {
+		t.Errorf("reset state = %+v", st)
+	}
+	if r.checkPromptChange(task, st, map[string]string{}) {
+		t.Error("reset state reported as changed again")
+	}
+
+	for _, status := range []string{queue.StatusDone, queue.StatusRunning, queue.StatusNeedsReview} {
+		if st := stale(status); r.checkPromptChange(task, st, map[string]string{}) {
+			t.Errorf("%s task was reset", status)
+		}
+	}
+}
+
+func TestCheckTaskChange(t *testing.T) {
+	ran := &queue.Task{ID: "edit-me", Prompt: "same prompt", WorkingDir: "/repo", Model: "sonnet"}
+	edited := *ran
+	edited.Model = "opus"
+	stale := func(status string) *queue.TaskState {
+		return &queue.TaskState{ID: ran.ID, Status: status, Attempt: 2, PromptHash: hashPrompt(ran.Prompt), TaskChecksum: ran.Checksum(), SessionID: "s1"}
+	}
+
+	r := &Runner{Config: &config.Config{TaskChangeAction: "warn"}}
+	if st := stale(queue.StatusWaiting); r.checkTaskChange(ran, st, map[string]string{}) {
+		t.Error("an unedited task was reported as changed")
+	}
+	st := stale(queue.StatusWaiting)
+	if r.checkTaskChange(&edited, st, map[string]string{}) || st.Attempt != 2 || st.SessionID != "s1" {
+		t.Errorf("warn changed state: %+v", st)
+	}
+
+	r.Config.TaskChangeAction = "reset"
+	st = stale(queue.StatusFailed)
+	if !r.checkTaskChange(&edited, st, map[string]string{}) {
+		t.Fatal("reset reported no change")
+	}
+	if st.Status != queue.StatusPending || st.Attempt != 0 || st.SessionID != "" || st.TaskChecksum != edited.Checksum() {
+		t.Errorf("reset state = %+v", st)
+	}
+	if r.checkTaskChange(&edited, st, map[string]string{}) {
+		t.Error("reset state reported as changed again")
+	}
+
+	// A new prompt is checkPromptChange's to handle.
+	reprompted := edited
+	reprompted.Prompt = "new prompt"
+	if st := stale(queue.StatusWaiting); r.checkTaskChange(&reprompted, st, map[string]string{}) {
+		t.Error("a prompt edit was also handled as a task edit")
+	}
+	if st := stale(queue.StatusDone); r.checkTaskChange(&edited, st, map[string]string{}) {
+		t.Error("done task was reset")
+	}
+	warned := map[string]string{}
+	if st := stale(queue.StatusRunning); r.checkTaskChange(&edited, st, warned) || st.Attempt != 2 {
+		t.Errorf("running task was reset: %+v", st)
+	}
+	if warned[edited.ID] != edited.Checksum() {
+		t.Error("an edit to a running task was not warned about")
+	}
+}
+
+func TestArchivable(t *testing.T) {
+	no := false
+	tasks := []queue.Task{
+		{ID: "solo", Source: "/t/solo.yaml"},
+		{ID: "a", Source: "/t/multi.yaml#doc1"},
+		{ID: "b", Source: "/t/multi.yaml#doc2"},
+		{ID: "base", Source: "/t/base.yaml"},
+		{ID: "user", Source: "/t/user.yaml", DependsOn: []string{"base"}},
+		{ID: "keep", Source: "/t/keep.yaml", ArchiveDone: &no},
+	}
+	statuses := map[string]string{
+		"solo": queue.StatusDone, "a": queue.StatusDone, "b": queue.StatusPending,
+		"base": queue.StatusDone, "user": queue.StatusFailed, "keep": queue.StatusDone,
+	}
+
+	got := archivable(tasks, statuses, true)
+	if len(got) != 1 || len(got["/t/solo.yaml"]) != 1 {
+		t.Errorf("archivable = %v; want only solo.yaml", got)
+	}
+
+	statuses["b"], statuses["user"] = queue.StatusDone, queue.StatusDone
+	got = archivable(tasks, statuses, true)
+	if len(got) != 4 || len(got["/t/multi.yaml"]) != 2 || got["/t/keep.yaml"] != nil {
+		t.Errorf("archivable = %v; want solo, multi, base and user", got)
+	}
+
+	if got := archivable(tasks, statuses, false); len(got) != 0 {
+		t.Errorf("archivable with archive_done off = %v; want none", got)
+	}
+}
+
+func TestContainerArgs(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "sk-test")
+	task := &queue.Task{ID: "boxed", WorkingDir: "/work/wt", Container: "node:20", ContainerNet: "none", ContainerCPUs: 2, ContainerMem: "4g"}
+	args := containerArgs(task, "autopilot-boxed", []string{"/work/repo"}, false, "claude", []string{"--print", "--", "hi"})
+	got := strings.Join(args, " ")
+	for _, want := range []string{
+		"run --rm --init --name autopilot-boxed",
+		"-v /work/wt:/work/wt -v /work/repo:/work/repo -w /work/wt",
+		"--network none --cpus 2 --memory 4g",
+		"-e ANTHROPIC_API_KEY",
+		"node:20 claude --print -- hi",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("args = %q; missing %q", got, want)
+		}
+	}
+	if strings.Contains(got, "sk-test") || strings.Contains(got, " -t ") {
+		t.Errorf("args = %q; want no secret values and no tty", got)
+	}
+}
+
+func TestTaskLimits(t *testing.T) {
+	l, err := taskLimits(&queue.Task{ID: "capped", Nice: 10, MaxCPUTime: "30m", MaxMemory: "8g"})
+	if err != nil {
+		t.Fatalf("taskLimits: %v", err)
+	}
+	if l.nice != 10 || l.cpuTime != 30*time.Minute || l.memory != 8<<30 {
+		t.Errorf("limits = %+v; want nice 10, 30m, 8GiB", l)
+	}
+	if l, _ := taskLimits(&queue.Task{ID: "plain"}); !l.empty() {
+		t.Errorf("limits of a plain task = %+v; want none", l)
+	}
+	if _, err := taskLimits(&queue.Task{ID: "zero", MaxMemory: "0"}); err == nil {
+		t.Error("taskLimits(max_memory 0) succeeded; want an error")
+	}
+}
+
+func TestApplyLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip("sleep not available")
+	}
+	defer cmd.Process.Kill()
+
+	release, err := applyLimits(cmd.Process.Pid, limits{nice: 5, cpuTime: time.Minute, memory: 1 << 30})
+	if err != nil {
+		t.Fatalf("applyLimits: %v", err)
+	}
+	defer release()
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(cmd.Process.Pid), "limits"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Max cpu time              60                   70", "Max data size             1073741824           1073741824"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("limits missing %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestLowDisk(t *testing.T) {
+	dir := t.TempDir()
+	r := &Runner{Config: &config.Config{}}
+	if reason := r.lowDisk(dir); reason != "" {
+		t.Errorf("lowDisk with the check off = %q; want none", reason)
+	}
+	r.Config.MinFreeDiskMB = 1
+	if reason := r.lowDisk(dir, "", filepath.Join(dir, "missing")); reason != "" {
+		t.Errorf("lowDisk(1 MB) = %q; want none", reason)
+	}
+	r.Config.MinFreeDiskMB = 1 << 40 // an exabyte
+	if reason := r.lowDisk(dir); !strings.Contains(reason, dir) {
+		t.Errorf("lowDisk(1 EB) = %q; want it to name %s", reason, dir)
+	}
+}
+
+func TestHealthSnapshot(t *testing.T) {
+	r := &Runner{Paths: config.At(t.TempDir())}
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.health.h.StartedAt = started
+	r.setPhase(PhaseRunning, func(h *Health) {
+		h.TaskID = "build"
+		h.Attempt = 2
+	})
+	out := time.Now()
+	r.noteOutput(out)
+	r.writeHealth()
+
+	h, err := ReadHealth(r.Paths.HealthFile())
+	if err != nil {
+		t.Fatalf("ReadHealth: %v", err)
+	}
+	if h.PID != os.Getpid() || h.Phase != PhaseRunning || h.TaskID != "build" || h.Attempt != 2 || !h.StartedAt.Equal(started) {
+		t.Errorf("health = %+v; want running build attempt 2", h)
+	}
+	if h.LastOutputAt == nil || !h.LastOutputAt.Equal(out) {
+		t.Errorf("last_output_at = %v; want %v", h.LastOutputAt, out)
+	}
+
+	// A new phase drops the details of the previous one.
+	r.setPhase(PhaseIdle, nil)
+	if h, _ = ReadHealth(r.Paths.HealthFile()); h.Phase != PhaseIdle || h.TaskID != "" || h.LastOutputAt != nil || !h.StartedAt.Equal(started) {
+		t.Errorf("health after idle = %+v; want idle with no task", h)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	r := &Runner{Config: &config.Config{NoRetryFailures: "auth,context_too_long"}}
+	if r.retryable(detector.FailureAuth) || r.retryable(detector.FailureContextLength) {
+		t.Error("listed categories are retryable; want not")
+	}
+	if !r.retryable(detector.FailureNetwork) {
+		t.Error("network is not retryable; want it retried")
+	}
+	r.Config.NoRetryFailures = ""
+	if !r.retryable(detector.FailureAuth) {
+		t.Error("auth is not retryable with an empty no_retry_failures")
+	}
+}
+
+func TestHangTimeout(t *testing.T) {
+	r := &Runner{Config: &config.Config{HangTimeout: 10 * time.Minute}}
+	if got := r.hangTimeout(&queue.Task{}); got != 10*time.Minute {
+		t.Errorf("default = %v; want the global 10m", got)
+	}
+	if got := r.hangTimeout(&queue.Task{HangTimeout: "45m"}); got != 45*time.Minute {
+		t.Errorf("hang_timeout 45m = %v", got)
+	}
+	if got := r.hangTimeout(&queue.Task{NoHangDetection: true}); got != 0 {
+		t.Errorf("no_hang_detection = %v; want 0 (never)", got)
+	}
+}
+
+func TestActivity_HeartbeatsAndWork(t *testing.T) {
+	start := time.Date(2026, 1, 15, 22, 0, 0, 0, time.UTC)
+	timeout := 10 * time.Minute
+	a := newActivity(start)
+
+	// A tool result counts as work, a thinking-only message as a heartbeat.
+	a.output(transcript.ParseLine(`{"type":"user","message":{"content":[{"type":"tool_result","content":"ok"}]}}`), start.Add(5*time.Minute))
+	a.output(transcript.ParseLine(`{"type":"assistant","message":{"content":[{"type":"thinking","thinking":"..."}]}}`), start.Add(14*time.Minute))
+	if _, hung := a.hung(start.Add(20*time.Minute), timeout); hung {
+		t.Error("hung 6m after a heartbeat; want alive")
+	}
+	if got := a.silence(start.Add(20 * time.Minute)); got != 6*time.Minute {
+		t.Errorf("silence = %v; want 6m since the heartbeat", got)
+	}
+	if msg, hung := a.hung(start.Add(25*time.Minute), timeout); !hung || !strings.Contains(msg, "no output for 11m0s") {
+		t.Errorf("hung = %v (%q); want silent for 11m", hung, msg)
+	}
+
+	// Heartbeats alone keep a session alive for thinkingAllowance timeouts
+	// after its last work.
+	for m := 25; m <= 35; m += 5 {
+		a.beat(start.Add(time.Duration(m) * time.Minute))
+	}
+	if _, hung := a.hung(start.Add(35*time.Minute), timeout); !hung {
+		t.Error("not hung after 30m of heartbeats only")
+	}
+	a.work(start.Add(35 * time.Minute))
+	if _, hung := a.hung(start.Add(36*time.Minute), timeout); hung {
+		t.Error("hung right after work")
+	}
+}
+
+func TestMatchOutput(t *testing.T) {
+	patterns := []string{"context deadline exceeded", "Compilation failed"}
+	if got := matchOutput(patterns, "verify command exited with status 1", "panic: Context Deadline Exceeded"); got != "context deadline exceeded" {
+		t.Errorf("matchOutput = %q; want the first pattern, matched ignoring case", got)
+	}
+	if got := matchOutput(patterns, "compilation failed in file x.go"); got != "Compilation failed" {
+		t.Errorf("matchOutput = %q; want %q", got, "Compilation failed")
+	}
+	if got := matchOutput(patterns, "exit status 2"); got != "" {
+		t.Errorf("matchOutput = %q; want no match", got)
+	}
+	if got := matchOutput(nil, "anything"); got != "" {
+		t.Errorf("matchOutput with no patterns = %q", got)
+	}
+}
+
+func TestNetworkReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on loopback")
+	}
+	addr := ln.Addr().String()
+
+	r := &Runner{Config: &config.Config{NetworkProbe: addr}}
+	if !r.networkReachable() {
+		t.Errorf("networkReachable(%s) = false with a listener", addr)
+	}
+	ln.Close()
+	if r.networkReachable() {
+		t.Errorf("networkReachable(%s) = true after the listener closed", addr)
+	}
+
+	r.Config.NetworkProbe = ""
+	if !r.networkReachable() {
+		t.Error("networkReachable with no probe = false; want the network assumed up")
+	}
+}
+
+func TestScheduleOrder(t *testing.T) {
+	// Project a has three tasks queued ahead of project b's two.
+	tasks := []queue.Task{
+		{ID: "a1", WorkingDir: "/a"}, {ID: "a2", WorkingDir: "/a"}, {ID: "a3", WorkingDir: "/a"},
+		{ID: "b1", WorkingDir: "/b"}, {ID: "b2", WorkingDir: "/b"},
+	}
+	ids := func(ts []queue.Task) string {
+		var s []string
+		for _, t := range ts {
+			s = append(s, t.ID)
+		}
+		return strings.Join(s, ",")
+	}
+
+	r := &Runner{Config: &config.Config{Scheduling: SchedulePriority}}
+	r.noteDirStart("/a")
+	if got := ids(r.scheduleOrder(tasks)); got != "a1,a2,a3,b1,b2" {
+		t.Errorf("priority order = %s", got)
+	}
+
+	r = &Runner{Config: &config.Config{Scheduling: ScheduleRoundRobin}}
+	var started []string
+	queued := tasks
+	for len(queued) > 0 {
+		next := r.scheduleOrder(queued)[0]
+		r.noteDirStart(next.WorkingDir)
+		started = append(started, next.ID)
+		var rest []queue.Task
+		for _, t := range queued {
+			if t.ID != next.ID {
+				rest = append(rest, t)
+			}
+		}
+		queued = rest
+	}
+	if got := strings.Join(started, ","); got != "a1,b1,a2,b2,a3" {
+		t.Errorf("round-robin starts = %s; want a1,b1,a2,b2,a3", got)
+	}
+}
+
+func TestWeightedOrder(t *testing.T) {
+	tasks := []queue.Task{{ID: "low", Priority: 10}, {ID: "high", Priority: 1}, {ID: "neg", Priority: -5}}
+	first := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		ordered := weightedOrder(tasks)
+		if len(ordered) != len(tasks) {
+			t.Fatalf("weightedOrder returned %d tasks; want %d", len(ordered), len(tasks))
+		}
+		seen := map[string]bool{}
+		for _, task := range ordered {
+			seen[task.ID] = true
+		}
+		if len(seen) != len(tasks) {
+			t.Fatalf("weightedOrder = %v; want each task once", ordered)
+		}
+		first[ordered[0].ID]++
+	}
+	// Weights 0.1, 1 and 1: low comes first about 1 time in 21.
+	if first["low"] == 0 || first["low"] > first["high"]/3 || first["neg"] < first["high"]/2 {
+		t.Errorf("first picks = %v; want low rare but possible, high and neg alike", first)
+	}
+	if tasks[0].ID != "low" {
+		t.Error("weightedOrder reordered its input")
+	}
+}
+
+func TestCliFor(t *testing.T) {
+	r := &Runner{
+		Paths:    config.At(t.TempDir()),
+		Adapter:  compat.NewAdapter(nil),
+		Detector: detector.NewDetector(nil, 75),
+	}
+	if err := r.Paths.EnsureDirs(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := r.cliFor(&queue.Task{ID: "plain"})
+	if err != nil || c.path != "claude" || c.adapter != r.Adapter {
+		t.Fatalf("cliFor(no claude_version) = %+v, %v; want the default CLI", c, err)
+	}
+
+	// An unpinned version fails rather than silently using the default.
+	if _, err := r.cliFor(&queue.Task{ID: "pinned", ClaudeVersion: "1.0"}); err == nil {
+		t.Fatal("cliFor(unpinned version) succeeded; want an error")
+	}
+
+	if err := compat.SavePins(r.Paths.ClaudeVersionsFile(), []compat.Binary{{Version: "1.0.3", Path: "/opt/claude-1/claude"}}); err != nil {
+		t.Fatal(err)
+	}
+	c, err = r.cliFor(&queue.Task{ID: "pinned", ClaudeVersion: "1.0"})
+	if err != nil {
+		t.Fatalf("cliFor: %v", err)
+	}
+	if c.path != "/opt/claude-1/claude" {
+		t.Errorf("path = %q; want the pinned binary", c.path)
+	}
+	if c.adapter.SupportsStreamJSON() || c.adapter.SupportsResume() {
+		t.Error("adapter for 1.0.3 should not use stream-json or resume")
+	}
+	// 1.x has no rate-limit exit code, so 75 is an ordinary failure.
+	if res := c.detector.Detect(75, "", ""); res.Result == detector.RateLimited {
+		t.Error("detector for 1.0.3 treats exit code 75 as a rate limit")
+	}
+	if res := r.Detector.Detect(75, "", ""); res.Result != detector.RateLimited {
+		t.Error("the default detector was changed")
+	}
+
+	c, err = r.cliFor(&queue.Task{ID: "other", Agent: "gemini"})
+	if err != nil || c.path != "gemini" || c.adapter.SupportsStreamJSON() {
+		t.Fatalf("cliFor(agent gemini) = %+v, %v; want the gemini CLI in text mode", c, err)
+	}
+	if res := c.detector.Detect(1, "", "Error: RESOURCE_EXHAUSTED"); res.Result != detector.RateLimited {
+		t.Error("gemini detector does not match its rate-limit error")
+	}
+	if _, err := r.cliFor(&queue.Task{ID: "other", Agent: "cursor"}); err == nil {
+		t.Error("cliFor(unknown agent) succeeded; want an error")
+	}
+
+	agents := "cursor:\n  command: [cursor-agent, -p, \"{{prompt}}\"]\n  rate_limit_exit_code: 9\n"
+	if err := os.WriteFile(r.Paths.AgentsFile(), []byte(agents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err = r.cliFor(&queue.Task{ID: "other", Agent: "cursor"})
+	if err != nil || c.path != "cursor-agent" {
+		t.Fatalf("cliFor(agent from agents.yaml) = %+v, %v; want cursor-agent", c, err)
+	}
+	if res := c.detector.Detect(9, "", ""); res.Result != detector.RateLimited {
+		t.Error("template agent detector ignores rate_limit_exit_code")
+	}
+}
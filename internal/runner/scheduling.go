@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// Scheduling modes (config key scheduling).
+const (
+	SchedulePriority   = "priority"
+	ScheduleRoundRobin = "round_robin_by_dir"
+	ScheduleWeighted   = "weighted"
+)
+
+// scheduleOrder returns the actionable tasks in the order they should be
+// tried. They arrive in priority order, which the priority mode keeps.
+func (r *Runner) scheduleOrder(tasks []queue.Task) []queue.Task {
+	if r.Config == nil {
+		return tasks
+	}
+	switch r.Config.Scheduling {
+	case ScheduleRoundRobin:
+		return r.roundRobinOrder(tasks)
+	case ScheduleWeighted:
+		return weightedOrder(tasks)
+	}
+	return tasks
+}
+
+// roundRobinOrder lets the working directories take turns: those that have
+// not started a task this run come first, then the one whose last start is
+// oldest, so one project's long queue cannot hold the runner all night.
+// Within a directory, and between directories with the same turn, priority
+// order is kept.
+func (r *Runner) roundRobinOrder(tasks []queue.Task) []queue.Task {
+	ordered := append([]queue.Task(nil), tasks...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return r.dirTurns[ordered[i].WorkingDir] < r.dirTurns[ordered[j].WorkingDir]
+	})
+	return ordered
+}
+
+// noteDirStart records that a task in dir is starting, moving dir to the
+// back of the round-robin order.
+func (r *Runner) noteDirStart(dir string) {
+	if r.dirTurns == nil {
+		r.dirTurns = make(map[string]int)
+	}
+	r.startCount++
+	r.dirTurns[dir] = r.startCount
+}
+
+// weightedOrder shuffles tasks so that each place goes to one of the tasks
+// left with probability proportional to its weight, 1/priority: a priority
+// 1 task is ten times as likely to come next as a priority 10 one, but any
+// task may. Priorities below 1 weigh as 1.
+func weightedOrder(tasks []queue.Task) []queue.Task {
+	left := append([]queue.Task(nil), tasks...)
+	ordered := make([]queue.Task, 0, len(tasks))
+	for len(left) > 0 {
+		total := 0.0
+		for _, t := range left {
+			total += priorityWeight(t.Priority)
+		}
+		pick := len(left) - 1
+		x := rand.Float64() * total
+		for i, t := range left {
+			if x -= priorityWeight(t.Priority); x < 0 {
+				pick = i
+				break
+			}
+		}
+		ordered = append(ordered, left[pick])
+		left = append(left[:pick], left[pick+1:]...)
+	}
+	return ordered
+}
+
+// priorityWeight is the weighted-scheduling weight of a priority.
+func priorityWeight(priority int) float64 {
+	if priority < 1 {
+		priority = 1
+	}
+	return 1 / float64(priority)
+}
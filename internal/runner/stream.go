@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// maxOutputLine caps a subprocess output line. A longer one (a huge tool
+// result, say) is cut to this size rather than ending the read.
+const maxOutputLine = 8 * 1024 * 1024
+
+// lineWriter collects everything written to it, as strings.Builder would,
+// and calls onLine for each complete line as it arrives. It is used for the
+// subprocess's stderr so rate-limit messages are seen before the CLI exits.
+type lineWriter struct {
+	mu      sync.Mutex
+	buf     strings.Builder
+	partial string
+	onLine  func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.buf.Write(p)
+	w.partial += string(p)
+	var lines []string
+	for {
+		i := strings.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		lines = append(lines, w.partial[:i])
+		w.partial = w.partial[i+1:]
+	}
+	w.mu.Unlock()
+
+	if w.onLine != nil {
+		for _, l := range lines {
+			w.onLine(l)
+		}
+	}
+	return len(p), nil
+}
+
+// String returns everything written so far.
+func (w *lineWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+// lineReader reads output line by line, like bufio.Scanner with ScanLines,
+// but a line longer than max is cut to its first max bytes and the rest of
+// it skipped, so one oversized line does not stop the read and leave the
+// subprocess blocked on a full pipe.
+type lineReader struct {
+	r    *bufio.Reader
+	max  int
+	line []byte
+	size int // full length of the current line, cut bytes included
+	err  error
+}
+
+func newLineReader(r io.Reader, max int) *lineReader {
+	return &lineReader{r: bufio.NewReaderSize(r, 64*1024), max: max}
+}
+
+// Scan advances to the next line. It returns false at the end of the
+// output or on a read error, which Err then reports. A final line without
+// a newline is still returned.
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	l.line, l.size = l.line[:0], 0
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.size += len(chunk)
+		if room := l.max - len(l.line); room > 0 {
+			l.line = append(l.line, chunk[:min(room, len(chunk))]...)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil {
+			l.err = err
+			return l.size > 0
+		}
+		return true
+	}
+}
+
+// Text returns the current line without its line ending.
+func (l *lineReader) Text() string {
+	return string(bytes.TrimSuffix(bytes.TrimSuffix(l.line, []byte("\n")), []byte("\r")))
+}
+
+// Cut reports whether the current line was longer than max, and its full
+// length.
+func (l *lineReader) Cut() (int, bool) {
+	return l.size, l.size > len(l.line)
+}
+
+// Err returns the read error that ended Scan, or nil at the end of the
+// output.
+func (l *lineReader) Err() error {
+	if errors.Is(l.err, io.EOF) {
+		return nil
+	}
+	return l.err
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+// teePath returns the file that also receives task's raw output, or "" for
+// none: its tee_output, relative to dir (the task's own working directory,
+// not a review worktree), else <TeeDir>/<id>.log.
+func (r *Runner) teePath(task *queue.Task, dir string) string {
+	switch {
+	case task.TeeOutput != "" && filepath.IsAbs(task.TeeOutput):
+		return task.TeeOutput
+	case task.TeeOutput != "":
+		return filepath.Join(dir, task.TeeOutput)
+	case r.TeeDir != "":
+		return filepath.Join(r.TeeDir, task.ID+".log")
+	}
+	return ""
+}
+
+// teeWriter appends the subprocess's stdout and stderr, as they arrive, to
+// a user-chosen file. Writes never fail: losing the copy must not stop the
+// output being read, so the first error is logged and later output dropped.
+type teeWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	taskID string
+	failed bool
+}
+
+// openTee opens path for appending, creating its directory.
+func openTee(path, taskID string) (*teeWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("create tee directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open tee file: %w", err)
+	}
+	return &teeWriter{f: f, taskID: taskID}, nil
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failed {
+		return len(p), nil
+	}
+	if _, err := t.f.Write(p); err != nil {
+		t.failed = true
+		log.Printf("WARN: task %s: write tee output: %v; no longer copying output", t.taskID, err)
+	}
+	return len(p), nil
+}
+
+func (t *teeWriter) Close() error {
+	return t.f.Close()
+}
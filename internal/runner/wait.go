@@ -0,0 +1,162 @@
+package runner
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/hseinmoussa/claude-autopilot/internal/lock"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+)
+
+// wakeDebounce lets a burst of filesystem events (an editor saving a task
+// file, several appended control commands) settle before the queue is
+// re-evaluated.
+const wakeDebounce = 250 * time.Millisecond
+
+// fallbackPollInterval is the default wait_poll_interval, used to
+// re-evaluate the queue when filesystem notifications are unavailable.
+const fallbackPollInterval = 30 * time.Second
+
+// queueWatcher reports changes to the control directory and task sources so
+// the waiting loop can wake as soon as something actionable happens.
+type queueWatcher struct {
+	fs *fsnotify.Watcher
+}
+
+// newQueueWatcher watches the control directory and each task directory
+// together with its parent (for companion tasks.yaml files). Directories
+// that do not exist are skipped. On failure, a nil watcher is returned and
+// waits fall back to polling.
+func newQueueWatcher(controlDir string, taskDirs ...string) *queueWatcher {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("WARN: file watching unavailable, falling back to polling: %v", err)
+		return nil
+	}
+
+	dirs := []string{controlDir}
+	for _, d := range taskDirs {
+		if d != "" {
+			dirs = append(dirs, d, filepath.Dir(d))
+		}
+	}
+	for _, d := range dirs {
+		if info, err := os.Stat(d); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := w.Add(d); err != nil {
+			log.Printf("WARN: watch %s: %v", d, err)
+		}
+	}
+	return &queueWatcher{fs: w}
+}
+
+// Close stops watching.
+func (w *queueWatcher) Close() {
+	if w != nil {
+		w.fs.Close()
+	}
+}
+
+// relevant reports whether an event should wake the runner: any change to
+// the control command file or to a task YAML file.
+func relevant(ev fsnotify.Event) bool {
+	if ev.Op == fsnotify.Chmod {
+		return false
+	}
+	name := filepath.Base(ev.Name)
+	if name == "commands.jsonl" {
+		return true
+	}
+	if strings.HasPrefix(name, ".") {
+		return false // temp files from AtomicWrite
+	}
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+// waitForWake blocks until resumeAt, a relevant filesystem change, or a
+// shutdown request. It returns false on shutdown. A zero resumeAt waits for
+// a change only. When w is nil and poll is true, it also wakes every
+// wait_poll_interval so the caller can re-evaluate the queue. While
+// waiting, the overview (if any) is redrawn every second on interactive
+// terminals and printed once otherwise, with the predicted end of the queue
+// when there is history to estimate it from.
+func (r *Runner) waitForWake(resumeAt time.Time, w *queueWatcher, ov *overview, poll bool) bool {
+	var deadlineC <-chan time.Time
+	if !resumeAt.IsZero() {
+		deadline := time.NewTimer(time.Until(resumeAt))
+		defer deadline.Stop()
+		deadlineC = deadline.C
+	}
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	var pollC <-chan time.Time
+	if w != nil {
+		events = w.fs.Events
+		errs = w.fs.Errors
+	} else if poll {
+		every := fallbackPollInterval
+		if r.Config != nil {
+			every = durationOr(r.Config.WaitPollInterval, fallbackPollInterval)
+		}
+		t := time.NewTicker(every)
+		defer t.Stop()
+		pollC = t.C
+	}
+
+	var display <-chan time.Time
+	drawn := 0
+	if ov != nil {
+		if ui.Interactive() && !ui.Quiet() {
+			t := time.NewTicker(time.Second)
+			defer t.Stop()
+			display = t.C
+		}
+		if at, ok := r.queueETA(resumeAt); ok {
+			ov.eta = ui.In(at).Format("Jan 02 15:04")
+		}
+		drawn = ov.show(time.Now(), 0)
+	}
+
+	// Waiting is progress too, however long it lasts. Marked twice per
+	// heartbeat so no heartbeat misses it.
+	beat := time.NewTicker(lock.HeartbeatInterval / 2)
+	defer beat.Stop()
+
+	for {
+		select {
+		case <-r.stopCh:
+			return false
+		case <-beat.C:
+			r.progress()
+		case <-deadlineC:
+			return true
+		case <-pollC:
+			return true
+		case <-display:
+			drawn = ov.show(time.Now(), drawn)
+		case err := <-errs:
+			log.Printf("WARN: file watcher: %v", err)
+		case ev := <-events:
+			if !relevant(ev) {
+				continue
+			}
+			// Let the burst settle, then wake.
+			settle := time.NewTimer(wakeDebounce)
+		drain:
+			for {
+				select {
+				case <-events:
+				case <-settle.C:
+					break drain
+				}
+			}
+			return true
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package runs keeps a record of each finished run, so its end-of-run
+// summary can be shown again after the runner has exited.
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+	"github.com/hseinmoussa/claude-autopilot/internal/ui"
+)
+
+// maxRecords bounds the history kept on disk.
+const maxRecords = 100
+
+// idFormat turns a run's start time into its ID.
+const idFormat = "20060102-150405"
+
+// Task is the outcome of one task as of the end of a run.
+type Task struct {
+	ID        string        `json:"id"`
+	Status    string        `json:"status"`
+	Duration  time.Duration `json:"duration,omitempty"` // first start to last end; 0 if it never ran
+	Retries   int           `json:"retries"`
+	Changes   string        `json:"changes,omitempty"`    // diff summary of a done or needs_review task
+	NoChanges bool          `json:"no_changes,omitempty"` // completed without modifying files
+	Failure   string        `json:"failure,omitempty"`    // reason of a failed task's last attempt
+	Artifacts []string      `json:"artifacts,omitempty"`
+}
+
+// Record is the summary of one run.
+type Record struct {
+	ID        string         `json:"id"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+	Tasks     []Task         `json:"tasks"`
+	Totals    events.Summary `json:"totals"`
+}
+
+// ID names the run started at t, e.g. "20260115-220400".
+func ID(t time.Time) string { return t.UTC().Format(idFormat) }
+
+// Load reads the run history at path, oldest first. A missing file yields
+// no records.
+func Load(path string) ([]Record, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read run history: %w", err)
+	}
+	var recs []Record
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("parse run history: %w", err)
+	}
+	return recs, nil
+}
+
+// Append adds rec to the history at path, keeping the most recent
+// maxRecords runs. A record with the ID of one already kept replaces it.
+func Append(path string, rec Record) error {
+	recs, err := Load(path)
+	if err != nil {
+		// A corrupt history only costs old summaries; start afresh.
+		recs = nil
+	}
+	kept := recs[:0]
+	for _, r := range recs {
+		if r.ID != rec.ID {
+			kept = append(kept, r)
+		}
+	}
+	recs = append(kept, rec)
+	if len(recs) > maxRecords {
+		recs = recs[len(recs)-maxRecords:]
+	}
+	data, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal run history: %w", err)
+	}
+	return fileutil.AtomicWrite(path, data, 0644)
+}
+
+// Find returns the run with the given ID from recs; "last" or "" selects
+// the most recent one.
+func Find(recs []Record, id string) (Record, error) {
+	if len(recs) == 0 {
+		return Record{}, fmt.Errorf("no runs recorded yet")
+	}
+	if id == "" || id == "last" {
+		return recs[len(recs)-1], nil
+	}
+	for i := len(recs) - 1; i >= 0; i-- {
+		if recs[i].ID == id {
+			return recs[i], nil
+		}
+	}
+	return Record{}, fmt.Errorf("no run %q (recorded runs: %s ... %s)", id, recs[0].ID, recs[len(recs)-1].ID)
+}
+
+// Markdown renders the record as a Markdown report.
+func (r Record) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Run %s\n\n", r.ID)
+	fmt.Fprintf(&b, "Started %s, ended %s (%s).\n\n",
+		ui.At(r.StartedAt), ui.At(r.EndedAt),
+		r.EndedAt.Sub(r.StartedAt).Truncate(time.Second))
+
+	if len(r.Tasks) > 0 {
+		b.WriteString("| Task | Status | Duration | Retries | Changes |\n")
+		b.WriteString("|------|--------|----------|---------|---------|\n")
+		for _, t := range r.Tasks {
+			duration := "n/a"
+			if t.Duration > 0 {
+				duration = t.Duration.Truncate(time.Second).String()
+			}
+			changes := t.Changes
+			if t.NoChanges {
+				changes = "none (suspicious)"
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %d | %s |\n", t.ID, t.Status, duration, t.Retries, escapeCell(changes))
+		}
+		b.WriteString("\n")
+	}
+
+	var notes []string
+	for _, t := range r.Tasks {
+		if t.Failure != "" {
+			notes = append(notes, fmt.Sprintf("- %s failed: %s", t.ID, t.Failure))
+		}
+		for _, a := range t.Artifacts {
+			notes = append(notes, fmt.Sprintf("- %s artifact: `%s`", t.ID, a))
+		}
+	}
+	if len(notes) > 0 {
+		b.WriteString(strings.Join(notes, "\n") + "\n\n")
+	}
+
+	s := r.Totals
+	fmt.Fprintf(&b, "**Totals:** %d done, %d failed, %d cancelled, %d pending, %d waiting", s.Done, s.Failed, s.Cancelled, s.Pending, s.Waiting)
+	if s.NeedsReview > 0 {
+		fmt.Fprintf(&b, ", %d needs review", s.NeedsReview)
+	}
+	fmt.Fprintf(&b, " of %d task(s).\n", s.Total)
+	return b.String()
+}
+
+// escapeCell keeps s from breaking a Markdown table row.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
@@ -0,0 +1,76 @@
+package runs
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+)
+
+func TestAppendAndFind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs.json")
+	start := time.Date(2026, 1, 15, 22, 4, 0, 0, time.UTC)
+	for i := 0; i < maxRecords+2; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		if err := Append(path, Record{ID: ID(at), StartedAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Re-recording a run replaces it rather than adding another.
+	last := start.Add(time.Duration(maxRecords+1) * time.Hour)
+	if err := Append(path, Record{ID: ID(last), StartedAt: last, Totals: events.Summary{Done: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != maxRecords {
+		t.Fatalf("got %d records; want %d", len(recs), maxRecords)
+	}
+
+	got, err := Find(recs, "last")
+	if err != nil || got.ID != ID(last) || got.Totals.Done != 1 {
+		t.Errorf("Find(last) = %+v, %v", got, err)
+	}
+	want := ID(start.Add(5 * time.Hour))
+	if got, err := Find(recs, want); err != nil || got.ID != want {
+		t.Errorf("Find(%s) = %+v, %v", want, got, err)
+	}
+	if _, err := Find(recs, ID(start)); err == nil {
+		t.Error("a run dropped from the history should not be found")
+	}
+	if _, err := Find(nil, "last"); err == nil {
+		t.Error("Find on an empty history should fail")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	start := time.Date(2026, 1, 15, 22, 4, 0, 0, time.UTC)
+	rec := Record{
+		ID:        ID(start),
+		StartedAt: start,
+		EndedAt:   start.Add(90 * time.Minute),
+		Tasks: []Task{
+			{ID: "fix-auth", Status: "done", Duration: 12 * time.Minute, Changes: "3 files changed | +10 -2"},
+			{ID: "docs", Status: "failed", Retries: 2, Failure: "hang timeout"},
+		},
+		Totals: events.Summary{Done: 1, Failed: 1, Total: 2},
+	}
+
+	md := rec.Markdown()
+	for _, want := range []string{
+		"# Run 20260115-220400",
+		`| fix-auth | done | 12m0s | 0 | 3 files changed \| +10 -2 |`,
+		"| docs | failed | n/a | 2 |  |",
+		"- docs failed: hang timeout",
+		"**Totals:** 1 done, 1 failed, 0 cancelled, 0 pending, 0 waiting of 2 task(s).",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() missing %q:\n%s", want, md)
+		}
+	}
+}
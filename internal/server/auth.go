@@ -0,0 +1,134 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+)
+
+// tokenCookie remembers a token passed as ?token= so a browser's later
+// requests (the dashboard's polling, the websocket) are authorized too.
+const tokenCookie = "autopilot_token"
+
+// Security configures how clients authenticate to the listener.
+type Security struct {
+	// Token must accompany every request, as "Authorization: Bearer", a
+	// ?token= query parameter or the cookie set by the latter. Empty
+	// disables token checks.
+	Token string
+	// CertFile and KeyFile serve HTTPS instead of HTTP.
+	CertFile string
+	KeyFile  string
+	// ClientCAFile requires every client to present a certificate signed
+	// by this CA (mTLS). Such clients are authenticated by the handshake
+	// and need no token.
+	ClientCAFile string
+}
+
+// LoadOrCreateToken returns the token stored at path, generating a random
+// one (readable only by the owner) on first use.
+func LoadOrCreateToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if tok := strings.TrimSpace(string(data)); tok != "" {
+			return tok, nil
+		}
+		return "", fmt.Errorf("token file %s is empty; delete it to generate a new token", path)
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("read token file: %w", err)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	tok := hex.EncodeToString(buf)
+	created, err := fileutil.AtomicCreate(path, []byte(tok+"\n"), 0600)
+	if err != nil {
+		return "", fmt.Errorf("write token file: %w", err)
+	}
+	if !created {
+		// Another process generated one first; use theirs.
+		return LoadOrCreateToken(path)
+	}
+	return tok, nil
+}
+
+// tlsConfig builds the listener's TLS settings, or returns nil for plain
+// HTTP.
+func (sec Security) tlsConfig() (*tls.Config, error) {
+	if sec.CertFile == "" && sec.KeyFile == "" {
+		if sec.ClientCAFile != "" {
+			return nil, errors.New("http_client_ca requires http_tls_cert and http_tls_key")
+		}
+		return nil, nil
+	}
+	if sec.CertFile == "" || sec.KeyFile == "" {
+		return nil, errors.New("http_tls_cert and http_tls_key must be set together")
+	}
+	cert, err := tls.LoadX509KeyPair(sec.CertFile, sec.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if sec.ClientCAFile != "" {
+		pem, err := os.ReadFile(sec.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", sec.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// authorize rejects requests without the token. Clients verified by mTLS
+// are let through.
+func (s *Server) authorize(h http.Handler) http.Handler {
+	if s.sec.Token == "" || s.sec.ClientCAFile != "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tok := r.URL.Query().Get("token"); tok != "" && s.validToken(tok) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    tok,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+			h.ServeHTTP(w, r)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && s.validToken(strings.TrimPrefix(auth, "Bearer ")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if c, err := r.Cookie(tokenCookie); err == nil && s.validToken(c.Value) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="claude-autopilot"`)
+		http.Error(w, "unauthorized: pass the listener token (see http_token)", http.StatusUnauthorized)
+	})
+}
+
+func (s *Server) validToken(tok string) bool {
+	return subtle.ConstantTimeCompare([]byte(tok), []byte(s.sec.Token)) == 1
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+)
+
+func TestAuthorize_Token(t *testing.T) {
+	h := New("127.0.0.1:0", events.NewHub(), Source{}, Security{Token: "s3cret"}).Handler()
+
+	get := func(target string, setup func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if setup != nil {
+			setup(req)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/api/state", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("no token: %d; want 401", rec.Code)
+	}
+	if rec := get("/api/state", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d; want 401", rec.Code)
+	}
+	if rec := get("/api/state", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }); rec.Code != http.StatusOK {
+		t.Errorf("bearer token: %d; want 200", rec.Code)
+	}
+
+	// A token in the URL is remembered in a cookie for the page's own requests.
+	rec := get("/?token=s3cret", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("query token: %d; want 200", rec.Code)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != tokenCookie || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %+v", cookies)
+	}
+	if rec := get("/api/state", func(r *http.Request) { r.AddCookie(cookies[0]) }); rec.Code != http.StatusOK {
+		t.Errorf("cookie: %d; want 200", rec.Code)
+	}
+}
+
+func TestLoadOrCreateToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.token")
+	tok, err := LoadOrCreateToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tok) != 64 {
+		t.Errorf("token %q; want 64 hex chars", tok)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 && runtime.GOOS != "windows" {
+		t.Errorf("token file mode %v; want 0600", perm)
+	}
+	again, err := LoadOrCreateToken(path)
+	if err != nil || again != tok {
+		t.Errorf("second load = %q, %v; want the stored token", again, err)
+	}
+}
+
+// writeCert issues a certificate for template signed by parent (self-signed
+// when parent is nil) and writes it and its key as PEM files in dir.
+func writeCert(t *testing.T, dir, name string, template *x509.Certificate, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return cert, key
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := writeCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test CA"}, NotBefore: time.Now().Add(-time.Minute), NotAfter: notAfter,
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}, nil, nil)
+	writeCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "server"}, NotBefore: time.Now().Add(-time.Minute), NotAfter: notAfter,
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, KeyUsage: x509.KeyUsageDigitalSignature,
+	}, ca, caKey)
+	writeCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "client"}, NotBefore: time.Now().Add(-time.Minute), NotAfter: notAfter,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, KeyUsage: x509.KeyUsageDigitalSignature,
+	}, ca, caKey)
+
+	srv := New("127.0.0.1:0", events.NewHub(), Source{}, Security{
+		Token:        "unused-with-mtls",
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{clientCert}}}}
+	resp, err := withCert.Get(srv.URL() + "api/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("client certificate: %d; want 200", resp.StatusCode)
+	}
+
+	withoutCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if resp, err := withoutCert.Get(srv.URL() + "api/state"); err == nil {
+		resp.Body.Close()
+		t.Errorf("request without a client certificate succeeded: %d", resp.StatusCode)
+	}
+}
+
+func TestSecurity_RejectsIncompleteTLS(t *testing.T) {
+	for _, sec := range []Security{
+		{ClientCAFile: "ca.pem"},
+		{CertFile: "server.crt"},
+	} {
+		if _, err := sec.tlsConfig(); err == nil {
+			t.Errorf("%+v: expected an error", sec)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+//go:embed static
+var static embed.FS
+
+// historyLines is how many summary log lines the dashboard shows.
+const historyLines = 50
+
+// maxHistoryBytes bounds how much of the end of the summary log is read.
+const maxHistoryBytes = 64 * 1024
+
+// staticFiles returns the dashboard's assets rooted at the static directory.
+func staticFiles() fs.FS {
+	sub, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	return sub
+}
+
+// readOnly rejects anything but GET and HEAD; the dashboard never changes
+// the queue.
+func readOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// taskView is one task row on the dashboard.
+type taskView struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	Status     string     `json:"status"`
+	Priority   int        `json:"priority"`
+	Attempt    int        `json:"attempt"`
+	MaxRetries int        `json:"max_retries"`
+	WorkingDir string     `json:"working_dir"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	ResumeAt   *time.Time `json:"resume_at,omitempty"`
+	Changes    string     `json:"changes,omitempty"`
+}
+
+// progressView is the running task's progress, derived from its tool calls.
+type progressView struct {
+	StepsDone   int    `json:"steps_done"`
+	StepsTotal  int    `json:"steps_total"`
+	Percent     int    `json:"percent"` // of planned steps done; -1 without a plan
+	FilesEdited int    `json:"files_edited"`
+	Text        string `json:"text"`
+}
+
+// stateView is the /api/state response.
+type stateView struct {
+	Now        time.Time       `json:"now"`
+	Tasks      []taskView      `json:"tasks"`
+	NextResume *time.Time      `json:"next_resume,omitempty"` // earliest resume_at of waiting tasks
+	Active     *events.Event   `json:"active,omitempty"`      // task_started of the running task
+	Progress   *progressView   `json:"progress,omitempty"`    // of the running task
+	Output     []outputMessage `json:"output"`                // latest output of the running task
+	History    []string        `json:"history"`               // latest summary log lines
+}
+
+// handleState returns a snapshot of the queue, the running task's output
+// tail and recent run history.
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	view, err := s.snapshot()
+	if err != nil {
+		log.Printf("WARN: dashboard state: %v", err)
+		http.Error(w, "could not load queue", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(view)
+}
+
+func (s *Server) snapshot() (stateView, error) {
+	view := stateView{Now: time.Now().UTC(), Tasks: []taskView{}, Output: []outputMessage{}, History: []string{}}
+
+	if s.src.Tasks != nil {
+		tasks, err := s.src.Tasks()
+		if err != nil {
+			return view, err
+		}
+		states, _ := queue.LoadAllStates(s.src.StateDir)
+		for _, t := range tasks {
+			tv := taskView{
+				ID:         t.ID,
+				Title:      t.Title,
+				Status:     queue.StatusPending,
+				Priority:   t.Priority,
+				MaxRetries: t.MaxRetries,
+				WorkingDir: t.WorkingDir,
+			}
+			if st := states[t.ID]; st != nil {
+				tv.Status = st.Status
+				tv.Attempt = st.Attempt
+				tv.StartedAt = st.StartedAt
+				tv.EndedAt = st.EndedAt
+				tv.Changes = st.DiffSummary
+				if st.Status == queue.StatusWaiting && st.ResumeAt != nil {
+					tv.ResumeAt = st.ResumeAt
+					if view.NextResume == nil || st.ResumeAt.Before(*view.NextResume) {
+						view.NextResume = st.ResumeAt
+					}
+				}
+			}
+			view.Tasks = append(view.Tasks, tv)
+		}
+	}
+
+	s.mu.Lock()
+	view.Active = s.active
+	view.Output = append(view.Output, s.tail...)
+	if s.active != nil && s.progress.String() != "" {
+		p := s.progress
+		view.Progress = &progressView{StepsDone: p.StepsDone, StepsTotal: p.StepsTotal, Percent: p.Percent(), FilesEdited: p.FilesEdited, Text: p.String()}
+	}
+	s.mu.Unlock()
+
+	if s.src.SummaryLog != "" {
+		view.History = append(view.History, tailLines(s.src.SummaryLog, historyLines)...)
+	}
+	return view, nil
+}
+
+// tailLines returns up to n last lines of the file at path, or nil if it
+// cannot be read.
+func tailLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil
+	}
+	offset := info.Size() - maxHistoryBytes
+	if offset < 0 {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if offset > 0 && len(lines) > 0 {
+		lines = lines[1:] // probably cut mid-line
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+func TestDashboard_State(t *testing.T) {
+	stateDir := t.TempDir()
+	resumeAt := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	if err := queue.SaveState(stateDir, &queue.TaskState{ID: "b", Status: queue.StatusWaiting, Attempt: 2, ResumeAt: &resumeAt}); err != nil {
+		t.Fatal(err)
+	}
+	summaryLog := filepath.Join(t.TempDir(), "summary.log")
+	if err := os.WriteFile(summaryLog, []byte("[t1] Task a: DONE\n[t2] Run completed: done=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := events.NewHub()
+	srv := New("127.0.0.1:0", hub, Source{
+		Tasks: func() ([]queue.Task, error) {
+			return []queue.Task{{ID: "a", Title: "Task A", MaxRetries: 3}, {ID: "b", Title: "Task B", MaxRetries: 3}}, nil
+		},
+		StateDir:   stateDir,
+		SummaryLog: summaryLog,
+	}, Security{})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	hub.Publish(events.Event{Type: events.TaskStarted, TaskID: "a", Attempt: 1})
+	hub.Publish(events.Event{Type: events.OutputChunk, TaskID: "a", Stream: "stdout", Output: `{"type":"assistant","message":"hello"}`})
+	hub.Publish(events.Event{Type: events.OutputChunk, TaskID: "a", Stream: "stdout", Output: `{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"a.go"}}]}}`})
+
+	var view stateView
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := http.Get("http://" + srv.Addr() + "/api/state")
+		if err != nil {
+			t.Fatal(err)
+		}
+		view = stateView{}
+		err = json.NewDecoder(resp.Body).Decode(&view)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(view.Output) > 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(view.Tasks) != 2 || view.Tasks[0].Status != queue.StatusPending || view.Tasks[1].Status != queue.StatusWaiting {
+		t.Fatalf("tasks = %+v", view.Tasks)
+	}
+	if view.NextResume == nil || !view.NextResume.Equal(resumeAt) {
+		t.Errorf("next_resume = %v; want %v", view.NextResume, resumeAt)
+	}
+	if view.Active == nil || view.Active.TaskID != "a" {
+		t.Errorf("active = %+v", view.Active)
+	}
+	if len(view.Output) != 2 || view.Output[0].Text != "hello" {
+		t.Errorf("output = %+v", view.Output)
+	}
+	if view.Progress == nil || view.Progress.FilesEdited != 1 || view.Progress.Percent != -1 {
+		t.Errorf("progress = %+v; want 1 file edited and no plan", view.Progress)
+	}
+	if len(view.History) != 2 || !strings.Contains(view.History[1], "Run completed") {
+		t.Errorf("history = %q", view.History)
+	}
+}
+
+func TestDashboard_ServesPageReadOnly(t *testing.T) {
+	h := New("127.0.0.1:0", events.NewHub(), Source{}, Security{}).Handler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "<title>claude-autopilot</title>") {
+		t.Errorf("GET /: %d", rec.Code)
+	}
+
+	for _, path := range []string{"/", "/api/state"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("POST %s: %d; want 405", path, rec.Code)
+		}
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.log")
+	var b strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got := tailLines(path, 3)
+	if strings.Join(got, ",") != "line 4997,line 4998,line 4999" {
+		t.Errorf("tailLines = %q", got)
+	}
+	if got := tailLines(filepath.Join(t.TempDir(), "missing"), 3); got != nil {
+		t.Errorf("missing file: %q", got)
+	}
+}
@@ -0,0 +1,320 @@
+// Package server is the runner's optional HTTP listener (config key
+// http_listen). It serves a read-only dashboard and a websocket that streams
+// the running task's parsed output and lifecycle events, for editor
+// extensions and simple web pages.
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+	"github.com/hseinmoussa/claude-autopilot/internal/transcript"
+)
+
+// maxOutputText caps the text of one output message; tool results in
+// particular can be very large.
+const maxOutputText = 4096
+
+// pingInterval keeps idle websocket connections alive through proxies and
+// detects clients that vanished without a close.
+const pingInterval = 30 * time.Second
+
+// outputTailLines is how many output messages of the running task the
+// dashboard shows.
+const outputTailLines = 200
+
+// Source supplies the dashboard's view of the queue.
+type Source struct {
+	Tasks      func() ([]queue.Task, error) // the run's tasks in execution order
+	StateDir   string
+	SummaryLog string // run history, appended by the runner
+}
+
+// Server serves the HTTP endpoints for one run.
+type Server struct {
+	addr string
+	hub  *events.Hub
+	src  Source
+	sec  Security
+
+	ln          net.Listener
+	srv         *http.Server
+	stop        chan struct{}
+	unsubscribe func()
+
+	mu       sync.Mutex
+	active   *events.Event       // task_started of the task currently running
+	tail     []outputMessage     // latest output of the active task
+	progress transcript.Progress // of the active task, from its tool calls
+}
+
+// New returns a Server that will listen on addr, stream events published to
+// hub and show the queue described by src, admitting clients per sec.
+func New(addr string, hub *events.Hub, src Source, sec Security) *Server {
+	return &Server{addr: addr, hub: hub, src: src, sec: sec, stop: make(chan struct{})}
+}
+
+// Start binds the listener and serves in the background.
+func (s *Server) Start() error {
+	tlsCfg, err := s.sec.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("http listener: %w", err)
+	}
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("http listener: %w", err)
+	}
+	if tlsCfg != nil {
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+	s.ln = ln
+	s.srv = &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+
+	updates, unsubscribe := s.hub.Subscribe()
+	s.unsubscribe = unsubscribe
+	go s.trackActive(updates)
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("WARN: http listener: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the listener is bound to.
+func (s *Server) Addr() string {
+	if s.ln == nil {
+		return s.addr
+	}
+	return s.ln.Addr().String()
+}
+
+// URL returns the dashboard's base URL.
+func (s *Server) URL() string {
+	if s.sec.CertFile != "" {
+		return "https://" + s.Addr() + "/"
+	}
+	return "http://" + s.Addr() + "/"
+}
+
+// Close stops the listener and disconnects websocket clients.
+func (s *Server) Close() error {
+	close(s.stop)
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+	}
+	if s.srv == nil {
+		return nil
+	}
+	return s.srv.Close()
+}
+
+// Handler returns the listener's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", s.handleWS)
+	mux.Handle("/api/state", readOnly(http.HandlerFunc(s.handleState)))
+	mux.Handle("/", readOnly(http.FileServer(http.FS(staticFiles()))))
+	return s.authorize(mux)
+}
+
+// trackActive remembers the running task, its latest output and its
+// progress, so clients that connect mid-task learn which task is running
+// and what it last did.
+func (s *Server) trackActive(updates <-chan events.Event) {
+	for ev := range updates {
+		s.mu.Lock()
+		switch ev.Type {
+		case events.TaskStarted:
+			started := ev
+			s.active = &started
+			s.tail = nil
+			s.progress = transcript.Progress{}
+		case events.OutputChunk:
+			s.tail = append(s.tail, parseOutput(ev)...)
+			if ev.Stream == "stdout" {
+				for _, e := range transcript.ParseLine(ev.Output) {
+					s.progress.Add(e)
+				}
+			}
+			if len(s.tail) > outputTailLines {
+				s.tail = append([]outputMessage(nil), s.tail[len(s.tail)-outputTailLines:]...)
+			}
+		case events.TaskDone, events.TaskFailed, events.TaskRetrying, events.RateLimited:
+			if s.active != nil && s.active.TaskID == ev.TaskID {
+				s.active = nil
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) activeTask() *events.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active
+}
+
+// handleWS streams events to a websocket client until it disconnects or the
+// run ends. The optional ?dir= parameter limits the stream to tasks whose
+// working directory is that directory or below it.
+func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
+	filter := newDirFilter(r.URL.Query().Get("dir"))
+
+	// Subscribe before the handshake so nothing published after the
+	// client sees the 101 response is missed.
+	evs, unsubscribe := s.hub.Subscribe()
+	defer unsubscribe()
+	active := s.activeTask()
+
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		conn.readLoop()
+		close(closed)
+	}()
+
+	send := func(ev events.Event) error {
+		if !filter.allow(ev) {
+			return nil
+		}
+		for _, msg := range messages(ev) {
+			if err := conn.writeText(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if active != nil {
+		if send(*active) != nil {
+			return
+		}
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.stop:
+			conn.writeFrame(opClose, []byte{0x03, 0xE9}) // 1001: going away
+			return
+		case <-ping.C:
+			if conn.writeFrame(opPing, nil) != nil {
+				return
+			}
+		case ev, ok := <-evs:
+			if !ok || send(ev) != nil {
+				return
+			}
+		}
+	}
+}
+
+// outputMessage is one parsed piece of CLI output sent to websocket clients.
+type outputMessage struct {
+	Type    string    `json:"type"` // always "output"
+	Time    time.Time `json:"time"`
+	TaskID  string    `json:"task_id"`
+	Attempt int       `json:"attempt"`
+	Kind    string    `json:"kind"` // text, tool_use, tool_result, result, raw or stderr
+	Text    string    `json:"text"`
+	IsError bool      `json:"is_error,omitempty"`
+}
+
+// messages renders ev for websocket clients: output chunks as parsed
+// output messages, other events in their event-stream form.
+func messages(ev events.Event) [][]byte {
+	if ev.Type != events.OutputChunk {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return nil
+		}
+		return [][]byte{data}
+	}
+	var out [][]byte
+	for _, m := range parseOutput(ev) {
+		if data, err := json.Marshal(m); err == nil {
+			out = append(out, data)
+		}
+	}
+	return out
+}
+
+// parseOutput splits an output chunk into assistant prose, tool calls and
+// results; session metadata and blank lines are dropped.
+func parseOutput(ev events.Event) []outputMessage {
+	base := outputMessage{Type: "output", Time: ev.Time, TaskID: ev.TaskID, Attempt: ev.Attempt}
+	var out []outputMessage
+	add := func(kind, text string, isError bool) {
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		if len(text) > maxOutputText {
+			text = text[:maxOutputText] + "..."
+		}
+		m := base
+		m.Kind, m.Text, m.IsError = kind, text, isError
+		out = append(out, m)
+	}
+
+	if ev.Stream == "stderr" {
+		add("stderr", ev.Output, false)
+		return out
+	}
+	for _, e := range transcript.ParseLine(ev.Output) {
+		switch e.Kind {
+		case transcript.System:
+		case transcript.ToolUse:
+			add(string(e.Kind), transcript.ToolSummary(e), false)
+		default:
+			add(string(e.Kind), e.Text, e.IsError)
+		}
+	}
+	return out
+}
+
+// dirFilter passes only events for tasks under one directory. Tasks are
+// matched by the working_dir of their task_started event; events that are
+// not about a task (run_summary) always pass.
+type dirFilter struct {
+	dir   string
+	tasks map[string]bool
+}
+
+func newDirFilter(dir string) *dirFilter {
+	if dir != "" {
+		dir = filepath.Clean(dir)
+	}
+	return &dirFilter{dir: dir, tasks: make(map[string]bool)}
+}
+
+func (f *dirFilter) allow(ev events.Event) bool {
+	if f.dir == "" || ev.TaskID == "" {
+		return true
+	}
+	if ev.Type == events.TaskStarted {
+		rel, err := filepath.Rel(f.dir, filepath.Clean(ev.WorkingDir))
+		f.tasks[ev.TaskID] = err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	}
+	return f.tasks[ev.TaskID]
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/events"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Sample handshake from RFC 6455 section 1.3.
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %q", got)
+	}
+}
+
+// dialWS performs a websocket handshake against addr and returns the
+// connection for reading frames.
+func dialWS(t *testing.T, addr, path string) *wsConn {
+	t.Helper()
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return &wsConn{conn: conn, rw: bufio.NewReadWriter(br, bufio.NewWriter(conn))}
+}
+
+func readMessage(t *testing.T, c *wsConn) map[string]interface{} {
+	t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	op, payload, err := c.readFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op != opText {
+		t.Fatalf("opcode = %d; want text", op)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(payload, &m); err != nil {
+		t.Fatalf("payload %q: %v", payload, err)
+	}
+	return m
+}
+
+func TestWebsocket_StreamsParsedOutput(t *testing.T) {
+	hub := events.NewHub()
+	srv := New("127.0.0.1:0", hub, Source{}, Security{})
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	all := dialWS(t, srv.Addr(), "/ws")
+	mine := dialWS(t, srv.Addr(), "/ws?dir=/work/api")
+
+	hub.Publish(events.Event{Type: events.TaskStarted, TaskID: "other", WorkingDir: "/work/web"})
+	hub.Publish(events.Event{Type: events.OutputChunk, TaskID: "other", Stream: "stdout", Output: `{"type":"assistant","message":"elsewhere"}`})
+	hub.Publish(events.Event{Type: events.TaskStarted, TaskID: "api", WorkingDir: "/work/api/svc"})
+	hub.Publish(events.Event{Type: events.OutputChunk, TaskID: "api", Stream: "stdout", Output: `{"type":"system","session_id":"s"}`})
+	hub.Publish(events.Event{Type: events.OutputChunk, TaskID: "api", Stream: "stdout",
+		Output: `{"type":"assistant","message":{"content":[{"type":"text","text":"Editing"},{"type":"tool_use","name":"Edit","input":{"file_path":"main.go"}}]}}`})
+
+	if m := readMessage(t, all); m["type"] != "task_started" || m["task_id"] != "other" {
+		t.Errorf("unfiltered first message = %v", m)
+	}
+	if m := readMessage(t, all); m["kind"] != "text" || m["text"] != "elsewhere" {
+		t.Errorf("unfiltered second message = %v", m)
+	}
+
+	if m := readMessage(t, mine); m["type"] != "task_started" || m["task_id"] != "api" {
+		t.Fatalf("filtered first message = %v", m)
+	}
+	if m := readMessage(t, mine); m["type"] != "output" || m["kind"] != "text" || m["text"] != "Editing" {
+		t.Errorf("text message = %v", m)
+	}
+	if m := readMessage(t, mine); m["kind"] != "tool_use" || m["text"] != "Edit(file_path=main.go)" {
+		t.Errorf("tool message = %v", m)
+	}
+
+	// A client connecting mid-task is told which task is running.
+	late := dialWS(t, srv.Addr(), "/ws")
+	if m := readMessage(t, late); m["type"] != "task_started" || m["task_id"] != "api" {
+		t.Errorf("late client first message = %v", m)
+	}
+}
+
+func TestWebsocket_RejectsCrossOrigin(t *testing.T) {
+	srv := New("127.0.0.1:0", events.NewHub(), Source{}, Security{})
+	for _, tc := range []struct {
+		origin string
+		want   int
+	}{
+		{"https://evil.example", http.StatusForbidden},
+		{"vscode-webview://abc123", http.StatusSwitchingProtocols},
+		{"http://localhost:8787", http.StatusSwitchingProtocols},
+	} {
+		ts := httptest.NewServer(srv.Handler())
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/ws", nil)
+		req.Host = "localhost:8787"
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		req.Header.Set("Origin", tc.origin)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("origin %s: status %d; want %d", tc.origin, resp.StatusCode, tc.want)
+		}
+		ts.Close()
+	}
+	close(srv.stop)
+}
+
+func TestUpgrade_RequiresWebsocketHeaders(t *testing.T) {
+	srv := New("127.0.0.1:0", events.NewHub(), Source{}, Security{})
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws", nil))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "websocket upgrade required") {
+		t.Errorf("plain GET: %d %q", rec.Code, rec.Body.String())
+	}
+}
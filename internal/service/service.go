@@ -0,0 +1,263 @@
+// Package service installs claude-autopilot as a per-user background
+// service: a systemd user unit on Linux or a launchd agent on macOS. The
+// service runs "run --watch --yes" and is restarted if it exits abnormally.
+package service
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+)
+
+const (
+	// unitPrefix starts every systemd user unit file name.
+	unitPrefix = "claude-autopilot"
+	// labelPrefix starts every launchd agent label.
+	labelPrefix = "com.claude-autopilot.runner"
+)
+
+// unitName is the systemd user unit file name for queue ("" = the default
+// queue), so each named queue gets a service of its own.
+func unitName(queue string) string {
+	if queue == "" {
+		return unitPrefix + ".service"
+	}
+	return unitPrefix + "-" + queue + ".service"
+}
+
+// launchdLabel identifies the launchd agent for queue.
+func launchdLabel(queue string) string {
+	if queue == "" {
+		return labelPrefix
+	}
+	return labelPrefix + "." + queue
+}
+
+// restartDelaySeconds is how long the service manager waits before
+// restarting a runner that exited abnormally.
+const restartDelaySeconds = 30
+
+// Spec describes the service to install.
+type Spec struct {
+	Executable string            // absolute path to the claude-autopilot binary
+	Args       []string          // arguments, e.g. ["run", "--watch", "--yes"]
+	Env        map[string]string // environment for the runner (PATH, HOME, ...)
+	LogPath    string            // file receiving stdout and stderr
+	WorkingDir string
+	Queue      string // named queue the service runs; "" for the default queue
+}
+
+// SystemdUnit renders a systemd user unit for spec.
+func SystemdUnit(spec Spec) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	if spec.Queue != "" {
+		fmt.Fprintf(&b, "Description=claude-autopilot task runner (queue %s)\n", spec.Queue)
+	} else {
+		b.WriteString("Description=claude-autopilot task runner\n")
+	}
+	b.WriteString("After=network-online.target\n\n")
+
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdQuoteCommand(spec.Executable, spec.Args))
+	if spec.WorkingDir != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", spec.WorkingDir)
+	}
+	for _, k := range sortedKeys(spec.Env) {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(k+"="+spec.Env[k]))
+	}
+	b.WriteString("Restart=on-failure\n")
+	fmt.Fprintf(&b, "RestartSec=%d\n", restartDelaySeconds)
+	if spec.LogPath != "" {
+		fmt.Fprintf(&b, "StandardOutput=append:%s\n", spec.LogPath)
+		fmt.Fprintf(&b, "StandardError=append:%s\n", spec.LogPath)
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=default.target\n")
+	return b.String()
+}
+
+// LaunchdPlist renders a launchd agent property list for spec.
+func LaunchdPlist(spec Spec) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	plistKey(&b, "Label", launchdLabel(spec.Queue))
+
+	b.WriteString("  <key>ProgramArguments</key>\n  <array>\n")
+	for _, a := range append([]string{spec.Executable}, spec.Args...) {
+		fmt.Fprintf(&b, "    <string>%s</string>\n", html.EscapeString(a))
+	}
+	b.WriteString("  </array>\n")
+
+	if len(spec.Env) > 0 {
+		b.WriteString("  <key>EnvironmentVariables</key>\n  <dict>\n")
+		for _, k := range sortedKeys(spec.Env) {
+			fmt.Fprintf(&b, "    <key>%s</key>\n    <string>%s</string>\n", html.EscapeString(k), html.EscapeString(spec.Env[k]))
+		}
+		b.WriteString("  </dict>\n")
+	}
+	if spec.WorkingDir != "" {
+		plistKey(&b, "WorkingDirectory", spec.WorkingDir)
+	}
+	if spec.LogPath != "" {
+		plistKey(&b, "StandardOutPath", spec.LogPath)
+		plistKey(&b, "StandardErrorPath", spec.LogPath)
+	}
+	b.WriteString("  <key>RunAtLoad</key>\n  <true/>\n")
+	// Restart only after abnormal exits; a clean stop stays stopped.
+	b.WriteString("  <key>KeepAlive</key>\n  <dict>\n    <key>SuccessfulExit</key>\n    <false/>\n  </dict>\n")
+	fmt.Fprintf(&b, "  <key>ThrottleInterval</key>\n  <integer>%d</integer>\n", restartDelaySeconds)
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// Path returns where the service definition of queue is installed for the
+// current platform.
+func Path(queue string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+	switch runtime.GOOS {
+	case "linux":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "systemd", "user", unitName(queue)), nil
+	case "darwin":
+		return filepath.Join(home, "Library", "LaunchAgents", launchdLabel(queue)+".plist"), nil
+	default:
+		return "", fmt.Errorf("service management is not supported on %s", runtime.GOOS)
+	}
+}
+
+// Install writes the service definition and starts the service.
+func Install(spec Spec) (string, error) {
+	path, err := Path(spec.Queue)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+
+	var content string
+	if runtime.GOOS == "darwin" {
+		content = LaunchdPlist(spec)
+	} else {
+		content = SystemdUnit(spec)
+	}
+	if err := fileutil.AtomicWrite(path, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("write %s: %w", path, err)
+	}
+
+	if runtime.GOOS == "darwin" {
+		// Reinstalling replaces a loaded agent; ignore "not loaded".
+		_ = run("launchctl", "bootout", launchdTarget(spec.Queue))
+		return path, run("launchctl", "bootstrap", launchdDomain(), path)
+	}
+	if err := run("systemctl", "--user", "daemon-reload"); err != nil {
+		return path, err
+	}
+	return path, run("systemctl", "--user", "enable", "--now", unitName(spec.Queue))
+}
+
+// Uninstall stops the service of queue and removes its definition.
+func Uninstall(queue string) (string, error) {
+	path, err := Path(queue)
+	if err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "darwin" {
+		_ = run("launchctl", "bootout", launchdTarget(queue))
+	} else {
+		_ = run("systemctl", "--user", "disable", "--now", unitName(queue))
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return path, fmt.Errorf("remove %s: %w", path, err)
+	}
+	if runtime.GOOS != "darwin" {
+		_ = run("systemctl", "--user", "daemon-reload")
+	}
+	return path, nil
+}
+
+// Status returns the service manager's view of the service of queue.
+func Status(queue string) (string, error) {
+	path, err := Path(queue)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "not installed", nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("launchctl", "print", launchdTarget(queue))
+	} else {
+		cmd = exec.Command("systemctl", "--user", "status", "--no-pager", unitName(queue))
+	}
+	// systemctl status exits non-zero for inactive units; the output is
+	// still the useful part.
+	out, _ := cmd.CombinedOutput()
+	return fmt.Sprintf("installed at %s\n\n%s", path, strings.TrimRight(string(out), "\n")), nil
+}
+
+// run executes a service manager command, including its output in errors.
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w (%s)", name, strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func launchdDomain() string { return fmt.Sprintf("gui/%d", os.Getuid()) }
+
+func launchdTarget(queue string) string { return launchdDomain() + "/" + launchdLabel(queue) }
+
+func plistKey(b *strings.Builder, key, value string) {
+	fmt.Fprintf(b, "  <key>%s</key>\n  <string>%s</string>\n", key, html.EscapeString(value))
+}
+
+// systemdQuoteCommand renders an ExecStart command line.
+func systemdQuoteCommand(exe string, args []string) string {
+	parts := []string{systemdQuote(exe)}
+	for _, a := range args {
+		parts = append(parts, systemdQuote(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+// systemdQuote double-quotes s if it contains characters systemd would
+// split on or interpret.
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func testSpec() Spec {
+	return Spec{
+		Executable: "/usr/local/bin/claude-autopilot",
+		Args:       []string{"run", "--watch", "--yes"},
+		Env:        map[string]string{"PATH": "/usr/bin:/opt/my tools", "HOME": "/home/me"},
+		LogPath:    "/home/me/.claude-autopilot/logs/service.log",
+	}
+}
+
+func TestSystemdUnit(t *testing.T) {
+	unit := SystemdUnit(testSpec())
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/claude-autopilot run --watch --yes\n",
+		"Environment=HOME=/home/me\n",
+		`Environment="PATH=/usr/bin:/opt/my tools"` + "\n",
+		"Restart=on-failure\n",
+		"StandardOutput=append:/home/me/.claude-autopilot/logs/service.log\n",
+		"WantedBy=default.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	spec := testSpec()
+	spec.Env["X"] = "a&b"
+	plist := LaunchdPlist(spec)
+	for _, want := range []string{
+		"<string>" + launchdLabel("") + "</string>",
+		"<string>--watch</string>",
+		"<key>SuccessfulExit</key>\n    <false/>",
+		"<string>a&amp;b</string>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist missing %q:\n%s", want, plist)
+		}
+	}
+}
+
+func TestNamesPerQueue(t *testing.T) {
+	if got := unitName(""); got != "claude-autopilot.service" {
+		t.Errorf("default unit = %s", got)
+	}
+	if got := unitName("nightly"); got != "claude-autopilot-nightly.service" {
+		t.Errorf("queue unit = %s", got)
+	}
+	if got := launchdLabel("nightly"); got != "com.claude-autopilot.runner.nightly" {
+		t.Errorf("queue label = %s", got)
+	}
+
+	spec := testSpec()
+	spec.Queue = "nightly"
+	if plist := LaunchdPlist(spec); !strings.Contains(plist, "<string>com.claude-autopilot.runner.nightly</string>") {
+		t.Errorf("plist label is not the queue's:\n%s", plist)
+	}
+	if unit := SystemdUnit(spec); !strings.Contains(unit, "Description=claude-autopilot task runner (queue nightly)\n") {
+		t.Errorf("unit description does not name the queue:\n%s", unit)
+	}
+}
+
+func TestSystemdQuote(t *testing.T) {
+	tests := map[string]string{
+		"plain":      "plain",
+		"with space": `"with space"`,
+		"cost$5":     `"cost$$5"`,
+		"":           `""`,
+	}
+	for in, want := range tests {
+		if got := systemdQuote(in); got != want {
+			t.Errorf("systemdQuote(%q) = %s; want %s", in, got, want)
+		}
+	}
+}
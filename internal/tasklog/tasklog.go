@@ -0,0 +1,159 @@
+// Package tasklog writes and reads the per-task logs in logs/<id>.log. A
+// log holds every attempt of its task, either as the raw output lines
+// (log_format: text) or as one JSON record per line (log_format: ndjson).
+package tasklog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log formats, the values of the log_format setting.
+const (
+	FormatText   = "text"
+	FormatNDJSON = "ndjson"
+)
+
+// Streams a record can come from. Autopilot records are the runner's own
+// notes, such as the start of an attempt or an auto-answered prompt.
+const (
+	Stdout    = "stdout"
+	Stderr    = "stderr"
+	Autopilot = "autopilot"
+)
+
+// Record is one line of an NDJSON task log.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Stream  string    `json:"stream"`
+	Attempt int       `json:"attempt"`
+	Line    string    `json:"line"`
+}
+
+// Writer appends one attempt's output to a task log. It is safe for
+// concurrent use, so stdout and stderr can be logged as they arrive. A nil
+// *Writer discards everything, for a log that could not be opened.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	ndjson  bool
+	attempt int
+}
+
+// NewWriter returns a Writer for attempt that writes to w in format.
+// Unknown formats fall back to text.
+func NewWriter(w io.Writer, format string, attempt int) *Writer {
+	return &Writer{w: w, ndjson: format == FormatNDJSON, attempt: attempt}
+}
+
+// Start marks the beginning of the attempt.
+func (l *Writer) Start(taskID string) {
+	if l == nil {
+		return
+	}
+	now := time.Now().UTC()
+	if l.ndjson {
+		l.write(Record{Time: now, Stream: Autopilot, Attempt: l.attempt, Line: fmt.Sprintf("attempt=%d task=%s", l.attempt, taskID)})
+		return
+	}
+	l.text(fmt.Sprintf("\n[%s] attempt=%d task=%s", now.Format(time.RFC3339), l.attempt, taskID))
+}
+
+// Line logs one line of output from stream. Text logs keep stdout only.
+func (l *Writer) Line(stream, line string) {
+	if l == nil {
+		return
+	}
+	if l.ndjson {
+		l.write(Record{Time: time.Now().UTC(), Stream: stream, Attempt: l.attempt, Line: line})
+		return
+	}
+	if stream == Stdout {
+		l.text(line)
+	}
+}
+
+// Note logs a message from the runner itself.
+func (l *Writer) Note(msg string) {
+	if l == nil {
+		return
+	}
+	if l.ndjson {
+		l.write(Record{Time: time.Now().UTC(), Stream: Autopilot, Attempt: l.attempt, Line: msg})
+		return
+	}
+	l.text("[autopilot] " + msg)
+}
+
+func (l *Writer) text(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, line)
+}
+
+func (l *Writer) write(rec Record) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(data, '\n'))
+}
+
+// attemptHeader matches the line that starts an attempt in a text log.
+var attemptHeader = regexp.MustCompile(`^\[[^\]]+\] attempt=(\d+) task=\S+$`)
+
+// Copy writes the log read from r to w as text, keeping only attempt's
+// lines unless attempt is 0. NDJSON records are printed as their line,
+// stderr ones prefixed with "stderr: " and runner notes with
+// "[autopilot] "; a log whose format changed part way reads back whole.
+func Copy(w io.Writer, r io.Reader, attempt int) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	current := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rec, ok := parseRecord(line); ok {
+			current = rec.Attempt
+			switch rec.Stream {
+			case Stderr:
+				line = "stderr: " + rec.Line
+			case Autopilot:
+				line = "[autopilot] " + rec.Line
+			default:
+				line = rec.Line
+			}
+		} else if m := attemptHeader.FindStringSubmatch(line); m != nil {
+			current, _ = strconv.Atoi(m[1])
+		}
+		if attempt != 0 && current != attempt {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// parseRecord reads line as an NDJSON log record. Output lines of a text
+// log may be JSON too (stream-json), so a record needs a stream and an
+// attempt.
+func parseRecord(line string) (Record, bool) {
+	if !strings.HasPrefix(line, `{"time"`) {
+		return Record{}, false
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Stream == "" || rec.Attempt == 0 {
+		return Record{}, false
+	}
+	return rec, true
+}
@@ -0,0 +1,79 @@
+package tasklog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriter_NDJSONRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, FormatNDJSON, 2)
+	w.Start("fix-auth")
+	w.Line(Stdout, `{"type":"result"}`)
+	w.Line(Stderr, "warning: slow")
+	w.Note("verify failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines; want 4:\n%s", len(lines), buf.String())
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(lines[2]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Stream != Stderr || rec.Attempt != 2 || rec.Line != "warning: slow" || rec.Time.IsZero() {
+		t.Errorf("stderr record = %+v", rec)
+	}
+}
+
+func TestWriter_TextKeepsStdoutOnly(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, FormatText, 1)
+	w.Start("fix-auth")
+	w.Line(Stdout, "hello")
+	w.Line(Stderr, "noise")
+	w.Note("auto-answered prompt")
+
+	got := buf.String()
+	if strings.Contains(got, "noise") {
+		t.Errorf("text log should not keep stderr:\n%s", got)
+	}
+	if !strings.Contains(got, "attempt=1 task=fix-auth\nhello\n[autopilot] auto-answered prompt\n") {
+		t.Errorf("text log =\n%s", got)
+	}
+
+	var nilWriter *Writer
+	nilWriter.Line(Stdout, "dropped") // must not panic
+}
+
+func TestCopy_SlicesAttempts(t *testing.T) {
+	// A text log whose format was switched to ndjson after attempt 2.
+	var buf bytes.Buffer
+	for attempt := 1; attempt <= 2; attempt++ {
+		w := NewWriter(&buf, FormatText, attempt)
+		w.Start("t")
+		w.Line(Stdout, `{"type":"assistant"}`)
+	}
+	w := NewWriter(&buf, FormatNDJSON, 3)
+	w.Start("t")
+	w.Line(Stdout, "third")
+	w.Line(Stderr, "oops")
+
+	var out bytes.Buffer
+	if err := Copy(&out, bytes.NewReader(buf.Bytes()), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "attempt=2 task=t\n{\"type\":\"assistant\"}\n") || strings.Contains(got, "attempt=1") || strings.Contains(got, "third") {
+		t.Errorf("attempt 2 =\n%s", got)
+	}
+
+	out.Reset()
+	if err := Copy(&out, bytes.NewReader(buf.Bytes()), 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "[autopilot] attempt=3 task=t\nthird\nstderr: oops\n" {
+		t.Errorf("attempt 3 = %q", got)
+	}
+}
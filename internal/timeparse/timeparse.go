@@ -16,6 +16,12 @@ import (
 //   - "reset at Oct 7, 1am" (date + time)
 //   - "3pm (America/Santiago)" (with explicit timezone)
 //   - "14:30"               (24hr)
+//   - "2025-10-07T18:30:00Z" (ISO-8601; local or given zone if no offset)
+//   - "1759861800"          (unix epoch, seconds or milliseconds)
+//   - "in 2 hours", "in 1h 30m", "in 45 minutes" (relative to now)
+//
+// ISO-8601 and epoch values are absolute and, like an explicit date, are an
+// error when in the past. Relative phrasing is always counted from now.
 //
 // Priority chain:
 //  1. If a TZ name in parentheses is present, use that timezone.
@@ -34,6 +40,16 @@ func ParseResetTime(s string) (time.Time, error) {
 		loc = time.Now().Location()
 	}
 
+	if t, ok := extractAbsolute(s, loc); ok {
+		if t.Before(time.Now()) {
+			return t, fmt.Errorf("reset time %s is in the past", t.Format(time.RFC3339))
+		}
+		return t, nil
+	}
+	if d, ok := extractRelative(s); ok {
+		return time.Now().Add(d).In(loc), nil
+	}
+
 	dateMonth, dateDay, hasDate := extractDate(s)
 	hour, minute, err := extractTime(s)
 	if err != nil {
@@ -88,6 +104,82 @@ func extractTimezone(s string) (*time.Location, string) {
 	return loc, strings.TrimSpace(cleaned)
 }
 
+// reISO matches an ISO-8601 date and time: "2025-10-07T18:30:00Z",
+// "2025-10-07 18:30", "2025-10-07T18:30:00.000+02:00".
+var reISO = regexp.MustCompile(`\b(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2})(:\d{2}(?:\.\d+)?)?\s*(Z|[+-]\d{2}:?\d{2})?`)
+
+// reEpoch matches a unix timestamp in seconds (10 digits) or milliseconds
+// (13 digits), as found in API error bodies and rate-limit headers.
+var reEpoch = regexp.MustCompile(`\b(\d{10}|\d{13})\b`)
+
+// extractAbsolute looks for an ISO-8601 timestamp or a unix epoch value.
+// An ISO time without an offset is read in loc.
+func extractAbsolute(s string, loc *time.Location) (time.Time, bool) {
+	if m := reISO.FindStringSubmatch(s); m != nil {
+		secs := m[3]
+		if secs == "" {
+			secs = ":00"
+		}
+		zone := m[4]
+		if len(zone) == 5 { // +0200
+			zone = zone[:3] + ":" + zone[3:]
+		}
+		value := m[1] + "T" + m[2] + secs
+		var t time.Time
+		var err error
+		if zone != "" {
+			t, err = time.Parse(time.RFC3339Nano, value+zone)
+		} else {
+			t, err = time.ParseInLocation("2006-01-02T15:04:05.999999999", value, loc)
+		}
+		if err == nil {
+			return t.In(loc), true
+		}
+	}
+	if m := reEpoch.FindStringSubmatch(s); m != nil {
+		n, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if len(m[1]) == 13 {
+			return time.UnixMilli(n).In(loc), true
+		}
+		return time.Unix(n, 0).In(loc), true
+	}
+	return time.Time{}, false
+}
+
+// reRelative matches relative phrasing: "in 2 hours", "in 45 minutes",
+// "in an hour", "in 1h 30m", "in 1 hour and 5 minutes".
+var reRelative = regexp.MustCompile(`(?i)\bin\s+((?:(?:\d+|an?)\s*(?:hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)\b(?:\s*,?\s*(?:and\s+)?)?)+)`)
+
+// reRelativePart matches one amount and unit within a relative phrase.
+var reRelativePart = regexp.MustCompile(`(?i)\b(\d+|an?)\s*(h|m|s)`)
+
+// extractRelative parses a relative reset time into a duration from now.
+func extractRelative(s string) (time.Duration, bool) {
+	m := reRelative.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+	var total time.Duration
+	for _, part := range reRelativePart.FindAllStringSubmatch(m[1], -1) {
+		n := 1
+		if v, err := strconv.Atoi(part[1]); err == nil {
+			n = v
+		}
+		switch strings.ToLower(part[2]) {
+		case "h":
+			total += time.Duration(n) * time.Hour
+		case "m":
+			total += time.Duration(n) * time.Minute
+		case "s":
+			total += time.Duration(n) * time.Second
+		}
+	}
+	return total, true
+}
+
 // monthNames maps abbreviated and full month names to time.Month.
 var monthNames = map[string]time.Month{
 	"jan": time.January, "january": time.January,
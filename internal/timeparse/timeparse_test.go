@@ -1,6 +1,7 @@
 package timeparse
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -175,3 +176,80 @@ func TestParseResetTime_MonthBoundaryFormatAccepted(t *testing.T) {
 		t.Fatalf("unexpected error for month-boundary format: %v", err)
 	}
 }
+
+func TestParseResetTime_ISO8601(t *testing.T) {
+	want := time.Now().Add(3 * time.Hour).UTC().Truncate(time.Second)
+	for _, input := range []string{
+		want.Format(time.RFC3339),
+		want.Format("2006-01-02T15:04:05.000Z"),
+		want.In(time.FixedZone("", 2*3600)).Format("2006-01-02T15:04:05-0700"),
+		"resets_at: " + want.Format(time.RFC3339),
+	} {
+		result, err := ParseResetTime(input)
+		if err != nil {
+			t.Errorf("ParseResetTime(%q) error: %v", input, err)
+			continue
+		}
+		if !result.Equal(want) {
+			t.Errorf("ParseResetTime(%q) = %s; want %s", input, result, want)
+		}
+	}
+}
+
+func TestParseResetTime_ISO8601NoOffsetUsesTimezone(t *testing.T) {
+	loc, _ := time.LoadLocation("America/Santiago")
+	future := time.Now().In(loc).Add(48 * time.Hour)
+	result, err := ParseResetTime(future.Format("2006-01-02 15:04") + " (America/Santiago)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Hour() != future.Hour() || result.Minute() != future.Minute() || result.Location().String() != loc.String() {
+		t.Errorf("result = %s; want %s in America/Santiago", result, future.Format("15:04"))
+	}
+}
+
+func TestParseResetTime_Epoch(t *testing.T) {
+	want := time.Now().Add(time.Hour).Truncate(time.Second)
+	for _, input := range []string{
+		strconv.FormatInt(want.Unix(), 10),
+		strconv.FormatInt(want.UnixMilli(), 10),
+	} {
+		result, err := ParseResetTime(input)
+		if err != nil {
+			t.Fatalf("ParseResetTime(%q) error: %v", input, err)
+		}
+		if !result.Equal(want) {
+			t.Errorf("ParseResetTime(%q) = %s; want %s", input, result, want)
+		}
+	}
+}
+
+func TestParseResetTime_AbsoluteInPastReturnsError(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	for _, input := range []string{past.Format(time.RFC3339), strconv.FormatInt(past.Unix(), 10)} {
+		if _, err := ParseResetTime(input); err == nil || !strings.Contains(err.Error(), "in the past") {
+			t.Errorf("ParseResetTime(%q) error = %v; want in the past", input, err)
+		}
+	}
+}
+
+func TestParseResetTime_Relative(t *testing.T) {
+	for input, want := range map[string]time.Duration{
+		"in 2 hours":              2 * time.Hour,
+		"in 45 minutes":           45 * time.Minute,
+		"in an hour":              time.Hour,
+		"in 1h 30m":               90 * time.Minute,
+		"in 1 hour and 5 minutes": 65 * time.Minute,
+		"in 30 seconds":           30 * time.Second,
+	} {
+		before := time.Now()
+		result, err := ParseResetTime(input)
+		if err != nil {
+			t.Errorf("ParseResetTime(%q) error: %v", input, err)
+			continue
+		}
+		if got := result.Sub(before); got < want || got > want+time.Second {
+			t.Errorf("ParseResetTime(%q) = now+%s; want now+%s", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package transcript
+
+import "fmt"
+
+// editTools are the tools whose calls modify a file, mapped to the input key
+// naming that file.
+var editTools = map[string]string{
+	"Edit":         "file_path",
+	"MultiEdit":    "file_path",
+	"Write":        "file_path",
+	"NotebookEdit": "notebook_path",
+}
+
+// EditedFile returns the file a tool call modifies, or "" when e is not a
+// call to an editing tool.
+func EditedFile(e Entry) string {
+	if e.Kind != ToolUse {
+		return ""
+	}
+	key, ok := editTools[e.Tool]
+	if !ok {
+		return ""
+	}
+	path, _ := e.Input[key].(string)
+	return path
+}
+
+// Progress is a rough measure of how far a session has got: the steps of
+// its latest TodoWrite list, when it keeps one, and the distinct files it
+// has edited.
+type Progress struct {
+	StepsDone   int `json:"steps_done,omitempty"`
+	StepsTotal  int `json:"steps_total,omitempty"`
+	FilesEdited int `json:"files_edited,omitempty"`
+
+	files map[string]bool
+}
+
+// Add updates p from one transcript entry and reports whether it changed.
+func (p *Progress) Add(e Entry) bool {
+	if e.Kind != ToolUse {
+		return false
+	}
+	if path := EditedFile(e); path != "" {
+		if p.files[path] {
+			return false
+		}
+		if p.files == nil {
+			p.files = make(map[string]bool)
+		}
+		p.files[path] = true
+		p.FilesEdited++
+		return true
+	}
+	if e.Tool != "TodoWrite" {
+		return false
+	}
+	items, _ := e.Input["todos"].([]interface{})
+	done, total := 0, 0
+	for _, it := range items {
+		m, _ := it.(map[string]interface{})
+		if content, _ := m["content"].(string); content == "" {
+			continue
+		}
+		total++
+		if status, _ := m["status"].(string); status == "completed" {
+			done++
+		}
+	}
+	if total == 0 || (done == p.StepsDone && total == p.StepsTotal) {
+		return false
+	}
+	p.StepsDone, p.StepsTotal = done, total
+	return true
+}
+
+// Percent is the share of planned steps done, or -1 without a plan.
+func (p Progress) Percent() int {
+	if p.StepsTotal == 0 {
+		return -1
+	}
+	return p.StepsDone * 100 / p.StepsTotal
+}
+
+// String describes the progress, e.g. "7/10 planned steps done (70%), 4
+// file(s) edited". It is empty when nothing was seen yet.
+func (p Progress) String() string {
+	s := ""
+	if p.StepsTotal > 0 {
+		s = fmt.Sprintf("%d/%d planned steps done (%d%%)", p.StepsDone, p.StepsTotal, p.Percent())
+	}
+	if p.FilesEdited > 0 {
+		if s != "" {
+			s += ", "
+		}
+		s += fmt.Sprintf("%d file(s) edited", p.FilesEdited)
+	}
+	return s
+}
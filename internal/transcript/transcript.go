@@ -0,0 +1,201 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind classifies a transcript entry.
+type Kind string
+
+const (
+	// Text is assistant prose.
+	Text Kind = "text"
+	// ToolUse is an assistant tool invocation.
+	ToolUse Kind = "tool_use"
+	// ToolResult is the output of a tool invocation.
+	ToolResult Kind = "tool_result"
+	// Result is the final message of a session.
+	Result Kind = "result"
+	// System is session metadata (e.g. the init message carrying session_id).
+	System Kind = "system"
+	// Raw is a non-JSON output line, as produced by CLIs without stream-json.
+	Raw Kind = "raw"
+)
+
+// Entry is one meaningful item extracted from a stream-json line. A single
+// assistant line may carry several content blocks and so several entries.
+type Entry struct {
+	Kind    Kind
+	Text    string                 // prose, tool result content, or raw line
+	Tool    string                 // tool name for ToolUse
+	Input   map[string]interface{} // tool input for ToolUse
+	IsError bool                   // tool_result / result flagged as error
+}
+
+// message mirrors the subset of the Claude Code stream-json schema we read.
+// "message" is either an object with content blocks or, in simplified
+// streams, a plain string.
+type message struct {
+	Type    string          `json:"type"`
+	Subtype string          `json:"subtype"`
+	Message json.RawMessage `json:"message"`
+	Result  string          `json:"result"`
+	IsError bool            `json:"is_error"`
+}
+
+type contentBlock struct {
+	Type    string                 `json:"type"`
+	Text    string                 `json:"text"`
+	Name    string                 `json:"name"`
+	Input   map[string]interface{} `json:"input"`
+	Content json.RawMessage        `json:"content"`
+	IsError bool                   `json:"is_error"`
+}
+
+// ParseLine extracts entries from a single output line. Blank lines yield
+// nothing; lines that are not JSON objects yield a single Raw entry.
+func ParseLine(line string) []Entry {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	if !strings.HasPrefix(line, "{") {
+		return []Entry{{Kind: Raw, Text: line}}
+	}
+
+	var msg message
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return []Entry{{Kind: Raw, Text: line}}
+	}
+
+	switch msg.Type {
+	case "system":
+		return []Entry{{Kind: System, Text: msg.Subtype}}
+	case "result":
+		return []Entry{{Kind: Result, Text: msg.Result, IsError: msg.IsError}}
+	case "assistant", "user":
+		return parseContent(msg.Message)
+	default:
+		return nil
+	}
+}
+
+// parseContent decodes the "message" field of assistant/user lines.
+func parseContent(raw json.RawMessage) []Entry {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var plain string
+	if err := json.Unmarshal(raw, &plain); err == nil {
+		if strings.TrimSpace(plain) == "" {
+			return nil
+		}
+		return []Entry{{Kind: Text, Text: plain}}
+	}
+
+	var body struct {
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil
+	}
+
+	// content may itself be a bare string.
+	if err := json.Unmarshal(body.Content, &plain); err == nil {
+		if strings.TrimSpace(plain) == "" {
+			return nil
+		}
+		return []Entry{{Kind: Text, Text: plain}}
+	}
+
+	var blocks []contentBlock
+	if err := json.Unmarshal(body.Content, &blocks); err != nil {
+		return nil
+	}
+
+	var entries []Entry
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if strings.TrimSpace(b.Text) != "" {
+				entries = append(entries, Entry{Kind: Text, Text: b.Text})
+			}
+		case "tool_use":
+			entries = append(entries, Entry{Kind: ToolUse, Tool: b.Name, Input: b.Input})
+		case "tool_result":
+			entries = append(entries, Entry{Kind: ToolResult, Text: flattenContent(b.Content), IsError: b.IsError})
+		}
+	}
+	return entries
+}
+
+// flattenContent converts tool_result content (a string or a list of text
+// blocks) into plain text.
+func flattenContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var blocks []contentBlock
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Parse extracts entries from every line in order.
+func Parse(lines []string) []Entry {
+	var entries []Entry
+	for _, l := range lines {
+		entries = append(entries, ParseLine(l)...)
+	}
+	return entries
+}
+
+// ToolSummary renders a tool call compactly, e.g. `Edit(file_path=main.go)`.
+// Only short scalar inputs that identify the target are shown.
+func ToolSummary(e Entry) string {
+	var args []string
+	for _, key := range []string{"file_path", "path", "command", "pattern", "url"} {
+		v, ok := e.Input[key]
+		if !ok {
+			continue
+		}
+		s := fmt.Sprint(v)
+		if len(s) > 80 {
+			s = s[:80] + "..."
+		}
+		args = append(args, key+"="+s)
+	}
+	sort.Strings(args)
+	return e.Tool + "(" + strings.Join(args, ", ") + ")"
+}
+
+// LastText returns the final assistant prose in entries, preferring the
+// session's result message when present.
+func LastText(entries []Entry) string {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == Result && strings.TrimSpace(entries[i].Text) != "" {
+			return entries[i].Text
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == Text {
+			return entries[i].Text
+		}
+	}
+	return ""
+}
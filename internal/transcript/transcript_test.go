@@ -0,0 +1,86 @@
+package transcript
+
+import "testing"
+
+func TestParseLine_AssistantBlocks(t *testing.T) {
+	line := `{"type":"assistant","message":{"content":[` +
+		`{"type":"text","text":"Fixing the login handler."},` +
+		`{"type":"tool_use","name":"Edit","input":{"file_path":"auth.go","old_string":"x"}}]}}`
+
+	entries := ParseLine(line)
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries; want 2: %+v", len(entries), entries)
+	}
+	if entries[0].Kind != Text || entries[0].Text != "Fixing the login handler." {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].Kind != ToolUse || ToolSummary(entries[1]) != "Edit(file_path=auth.go)" {
+		t.Errorf("entry 1 summary = %q", ToolSummary(entries[1]))
+	}
+}
+
+func TestParseLine_ToolResultBlocks(t *testing.T) {
+	line := `{"type":"user","message":{"content":[{"type":"tool_result","is_error":true,` +
+		`"content":[{"type":"text","text":"FAIL TestLogin"}]}]}}`
+
+	entries := ParseLine(line)
+	if len(entries) != 1 || entries[0].Kind != ToolResult || !entries[0].IsError {
+		t.Fatalf("entries = %+v", entries)
+	}
+	if entries[0].Text != "FAIL TestLogin" {
+		t.Errorf("text = %q", entries[0].Text)
+	}
+}
+
+func TestParseLine_PlainStringMessageAndRaw(t *testing.T) {
+	if e := ParseLine(`{"type":"assistant","message":"working"}`); len(e) != 1 || e[0].Text != "working" {
+		t.Errorf("string message = %+v", e)
+	}
+	if e := ParseLine("not json at all"); len(e) != 1 || e[0].Kind != Raw {
+		t.Errorf("raw line = %+v", e)
+	}
+	if e := ParseLine("   "); e != nil {
+		t.Errorf("blank line = %+v; want nil", e)
+	}
+}
+
+func TestLastText_PrefersResult(t *testing.T) {
+	entries := Parse([]string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"halfway"}]}}`,
+		`{"type":"result","subtype":"success","result":"All done."}`,
+	})
+	if got := LastText(entries); got != "All done." {
+		t.Errorf("LastText = %q", got)
+	}
+	if got := LastText(entries[:1]); got != "halfway" {
+		t.Errorf("LastText without result = %q", got)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var p Progress
+	if p.String() != "" || p.Percent() != -1 {
+		t.Fatalf("empty progress = %q, %d", p, p.Percent())
+	}
+
+	lines := []string{
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"TodoWrite","input":{"todos":[` +
+			`{"content":"Read code","status":"completed"},{"content":"Fix bug","status":"in_progress"},` +
+			`{"content":"Add test","status":"pending"},{"content":"Run tests","status":"pending"}]}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Edit","input":{"file_path":"a.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Write","input":{"file_path":"a.go"}}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","name":"Bash","input":{"command":"go test"}}]}}`,
+	}
+	changes := 0
+	for _, e := range Parse(lines) {
+		if p.Add(e) {
+			changes++
+		}
+	}
+	if changes != 2 {
+		t.Errorf("got %d changes; want 2 (the todo list and the first edit of a.go)", changes)
+	}
+	if got := p.String(); got != "1/4 planned steps done (25%), 1 file(s) edited" {
+		t.Errorf("String() = %q", got)
+	}
+}
@@ -0,0 +1,162 @@
+// Package ui adapts human-facing output to where it is going. Interactive
+// flourishes (the live countdown, emoji, color) are only used when stdout is
+// a terminal, and the global --quiet and --no-color flags turn off
+// informational messages and color respectively. Times are shown in local
+// time, or UTC with --utc. Errors and warnings are logged separately and
+// are never suppressed.
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/queue"
+)
+
+var (
+	out         io.Writer = os.Stdout
+	interactive           = IsTerminal(os.Stdout)
+	quiet       bool
+	color       = interactive && os.Getenv("NO_COLOR") == ""
+	utc         bool
+)
+
+// Configure applies the global output flags. Color is also disabled when
+// stdout is not a terminal or the NO_COLOR environment variable is set.
+func Configure(quietFlag, noColor bool) {
+	quiet = quietFlag
+	color = interactive && !noColor && os.Getenv("NO_COLOR") == ""
+}
+
+// UseStderr sends human-facing output to stderr, keeping stdout free for a
+// machine-readable stream. Interactive flourishes follow stderr's terminal.
+func UseStderr() {
+	out = os.Stderr
+	interactive = IsTerminal(os.Stderr)
+	color = color && interactive
+}
+
+// SetUTC shows times in UTC instead of local time.
+func SetUTC(on bool) { utc = on }
+
+// In returns t in the display time zone: local, or UTC after SetUTC.
+func In(t time.Time) time.Time {
+	if utc {
+		return t.UTC()
+	}
+	return t.Local()
+}
+
+// At formats t as a date and time in the display time zone.
+func At(t time.Time) string {
+	return In(t).Format("2006-01-02 15:04:05 MST")
+}
+
+// Resume formats a resume time with how far off it is, e.g.
+// "2026-01-16 03:15:00 CET (in 2h 14m)".
+func Resume(t time.Time) string {
+	return At(t) + " (" + Relative(time.Until(t)) + ")"
+}
+
+// Relative phrases a wait of d: "in 2h 14m", "in 5m 3s", "in 40s", or
+// "now" once it has passed.
+func Relative(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d <= 0:
+		return "now"
+	case d >= time.Hour:
+		return fmt.Sprintf("in %dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("in %dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("in %ds", int(d.Seconds()))
+}
+
+// Writer returns where human-facing output goes: stdout, or stderr after
+// UseStderr.
+func Writer() io.Writer { return out }
+
+// IsTerminal reports whether f is an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Interactive reports whether stdout is a terminal, so in-place updates
+// such as the countdown can be used.
+func Interactive() bool { return interactive }
+
+// Quiet reports whether informational output is suppressed.
+func Quiet() bool { return quiet }
+
+// Printf prints informational output unless --quiet is set.
+func Printf(format string, args ...interface{}) {
+	if !quiet {
+		fmt.Fprintf(out, format, args...)
+	}
+}
+
+// Println prints informational output unless --quiet is set.
+func Println(args ...interface{}) {
+	if !quiet {
+		fmt.Fprintln(out, args...)
+	}
+}
+
+// Infof prints a notice, prefixed with "ℹ" on a terminal, unless --quiet is
+// set. A trailing newline is added.
+func Infof(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	prefix := "info: "
+	if interactive {
+		prefix = "ℹ "
+	}
+	fmt.Fprintf(out, prefix+format+"\n", args...)
+}
+
+// ANSI color codes.
+const (
+	reset  = "\033[0m"
+	red    = "\033[31m"
+	green  = "\033[32m"
+	yellow = "\033[33m"
+	cyan   = "\033[36m"
+	dim    = "\033[2m"
+)
+
+// Status pads a task status to width and colors it by outcome when color
+// is enabled. Padding is applied before coloring so table columns align.
+func Status(status string, width int) string {
+	padded := status
+	if n := width - len(status); n > 0 {
+		padded += strings.Repeat(" ", n)
+	}
+	if !color {
+		return padded
+	}
+	code := ""
+	switch status {
+	case queue.StatusDone:
+		code = green
+	case queue.StatusFailed:
+		code = red
+	case queue.StatusWaiting, queue.StatusNeedsReview:
+		code = yellow
+	case queue.StatusRunning:
+		code = cyan
+	case queue.StatusCancelled:
+		code = dim
+	default:
+		return padded
+	}
+	return code + padded + reset
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStatus_PadsBeforeColoring(t *testing.T) {
+	color = true
+	defer func() { color = false }()
+
+	got := Status("done", 8)
+	want := green + "done    " + reset
+	if got != want {
+		t.Errorf("Status = %q; want %q", got, want)
+	}
+
+	color = false
+	if got := Status("failed", 8); got != "failed  " {
+		t.Errorf("uncolored Status = %q", got)
+	}
+}
+
+func TestRelative(t *testing.T) {
+	for _, tt := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{2*time.Hour + 14*time.Minute + 20*time.Second, "in 2h 14m"},
+		{5*time.Minute + 3*time.Second, "in 5m 3s"},
+		{40 * time.Second, "in 40s"},
+		{-time.Minute, "now"},
+	} {
+		if got := Relative(tt.d); got != tt.want {
+			t.Errorf("Relative(%v) = %q; want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestAt_UTC(t *testing.T) {
+	SetUTC(true)
+	defer SetUTC(false)
+	at := time.Date(2026, 1, 16, 3, 15, 0, 0, time.FixedZone("CET", 3600))
+	if got := At(at); got != "2026-01-16 02:15:00 UTC" {
+		t.Errorf("At with --utc = %q", got)
+	}
+}
+
+func TestQuietSuppressesInfo(t *testing.T) {
+	var buf bytes.Buffer
+	out = &buf
+	defer func() { quiet = false }()
+
+	quiet = true
+	Infof("hello %d", 1)
+	Printf("x")
+	if buf.Len() != 0 {
+		t.Errorf("quiet output = %q", buf.String())
+	}
+
+	quiet = false
+	interactive = false
+	Infof("hello %d", 1)
+	if buf.String() != "info: hello 1\n" {
+		t.Errorf("non-interactive Infof = %q", buf.String())
+	}
+}
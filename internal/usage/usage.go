@@ -0,0 +1,99 @@
+// Package usage records observed rate limits and predicts when the current
+// Claude usage window resets, so task starts can be paced around it.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hseinmoussa/claude-autopilot/internal/fileutil"
+)
+
+// DefaultWindow is the length of a Claude usage window.
+const DefaultWindow = 5 * time.Hour
+
+// maxEvents bounds the history kept on disk.
+const maxEvents = 200
+
+// maxAnchorAge is how long an observed reset time is trusted for predicting
+// later windows. Windows only line up while usage is continuous, so old
+// observations say little about the current window.
+const maxAnchorAge = 24 * time.Hour
+
+// Event is one observed rate limit.
+type Event struct {
+	At      time.Time  `json:"at"`
+	ResetAt *time.Time `json:"reset_at,omitempty"` // reset time reported by the CLI, if any
+	TaskID  string     `json:"task_id,omitempty"`
+}
+
+// Window is a predicted usage window.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Load reads the event history at path, oldest first. A missing file yields
+// no events.
+func Load(path string) ([]Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read rate limit history: %w", err)
+	}
+	var events []Event
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("parse rate limit history: %w", err)
+	}
+	return events, nil
+}
+
+// Record appends ev to the history at path, keeping the most recent
+// maxEvents entries.
+func Record(path string, ev Event) error {
+	events, err := Load(path)
+	if err != nil {
+		// A corrupt history is not worth failing a task over; start afresh.
+		events = nil
+	}
+	events = append(events, ev)
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	data, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal rate limit history: %w", err)
+	}
+	return fileutil.AtomicWrite(path, data, 0644)
+}
+
+// Predict estimates the usage window containing now. The most recent
+// reported reset time is a window boundary, and while usage is continuous
+// later boundaries follow every window after it. It returns false when no
+// reset time was observed within maxAnchorAge.
+func Predict(events []Event, window time.Duration, now time.Time) (Window, bool) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	var anchor *time.Time
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ResetAt != nil {
+			anchor = events[i].ResetAt
+			break
+		}
+	}
+	if anchor == nil || now.Sub(*anchor) > maxAnchorAge {
+		return Window{}, false
+	}
+
+	end := *anchor
+	if !now.Before(end) {
+		end = end.Add(window * (now.Sub(end)/window + 1))
+	}
+	return Window{Start: end.Add(-window), End: end}, true
+}
@@ -0,0 +1,59 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad_KeepsMostRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimits.json")
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < maxEvents+5; i++ {
+		if err := Record(path, Event{At: base.Add(time.Duration(i) * time.Minute), TaskID: "t"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != maxEvents {
+		t.Fatalf("got %d events; want %d", len(events), maxEvents)
+	}
+	if !events[0].At.Equal(base.Add(5 * time.Minute)) {
+		t.Errorf("oldest kept event at %v; want the first 5 dropped", events[0].At)
+	}
+}
+
+func TestPredict(t *testing.T) {
+	reset := time.Date(2026, 1, 1, 15, 0, 0, 0, time.UTC)
+	events := []Event{
+		{At: reset.Add(-2 * time.Hour), ResetAt: &reset},
+		{At: reset.Add(-time.Hour)}, // no reset time; ignored as anchor
+	}
+
+	cases := []struct {
+		name string
+		now  time.Time
+		end  time.Time
+	}{
+		{"before reset", reset.Add(-time.Hour), reset},
+		{"at reset", reset, reset.Add(DefaultWindow)},
+		{"two windows later", reset.Add(6 * time.Hour), reset.Add(2 * DefaultWindow)},
+	}
+	for _, tc := range cases {
+		w, ok := Predict(events, DefaultWindow, tc.now)
+		if !ok || !w.End.Equal(tc.end) || !w.Start.Equal(tc.end.Add(-DefaultWindow)) {
+			t.Errorf("%s: Predict = %+v, %v; want end %v", tc.name, w, ok, tc.end)
+		}
+	}
+
+	if _, ok := Predict(events, DefaultWindow, reset.Add(maxAnchorAge+time.Hour)); ok {
+		t.Error("stale anchor should not predict")
+	}
+	if _, ok := Predict(events[1:], DefaultWindow, reset); ok {
+		t.Error("events without reset times should not predict")
+	}
+}
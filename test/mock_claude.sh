@@ -24,6 +24,12 @@ case "${mode}" in
     printf '{"type":"assistant","message":"working"}\n'
     printf '{"type":"result"}\n'
     ;;
+  edit)
+    echo "mock change" >> mock-change.txt
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    printf '{"type":"assistant","message":"edited"}\n'
+    printf '{"type":"result"}\n'
+    ;;
   fail)
     printf '{"type":"system","session_id":"mock-session"}\n'
     printf '{"type":"assistant","message":"failed"}\n'
@@ -47,6 +53,34 @@ case "${mode}" in
     printf '{"type":"assistant","message":"resumed"}\n'
     printf '{"type":"result"}\n'
     ;;
+  rate_limit_hang)
+    trap 'exit 143' TERM INT
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    >&2 echo "rate limit exceeded. reset at $(next_minute)."
+    while true; do
+      sleep 1
+    done
+    ;;
+  permission_prompt)
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    printf 'Do you want to proceed? (y/n) '
+    answer=""
+    read -r -t 20 answer || true
+    if [[ "${answer}" != "y" ]]; then
+      >&2 echo "permission denied"
+      exit 1
+    fi
+    printf '{"type":"result"}\n'
+    ;;
+  require_tty)
+    if [[ ! -t 1 ]]; then
+      >&2 echo "stdout is not a terminal"
+      exit 1
+    fi
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    printf '\033[32m{"type":"assistant","message":"on a tty"}\033[0m\n'
+    printf '{"type":"result"}\n'
+    ;;
   long_running)
     trap 'exit 143' TERM INT
     printf '{"type":"system","session_id":"mock-session"}\n'
@@ -55,6 +89,20 @@ case "${mode}" in
       sleep 2
     done
     ;;
+  plan)
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    printf '%s\n' '{"type":"result","result":"```json\n[{\"id\":\"schema\",\"prompt\":\"Add the table\"},{\"id\":\"api\",\"prompt\":\"Add the endpoint\",\"depends_on\":[\"schema\"]}]\n```"}'
+    ;;
+  expensive)
+    trap 'exit 143' TERM INT
+    printf '{"type":"system","session_id":"mock-session"}\n'
+    n=0
+    while true; do
+      n=$((n + 1))
+      printf '{"type":"assistant","message":{"id":"msg-%d","content":[{"type":"text","text":"spending"}],"usage":{"input_tokens":400,"output_tokens":100}}}\n' "${n}"
+      sleep 1
+    done
+    ;;
   *)
     >&2 echo "unknown MOCK_CLAUDE_MODE=${mode}"
     exit 2
@@ -21,7 +21,10 @@ export PATH="${mock_bin_dir}:${PATH}"
 export MOCK_CLAUDE_MODE="rate_limit_once"
 export MOCK_CLAUDE_STATE_DIR="${tmp_root}/mock-state"
 
-"${BIN}" add "Global smoke task" --dir "${workdir}" --id global-smoke --priority 1 >/dev/null
+"${BIN}" add "Global smoke task" --dir "${workdir}" --id global-smoke --priority 1 \
+  --max-retries 4 --flag=--verbose --export-summary >/dev/null
+grep -q -- '- --verbose' "${HOME}/.claude-autopilot/tasks/global-smoke.yaml"
+grep -q 'max_retries: 4' "${HOME}/.claude-autopilot/tasks/global-smoke.yaml"
 
 cat > "${workdir}/.autopilot/tasks/project-smoke.yaml" <<YAML
 id: project-smoke
@@ -41,6 +44,11 @@ state_dir="${HOME}/.claude-autopilot/state"
 grep -q '"status": "done"' "${state_dir}/global-smoke.state.json"
 grep -q '"status": "done"' "${state_dir}/project-smoke.state.json"
 grep -q '"attempt": 2' "${state_dir}/global-smoke.state.json"
+grep -q '"phase": "stopped"' "${HOME}/.claude-autopilot/health.json"
+
+show_out="$("${BIN}" show global-smoke --project-dir "${workdir}")"
+printf '%s\n' "${show_out}" | grep -q "rate_limited"
+printf '%s\n' "${show_out}" | grep -q "completed"
 
 status_out="$("${BIN}" status --project-dir "${workdir}")"
 printf '%s\n' "${status_out}" | grep -q "Done:      2"
@@ -48,4 +56,214 @@ printf '%s\n' "${status_out}" | grep -q "Done:      2"
 clean_out="$("${BIN}" clean --project-dir "${workdir}")"
 printf '%s\n' "${clean_out}" | grep -q "Cleaned artifacts:"
 
+# Invalid config must stop run with the dedicated exit code (4).
+bad_home="${tmp_root}/bad-config"
+mkdir -p "${bad_home}"
+printf 'hang_timeout: soon\n' > "${bad_home}/config.yaml"
+set +e
+CLAUDE_AUTOPILOT_HOME="${bad_home}" "${BIN}" run --yes >/dev/null 2>&1
+rc=$?
+set -e
+test "${rc}" -eq 4
+
+# --max-wait shorter than the rate-limit reset exits with code 5 instead of sleeping.
+wait_home="${tmp_root}/max-wait"
+mkdir -p "${wait_home}"
+CLAUDE_AUTOPILOT_HOME="${wait_home}" "${BIN}" add "Max wait task" --dir "${workdir}" --id max-wait-smoke >/dev/null
+set +e
+CLAUDE_AUTOPILOT_HOME="${wait_home}" MOCK_CLAUDE_STATE_DIR="${tmp_root}/mock-state-wait" \
+  "${BIN}" run --yes --max-wait 1s >/dev/null 2>&1
+rc=$?
+set -e
+test "${rc}" -eq 5
+grep -q '"status": "waiting"' "${wait_home}/state/max-wait-smoke.state.json"
+
+# A rate limit reported while the CLI keeps running is acted on immediately.
+hang_home="${tmp_root}/rate-limit-hang"
+mkdir -p "${hang_home}"
+CLAUDE_AUTOPILOT_HOME="${hang_home}" "${BIN}" add "Streamed limit task" --dir "${workdir}" --id streamed-limit-smoke >/dev/null
+set +e
+CLAUDE_AUTOPILOT_HOME="${hang_home}" MOCK_CLAUDE_MODE="rate_limit_hang" \
+  timeout 60 "${BIN}" run --yes --max-wait 1s >/dev/null 2>&1
+rc=$?
+set -e
+test "${rc}" -eq 5
+grep -q '"result": "rate_limited"' "${hang_home}/state/streamed-limit-smoke.state.json"
+
+# prompt_action=answer types whitelisted replies into a PTY and logs them for audit.
+answer_home="${tmp_root}/prompt-answer"
+mkdir -p "${answer_home}/tasks"
+cat > "${answer_home}/matchers.yaml" <<'YAML'
+prompt_answers:
+  - name: proceed
+    pattern: "Do you want to proceed?"
+    response: "y\n"
+YAML
+cat > "${answer_home}/tasks/prompt-answer-smoke.yaml" <<YAML
+id: prompt-answer-smoke
+working_dir: ${workdir}
+prompt: "Answer the prompt"
+auto_approve: [proceed]
+YAML
+CLAUDE_AUTOPILOT_HOME="${answer_home}" MOCK_CLAUDE_MODE="permission_prompt" \
+  CLAUDE_AUTOPILOT_PROMPT_ACTION=answer CLAUDE_AUTOPILOT_HANG_CHECK_INTERVAL=1s \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>"${tmp_root}/prompt-answer.log"
+grep -q '"status": "done"' "${answer_home}/state/prompt-answer-smoke.state.json"
+grep -q 'AUDIT: task prompt-answer-smoke auto-answered prompt "proceed"' "${tmp_root}/prompt-answer.log"
+
+# use_pty runs the CLI on a terminal while still capturing its output.
+pty_home="${tmp_root}/use-pty"
+mkdir -p "${pty_home}"
+CLAUDE_AUTOPILOT_HOME="${pty_home}" "${BIN}" add "PTY task" --dir "${workdir}" --id use-pty-smoke >/dev/null
+CLAUDE_AUTOPILOT_HOME="${pty_home}" MOCK_CLAUDE_MODE="require_tty" CLAUDE_AUTOPILOT_USE_PTY=true \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1
+grep -q '"status": "done"' "${pty_home}/state/use-pty-smoke.state.json"
+grep -q '"session_id": "mock-session"' "${pty_home}/state/use-pty-smoke.state.json"
+
+# fail_on_no_changes fails a task that "completes" without touching its git working tree.
+nochange_home="${tmp_root}/no-changes"
+nochange_repo="${tmp_root}/no-changes-repo"
+mkdir -p "${nochange_repo}"
+git -C "${nochange_repo}" init -q
+git -C "${nochange_repo}" -c user.name=smoke -c user.email=smoke@example.com commit -q --allow-empty -m init
+mkdir -p "${nochange_home}/tasks"
+cat > "${nochange_home}/tasks/no-changes-smoke.yaml" <<YAML
+id: no-changes-smoke
+working_dir: ${nochange_repo}
+prompt: "Change nothing"
+max_retries: 1
+YAML
+CLAUDE_AUTOPILOT_HOME="${nochange_home}" MOCK_CLAUDE_MODE="success" CLAUDE_AUTOPILOT_FAIL_ON_NO_CHANGES=true \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1 || true
+grep -q '"status": "failed"' "${nochange_home}/state/no-changes-smoke.state.json"
+grep -q '"no_changes": true' "${nochange_home}/state/no-changes-smoke.state.json"
+
+# max_tokens stops a task once its sessions use that many tokens, without a retry.
+budget_home="${tmp_root}/budget"
+mkdir -p "${budget_home}/tasks"
+cat > "${budget_home}/tasks/budget-smoke.yaml" <<YAML
+id: budget-smoke
+working_dir: ${workdir}
+prompt: "Spend tokens"
+max_retries: 3
+max_tokens: 1200
+YAML
+CLAUDE_AUTOPILOT_HOME="${budget_home}" MOCK_CLAUDE_MODE="expensive" \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1 || true
+grep -q '"status": "failed"' "${budget_home}/state/budget-smoke.state.json"
+grep -q '"result": "budget_exceeded"' "${budget_home}/state/budget-smoke.state.json"
+grep -q '"attempt": 1,' "${budget_home}/state/budget-smoke.state.json"
+
+# export_summary keeps the final message for {{task:<id>.summary}}; the consumer waits for it.
+summary_home="${tmp_root}/summary"
+mkdir -p "${summary_home}/tasks"
+cat > "${summary_home}/tasks/summary.yaml" <<YAML
+id: summary-consumer
+priority: 1
+working_dir: ${workdir}
+prompt: "Act on: {{task:summary-producer.summary}}"
+---
+id: summary-producer
+priority: 2
+working_dir: ${workdir}
+prompt: "Analyze"
+export_summary: true
+YAML
+CLAUDE_AUTOPILOT_HOME="${summary_home}" MOCK_CLAUDE_MODE="success" \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1
+grep -q '"summary": "working"' "${summary_home}/state/summary-producer.state.json"
+grep -q '"status": "done"' "${summary_home}/state/summary-consumer.state.json"
+
+# A pipeline runs its steps in order; a failing verify command fails its step and holds the next.
+pipeline_home="${tmp_root}/pipeline"
+mkdir -p "${pipeline_home}/tasks"
+cat > "${pipeline_home}/pipeline.yaml" <<YAML
+name: pipe
+defaults:
+  working_dir: ${workdir}
+  max_retries: 1
+steps:
+  - id: analyze
+    prompt: "Analyze"
+  - id: implement
+    prompt: "Implement"
+    verify: "echo '1 check failed'; exit 1"
+  - id: tests
+    prompt: "Write tests"
+YAML
+CLAUDE_AUTOPILOT_HOME="${pipeline_home}" MOCK_CLAUDE_MODE="success" \
+  timeout 60 "${BIN}" run --yes >"${tmp_root}/pipeline.log" 2>&1 || true
+grep -q '"summary": "working"' "${pipeline_home}/state/pipe-analyze.state.json"
+grep -q '"status": "failed"' "${pipeline_home}/state/pipe-implement.state.json"
+grep -q 'verify command exited with status 1: 1 check failed' "${pipeline_home}/state/pipe-implement.state.json"
+grep -q 'Task pipe-tests is blocked: dependency pipe-implement is failed' "${tmp_root}/pipeline.log"
+
+# A plan task queues the subtasks from its reply as a pipeline, which then runs.
+plan_home="${tmp_root}/plan"
+mkdir -p "${plan_home}/tasks"
+cat > "${plan_home}/tasks/plan-smoke.yaml" <<YAML
+id: plan-smoke
+working_dir: ${workdir}
+prompt: "Build the user service"
+plan: true
+YAML
+CLAUDE_AUTOPILOT_HOME="${plan_home}" MOCK_CLAUDE_MODE="plan" \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1
+grep -q '"status": "done"' "${plan_home}/state/plan-smoke.state.json"
+grep -q 'depends_on: \[\]' "${plan_home}/tasks/plan-smoke.pipeline.yaml"
+grep -q '"status": "done"' "${plan_home}/state/plan-smoke-api.state.json"
+
+# A review task works in a worktree and changes working_dir only once approved.
+review_home="${tmp_root}/review"
+review_repo="${tmp_root}/review-repo"
+mkdir -p "${review_repo}" "${review_home}/tasks"
+git -C "${review_repo}" init -q
+git -C "${review_repo}" -c user.name=smoke -c user.email=smoke@example.com commit -q --allow-empty -m init
+cat > "${review_home}/tasks/review-smoke.yaml" <<YAML
+id: review-smoke
+working_dir: ${review_repo}
+prompt: "Edit a file"
+review: true
+YAML
+CLAUDE_AUTOPILOT_HOME="${review_home}" MOCK_CLAUDE_MODE="edit" \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1
+grep -q '"status": "needs_review"' "${review_home}/state/review-smoke.state.json"
+test ! -e "${review_repo}/mock-change.txt"
+CLAUDE_AUTOPILOT_HOME="${review_home}" "${BIN}" review review-smoke | grep -q '+mock change'
+CLAUDE_AUTOPILOT_HOME="${review_home}" "${BIN}" approve review-smoke >/dev/null
+grep -q '"status": "done"' "${review_home}/state/review-smoke.state.json"
+grep -q 'mock change' "${review_repo}/mock-change.txt"
+test ! -e "${review_home}/worktrees/review-smoke"
+
+# archive_done moves a finished task out of the queue at the end of the run.
+archive_home="${tmp_root}/archive"
+mkdir -p "${archive_home}/tasks"
+cat > "${archive_home}/tasks/archive-smoke.yaml" <<YAML
+id: archive-smoke
+working_dir: ${workdir}
+prompt: "Archive me"
+archive_done: true
+YAML
+CLAUDE_AUTOPILOT_HOME="${archive_home}" MOCK_CLAUDE_MODE="success" \
+  timeout 60 "${BIN}" run --yes >/dev/null 2>&1
+test ! -e "${archive_home}/tasks/archive-smoke.yaml"
+test ! -e "${archive_home}/state/archive-smoke.state.json"
+grep -q '"status": "done"' "${archive_home}"/archive/*/archive-smoke.state.json
+test -e "${archive_home}"/archive/*/archive-smoke.yaml
+
+# --events writes only NDJSON lifecycle events to stdout.
+events_home="${tmp_root}/events"
+mkdir -p "${events_home}"
+CLAUDE_AUTOPILOT_HOME="${events_home}" "${BIN}" add "Events task" --dir "${workdir}" --id events-smoke >/dev/null
+CLAUDE_AUTOPILOT_HOME="${events_home}" MOCK_CLAUDE_MODE="success" \
+  timeout 60 "${BIN}" run --yes --events >"${tmp_root}/events.ndjson" 2>/dev/null
+grep -q '"type":"task_started","time":"[^"]*","task_id":"events-smoke"' "${tmp_root}/events.ndjson"
+grep -q '"type":"output_chunk"' "${tmp_root}/events.ndjson"
+grep -q '"type":"task_done"' "${tmp_root}/events.ndjson"
+grep -q '"type":"run_summary".*"done":1' "${tmp_root}/events.ndjson"
+if grep -qv '^{' "${tmp_root}/events.ndjson"; then
+  echo "--events stdout contains non-event output" >&2
+  exit 1
+fi
+
 echo "Smoke test passed"